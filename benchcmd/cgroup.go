@@ -0,0 +1,157 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// A cgroupConfig confines each benchmark iteration to a transient
+// cgroup with CPU and memory limits.
+type cgroupConfig struct {
+	// how is "systemd" to create cgroups with systemd-run, or
+	// the path of a cgroupfs directory to create them under.
+	how string
+
+	cpus   float64 // CPU quota in CPUs, or 0 for no limit
+	memory int64   // Memory limit in bytes, or 0 for no limit
+}
+
+// printConfig prints the limits in c as benchfmt configuration lines.
+func (c *cgroupConfig) printConfig() {
+	fmt.Printf("cgroup: %s\n", c.how)
+	if c.cpus > 0 {
+		fmt.Printf("cpu-quota: %g\n", c.cpus)
+	}
+	if c.memory > 0 {
+		fmt.Printf("memory-max: %d\n", c.memory)
+	}
+}
+
+// command returns a command that runs args in a new cgroup named for
+// iteration iter. Creating the cgroup takes time that shouldn't count
+// toward the benchmark, so once the command has started, the caller
+// must call ready, which returns when the command is in its cgroup
+// and about to exec args. The caller must call cleanup after the
+// command exits.
+//
+// The process that execs args first runs a shell, and with systemd,
+// systemd-run, whose CPU time is included in the command's user and
+// system time, though not in its wall-clock time.
+func (c *cgroupConfig) command(iter int, args []string) (cmd *exec.Cmd, ready func() error, cleanup func(), err error) {
+	// The shell in the cgroup writes to fd 3 and closes it just
+	// before it execs args.
+	const handshake = `echo >&3 && exec "$@" 3>&-`
+	rp, wp, err := os.Pipe()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	ready = func() error {
+		wp.Close()
+		defer rp.Close()
+		if _, err := rp.Read(make([]byte, 1)); err != nil {
+			return fmt.Errorf("waiting for command to start in cgroup: %w", err)
+		}
+		return nil
+	}
+	closePipe := func() {
+		rp.Close()
+		wp.Close()
+	}
+
+	if c.how == "systemd" {
+		// systemd-run --scope runs the command directly (not
+		// as a service), so its resource usage is still
+		// reported to us.
+		sargs := []string{"--scope", "--quiet", "--collect"}
+		if os.Getuid() != 0 {
+			sargs = append(sargs, "--user")
+		}
+		if c.cpus > 0 {
+			sargs = append(sargs, fmt.Sprintf("--property=CPUQuota=%g%%", c.cpus*100))
+		}
+		if c.memory > 0 {
+			sargs = append(sargs, fmt.Sprintf("--property=MemoryMax=%d", c.memory), "--property=MemorySwapMax=0")
+		}
+		sargs = append(sargs, "--", "/bin/sh", "-c", handshake, "benchcmd")
+		sargs = append(sargs, args...)
+		cmd = exec.Command("systemd-run", sargs...)
+		cmd.ExtraFiles = []*os.File{wp}
+		return cmd, ready, closePipe, nil
+	}
+
+	// Create a cgroup directly in cgroupfs. This requires that
+	// the cpu and memory controllers are enabled in the parent's
+	// cgroup.subtree_control and that we can write to it.
+	dir := filepath.Join(c.how, fmt.Sprintf("benchcmd-%d-%d", os.Getpid(), iter))
+	if err := os.Mkdir(dir, 0777); err != nil {
+		closePipe()
+		return nil, nil, nil, err
+	}
+	cleanup = func() {
+		closePipe()
+		os.Remove(dir)
+	}
+	write := func(file, val string) error {
+		if err := os.WriteFile(filepath.Join(dir, file), []byte(val), 0666); err != nil {
+			return fmt.Errorf("setting cgroup limit: %w", err)
+		}
+		return nil
+	}
+	if c.cpus > 0 {
+		const period = 100000
+		if err := write("cpu.max", fmt.Sprintf("%d %d", int64(c.cpus*period), period)); err != nil {
+			cleanup()
+			return nil, nil, nil, err
+		}
+	}
+	if c.memory > 0 {
+		if err := write("memory.max", fmt.Sprint(c.memory)); err != nil {
+			cleanup()
+			return nil, nil, nil, err
+		}
+		// Ignore errors; there may be no swap controller.
+		write("memory.swap.max", "0")
+	}
+	// Move the process into the cgroup before it execs the
+	// command so that all of its children are in the cgroup,
+	// too.
+	sargs := []string{"-c", `echo $$ > "$0" && ` + handshake, filepath.Join(dir, "cgroup.procs")}
+	sargs = append(sargs, args...)
+	cmd = exec.Command("/bin/sh", sargs...)
+	cmd.ExtraFiles = []*os.File{wp}
+	return cmd, ready, cleanup, nil
+}
+
+// parseBytes parses a byte count with an optional K, M, G, or T
+// binary suffix.
+func parseBytes(s string) (int64, error) {
+	mult := int64(1)
+	if s != "" {
+		switch strings.ToUpper(s[len(s)-1:]) {
+		case "K":
+			mult = 1 << 10
+		case "M":
+			mult = 1 << 20
+		case "G":
+			mult = 1 << 30
+		case "T":
+			mult = 1 << 40
+		}
+		if mult != 1 {
+			s = s[:len(s)-1]
+		}
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("bad byte count %q", s)
+	}
+	return n * mult, nil
+}
@@ -3,6 +3,26 @@
 // license that can be found in the LICENSE file.
 
 // Command benchcmd times a shell command using Go benchmark format.
+//
+// With -cpus or -memory, benchcmd runs each iteration in a transient
+// cgroup with the given CPU quota and memory limit, and emits the
+// limits as benchfmt configuration lines. By default it creates these
+// cgroups with systemd-run. With -cgroup dir, it instead creates them
+// directly under the cgroup v2 directory dir, which must have the cpu
+// and memory controllers enabled and be writable. Timing starts once
+// the command is in its cgroup, so creating the cgroup doesn't count
+// toward ns/op, but the small CPU time of the shell that moves the
+// command into its cgroup (and of systemd-run) does count toward
+// user-ns/op and sys-ns/op.
+//
+// -setup and -teardown give shell commands to run before and after
+// each iteration. These aren't timed, so they can restore state the
+// benchmarked command changes, such as by deleting its outputs. With
+// -tmpdir, each iteration runs in a fresh temporary directory, which
+// is also the working directory of the setup and teardown commands
+// and is deleted after teardown. benchcmd prints each iteration's
+// directory as a "# iteration N: dir" line, which benchmark tools
+// ignore.
 package main
 
 import (
@@ -10,16 +30,25 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"strings"
 	"syscall"
 	"time"
 )
 
 func main() {
 	flag.Usage = func() {
-		fmt.Fprintf(os.Stderr, "Usage: %s [-n iters] benchname cmd...\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Usage: %s [-n iters] [-cpus n] [-memory bytes] [-setup cmd] [-teardown cmd] [-tmpdir] benchname cmd...\n", os.Args[0])
 		flag.PrintDefaults()
 	}
 	n := flag.Int("n", 5, "iterations")
+	var cg cgroupConfig
+	flag.Float64Var(&cg.cpus, "cpus", 0, "limit each iteration to a CPU quota of `n` CPUs")
+	flagMemory := flag.String("memory", "", "limit each iteration to `bytes` of memory (with optional K, M, G, or T suffix)")
+	flag.StringVar(&cg.how, "cgroup", "systemd", "create cgroups with systemd-run, or directly under cgroup `dir`")
+	setup := flag.String("setup", "", "run shell `command` before each iteration")
+	teardown := flag.String("teardown", "", "run shell `command` after each iteration")
+	tmpdir := flag.Bool("tmpdir", false, "run each iteration in a fresh temporary directory")
 	flag.Parse()
 	if flag.NArg() < 2 {
 		flag.Usage()
@@ -27,17 +56,89 @@ func main() {
 	}
 	benchname := flag.Arg(0)
 	args := flag.Args()[1:]
+	if *tmpdir && strings.ContainsRune(args[0], filepath.Separator) && !filepath.IsAbs(args[0]) {
+		// exec resolves relative command paths against
+		// cmd.Dir, but this was given relative to our
+		// directory.
+		abs, err := filepath.Abs(args[0])
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		args[0] = abs
+	}
+
+	if *flagMemory != "" {
+		var err error
+		cg.memory, err = parseBytes(*flagMemory)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+	}
+	useCgroup := cg.cpus > 0 || cg.memory > 0
+	if useCgroup {
+		cg.printConfig()
+	}
+	if *tmpdir {
+		fmt.Printf("workdir: tmp\n")
+	}
+	if useCgroup || *tmpdir {
+		fmt.Println()
+	}
 
 	for i := 0; i < *n; i++ {
+		dir := ""
+		if *tmpdir {
+			var err error
+			dir, err = os.MkdirTemp("", "benchcmd-")
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			fmt.Printf("# iteration %d: %s\n", i, dir)
+		}
+		if err := runHook("setup", *setup, dir); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
 		cmd := exec.Command(args[0], args[1:]...)
+		ready, cleanup := func() error { return nil }, func() {}
+		if useCgroup {
+			var err error
+			cmd, ready, cleanup, err = cg.command(i, args)
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+		}
+		cmd.Dir = dir
 		cmd.Stdout = os.Stdout
 		cmd.Stderr = os.Stderr
 		before := time.Now()
-		if err := cmd.Run(); err != nil {
+		err := cmd.Start()
+		if err == nil {
+			// Don't time creating the cgroup.
+			if err = ready(); err != nil {
+				cmd.Wait()
+			} else {
+				before = time.Now()
+				err = cmd.Wait()
+			}
+		}
+		after := time.Now()
+		cleanup()
+		if err == nil {
+			err = runHook("teardown", *teardown, dir)
+		}
+		if dir != "" {
+			os.RemoveAll(dir)
+		}
+		if err != nil {
 			fmt.Println(err)
 			os.Exit(1)
 		}
-		after := time.Now()
 		fmt.Printf("Benchmark%s\t", benchname)
 		fmt.Printf("%d\t%d ns/op", 1, after.Sub(before))
 		fmt.Printf("\t%d user-ns/op\t%d sys-ns/op", cmd.ProcessState.UserTime(), cmd.ProcessState.SystemTime())
@@ -47,3 +148,20 @@ func main() {
 		fmt.Printf("\n")
 	}
 }
+
+// runHook runs the shell command script in directory dir, unless
+// script is "". Its output goes to stderr so it doesn't mix with the
+// benchmark results.
+func runHook(name, script, dir string) error {
+	if script == "" {
+		return nil
+	}
+	cmd := exec.Command("/bin/sh", "-c", script)
+	cmd.Dir = dir
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s command failed: %w", name, err)
+	}
+	return nil
+}
@@ -7,6 +7,7 @@
 // Usage:
 //
 //      benchmany [-C git-dir] [-n iterations] <commit or range>...
+//      benchmany [-n iterations] -toolchains <toolchain>...
 //
 // benchmany runs the benchmarks in the current directory <iterations>
 // times for each commit in <commit or range> and writes the benchmark
@@ -43,6 +44,27 @@
 // between the pair of commits with the biggest difference in the
 // metric. This is like "git bisect", but for performance.
 //
+// With -toolchains, benchmany instead benchmarks the current directory
+// with each of a list of Go toolchains, given oldest first, and
+// records each result under a "toolchain" key rather than "commit".
+// Each toolchain may be a GOROOT, a go command, or a Go version such
+// as go1.21.0, which is found in ~/sdk, in $PATH (as installed by
+// golang.org/dl), or downloaded using GOTOOLCHAIN. This answers which
+// Go release changed the performance of a program without building Go
+// at each commit. All orders work in this mode; "metric" bisects
+// between releases.
+//
+// With -cache, benchmany keeps a copy of each benchmark binary it
+// builds in $XDG_CACHE_HOME/benchmany, keyed by the commit or
+// toolchain, the current directory, the -buildcmd, and the Go
+// environment variables that affect builds, and reuses them in later
+// runs instead of checking out and building that commit again. This
+// makes it cheap to rerun a campaign with different -benchflags. With
+// -toolchains, the key also covers the files of the benchmark package
+// and its go.mod and go.sum, so editing the benchmark rebuilds it.
+// Otherwise, the cache assumes the benchmark itself doesn't change at
+// a given commit, so clear it if the benchmark changes outside of git.
+//
 // Benchmany is safe to interrupt. If it is restarted, it will parse
 // the benchmark log files to recover its state.
 package main
@@ -0,0 +1,168 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// cacheEnv are the environment variables that affect how benchmark
+// binaries are built, and hence are part of their cache key.
+var cacheEnv = []string{"GOOS", "GOARCH", "GOFLAGS", "GOEXPERIMENT", "CGO_ENABLED", "GOAMD64", "GOARM"}
+
+// binCacheDir returns the directory of benchmark binaries shared
+// across runs with -cache.
+func binCacheDir() string {
+	return filepath.Join(cacheDir(), "benchmany")
+}
+
+// binCacheKey returns the cache key for the binary of commit c built
+// in the current directory.
+//
+// With -toolchains, the benchmark source isn't part of any commit, so
+// the key includes the contents of the benchmark package's files.
+// Otherwise, it assumes the commit determines the benchmark source.
+func binCacheKey(c *commitInfo) (string, error) {
+	h := sha256.New()
+	fmt.Fprintf(h, "commit %s\n", c.hash)
+	if c.toolchain != nil {
+		fmt.Fprintf(h, "toolchain %s %q\n", c.toolchain.goCmd, c.toolchain.env)
+		if err := hashPackage(h, c.toolchain); err != nil {
+			return "", err
+		}
+	}
+	wd, _ := os.Getwd()
+	fmt.Fprintf(h, "dir %s\n", wd)
+	fmt.Fprintf(h, "build %s\n", run.buildCmd)
+	for _, name := range cacheEnv {
+		fmt.Fprintf(h, "%s=%s\n", name, os.Getenv(name))
+	}
+	return fmt.Sprintf("%x", h.Sum(nil))[:32], nil
+}
+
+// hashPackage writes the names and contents of the files that make up
+// the package in the current directory, as listed by t's go command,
+// to h. This includes the package's go.mod and go.sum, but not other
+// packages it imports from the same module.
+func hashPackage(h hash.Hash, t *toolchain) error {
+	cmd := t.command([]string{"go", "list", "-json"})
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return fmt.Errorf("listing benchmark package: %v\n%s", err, stderr.Bytes())
+	}
+	// Older go commands omit the fields they don't support.
+	var pkg struct {
+		Dir                               string
+		GoFiles, CgoFiles, CFiles, HFiles []string
+		SFiles, SysoFiles, EmbedFiles     []string
+		TestGoFiles, XTestGoFiles         []string
+		TestEmbedFiles, XTestEmbedFiles   []string
+		Module                            *struct{ GoMod string }
+	}
+	if err := json.Unmarshal(out, &pkg); err != nil {
+		return fmt.Errorf("listing benchmark package: %v", err)
+	}
+	var paths []string
+	for _, files := range [][]string{pkg.GoFiles, pkg.CgoFiles, pkg.CFiles, pkg.HFiles, pkg.SFiles, pkg.SysoFiles, pkg.EmbedFiles, pkg.TestGoFiles, pkg.XTestGoFiles, pkg.TestEmbedFiles, pkg.XTestEmbedFiles} {
+		for _, file := range files {
+			paths = append(paths, filepath.Join(pkg.Dir, file))
+		}
+	}
+	if pkg.Module != nil && pkg.Module.GoMod != "" {
+		paths = append(paths, pkg.Module.GoMod, filepath.Join(filepath.Dir(pkg.Module.GoMod), "go.sum"))
+	}
+	for _, path := range paths {
+		data, err := ioutil.ReadFile(path)
+		if os.IsNotExist(err) {
+			// There may not be a go.sum.
+			continue
+		} else if err != nil {
+			return err
+		}
+		fmt.Fprintf(h, "file %s %d\n", path, len(data))
+		h.Write(data)
+	}
+	return nil
+}
+
+// fetchCached copies the cached binary of commit c, if any, to
+// binPath. It reports whether there was a cached binary.
+func fetchCached(c *commitInfo, binPath string) bool {
+	key, err := binCacheKey(c)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "warning: not using cached binary: %s\n", err)
+		return false
+	}
+	cached := filepath.Join(binCacheDir(), key)
+	if !exists(cached) {
+		return false
+	}
+	if err := os.Link(cached, binPath); err == nil {
+		return true
+	}
+	// Perhaps binPath is on a different file system.
+	if err := copyFile(binPath, cached); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: copying cached binary %s: %s\n", cached, err)
+		os.Remove(binPath)
+		return false
+	}
+	return true
+}
+
+// storeCached adds binPath to the cache as the binary of commit c.
+func storeCached(c *commitInfo, binPath string) error {
+	key, err := binCacheKey(c)
+	if err != nil {
+		return err
+	}
+	dir := binCacheDir()
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return err
+	}
+	// Copy to a temporary file first so concurrent runs never
+	// see a partial binary.
+	tmp, err := ioutil.TempFile(dir, "tmp-")
+	if err != nil {
+		return err
+	}
+	tmp.Close()
+	defer os.Remove(tmp.Name())
+	if err := copyFile(tmp.Name(), binPath); err != nil {
+		return err
+	}
+	// TempFile creates files that aren't executable.
+	if err := os.Chmod(tmp.Name(), 0755); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filepath.Join(dir, key))
+}
+
+// copyFile copies src to dst as an executable file.
+func copyFile(dst, src string) error {
+	r, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	w, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0777)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, r)
+	if err2 := w.Close(); err == nil {
+		err = err2
+	}
+	return err
+}
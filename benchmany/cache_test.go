@@ -0,0 +1,95 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestBinCache(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("XDG_CACHE_HOME", filepath.Join(dir, "cache"))
+
+	c1 := &commitInfo{hash: "0123456789abcdef"}
+	c2 := &commitInfo{hash: "fedcba9876543210"}
+	bin := filepath.Join(dir, "bench.0123456")
+	if err := ioutil.WriteFile(bin, []byte("binary"), 0777); err != nil {
+		t.Fatal(err)
+	}
+	if err := storeCached(c1, bin); err != nil {
+		t.Fatal(err)
+	}
+
+	out := filepath.Join(dir, "out")
+	if fetchCached(c2, out) {
+		t.Fatalf("fetched binary of a different commit")
+	}
+	if !fetchCached(c1, out) {
+		t.Fatalf("failed to fetch cached binary")
+	}
+	data, err := ioutil.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "binary" {
+		t.Errorf("cached binary is %q, want %q", data, "binary")
+	}
+	if fi, err := os.Stat(out); err != nil {
+		t.Fatal(err)
+	} else if fi.Mode()&0100 == 0 {
+		t.Errorf("cached binary has mode %v, want executable", fi.Mode())
+	}
+
+	// A different build command is a different binary.
+	defer func(cmd string) { run.buildCmd = cmd }(run.buildCmd)
+	run.buildCmd = "go test -c -race"
+	if fetchCached(c1, filepath.Join(dir, "out2")) {
+		t.Errorf("fetched binary built with a different build command")
+	}
+}
+
+func TestBinCacheSource(t *testing.T) {
+	goCmd, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go command not found")
+	}
+	dir := t.TempDir()
+	t.Setenv("XDG_CACHE_HOME", filepath.Join(dir, "cache"))
+	src := filepath.Join(dir, "src")
+	write := func(name, data string) {
+		t.Helper()
+		if err := ioutil.WriteFile(filepath.Join(src, name), []byte(data), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Mkdir(src, 0777); err != nil {
+		t.Fatal(err)
+	}
+	write("go.mod", "module example.com/bench\n")
+	write("bench_test.go", "package bench\n")
+	t.Chdir(src)
+
+	c := &commitInfo{hash: "go1.21.0", toolchain: &toolchain{goCmd: goCmd}}
+	bin := filepath.Join(dir, "bench.go1.21.0")
+	if err := ioutil.WriteFile(bin, []byte("binary"), 0777); err != nil {
+		t.Fatal(err)
+	}
+	if err := storeCached(c, bin); err != nil {
+		t.Fatal(err)
+	}
+	if !fetchCached(c, filepath.Join(dir, "out1")) {
+		t.Fatalf("failed to fetch cached binary")
+	}
+
+	// Editing the benchmark makes the cached binary stale.
+	write("bench_test.go", "package bench\n\nconst changed = true\n")
+	if fetchCached(c, filepath.Join(dir, "out2")) {
+		t.Errorf("fetched binary built from old benchmark source")
+	}
+}
@@ -26,6 +26,11 @@ type commitInfo struct {
 	logPath      string
 	count, fails int
 	buildFailed  bool
+
+	// toolchain is the toolchain to benchmark with in
+	// -toolchains mode, in which case hash is the toolchain's
+	// name. Otherwise, it is nil.
+	toolchain *toolchain
 }
 
 // getCommits returns the commit info for all of the revisions in the
@@ -85,18 +90,23 @@ func getCommits(revRange []string, logPath string) []*commitInfo {
 
 // goverDir returns the directory containing gover-cached builds.
 func goverDir() string {
+	return filepath.Join(cacheDir(), "gover")
+}
+
+// cacheDir returns the user's XDG cache directory.
+func cacheDir() string {
 	cache := os.Getenv("XDG_CACHE_HOME")
 	if cache == "" {
 		home := os.Getenv("HOME")
 		if home == "" {
 			u, err := user.Current()
-			if err != nil {
+			if err == nil {
 				home = u.HomeDir
 			}
 		}
 		cache = filepath.Join(home, ".cache")
 	}
-	return filepath.Join(cache, "gover")
+	return cache
 }
 
 // parseLog parses benchmark runs and failures from r and updates
@@ -112,6 +122,12 @@ func parseLog(commitMap map[string]*commitInfo, r io.Reader) {
 				ci.count++
 			}
 
+		case bytes.HasPrefix(b, []byte("toolchain: ")):
+			name := scanner.Text()[len("toolchain: "):]
+			if ci := commitMap[name]; ci != nil && ci.toolchain != nil {
+				ci.count++
+			}
+
 		case bytes.HasPrefix(b, []byte("# FAILED at ")):
 			hash := scanner.Text()[len("# FAILED at "):]
 			if ci := commitMap[hash]; ci != nil {
@@ -132,10 +148,21 @@ func parseLog(commitMap map[string]*commitInfo, r io.Reader) {
 
 // binPath returns the file name of the binary for this commit.
 func (c *commitInfo) binPath() string {
+	if c.toolchain != nil {
+		return "bench." + unsafeFileRe.ReplaceAllString(c.hash, "_")
+	}
 	// TODO: This assumes the short commit hash is unique.
 	return fmt.Sprintf("bench.%s", c.hash[:7])
 }
 
+// shortName returns a short name for c for status messages.
+func (c *commitInfo) shortName() string {
+	if c.toolchain != nil {
+		return c.hash
+	}
+	return c.hash[:7]
+}
+
 // failed returns whether commit c has failed and should not be run
 // any more.
 func (c *commitInfo) failed() bool {
@@ -154,7 +181,7 @@ func (c *commitInfo) partial() bool {
 	return c.count > 0 && c.runnable()
 }
 
-var commitRe = regexp.MustCompile(`^commit: |^# FAILED|^# BUILD FAILED`)
+var commitRe = regexp.MustCompile(`^commit: |^toolchain: |^# FAILED|^# BUILD FAILED`)
 
 // cleanLog escapes lines in l that may confuse the log parser and
 // makes sure l is newline terminated.
@@ -169,8 +196,12 @@ func cleanLog(l string) string {
 // logRun updates c with a successful run.
 func (c *commitInfo) logRun(out string) {
 	var log bytes.Buffer
-	fmt.Fprintf(&log, "commit: %s\n", c.hash)
-	fmt.Fprintf(&log, "commit-time: %s\n", c.commitDate.UTC().Format(time.RFC3339))
+	if c.toolchain != nil {
+		fmt.Fprintf(&log, "toolchain: %s\n", c.hash)
+	} else {
+		fmt.Fprintf(&log, "commit: %s\n", c.hash)
+		fmt.Fprintf(&log, "commit-time: %s\n", c.commitDate.UTC().Format(time.RFC3339))
+	}
 	fmt.Fprintf(&log, "\n%s\n", cleanLog(out))
 	c.writeLog(log.String())
 	c.count++
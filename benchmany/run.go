@@ -40,6 +40,9 @@ var run struct {
 	timeout    time.Duration
 	clean      bool
 	cleanFlags string
+	telemetry  bool
+	toolchains bool
+	cache      bool
 
 	logPath string
 	binDir  string
@@ -55,6 +58,7 @@ func init() {
 	f := flag.CommandLine
 	flag.Usage = func() {
 		fmt.Fprintf(os.Stderr, "Usage: %s [flags] <revision range>\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s [flags] -toolchains <toolchain>...\n", os.Args[0])
 		f.PrintDefaults()
 	}
 	f.StringVar(&run.order, "order", "seq", "run benchmarks in `order`, which must be one of: seq, spread, metric")
@@ -78,8 +82,15 @@ func init() {
 	f.BoolVar(&dryRun, "dry-run", false, "print commands but do not run them")
 	f.BoolVar(&run.clean, "clean", false, "run \"git clean -f\" after every checkout")
 	f.StringVar(&run.cleanFlags, "cleanflags", "", "add `flags` to git clean command")
+	f.BoolVar(&run.telemetry, "telemetry", false, "record energy (J/op) and maximum CPU temperature (max-C) of each benchmark (Linux only)")
+	f.BoolVar(&run.toolchains, "toolchains", false, "benchmark the current directory with each of the listed Go toolchains, oldest first, instead of at each commit")
+	f.BoolVar(&run.cache, "cache", false, "reuse benchmark binaries built by earlier runs for the same commit or toolchain, and cache new ones, in $XDG_CACHE_HOME/benchmany")
 }
 
+// telemetry is the power and thermal telemetry source, or nil if
+// -telemetry is disabled.
+var telemetry telemetrySource
+
 func doRun() {
 	if flag.NArg() < 1 {
 		flag.Usage()
@@ -104,7 +115,19 @@ func doRun() {
 		run.logPath = filepath.Join(run.binDir, "bench.log")
 	}
 
-	commits := getCommits(flag.Args(), run.logPath)
+	if run.telemetry {
+		telemetry = newTelemetrySource()
+		if telemetry == nil {
+			fmt.Fprintf(os.Stderr, "warning: no energy or temperature sensors available; ignoring -telemetry\n")
+		}
+	}
+
+	var commits []*commitInfo
+	if run.toolchains {
+		commits = getToolchains(flag.Args(), run.logPath)
+	} else {
+		commits = getCommits(flag.Args(), run.logPath)
+	}
 
 	// Write header block to log.
 	if len(commits) > 0 {
@@ -117,7 +140,9 @@ func doRun() {
 
 	// Always run git from the top level of the git tree. Some
 	// commands, like git clean, care about this.
-	gitDir = trimNL(git("rev-parse", "--show-toplevel"))
+	if !run.toolchains {
+		gitDir = trimNL(git("rev-parse", "--show-toplevel"))
+	}
 
 	status := NewStatusReporter()
 	defer status.Stop()
@@ -345,10 +370,12 @@ func pickCommitMetric(commits []*commitInfo) *commitInfo {
 	results := make(map[string]map[string][]float64)
 	for _, b := range bs {
 		var hash string
-		if commitConfig, ok := b.Config["commit"]; !ok {
-			continue
-		} else {
+		if commitConfig, ok := b.Config["commit"]; ok {
 			hash = commitConfig.RawValue
+		} else if toolchainConfig, ok := b.Config["toolchain"]; ok {
+			hash = toolchainConfig.RawValue
+		} else {
+			continue
 		}
 		result, ok := b.Result[run.metric]
 		if !ok {
@@ -403,7 +430,24 @@ func pickCommitMetric(commits []*commitInfo) *commitInfo {
 func runBenchmark(commit *commitInfo, status *StatusReporter) {
 	// Build the benchmark if necessary.
 	binPath := filepath.Join(run.binDir, commit.binPath())
-	if !exists(binPath) {
+	if run.cache && !dryRun && !exists(binPath) {
+		fetchCached(commit, binPath)
+	}
+	needBuild := !exists(binPath)
+	if needBuild && commit.toolchain != nil {
+		runStatus(status, commit, "building")
+
+		buildCmd := append(strings.Fields(run.buildCmd), "-o", binPath)
+		cmd := commit.toolchain.command(buildCmd)
+		if dryRun {
+			dryPrint(cmd)
+		} else if out, err := combinedOutputTimeout(cmd); err != nil {
+			detail := indent(string(out)) + indent(err.Error())
+			fmt.Fprintf(os.Stderr, "failed to build tests with %s:\n%s", commit.hash, detail)
+			commit.logFailed(true, detail)
+			return
+		}
+	} else if needBuild {
 		runStatus(status, commit, "building")
 
 		// Check out the appropriate commit. This is necessary
@@ -457,6 +501,11 @@ func runBenchmark(commit *commitInfo, status *StatusReporter) {
 			return
 		}
 	}
+	if needBuild && run.cache && !dryRun {
+		if err := storeCached(commit, binPath); err != nil {
+			fmt.Fprintf(os.Stderr, "warning: caching %s: %s\n", binPath, err)
+		}
+	}
 
 	// Run the benchmark.
 	runStatus(status, commit, "running")
@@ -474,7 +523,17 @@ func runBenchmark(commit *commitInfo, status *StatusReporter) {
 		commit.count++
 		return
 	}
-	out, err := combinedOutputTimeout(cmd)
+	var out []byte
+	var err error
+	if telemetry != nil {
+		var b bytes.Buffer
+		tw := newTelemetryWriter(&b, telemetry)
+		err = outputTimeout(cmd, tw)
+		tw.Close()
+		out = b.Bytes()
+	} else {
+		out, err = combinedOutputTimeout(cmd)
+	}
 	if err == nil {
 		commit.logRun(string(out))
 	} else {
@@ -501,22 +560,32 @@ func doGoverSave() error {
 
 // runStatus updates the status message for commit.
 func runStatus(sr *StatusReporter, commit *commitInfo, status string) {
-	sr.Message(fmt.Sprintf("commit %s, iteration %d/%d: %s...", commit.hash[:7], commit.count+1, run.iterations, status))
+	what := "commit"
+	if commit.toolchain != nil {
+		what = "toolchain"
+	}
+	sr.Message(fmt.Sprintf("%s %s, iteration %d/%d: %s...", what, commit.shortName(), commit.count+1, run.iterations, status))
 }
 
 // combinedOutputTimeout is like c.CombinedOutput(), but if
 // run.timeout != 0, it will kill c after run.timeout time expires.
 func combinedOutputTimeout(c *exec.Cmd) (out []byte, err error) {
 	var b bytes.Buffer
-	c.Stdout = &b
-	c.Stderr = &b
+	err = outputTimeout(c, &b)
+	return b.Bytes(), err
+}
+
+// outputTimeout runs c with its stdout and stderr written to w. If
+// run.timeout != 0, it will kill c after run.timeout time expires.
+func outputTimeout(c *exec.Cmd, w io.Writer) (err error) {
+	c.Stdout = w
+	c.Stderr = w
 	if err := c.Start(); err != nil {
-		return nil, err
+		return err
 	}
 
 	if run.timeout == 0 {
-		err := c.Wait()
-		return b.Bytes(), err
+		return c.Wait()
 	}
 
 	tick := time.NewTimer(run.timeout)
@@ -543,5 +612,5 @@ loop:
 		}
 	}
 	tick.Stop()
-	return b.Bytes(), err
+	return err
 }
@@ -123,7 +123,7 @@ func TestMain(m *testing.M) {
 			if uname, ok := b.Config["uname-sr"]; !ok {
 				t.Errorf("missing uname-sr config")
 			} else {
-				t.Logf("uname-sr: %s", uname)
+				t.Logf("uname-sr: %s", uname.RawValue)
 			}
 		}
 		for _, rev := range revs {
@@ -0,0 +1,165 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A telemetrySource samples system power and thermal state.
+type telemetrySource interface {
+	// Energy returns a cumulative energy counter in joules. The
+	// counter may wrap around; the returned range is the value
+	// at which it wraps.
+	Energy() (joules, wrap float64, ok bool)
+
+	// Temp returns the current maximum CPU temperature in
+	// degrees Celsius.
+	Temp() (celsius float64, ok bool)
+}
+
+// telemetryWriter is an io.Writer that annotates each benchmark
+// result line passing through it with the energy per operation and
+// maximum temperature observed while that benchmark ran.
+//
+// Energy is attributed by multiplying the average power since the
+// previous result line by the benchmark's ns/op, which accounts for
+// the benchmark's calibration runs.
+type telemetryWriter struct {
+	w   io.Writer
+	src telemetrySource
+
+	mu       sync.Mutex
+	buf      []byte
+	lastTime time.Time
+	lastJ    float64
+	haveJ    bool
+	maxTemp  float64
+	haveTemp bool
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+var benchLineRe = regexp.MustCompile(`^Benchmark\S*\s+\d+\s`)
+
+func newTelemetryWriter(w io.Writer, src telemetrySource) *telemetryWriter {
+	tw := &telemetryWriter{w: w, src: src, stop: make(chan struct{}), done: make(chan struct{})}
+	tw.mark()
+	go tw.sampleTemp()
+	return tw
+}
+
+// mark resets the energy and temperature baselines.
+func (tw *telemetryWriter) mark() {
+	tw.lastTime = time.Now()
+	tw.lastJ, _, tw.haveJ = tw.src.Energy()
+	tw.maxTemp, tw.haveTemp = tw.src.Temp()
+}
+
+// tempSampleInterval is how often sampleTemp samples the temperature.
+var tempSampleInterval = 100 * time.Millisecond
+
+// sampleTemp periodically samples the temperature so we catch peaks
+// between result lines.
+func (tw *telemetryWriter) sampleTemp() {
+	defer close(tw.done)
+	tick := time.NewTicker(tempSampleInterval)
+	defer tick.Stop()
+	for {
+		select {
+		case <-tw.stop:
+			return
+		case <-tick.C:
+		}
+		if t, ok := tw.src.Temp(); ok {
+			tw.mu.Lock()
+			if !tw.haveTemp || t > tw.maxTemp {
+				tw.maxTemp, tw.haveTemp = t, true
+			}
+			tw.mu.Unlock()
+		}
+	}
+}
+
+func (tw *telemetryWriter) Write(data []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	tw.buf = append(tw.buf, data...)
+	for {
+		i := bytes.IndexByte(tw.buf, '\n')
+		if i < 0 {
+			break
+		}
+		line := string(tw.buf[:i])
+		tw.buf = tw.buf[i+1:]
+		if benchLineRe.MatchString(line) {
+			line += tw.metrics(line)
+		}
+		if _, err := io.WriteString(tw.w, line+"\n"); err != nil {
+			return 0, err
+		}
+	}
+	return len(data), nil
+}
+
+// metrics returns the telemetry metrics for the benchmark result
+// line and resets the baselines for the next benchmark.
+func (tw *telemetryWriter) metrics(line string) string {
+	var extra strings.Builder
+	now := time.Now()
+	j, wrap, ok := tw.src.Energy()
+	if ok && tw.haveJ {
+		dj := j - tw.lastJ
+		if dj < 0 {
+			dj += wrap
+		}
+		if dt := now.Sub(tw.lastTime).Seconds(); dt > 0 {
+			if nsPerOp, ok := benchNsPerOp(line); ok {
+				fmt.Fprintf(&extra, "\t%.4g J/op", dj/dt*nsPerOp/1e9)
+			}
+		}
+	}
+	if t, ok := tw.src.Temp(); ok && (!tw.haveTemp || t > tw.maxTemp) {
+		tw.maxTemp, tw.haveTemp = t, true
+	}
+	if tw.haveTemp {
+		fmt.Fprintf(&extra, "\t%.1f max-C", tw.maxTemp)
+	}
+	tw.mark()
+	return extra.String()
+}
+
+// benchNsPerOp returns the ns/op value of a benchmark result line.
+func benchNsPerOp(line string) (float64, bool) {
+	fields := strings.Fields(line)
+	for i := 3; i < len(fields); i += 2 {
+		if fields[i] == "ns/op" {
+			v, err := strconv.ParseFloat(fields[i-1], 64)
+			return v, err == nil
+		}
+	}
+	return 0, false
+}
+
+// Close stops sampling and writes any incomplete final line.
+func (tw *telemetryWriter) Close() error {
+	close(tw.stop)
+	<-tw.done
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if len(tw.buf) > 0 {
+		_, err := tw.w.Write(tw.buf)
+		tw.buf = nil
+		return err
+	}
+	return nil
+}
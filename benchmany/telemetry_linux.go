@@ -0,0 +1,90 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// sysfsTelemetry reads RAPL energy counters from the powercap
+// interface and temperatures from the thermal zones.
+type sysfsTelemetry struct {
+	raplDirs  []string
+	thermDirs []string
+}
+
+// newTelemetrySource returns a telemetrySource for this system, or
+// nil if neither energy nor temperature are available.
+func newTelemetrySource() telemetrySource {
+	var t sysfsTelemetry
+	// Top-level RAPL domains (one per package) have names like
+	// "intel-rapl:0". Subdomains ("intel-rapl:0:0") are included
+	// in their package's counter.
+	dirs, _ := filepath.Glob("/sys/class/powercap/intel-rapl:*")
+	for _, dir := range dirs {
+		if strings.Count(filepath.Base(dir), ":") != 1 {
+			continue
+		}
+		if _, err := readSysInt(filepath.Join(dir, "energy_uj")); err == nil {
+			t.raplDirs = append(t.raplDirs, dir)
+		}
+	}
+	dirs, _ = filepath.Glob("/sys/class/thermal/thermal_zone*")
+	for _, dir := range dirs {
+		if _, err := readSysInt(filepath.Join(dir, "temp")); err == nil {
+			t.thermDirs = append(t.thermDirs, dir)
+		}
+	}
+	if t.raplDirs == nil && t.thermDirs == nil {
+		return nil
+	}
+	return &t
+}
+
+func (t *sysfsTelemetry) Energy() (joules, wrap float64, ok bool) {
+	if t.raplDirs == nil {
+		return 0, 0, false
+	}
+	// Sum the package counters. Each wraps independently, but
+	// we only report one wrap range, so this is approximate if
+	// multiple packages wrap in the same interval.
+	for _, dir := range t.raplDirs {
+		uj, err := readSysInt(filepath.Join(dir, "energy_uj"))
+		if err != nil {
+			return 0, 0, false
+		}
+		max, err := readSysInt(filepath.Join(dir, "max_energy_range_uj"))
+		if err != nil {
+			return 0, 0, false
+		}
+		joules += float64(uj) / 1e6
+		wrap += float64(max) / 1e6
+	}
+	return joules, wrap, true
+}
+
+func (t *sysfsTelemetry) Temp() (celsius float64, ok bool) {
+	for _, dir := range t.thermDirs {
+		mc, err := readSysInt(filepath.Join(dir, "temp"))
+		if err != nil {
+			continue
+		}
+		if c := float64(mc) / 1000; !ok || c > celsius {
+			celsius, ok = c, true
+		}
+	}
+	return
+}
+
+func readSysInt(path string) (int64, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+}
@@ -0,0 +1,64 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeTelemetry is a telemetrySource whose readings are set by the
+// test. telemetryWriter samples the temperature in the background,
+// so the readings are protected by mu.
+type fakeTelemetry struct {
+	mu           sync.Mutex
+	joules, temp float64
+}
+
+func (f *fakeTelemetry) set(joules, temp float64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.joules, f.temp = joules, temp
+}
+
+func (f *fakeTelemetry) Energy() (joules, wrap float64, ok bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.joules, 100, true
+}
+
+func (f *fakeTelemetry) Temp() (float64, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.temp, true
+}
+
+func TestTelemetryWriter(t *testing.T) {
+	defer func(d time.Duration) { tempSampleInterval = d }(tempSampleInterval)
+	tempSampleInterval = time.Millisecond
+
+	src := &fakeTelemetry{joules: 10, temp: 40}
+	var b bytes.Buffer
+	tw := newTelemetryWriter(&b, src)
+	tw.Write([]byte("goos: linux\nBenchmarkA-8 \t"))
+	// Peak while the benchmark runs, so only sampleTemp sees it.
+	src.set(10, 60)
+	time.Sleep(50 * tempSampleInterval)
+	// Wrap the energy counter and cool down.
+	src.set(5, 50)
+	tw.Write([]byte("1000\t100 ns/op\nPASS"))
+	tw.Close()
+
+	lines := strings.Split(b.String(), "\n")
+	if len(lines) != 3 || lines[0] != "goos: linux" || lines[2] != "PASS" {
+		t.Fatalf("unexpected output:\n%s", b.String())
+	}
+	if !strings.HasPrefix(lines[1], "BenchmarkA-8 \t1000\t100 ns/op\t") || !strings.HasSuffix(lines[1], "J/op\t60.0 max-C") {
+		t.Errorf("bad result line %q", lines[1])
+	}
+}
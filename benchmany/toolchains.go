@@ -0,0 +1,98 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// A toolchain is a Go toolchain to benchmark with in -toolchains
+// mode.
+type toolchain struct {
+	goCmd string   // Path of the go command
+	env   []string // Additional environment variables
+}
+
+// getToolchains returns the commit info for each of the named
+// toolchains. Toolchains are given oldest first, but are returned
+// most recent first to match getCommits.
+//
+// Each name may be the path of a GOROOT or a go command, or a Go
+// version such as "go1.21.0". A version is found in ~/sdk/<version>
+// (where golang.org/dl installs it) or as a golang.org/dl wrapper
+// command in $PATH. Otherwise, it is downloaded on demand using
+// GOTOOLCHAIN, which requires Go 1.21 or later.
+func getToolchains(names []string, logPath string) []*commitInfo {
+	commits := make([]*commitInfo, len(names))
+	commitMap := make(map[string]*commitInfo)
+	for i, name := range names {
+		if commitMap[name] != nil {
+			log.Fatalf("toolchain %s listed more than once", name)
+		}
+		c := &commitInfo{
+			hash:      name,
+			logPath:   logPath,
+			toolchain: findToolchain(name),
+		}
+		commits[len(names)-1-i] = c
+		commitMap[name] = c
+	}
+
+	// Load current benchmark state.
+	logf, err := os.Open(logPath)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Fatalf("opening %s: %v", logPath, err)
+		}
+	} else {
+		defer logf.Close()
+		parseLog(commitMap, logf)
+	}
+
+	return commits
+}
+
+func findToolchain(name string) *toolchain {
+	if strings.ContainsRune(name, filepath.Separator) {
+		if fi, err := os.Stat(name); err != nil {
+			log.Fatal(err)
+		} else if fi.IsDir() {
+			return &toolchain{goCmd: filepath.Join(name, "bin", "go")}
+		}
+		return &toolchain{goCmd: name}
+	}
+
+	if home, err := os.UserHomeDir(); err == nil {
+		goCmd := filepath.Join(home, "sdk", name, "bin", "go")
+		if exists(goCmd) {
+			return &toolchain{goCmd: goCmd}
+		}
+	}
+	if goCmd, err := exec.LookPath(name); err == nil {
+		return &toolchain{goCmd: goCmd}
+	}
+	return &toolchain{goCmd: "go", env: []string{"GOTOOLCHAIN=" + name}}
+}
+
+// command returns a command to run args using toolchain t. If args[0]
+// is "go", it is replaced with t's go command.
+func (t *toolchain) command(args []string) *exec.Cmd {
+	if args[0] == "go" {
+		args = append([]string{t.goCmd}, args[1:]...)
+	}
+	cmd := exec.Command(args[0], args[1:]...)
+	if len(t.env) > 0 {
+		cmd.Env = append(os.Environ(), t.env...)
+	}
+	return cmd
+}
+
+// unsafeFileRe matches characters to avoid in binary names.
+var unsafeFileRe = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
@@ -0,0 +1,242 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/aclements/go-misc/bench"
+)
+
+// A followInput is a benchmark input that may still be growing.
+type followInput struct {
+	path string
+
+	// For files, size and mod are the size and modification time
+	// of the file when it was last read.
+	size int64
+	mod  time.Time
+
+	// For standard input, a goroutine appends to data and sets
+	// changed and eof.
+	mu      sync.Mutex
+	data    []byte
+	changed bool
+	eof     bool
+}
+
+// readStdin copies standard input into in until EOF.
+func (in *followInput) readStdin() {
+	buf := make([]byte, 64<<10)
+	for {
+		n, err := os.Stdin.Read(buf)
+		in.mu.Lock()
+		in.data = append(in.data, buf[:n]...)
+		in.changed = in.changed || n > 0
+		if err != nil {
+			if err != io.EOF {
+				log.Printf("reading standard input: %s", err)
+			}
+			in.eof = true
+		}
+		in.mu.Unlock()
+		if err != nil {
+			return
+		}
+	}
+}
+
+// poll checks whether in has changed since the last poll and, if so,
+// updates in.data. It reports whether in changed and whether it may
+// change again.
+func (in *followInput) poll() (changed, more bool) {
+	if in.path == "-" {
+		in.mu.Lock()
+		defer in.mu.Unlock()
+		changed, in.changed = in.changed, false
+		return changed, !in.eof
+	}
+
+	fi, err := os.Stat(in.path)
+	if err != nil {
+		log.Print(err)
+		return false, true
+	}
+	if fi.Size() == in.size && fi.ModTime().Equal(in.mod) {
+		return false, true
+	}
+	data, err := os.ReadFile(in.path)
+	if err != nil {
+		log.Print(err)
+		return false, true
+	}
+	in.size, in.mod = fi.Size(), fi.ModTime()
+	in.mu.Lock()
+	in.data = data
+	in.mu.Unlock()
+	return true, true
+}
+
+// lines returns the complete lines read from in so far. A partial
+// last line is probably a result that's still being written, so it's
+// left for the next poll.
+func (in *followInput) lines() []byte {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	return in.data[:bytes.LastIndexByte(in.data, '\n')+1]
+}
+
+// follow re-reads p's inputs every refresh interval and, when they
+// change, re-renders the plot to out in format and, if addr isn't "",
+// to a web page served on addr. It returns once all inputs are pipes
+// that have reached EOF and there's no web server.
+func follow(p *plotter, out, format string, refresh time.Duration, addr string) {
+	var inputs []*followInput
+	for _, path := range p.paths {
+		in := &followInput{path: path}
+		if path == "-" {
+			go in.readStdin()
+		}
+		inputs = append(inputs, in)
+	}
+
+	var srv *liveServer
+	if addr != "" {
+		srv = &liveServer{refresh: refresh}
+		go func() {
+			log.Fatal(http.ListenAndServe(addr, srv))
+		}()
+		log.Printf("serving plot at http://%s/", addr)
+	}
+
+	tick := time.NewTicker(refresh)
+	defer tick.Stop()
+	for {
+		changed, more := false, false
+		for _, in := range inputs {
+			c, m := in.poll()
+			changed = changed || c
+			more = more || m
+		}
+		if changed {
+			if err := p.update(inputs, out, format, srv); err != nil {
+				log.Print(err)
+			}
+		}
+		if !more && srv == nil {
+			return
+		}
+		<-tick.C
+	}
+}
+
+// update parses inputs and re-renders them to out and srv.
+func (p *plotter) update(inputs []*followInput, out, format string, srv *liveServer) error {
+	var benchmarks []*bench.Benchmark
+	for _, in := range inputs {
+		bs, err := bench.Parse(bytes.NewReader(in.lines()))
+		if err != nil {
+			return fmt.Errorf("%s: %w", in.path, err)
+		}
+		benchmarks = append(benchmarks, bs...)
+	}
+	if len(benchmarks) == 0 {
+		return nil
+	}
+
+	var svg bytes.Buffer
+	if out != "" {
+		// Write to a temporary file and rename it so viewers
+		// never see a partially written plot.
+		tmp := filepath.Join(filepath.Dir(out), "."+filepath.Base(out)+".tmp")
+		if format == "svg" {
+			if err := p.render(&svg, "", format, benchmarks); err != nil {
+				return err
+			}
+			if err := os.WriteFile(tmp, svg.Bytes(), 0666); err != nil {
+				return err
+			}
+		} else if err := p.render(nil, tmp, format, benchmarks); err != nil {
+			return err
+		}
+		if err := os.Rename(tmp, out); err != nil {
+			return err
+		}
+	}
+	if srv != nil {
+		if svg.Len() == 0 {
+			if err := p.render(&svg, "", "svg", benchmarks); err != nil {
+				return err
+			}
+		}
+		srv.set(svg.Bytes(), p.table)
+	}
+	log.Printf("rendered %d results", len(benchmarks))
+	return nil
+}
+
+// A liveServer serves the most recently rendered plot on a page that
+// reloads itself.
+type liveServer struct {
+	refresh time.Duration
+
+	mu      sync.Mutex
+	body    []byte
+	isTable bool
+	updated time.Time
+}
+
+func (s *liveServer) set(body []byte, isTable bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.body, s.isTable, s.updated = body, isTable, time.Now()
+}
+
+func (s *liveServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	body, isTable, updated := s.body, s.isTable, s.updated
+	s.mu.Unlock()
+
+	switch r.URL.Path {
+	case "/":
+	case "/plot.svg":
+		if body == nil || isTable {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "image/svg+xml")
+		w.Write(body)
+		return
+	default:
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprintf(w, "<!DOCTYPE html>\n<html><head><meta http-equiv=\"refresh\" content=\"%d\"><title>benchplot</title></head><body>\n", int(math.Ceil(s.refresh.Seconds())))
+	switch {
+	case body == nil:
+		fmt.Fprintf(w, "<p>Waiting for benchmark results...</p>\n")
+	case isTable:
+		fmt.Fprintf(w, "<pre>%s</pre>\n", html.EscapeString(string(body)))
+	default:
+		// Inline the SVG so its tooltips work.
+		w.Write(body)
+	}
+	if body != nil {
+		fmt.Fprintf(w, "<p>Updated %s</p>\n", updated.Format(time.RFC1123))
+	}
+	fmt.Fprintf(w, "</body></html>\n")
+}
@@ -10,6 +10,16 @@
 // benchplot will cross-reference these hashes against the specified
 // Git repository and plot each metric over time for each benchmark.
 //
+// By default, benchplot produces an SVG. If the -o file name ends in
+// .png or .pdf, benchplot instead renders a PNG or PDF, which
+// requires rsvg-convert from librsvg. With -rows-per-page, a PDF
+// splits the benchmarks across multiple pages.
+//
+// With -follow, benchplot keeps reading its inputs as they grow, for
+// example while benchmany is still running, and periodically
+// re-renders the plot to the -o file. With -http, it also serves the
+// plot on a web page that reloads itself as results arrive.
+//
 // [1] https://github.com/golang/proposal/blob/master/design/14313-benchmark-format.md
 package main
 
@@ -17,12 +27,16 @@ import (
 	"bytes"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"runtime"
 	"runtime/pprof"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/aclements/go-gg/gg"
 	"github.com/aclements/go-gg/table"
@@ -41,6 +55,11 @@ func main() {
 		flagGitDir     = flag.String("C", string(defaultGitDir), "run git in `dir`")
 		flagOut        = flag.String("o", "", "write output to `file` (default: stdout)")
 		flagTable      = flag.Bool("table", false, "output a table instead of a plot")
+		flagDPI        = flag.Float64("dpi", 96, "render PNG and PDF output at `dpi`")
+		flagPageRows   = flag.Int("rows-per-page", 0, "in PDF output, plot at most `n` benchmarks per page (0 means one page)")
+		flagFollow     = flag.Bool("follow", false, "keep reading inputs as they grow and re-render the output as results arrive")
+		flagRefresh    = flag.Duration("refresh", 5*time.Second, "in -follow mode, check for new results every `interval`")
+		flagHTTP       = flag.String("http", "", "in -follow mode, serve the plot on `addr` with automatic refresh")
 	)
 	flag.Usage = func() {
 		fmt.Fprintf(os.Stderr, "Usage: %s [flags] [inputs...]\n", os.Args[0])
@@ -74,6 +93,35 @@ func main() {
 	if len(paths) == 0 {
 		paths = []string{"-"}
 	}
+	p := &plotter{
+		paths:    paths,
+		gitDir:   *flagGitDir,
+		table:    *flagTable,
+		dpi:      *flagDPI,
+		pageRows: *flagPageRows,
+	}
+
+	// Prepare for output. rsvg-convert writes PNG and PDF output
+	// itself.
+	format := strings.TrimPrefix(strings.ToLower(filepath.Ext(*flagOut)), ".")
+	if *flagTable || (format != "png" && format != "pdf") {
+		format = "svg"
+	}
+	if *flagPageRows != 0 && format != "pdf" {
+		log.Fatal("-rows-per-page requires PDF output")
+	}
+
+	if *flagFollow {
+		if *flagOut == "" && *flagHTTP == "" {
+			log.Fatal("-follow requires -o or -http")
+		}
+		follow(p, *flagOut, format, *flagRefresh, *flagHTTP)
+		return
+	}
+	if *flagHTTP != "" {
+		log.Fatal("-http requires -follow")
+	}
+
 	var benchmarks []*bench.Benchmark
 	for _, path := range paths {
 		func() {
@@ -94,6 +142,37 @@ func main() {
 			benchmarks = append(benchmarks, bs...)
 		}()
 	}
+
+	f := os.Stdout
+	if *flagOut != "" && format == "svg" {
+		var err error
+		f, err = os.Create(*flagOut)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+	}
+	if err := p.render(f, *flagOut, format, benchmarks); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// A plotter renders benchmark results as a plot or table.
+type plotter struct {
+	paths    []string
+	gitDir   string
+	table    bool
+	dpi      float64
+	pageRows int
+
+	// commits is the table of commits in gitDir, read on first
+	// use.
+	commits *table.Table
+}
+
+// render renders benchmarks in format. SVG and table output is
+// written to w. PNG and PDF output is written to the file path.
+func (p *plotter) render(w io.Writer, path, format string, benchmarks []*bench.Benchmark) error {
 	bench.ParseValues(benchmarks, nil)
 
 	// Prepare gg tables.
@@ -102,35 +181,66 @@ func main() {
 	if btab.Column("commit") == nil {
 		tab = btab
 	} else {
-		gtab := commitsToTable(Commits(*flagGitDir))
-		tab = table.Join(btab, "commit", gtab, "commit")
-	}
-
-	// Prepare for output.
-	f := os.Stdout
-	if *flagOut != "" {
-		var err error
-		f, err = os.Create(*flagOut)
-		if err != nil {
-			log.Fatal(err)
+		if p.commits == nil {
+			p.commits = commitsToTable(Commits(p.gitDir))
 		}
-		defer f.Close()
+		tab = table.Join(btab, "commit", p.commits, "commit")
 	}
 
 	// Output table.
-	if *flagTable {
-		table.Fprint(f, tab)
-		return
+	if p.table {
+		table.Fprint(w, tab)
+		return nil
 	}
 
 	// Plot.
 	//
 	// TODO: Collect nrows/ncols from the plot itself.
-	p, nrows, ncols := plot(tab, configCols, resultCols)
-	if !(len(paths) == 1 && paths[0] == "-") {
-		p.Add(gg.Title(strings.Join(paths, " ")))
+	var pageNames [][]string
+	if p.pageRows <= 0 {
+		pageNames = [][]string{nil}
+	} else {
+		names := benchmarkNames(benchmarks)
+		for len(names) > 0 {
+			n := p.pageRows
+			if n > len(names) {
+				n = len(names)
+			}
+			pageNames = append(pageNames, names[:n])
+			names = names[n:]
+		}
+	}
+	var pages []page
+	for _, names := range pageNames {
+		pl, nrows, ncols := plot(tab, configCols, resultCols, names)
+		if !(len(p.paths) == 1 && p.paths[0] == "-") {
+			pl.Add(gg.Title(strings.Join(p.paths, " ")))
+		}
+		pages = append(pages, page{pl, 500 * ncols, 350 * nrows})
 	}
 
 	// Render plot.
-	p.WriteSVG(f, 500*ncols, 350*nrows)
+	if format == "svg" {
+		pages[0].plot.WriteSVG(w, pages[0].width, pages[0].height)
+		return nil
+	}
+	return renderPages(path, format, p.dpi, pages)
+}
+
+// benchmarkNames returns the sorted benchmark names in bs, in the
+// order they appear as facet rows.
+func benchmarkNames(bs []*bench.Benchmark) []string {
+	set := make(map[string]bool)
+	for _, b := range bs {
+		set[b.Name] = true
+	}
+	names := make([]string, 0, len(set)+1)
+	if len(set) > 1 {
+		names = append(names, geomeanName)
+	}
+	for name := range set {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
 }
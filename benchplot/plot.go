@@ -12,7 +12,13 @@ import (
 
 // TODO: Support plotting non-normalized results.
 
-func plot(t table.Grouping, configCols, resultCols []string) (*gg.Plot, int, int) {
+// geomeanName is the benchmark name of the geomean row.
+const geomeanName = " geomean"
+
+// plot plots the benchmarks in t. If names is non-nil, it plots only
+// the benchmarks (including geomeanName) in names, which is useful
+// for splitting a large facet grid across pages.
+func plot(t table.Grouping, configCols, resultCols, names []string) (*gg.Plot, int, int) {
 	//t = table.Flatten(table.HeadTables(table.GroupBy(t, "name"), 9))
 
 	// Filter to just the master branch.
@@ -60,13 +66,26 @@ func plot(t table.Grouping, configCols, resultCols []string) (*gg.Plot, int, int
 		gt := removeNaNs(plot.Data(), y)
 		gt = ggstat.Agg("commit", "metric")(ggstat.AggGeoMean(y)).F(gt)
 		gt = table.MapTables(gt, func(_ table.GroupID, t *table.Table) *table.Table {
-			return table.NewBuilder(t).AddConst("name", " geomean").Done()
+			return table.NewBuilder(t).AddConst("name", geomeanName).Done()
 		})
 		gt = table.Rename(gt, "geomean "+y, y)
 		plot.SetData(table.Concat(plot.Data(), gt))
 		nrows++
 	}
 
+	// Limit to the requested benchmarks. We do this after
+	// computing the geomean so it reflects all benchmarks.
+	if names != nil {
+		keep := make(map[string]bool)
+		for _, name := range names {
+			keep[name] = true
+		}
+		plot.SetData(table.Filter(plot.Data(), func(name string) bool {
+			return keep[name]
+		}, "name"))
+		nrows = len(names)
+	}
+
 	// Facet by name and metric.
 	plot.Add(gg.FacetY{Col: "name"}, gg.FacetX{Col: "metric"})
 
@@ -0,0 +1,69 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/aclements/go-gg/gg"
+)
+
+// A page is a plot to render at a particular size in pixels.
+type page struct {
+	plot          *gg.Plot
+	width, height int
+}
+
+// renderPages renders pages to path in format, which must be "png"
+// or "pdf". Each page becomes one page of a PDF; PNG output supports
+// only one page.
+//
+// gg can only produce SVG, so this uses rsvg-convert from librsvg to
+// convert it.
+func renderPages(path, format string, dpi float64, pages []page) error {
+	if format == "png" && len(pages) != 1 {
+		return fmt.Errorf("PNG output supports only one page")
+	}
+
+	dir, err := os.MkdirTemp("", "benchplot-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	args := []string{"-f", format, "-o", path}
+	if format == "png" {
+		// SVG sizes are in pixels at 96 DPI.
+		args = append(args, "-z", fmt.Sprint(dpi/96))
+	} else {
+		args = append(args, "-d", fmt.Sprint(dpi), "-p", fmt.Sprint(dpi))
+	}
+	for i, pg := range pages {
+		svgPath := filepath.Join(dir, fmt.Sprintf("page%d.svg", i))
+		f, err := os.Create(svgPath)
+		if err != nil {
+			return err
+		}
+		pg.plot.WriteSVG(f, pg.width, pg.height)
+		if err := f.Close(); err != nil {
+			return err
+		}
+		args = append(args, svgPath)
+	}
+
+	cmd := exec.Command("rsvg-convert", args...)
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			return fmt.Errorf("%s output requires rsvg-convert (from librsvg): %w", format, err)
+		}
+		return fmt.Errorf("rsvg-convert failed: %w", err)
+	}
+	return nil
+}
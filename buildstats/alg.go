@@ -9,7 +9,7 @@ func FilterInPlace[T any](xs []T, keep func(x T) bool) []T {
 	for i := range xs {
 		if keep(xs[i]) {
 			if i != j {
-				xs[i] = xs[j]
+				xs[j] = xs[i]
 			}
 			j++
 		}
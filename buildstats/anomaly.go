@@ -0,0 +1,147 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"sort"
+)
+
+const (
+	// alertBaselineWindows is the length of the baseline that a
+	// builder's recent window is compared against, in windows.
+	alertBaselineWindows = 10
+
+	// alertMinRate is the lowest baseline failure rate used when
+	// comparing against the recent window, so a builder with a
+	// perfect baseline isn't flagged for a single flake.
+	alertMinRate = 0.01
+
+	// alertMinFails is the fewest failures in the recent window
+	// that can raise an alert.
+	alertMinFails = 2
+)
+
+// An alert reports a builder whose recent failure rate spiked above
+// its baseline.
+type alert struct {
+	label          string
+	recent, before sum
+
+	// first is the first failure in the recent window and good
+	// is the last successful revision before it, or nil if
+	// there is none. The breakage was probably introduced in
+	// (good, first].
+	good, first *rev
+}
+
+// findAlerts returns the builders in g whose failure rate in the last
+// window revisions is at least factor times their failure rate in the
+// preceding alertBaselineWindows*window revisions, worst first.
+func findAlerts(g *grid, window int, factor float64) []alert {
+	if window <= 0 || len(g.revs) <= window {
+		return nil
+	}
+	split := len(g.revs) - window
+	baseStart := split - alertBaselineWindows*window
+	if baseStart < 0 {
+		baseStart = 0
+	}
+
+	var alerts []alert
+	for label := range g.labels {
+		results := g.labelResults(label)
+		a := alert{label: label}
+		for i, r := range results[baseStart:split] {
+			a.before.add(r)
+			if r == resOK {
+				a.good = g.revs[baseStart+i]
+			}
+		}
+		for i, r := range results[split:] {
+			a.recent.add(r)
+			switch {
+			case r == resOK && a.first == nil:
+				a.good = g.revs[split+i]
+			case r == resFail && a.first == nil:
+				a.first = g.revs[split+i]
+			}
+		}
+		if a.before.total == 0 || a.recent.total == 0 || a.recent.fails < alertMinFails {
+			continue
+		}
+		base := a.before.failureRate()
+		if base < alertMinRate {
+			base = alertMinRate
+		}
+		if a.recent.failureRate() < factor*base {
+			continue
+		}
+		alerts = append(alerts, a)
+	}
+	sort.Slice(alerts, func(i, j int) bool {
+		if ri, rj := alerts[i].ratio(), alerts[j].ratio(); ri != rj {
+			return ri > rj
+		}
+		return alerts[i].label < alerts[j].label
+	})
+	return alerts
+}
+
+// ratio returns how many times higher the recent failure rate is than
+// the baseline.
+func (a alert) ratio() float64 {
+	base := a.before.failureRate()
+	if base < alertMinRate {
+		base = alertMinRate
+	}
+	return a.recent.failureRate() / base
+}
+
+// suspects returns the range of revisions that probably introduced
+// the failures, in git's "good..first" syntax.
+func (a alert) suspects() string {
+	if a.good == nil {
+		return "?.." + a.first.hash()
+	}
+	return a.good.hash() + ".." + a.first.hash()
+}
+
+// hash returns the abbreviated commit hash of r.
+func (r *rev) hash() string {
+	h := r.commit()
+	if len(h) > 10 {
+		h = h[:10]
+	}
+	return h
+}
+
+// printAlertsHTML writes alerts as an HTML list to w.
+func printAlertsHTML(w io.Writer, alerts []alert) {
+	if len(alerts) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "<h2>Failure rate alerts</h2>\n<ul>\n")
+	for _, a := range alerts {
+		fmt.Fprintf(w, "<li><b>%s</b>: %s now vs %s before; suspect revisions <code>%s</code></li>\n", html.EscapeString(a.label), digestRate(a.recent), digestRate(a.before), a.suspects())
+	}
+	fmt.Fprintf(w, "</ul>\n")
+}
+
+// printAlertsMarkdown writes alerts as a Markdown table to w.
+func printAlertsMarkdown(w io.Writer, alerts []alert) {
+	if len(alerts) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "## Alerts\n\n")
+	fmt.Fprintf(w, "| Builder | Recent | Baseline | Suspect revisions |\n")
+	fmt.Fprintf(w, "|---|--:|--:|---|\n")
+	for _, a := range alerts {
+		fmt.Fprintf(w, "| %s | %.1f%% (%d/%d) | %s | %s |\n", a.label, 100*a.recent.failureRate(), a.recent.fails, a.recent.total, digestRate(a.before), a.suspects())
+	}
+	fmt.Fprintf(w, "\n")
+}
@@ -0,0 +1,116 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// metaCacheVersion must be incremented when revMeta changes.
+const metaCacheVersion = 2
+
+// A metaCache caches the decoded metadata of fetchlogs revisions so
+// that repeated runs only need to read revisions that are new or
+// have changed since the last run.
+type metaCache struct {
+	path  string
+	dirty bool
+
+	Version int
+	Revs    map[string]*cachedMeta // Keyed by revision directory name
+}
+
+type cachedMeta struct {
+	// RevTime and BuildersTime are the modification times of the
+	// revision's .rev.json and .builders.json files. fetchlogs
+	// rewrites these as more results come in, so if either
+	// changes, the cached metadata is stale.
+	RevTime, BuildersTime time.Time
+
+	Meta revMeta
+}
+
+// openMetaCache loads the metadata cache from the user cache
+// directory. If the cache doesn't exist or can't be read, it returns
+// an empty cache.
+func openMetaCache(cacheDir string) *metaCache {
+	c := &metaCache{path: filepath.Join(cacheDir, "buildstats", "revmeta.json")}
+	b, err := os.ReadFile(c.path)
+	if err == nil {
+		err = json.Unmarshal(b, c)
+	}
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		log.Printf("ignoring bad metadata cache %s: %s", c.path, err)
+	}
+	if err != nil || c.Version != metaCacheVersion {
+		c.Version = metaCacheVersion
+		c.Revs = nil
+	}
+	if c.Revs == nil {
+		c.Revs = make(map[string]*cachedMeta)
+	}
+	return c
+}
+
+// readMeta returns the metadata of the revision in revPath, using the
+// cache if it's up to date.
+func (c *metaCache) readMeta(revPath string) revMeta {
+	revTime, buildersTime := modTime(filepath.Join(revPath, ".rev.json")), modTime(filepath.Join(revPath, ".builders.json"))
+	name := filepath.Base(revPath)
+	if cm, ok := c.Revs[name]; ok && cm.RevTime.Equal(revTime) && cm.BuildersTime.Equal(buildersTime) {
+		return cm.Meta
+	}
+	meta := readMeta(revPath)
+	c.Revs[name] = &cachedMeta{revTime, buildersTime, meta}
+	c.dirty = true
+	return meta
+}
+
+func modTime(path string) time.Time {
+	fi, err := os.Stat(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return fi.ModTime()
+}
+
+// prune removes cached revisions that are not in names.
+func (c *metaCache) prune(names map[string]bool) {
+	for name := range c.Revs {
+		if !names[name] {
+			delete(c.Revs, name)
+			c.dirty = true
+		}
+	}
+}
+
+// save writes the cache back to disk if it changed. Failing to save
+// the cache isn't fatal.
+func (c *metaCache) save() {
+	if !c.dirty {
+		return
+	}
+	b, err := json.Marshal(c)
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(c.path), 0777)
+	}
+	if err == nil {
+		err = os.WriteFile(c.path+".tmp", b, 0666)
+	}
+	if err == nil {
+		err = os.Rename(c.path+".tmp", c.path)
+	}
+	if err != nil {
+		log.Printf("saving metadata cache: %s", err)
+		return
+	}
+	c.dirty = false
+}
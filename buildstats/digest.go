@@ -0,0 +1,114 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"time"
+)
+
+// printDigest writes a Markdown summary of the period ending at end
+// to w. It lists the top worst builders in that period and the top
+// builders whose failure rate changed the most from the previous
+// period of the same length. If there are any alerts or builders
+// below the SLO, it lists those first. If owners is non-nil, it then
+// summarizes the failures in the period by owner.
+func printDigest(w io.Writer, revs []*rev, alerts []alert, misses []sloMiss, slo float64, owners *ownerMap, end time.Time, period time.Duration, top, hardRun int) {
+	start := end.Add(-period)
+	cur := newGrid(FilterInPlace(append([]*rev(nil), revs...), func(r *rev) bool {
+		return !r.date.Before(start)
+	}))
+	prev := newGrid(FilterInPlace(append([]*rev(nil), revs...), func(r *rev) bool {
+		return r.date.Before(start) && !r.date.Before(start.Add(-period))
+	}))
+	for _, g := range []*grid{cur, prev} {
+		for _, rev := range g.revs {
+			rangeBuildResults(rev, func(label string, res result) {
+				g.add(label, rev, res)
+			})
+		}
+	}
+
+	fmt.Fprintf(w, "# Go builder digest, %s to %s\n\n", start.Format(rfc3339Date), end.Format(rfc3339Date))
+	fmt.Fprintf(w, "%d revisions this period, %d in the previous period.\n\n", len(cur.revs), len(prev.revs))
+	printAlertsMarkdown(w, alerts)
+	printSLOMarkdown(w, misses, slo)
+	if owners != nil {
+		printOwnersMarkdown(w, ownerSummary(cur, owners, hardRun))
+	}
+
+	// Worst builders this period.
+	fmt.Fprintf(w, "## Worst builders\n\n")
+	fmt.Fprintf(w, "| Builder | Failures | Flakes | Hard | Previous |\n")
+	fmt.Fprintf(w, "|---|--:|--:|--:|--:|\n")
+	n := 0
+	for _, label := range cur.sortedLabels() {
+		sum := cur.labels[label]
+		if sum.total == 0 || sum.fails == 0 {
+			continue
+		}
+		if n++; n > top {
+			break
+		}
+		hard := 0
+		for _, r := range markHardFailures(cur.labelResults(label), hardRun) {
+			if r == resHardFail {
+				hard++
+			}
+		}
+		fmt.Fprintf(w, "| %s | %.1f%% (%d/%d) | %d | %d | %s |\n", label, 100*sum.failureRate(), sum.fails, sum.total, sum.fails-hard, hard, digestRate(prev.labels[label]))
+	}
+	if n == 0 {
+		fmt.Fprintf(w, "No failures.\n")
+	}
+
+	// Biggest changes from the previous period.
+	type change struct {
+		label     string
+		cur, prev sum
+		delta     float64
+	}
+	var changes []change
+	for label, c := range cur.labels {
+		p, ok := prev.labels[label]
+		if c.total == 0 || !ok || p.total == 0 {
+			continue
+		}
+		delta := c.failureRate() - p.failureRate()
+		if delta == 0 {
+			continue
+		}
+		changes = append(changes, change{label, c, p, delta})
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		if a, b := math.Abs(changes[i].delta), math.Abs(changes[j].delta); a != b {
+			return a > b
+		}
+		return changes[i].label < changes[j].label
+	})
+	if len(changes) > top {
+		changes = changes[:top]
+	}
+	fmt.Fprintf(w, "\n## Biggest changes\n\n")
+	if len(changes) == 0 {
+		fmt.Fprintf(w, "No changes.\n")
+		return
+	}
+	fmt.Fprintf(w, "| Builder | Previous | Current | Change |\n")
+	fmt.Fprintf(w, "|---|--:|--:|--:|\n")
+	for _, c := range changes {
+		fmt.Fprintf(w, "| %s | %s | %s | %+.1f%% |\n", c.label, digestRate(c.prev), digestRate(c.cur), 100*c.delta)
+	}
+}
+
+func digestRate(s sum) string {
+	if s.total == 0 {
+		return "—"
+	}
+	return fmt.Sprintf("%.1f%%", 100*s.failureRate())
+}
@@ -0,0 +1,85 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// groupByFuncs maps each -groupby mode to a function that returns the
+// group of a builder name. Builder names have the form
+// GOOS-GOARCH[-variant].
+var groupByFuncs = map[string]func(builder string) string{
+	"goos": func(builder string) string {
+		goos, _, _ := strings.Cut(builder, "-")
+		return goos
+	},
+	"goarch": func(builder string) string {
+		parts := strings.SplitN(builder, "-", 3)
+		if len(parts) < 2 {
+			return builder
+		}
+		return parts[1]
+	},
+	"family": func(builder string) string {
+		parts := strings.SplitN(builder, "-", 3)
+		if len(parts) < 2 {
+			return builder
+		}
+		return parts[0] + "-" + parts[1]
+	},
+}
+
+func groupByModes() string {
+	return "goos, goarch, or family"
+}
+
+func groupByFunc(mode string) (func(builder string) string, error) {
+	f, ok := groupByFuncs[mode]
+	if !ok {
+		return nil, fmt.Errorf("unknown -groupby mode %q; must be %s", mode, groupByModes())
+	}
+	return f, nil
+}
+
+// groupGrid rolls up the labels of g into groups. It returns a grid
+// indexed by group and the labels in each group, in g.sortedLabels
+// order.
+//
+// A group's result for a revision is a failure if any builder in the
+// group failed, and a group's sum is the total of its builders' sums,
+// so its failure rate is the failure rate across all of its builds.
+func groupGrid(g *grid, group func(label string) string) (*grid, map[string][]string) {
+	gg := newGrid(g.revs)
+	members := make(map[string][]string)
+	for _, label := range g.sortedLabels() {
+		name := group(label)
+		members[name] = append(members[name], label)
+
+		s, ls := gg.labels[name], g.labels[label]
+		s.fails += ls.fails
+		s.total += ls.total
+		gg.labels[name] = s
+
+		for _, rev := range g.revs {
+			k := gridKey{name, rev}
+			gg.results[k] = mergeResults(gg.results[k], g.results[gridKey{label, rev}])
+		}
+	}
+	return gg, members
+}
+
+// mergeResults combines the results of two builders at the same
+// revision.
+func mergeResults(a, b result) result {
+	if a == resFail || b == resFail {
+		return resFail
+	}
+	if a == resOK || b == resOK {
+		return resOK
+	}
+	return resNone
+}
@@ -13,8 +13,11 @@ import (
 	"image"
 	"image/color"
 	"image/png"
+	"io"
 	"log"
+	"os"
 	"sort"
+	"time"
 )
 
 var since timeFlag
@@ -25,6 +28,7 @@ const (
 	resNone result = iota
 	resOK
 	resFail
+	resHardFail // A resFail that is part of a long run of failures
 )
 
 func resultFromString(s string) result {
@@ -103,7 +107,7 @@ func (s *sum) add(r result) {
 		return
 	}
 	s.total++
-	if r == resFail {
+	if r == resFail || r == resHardFail {
 		s.fails++
 	}
 }
@@ -122,6 +126,40 @@ func (s sum) less(s2 sum) bool {
 	return s.total < s2.total
 }
 
+// markHardFailures returns a copy of results in which every failure
+// that is part of a run of at least minRun consecutive failures is
+// changed to resHardFail. Missing results do not break a run. This
+// separates builders that were broken for an extended period from
+// isolated flakes.
+func markHardFailures(results []result, minRun int) []result {
+	out := append([]result(nil), results...)
+	start, run := 0, 0
+	flush := func(end int) {
+		if run < minRun {
+			return
+		}
+		for i := start; i < end; i++ {
+			if out[i] == resFail {
+				out[i] = resHardFail
+			}
+		}
+	}
+	for i, r := range results {
+		switch r {
+		case resFail:
+			if run == 0 {
+				start = i
+			}
+			run++
+		case resOK:
+			flush(i)
+			run = 0
+		}
+	}
+	flush(len(results))
+	return out
+}
+
 func rangeBuildResults(rev *rev, cb func(builder string, res result)) {
 	for i, builder := range rev.Builders {
 		cb(builder, resultFromString(rev.Results[i]))
@@ -130,10 +168,50 @@ func rangeBuildResults(rev *rev, cb func(builder string, res result)) {
 
 func main() {
 	flag.Var(&since, "since", "list only failures on revisions since this date, as an RFC-3339 date or date-time")
+	flagHardRun := flag.Int("hard-run", 3, "treat runs of at least `n` consecutive failures on a builder as hard failures rather than flakes")
+	flagDigest := flag.Bool("digest", false, "print a Markdown digest of the worst builders and biggest changes instead of a table")
+	flagPeriod := flag.Duration("period", 7*24*time.Hour, "summarize the `duration` up to now in -digest mode and compare with the duration before that")
+	flagTop := flag.Int("top", 10, "list at most `n` builders in each section in -digest mode")
+	flagGroupBy := flag.String("groupby", "", "roll up builders by `mode`, one of "+groupByModes())
+	flagAlertWindow := flag.Int("alert-window", 20, "alert on builders whose failure rate in the last `n` revisions spiked above their baseline, or 0 to disable")
+	flagAlertFactor := flag.Float64("alert-factor", 3, "alert when the recent failure rate is at least `factor` times the baseline")
+	flagSLO := flag.Float64("slo", 0, "list builders whose pass rate is below `percent`, or 0 to disable")
+	flagOut := flag.String("o", "", "write a static site with an index page and a page per builder to `dir` instead of printing HTML")
+	flagOwners := flag.String("owners", "", "summarize failures by owner, using the builder and test owners in `file`")
 	flag.Parse()
+	if *flagSLO < 0 || *flagSLO > 100 {
+		log.Fatal("-slo must be a percentage between 0 and 100")
+	}
+	slo := *flagSLO / 100
+
+	var owners *ownerMap
+	if *flagOwners != "" {
+		var err error
+		owners, err = readOwners(*flagOwners)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	var groupBy func(string) string
+	if *flagGroupBy != "" {
+		var err error
+		groupBy, err = groupByFunc(*flagGroupBy)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	now := time.Now()
+	if *flagDigest && since.Time.IsZero() {
+		since.Time = now.Add(-2 * *flagPeriod)
+	}
 
 	revs := getRevs(since.Time)
 	revs = FilterInPlace(revs, func(r *rev) bool { return r.Repo == "go" })
+	if len(revs) == 0 {
+		log.Fatal("no revisions found")
+	}
 
 	g := newGrid(revs)
 	for _, rev := range revs {
@@ -141,23 +219,110 @@ func main() {
 			g.add(label, rev, res)
 		})
 	}
+	alerts := findAlerts(g, *flagAlertWindow, *flagAlertFactor)
+	var misses []sloMiss
+	if slo > 0 {
+		misses = findSLOMisses(g, slo)
+	}
 
-	fmt.Printf("<!DOCTYPE html>\n")
-	fmt.Printf("<html><body>\n")
-	fmt.Printf("<table>\n")
-	fmt.Printf(`<tr><td>builder</td><td>failures</td><td>%s</td><td align="right">%s</td></tr>`, revs[0].date.Format(rfc3339Date), revs[len(revs)-1].date.Format(rfc3339Date))
+	if *flagDigest {
+		printDigest(os.Stdout, revs, alerts, misses, slo, owners, now, *flagPeriod, *flagTop, *flagHardRun)
+		return
+	}
+
+	var byOwner []*ownerStats
+	if owners != nil {
+		byOwner = ownerSummary(g, owners, *flagHardRun)
+	}
+	if *flagOut != "" {
+		if err := writeSite(*flagOut, g, alerts, misses, slo, byOwner, *flagHardRun, groupBy); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	printHTML(os.Stdout, g, alerts, misses, slo, byOwner, *flagHardRun, groupBy, nil)
+}
+
+// printHTML writes an HTML page summarizing every builder in g to w.
+// If builderURL is non-nil, builder names link to the URLs it
+// returns.
+func printHTML(w io.Writer, g *grid, alerts []alert, misses []sloMiss, slo float64, owners []*ownerStats, hardRun int, groupBy func(string) string, builderURL func(label string) string) {
+	revs := g.revs
+	fmt.Fprintf(w, "<!DOCTYPE html>\n")
+	fmt.Fprintf(w, "<html><body>\n")
+	printAlertsHTML(w, alerts)
+	printSLOHTML(w, misses, slo)
+	printOwnersHTML(w, owners)
+	if groupBy != nil {
+		fmt.Fprintf(w, "<style>tbody.group > tr { cursor: pointer; } tbody.group > tr > td:first-child::before { content: \"+ \"; }</style>\n")
+	}
+	fmt.Fprintf(w, "<table>\n")
+	fmt.Fprintf(w, `<tr><td>builder</td><td>failures</td><td>flakes</td><td>hard</td><td>%s</td><td align="right">%s</td></tr>`, revs[0].date.Format(rfc3339Date), revs[len(revs)-1].date.Format(rfc3339Date))
+
+	name := func(label string) string {
+		if builderURL == nil {
+			return html.EscapeString(label)
+		}
+		return fmt.Sprintf(`<a href="%s">%s</a>`, html.EscapeString(builderURL(label)), html.EscapeString(label))
+	}
+	if groupBy == nil {
+		for _, label := range g.sortedLabels() {
+			results, hard := hardFailures(g, label, hardRun)
+			fmt.Fprint(w, row(name(label), g.labels[label], hard, resultsImgHTML(results, g.revs, label)))
+		}
+	} else {
+		// Print a roll-up row for each group. Clicking it
+		// toggles the rows of the builders in the group.
+		// Hard failures are counted per builder, like the
+		// group's failures.
+		gg, members := groupGrid(g, groupBy)
+		for _, group := range gg.sortedLabels() {
+			var rows bytes.Buffer
+			groupHard := 0
+			for _, label := range members[group] {
+				results, hard := hardFailures(g, label, hardRun)
+				groupHard += hard
+				rows.WriteString(row(name(label), g.labels[label], hard, resultsImgHTML(results, g.revs, label)))
+			}
+			results, _ := hardFailures(gg, group, hardRun)
+			fmt.Fprintf(w, `<tbody class="group" onclick="var m = this.nextElementSibling; m.hidden = !m.hidden">`)
+			fmt.Fprint(w, row(html.EscapeString(group), gg.labels[group], groupHard, resultsImgHTML(results, gg.revs, "")))
+			fmt.Fprintf(w, "</tbody>\n<tbody hidden>%s</tbody>\n", rows.Bytes())
+		}
+	}
+
+	fmt.Fprintf(w, "</table>\n")
+	printRevScript(w, revs)
+	fmt.Fprintf(w, "</body></html>\n")
+}
 
-	labels := g.sortedLabels()
-	for _, label := range labels {
-		results := g.labelResults(label)
-		sum := g.labels[label]
-		fmt.Printf(`<tr><td>%s</td><td>%6.2f%% (%d/%d)</td><td colspan="2"><img src="%s" /></td></tr>`, html.EscapeString(label), 100*sum.failureRate(), sum.fails, sum.total, pngURI(makeResults(results)))
+// hardFailures returns the results for label in g with hard failures
+// marked, and the number of hard failures.
+func hardFailures(g *grid, label string, hardRun int) ([]result, int) {
+	results := markHardFailures(g.labelResults(label), hardRun)
+	hard := 0
+	for _, r := range results {
+		if r == resHardFail {
+			hard++
+		}
 	}
+	return results, hard
+}
 
-	fmt.Printf("</table>\n")
-	fmt.Printf("</body></html>\n")
+// row returns an HTML table row summarizing a builder or group.
+// labelHTML is the label of the row and imgHTML is its results image,
+// both already HTML.
+func row(labelHTML string, sum sum, hard int, imgHTML string) string {
+	return fmt.Sprintf(`<tr><td>%s</td><td>%6.2f%% (%d/%d)</td><td>%d</td><td>%d</td><td colspan="2">%s</td></tr>`, labelHTML, 100*sum.failureRate(), sum.fails, sum.total, sum.fails-hard, hard, imgHTML)
 }
 
+// Layout of the results image. Result i is the square at column
+// i/resultRows, row i%resultRows.
+const (
+	resultPx   = 3 // Size in pixels of a result
+	resultRows = 6 // Height in results
+)
+
 func makeResults(results []result) image.Image {
 	// TODO: Hilbert curve?
 
@@ -165,10 +330,10 @@ func makeResults(results []result) image.Image {
 		colorNone = color.NRGBA{200, 200, 200, 255}
 		colorOK   = color.NRGBA{220, 255, 220, 255}
 		colorFail = color.NRGBA{200, 50, 50, 255}
+		colorHard = color.NRGBA{90, 20, 90, 255}
 	)
 
-	const px = 3 // Size in pixels of a result
-	const h = 6  // Height in results
+	const px, h = resultPx, resultRows
 	w := (len(results) + h - 1) / h
 	img := image.NewNRGBA(image.Rect(0, 0, w*px, h*px))
 	for i, r := range results {
@@ -180,6 +345,8 @@ func makeResults(results []result) image.Image {
 			c = colorOK
 		case resFail:
 			c = colorFail
+		case resHardFail:
+			c = colorHard
 		}
 
 		for dx := 0; dx < px; dx++ {
@@ -0,0 +1,271 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"html"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// ownerTopN is the number of most-failing builders and tests listed
+// for each owner.
+const ownerTopN = 3
+
+// unowned is the owner of failures that match no rule.
+const unowned = "(unowned)"
+
+// An ownerMap maps builders and tests to their owners. It's read from
+// a file in which each line has the form
+//
+//	builder <prefix> <owner>
+//	test <prefix> <owner>
+//
+// A failure is owned by the owners of the failing tests in its log.
+// If no failing test has an owner, it's owned by the owner of the
+// builder. In both cases, the longest matching prefix wins. Blank
+// lines and lines starting with # are ignored.
+type ownerMap struct {
+	builders, tests []ownerRule
+}
+
+type ownerRule struct {
+	prefix, owner string
+}
+
+// readOwners reads an ownerMap from path.
+func readOwners(path string) (*ownerMap, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	m := new(ownerMap)
+	scanner := bufio.NewScanner(f)
+	for lineno := 1; scanner.Scan(); lineno++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fs := strings.Fields(line)
+		if len(fs) != 3 {
+			return nil, fmt.Errorf("%s:%d: want \"builder|test prefix owner\"", path, lineno)
+		}
+		rule := ownerRule{fs[1], fs[2]}
+		switch fs[0] {
+		case "builder":
+			m.builders = append(m.builders, rule)
+		case "test":
+			m.tests = append(m.tests, rule)
+		default:
+			return nil, fmt.Errorf("%s:%d: unknown rule kind %q", path, lineno, fs[0])
+		}
+	}
+	return m, scanner.Err()
+}
+
+// lookup returns the owner of the longest prefix in rules that
+// matches name, or "" if none match.
+func lookup(rules []ownerRule, name string) string {
+	owner, n := "", -1
+	for _, r := range rules {
+		if strings.HasPrefix(name, r.prefix) && len(r.prefix) > n {
+			owner, n = r.owner, len(r.prefix)
+		}
+	}
+	return owner
+}
+
+// ownerStats summarizes the failures owned by one owner.
+type ownerStats struct {
+	owner string
+
+	// fails and hard count the failed builds owned by this owner.
+	// A build whose failing tests have several owners counts for
+	// each of them.
+	fails, hard int
+
+	// builders is the total of the results of the builders this
+	// owner owns, regardless of who owns their failures.
+	builders sum
+
+	// byBuilder and byTest count the owned failures on each
+	// builder and of each test.
+	byBuilder, byTest map[string]int
+}
+
+var failedTestRe = regexp.MustCompile(`(?m)^\s*--- FAIL: (\S+)`)
+
+// failedTests returns the names of the top-level tests that failed in
+// label's log at r, or nil if the log can't be read.
+func failedTests(r *rev, label string) []string {
+	data, err := r.readLog(label)
+	if err != nil {
+		return nil
+	}
+	var tests []string
+	seen := make(map[string]bool)
+	for _, m := range failedTestRe.FindAllSubmatch(data, -1) {
+		// Attribute subtest failures to the top-level test.
+		name, _, _ := strings.Cut(string(m[1]), "/")
+		if !seen[name] {
+			seen[name] = true
+			tests = append(tests, name)
+		}
+	}
+	return tests
+}
+
+// ownerSummary attributes the failures in g to the owners in m and
+// returns the owners, most failures first. Owners with rules but no
+// failures are included, and failures with no owner are attributed to
+// unowned, which is always listed last.
+func ownerSummary(g *grid, m *ownerMap, hardRun int) []*ownerStats {
+	stats := make(map[string]*ownerStats)
+	get := func(owner string) *ownerStats {
+		s := stats[owner]
+		if s == nil {
+			s = &ownerStats{owner: owner, byBuilder: make(map[string]int), byTest: make(map[string]int)}
+			stats[owner] = s
+		}
+		return s
+	}
+	for _, rules := range [][]ownerRule{m.builders, m.tests} {
+		for _, r := range rules {
+			get(r.owner)
+		}
+	}
+
+	for label, lsum := range g.labels {
+		builderOwner := lookup(m.builders, label)
+		if builderOwner != "" {
+			s := get(builderOwner)
+			s.builders.fails += lsum.fails
+			s.builders.total += lsum.total
+		}
+		results := markHardFailures(g.labelResults(label), hardRun)
+		for i, res := range results {
+			if res != resFail && res != resHardFail {
+				continue
+			}
+			// Find the owners of the failing tests.
+			owners := make(map[string][]string)
+			if len(m.tests) > 0 {
+				for _, test := range failedTests(g.revs[i], label) {
+					if owner := lookup(m.tests, test); owner != "" {
+						owners[owner] = append(owners[owner], test)
+					}
+				}
+			}
+			if len(owners) == 0 {
+				owner := builderOwner
+				if owner == "" {
+					owner = unowned
+				}
+				owners[owner] = nil
+			}
+			for owner, tests := range owners {
+				s := get(owner)
+				s.fails++
+				if res == resHardFail {
+					s.hard++
+				}
+				s.byBuilder[label]++
+				for _, test := range tests {
+					s.byTest[test]++
+				}
+			}
+		}
+	}
+
+	var out []*ownerStats
+	for _, s := range stats {
+		out = append(out, s)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		a, b := out[i], out[j]
+		if (a.owner == unowned) != (b.owner == unowned) {
+			return b.owner == unowned
+		}
+		if a.fails != b.fails {
+			return a.fails > b.fails
+		}
+		return a.owner < b.owner
+	})
+	return out
+}
+
+// top returns the ownerTopN most-failing builders and tests of s as
+// "name (count)" strings.
+func (s *ownerStats) top() []string {
+	type count struct {
+		name string
+		n    int
+	}
+	var counts []count
+	for _, m := range []map[string]int{s.byTest, s.byBuilder} {
+		for name, n := range m {
+			counts = append(counts, count{name, n})
+		}
+	}
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].n != counts[j].n {
+			return counts[i].n > counts[j].n
+		}
+		return counts[i].name < counts[j].name
+	})
+	var out []string
+	for i, c := range counts {
+		if i == ownerTopN {
+			break
+		}
+		out = append(out, fmt.Sprintf("%s (%d)", c.name, c.n))
+	}
+	return out
+}
+
+// builderRate returns the failure rate of the builders s owns, or
+// "—" if it owns none.
+func (s *ownerStats) builderRate() string {
+	if s.builders.total == 0 {
+		return "—"
+	}
+	return fmt.Sprintf("%.1f%% (%d/%d)", 100*s.builders.failureRate(), s.builders.fails, s.builders.total)
+}
+
+// printOwnersHTML writes the summary of each owner in stats as an
+// HTML table to w.
+func printOwnersHTML(w io.Writer, stats []*ownerStats) {
+	if len(stats) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "<h2>Failures by owner</h2>\n<table>\n")
+	fmt.Fprintf(w, "<tr><td>owner</td><td>failures</td><td>flakes</td><td>hard</td><td>owned builders</td><td>top failures</td></tr>\n")
+	for _, s := range stats {
+		fmt.Fprintf(w, "<tr><td>%s</td><td>%d</td><td>%d</td><td>%d</td><td>%s</td><td>%s</td></tr>\n", html.EscapeString(s.owner), s.fails, s.fails-s.hard, s.hard, s.builderRate(), html.EscapeString(strings.Join(s.top(), ", ")))
+	}
+	fmt.Fprintf(w, "</table>\n")
+}
+
+// printOwnersMarkdown writes the summary of each owner in stats as a
+// Markdown table to w.
+func printOwnersMarkdown(w io.Writer, stats []*ownerStats) {
+	if len(stats) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "## Failures by owner\n\n")
+	fmt.Fprintf(w, "| Owner | Failures | Flakes | Hard | Owned builders | Top failures |\n")
+	fmt.Fprintf(w, "|---|--:|--:|--:|--:|---|\n")
+	for _, s := range stats {
+		fmt.Fprintf(w, "| %s | %d | %d | %d | %s | %s |\n", s.owner, s.fails, s.fails-s.hard, s.hard, s.builderRate(), strings.Join(s.top(), ", "))
+	}
+	fmt.Fprintf(w, "\n")
+}
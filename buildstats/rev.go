@@ -12,6 +12,7 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"strings"
 	"time"
 )
 
@@ -24,6 +25,9 @@ type rev struct {
 
 var pathDateRe = regexp.MustCompile(`^(\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2})-[0-9a-f]+$`)
 
+// getRevs returns the revisions fetched by fetchlogs since the given
+// time. Revision metadata is cached across runs, so this only reads
+// revisions that are new or updated since the last run.
 func getRevs(since time.Time) []*rev {
 	cacheDir, err := os.UserCacheDir()
 	if err != nil {
@@ -37,6 +41,7 @@ func getRevs(since time.Time) []*rev {
 
 	// Filter the paths down without additional I/O.
 	var revs []*rev
+	names := make(map[string]bool)
 	for _, dir := range dirs {
 		if !dir.IsDir() {
 			continue
@@ -46,6 +51,7 @@ func getRevs(since time.Time) []*rev {
 		if m == nil {
 			continue
 		}
+		names[name] = true
 		t, err := time.Parse(rfc3339DateTime, m[1])
 		if err != nil {
 			continue
@@ -62,11 +68,16 @@ func getRevs(since time.Time) []*rev {
 	}
 
 	// Load revision metadata.
+	cache := openMetaCache(cacheDir)
 	for i, rev := range revs {
 		fmt.Fprintf(os.Stderr, "\rLoading rev %d/%d...", i+1, len(revs))
-		rev.revMeta = readMeta(rev.path)
+		rev.revMeta = cache.readMeta(rev.path)
 	}
 	fmt.Fprintf(os.Stderr, "\n")
+	// Drop revisions fetchlogs no longer has, but keep ones
+	// outside this run's date range for future runs.
+	cache.prune(names)
+	cache.save()
 
 	return revs
 }
@@ -75,8 +86,16 @@ func (r *rev) String() string {
 	return r.path
 }
 
+// commit returns the full commit hash of r.
+func (r *rev) commit() string {
+	name := filepath.Base(r.path)
+	return name[strings.LastIndex(name, "-")+1:]
+}
+
 type revMeta struct {
 	Repo     string   `json:"repo"`
+	Author   string   `json:"author"` // "Name <email>"
+	Desc     string   `json:"desc"`   // Only the subject line is kept
 	Builders []string `json:""`
 	Results  []string `json:"results"`
 }
@@ -92,6 +111,10 @@ func readMeta(revPath string) revMeta {
 	if err = json.Unmarshal(b, &meta); err != nil {
 		log.Fatalf("decoding %s: %s", path, err)
 	}
+	// Keep the cache small.
+	if i := strings.IndexByte(meta.Desc, '\n'); i >= 0 {
+		meta.Desc = meta.Desc[:i]
+	}
 
 	path = filepath.Join(revPath, ".builders.json")
 	b, err = ioutil.ReadFile(path)
@@ -105,6 +128,15 @@ func readMeta(revPath string) revMeta {
 	return meta
 }
 
+// authorName returns the name of r's author, without the email
+// address.
+func (r *rev) authorName() string {
+	if i := strings.Index(r.Author, " <"); i >= 0 {
+		return r.Author[:i]
+	}
+	return r.Author
+}
+
 func (r *rev) getLogPath(builder string) (string, error) {
 	p := filepath.Join(r.path, builder)
 	target, err := os.Readlink(p)
@@ -0,0 +1,116 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"log"
+	"path/filepath"
+	"strings"
+)
+
+// revInfo is the metadata of a revision shown by results image
+// tooltips.
+type revInfo struct {
+	Commit  string `json:"c"`
+	Hash    string `json:"h"`
+	Date    string `json:"d"`
+	Author  string `json:"a"`
+	Subject string `json:"s"`
+}
+
+// resultChars encodes results in the data-results attribute of a
+// results image.
+var resultChars = [...]byte{resNone: '-', resOK: 'o', resFail: 'f', resHardFail: 'h'}
+
+// resultsImgHTML returns an HTML results image for results, which are
+// indexed like the revisions passed to printRevScript. If label is a
+// builder, clicking a failure opens its log, and clicking any other
+// result opens the commit. Group rows pass "" and aren't clickable,
+// since clicking them expands the group.
+func resultsImgHTML(results []result, revs []*rev, label string) string {
+	var buf strings.Builder
+	codes := make([]byte, len(results))
+	for i, res := range results {
+		codes[i] = resultChars[res]
+	}
+	fmt.Fprintf(&buf, `<img class="results" src="%s" data-results="%s"`, pngURI(makeResults(results)), codes)
+	if label != "" {
+		// Map the index of each failure to its log.
+		logs := make(map[int]string)
+		for i, res := range results {
+			if res != resFail && res != resHardFail {
+				continue
+			}
+			if path, err := revs[i].getLogPath(label); err == nil {
+				logs[i] = filepath.Base(path)
+			}
+		}
+		js, err := json.Marshal(logs)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Fprintf(&buf, ` data-logs="%s"`, html.EscapeString(string(js)))
+	}
+	buf.WriteString(" />")
+	return buf.String()
+}
+
+// printRevScript writes a script to w that shows the revision under
+// the mouse in results images as a tooltip and follows clicks on them.
+// Pages share the revision metadata, so results images only record
+// their results and logs.
+func printRevScript(w io.Writer, revs []*rev) {
+	infos := make([]revInfo, len(revs))
+	for i, r := range revs {
+		infos[i] = revInfo{r.commit(), r.hash(), r.date.Format(rfc3339DateTime), r.authorName(), r.Desc}
+	}
+	// json.Marshal escapes "<", so this can't end the script.
+	js, err := json.Marshal(infos)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Fprintf(w, "<style>img.results[data-logs] { cursor: pointer; }</style>\n")
+	fmt.Fprintf(w, "<script>\n")
+	fmt.Fprintf(w, "var revs = %s;\n", js)
+	fmt.Fprintf(w, "var resultPx = %d, resultRows = %d;\n", resultPx, resultRows)
+	fmt.Fprintf(w, "var commitURL = %q, logURL = %q;\n", commitURL, logURL)
+	fmt.Fprint(w, revScript)
+	fmt.Fprintf(w, "</script>\n")
+}
+
+const revScript = `
+var resultNames = {"-": "not run", "o": "ok", "f": "flake", "h": "hard failure"};
+
+// resultAt returns the result in a results image under mouse event e.
+function resultAt(e) {
+	var img = e.target;
+	if (!img.classList || !img.classList.contains("results")) return null;
+	var r = img.getBoundingClientRect();
+	var i = Math.floor((e.clientX - r.left) / resultPx) * resultRows + Math.floor((e.clientY - r.top) / resultPx);
+	if (i < 0 || i >= revs.length) return null;
+	if (img.dataset.logs !== undefined && !img.logs) img.logs = JSON.parse(img.dataset.logs);
+	return {img: img, rev: revs[i], res: img.dataset.results.charAt(i), log: img.logs && img.logs[i]};
+}
+
+document.addEventListener("mousemove", function(e) {
+	var c = resultAt(e);
+	if (!c) return;
+	var title = c.rev.h + " " + c.rev.d + " " + c.rev.a + "\n" + c.rev.s + "\n" + resultNames[c.res];
+	if (c.log) title += " (click for log, shift-click for commit)";
+	else if (c.img.logs) title += " (click for commit)";
+	c.img.title = title;
+});
+
+document.addEventListener("click", function(e) {
+	var c = resultAt(e);
+	if (!c || !c.img.logs) return;
+	if (c.log && !e.shiftKey) location.href = logURL + c.log;
+	else location.href = commitURL + c.rev.c;
+});
+`
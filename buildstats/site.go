@@ -0,0 +1,153 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"html"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// siteRecentFailures is the number of most recent failures
+	// listed at the top of each builder page.
+	siteRecentFailures = 20
+
+	commitURL = "https://go.googlesource.com/go/+/"
+	logURL    = "https://build.golang.org/log/"
+)
+
+// writeSite writes a static site summarizing g to dir. The site
+// consists of an index page, which is the same as the page buildstats
+// prints without -o, and a page for each builder with its full
+// history. Log links point to the build dashboard, so the site can be
+// published without the logs.
+func writeSite(dir string, g *grid, alerts []alert, misses []sloMiss, slo float64, owners []*ownerStats, hardRun int, groupBy func(string) string) error {
+	if err := os.MkdirAll(filepath.Join(dir, "builder"), 0777); err != nil {
+		return err
+	}
+	err := writeFile(filepath.Join(dir, "index.html"), func(w io.Writer) {
+		printHTML(w, g, alerts, misses, slo, owners, hardRun, groupBy, func(label string) string {
+			return "builder/" + url.PathEscape(builderPage(label))
+		})
+	})
+	if err != nil {
+		return err
+	}
+	for label := range g.labels {
+		err := writeFile(filepath.Join(dir, "builder", builderPage(label)), func(w io.Writer) {
+			printBuilderHTML(w, g, label, hardRun)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeFile creates path and writes its contents using write.
+func writeFile(path string, write func(w io.Writer)) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(f)
+	write(bw)
+	err = bw.Flush()
+	if err2 := f.Close(); err == nil {
+		err = err2
+	}
+	return err
+}
+
+// builderPage returns the file name of label's builder page.
+func builderPage(label string) string {
+	return strings.ReplaceAll(label, "/", "_") + ".html"
+}
+
+// printBuilderHTML writes an HTML page with the full history of
+// builder label in g to w.
+func printBuilderHTML(w io.Writer, g *grid, label string, hardRun int) {
+	results, hard := hardFailures(g, label, hardRun)
+	sum := g.labels[label]
+	name := html.EscapeString(label)
+
+	fmt.Fprintf(w, "<!DOCTYPE html>\n")
+	fmt.Fprintf(w, "<html><head><title>%s</title></head><body>\n", name)
+	fmt.Fprintf(w, "<p><a href=\"../index.html\">All builders</a></p>\n")
+	fmt.Fprintf(w, "<h1>%s</h1>\n", name)
+	fmt.Fprintf(w, "<p>%.2f%% failures (%d/%d), %d flakes, %d hard, from %s to %s</p>\n", 100*sum.failureRate(), sum.fails, sum.total, sum.fails-hard, hard, g.revs[0].date.Format(rfc3339Date), g.revs[len(g.revs)-1].date.Format(rfc3339Date))
+	fmt.Fprintf(w, "<p>%s</p>\n", resultsImgHTML(results, g.revs, label))
+
+	fmt.Fprintf(w, "<h2>Recent failures</h2>\n")
+	n := 0
+	for i := len(results) - 1; i >= 0 && n < siteRecentFailures; i-- {
+		if results[i] != resFail && results[i] != resHardFail {
+			continue
+		}
+		if n == 0 {
+			fmt.Fprintf(w, "<ul>\n")
+		}
+		n++
+		fmt.Fprintf(w, "<li>%s</li>\n", revHTML(g.revs[i], label, results[i]))
+	}
+	if n == 0 {
+		fmt.Fprintf(w, "<p>No failures.</p>\n")
+	} else {
+		fmt.Fprintf(w, "</ul>\n")
+	}
+
+	// List the full history, newest first, skipping revisions
+	// the builder didn't run.
+	fmt.Fprintf(w, "<h2>History</h2>\n<table>\n")
+	fmt.Fprintf(w, "<tr><td>date</td><td>revision</td><td>author</td><td>subject</td><td>result</td><td>log</td></tr>\n")
+	for i := len(results) - 1; i >= 0; i-- {
+		if results[i] == resNone {
+			continue
+		}
+		r := g.revs[i]
+		fmt.Fprintf(w, "<tr><td>%s</td><td>%s</td><td>%s</td><td>%s</td><td>%s</td><td>%s</td></tr>\n", r.date.Format(rfc3339DateTime), commitHTML(r), html.EscapeString(r.authorName()), html.EscapeString(r.Desc), resultName(results[i]), logHTML(r, label))
+	}
+	fmt.Fprintf(w, "</table>\n")
+	printRevScript(w, g.revs)
+	fmt.Fprintf(w, "</body></html>\n")
+}
+
+// revHTML returns an HTML summary of label's result at r.
+func revHTML(r *rev, label string, res result) string {
+	return fmt.Sprintf("%s %s %s %s %s", r.date.Format(rfc3339DateTime), commitHTML(r), html.EscapeString(r.Desc), resultName(res), logHTML(r, label))
+}
+
+func commitHTML(r *rev) string {
+	return fmt.Sprintf(`<a href="%s%s"><code>%s</code></a>`, commitURL, r.commit(), r.hash())
+}
+
+// logHTML returns a link to label's log at r, or "" if there's no
+// log.
+func logHTML(r *rev, label string) string {
+	path, err := r.getLogPath(label)
+	if err != nil {
+		return ""
+	}
+	// fetchlogs names logs by their dashboard log hash.
+	return fmt.Sprintf(`<a href="%s%s">log</a>`, logURL, url.PathEscape(filepath.Base(path)))
+}
+
+func resultName(r result) string {
+	switch r {
+	case resOK:
+		return "ok"
+	case resFail:
+		return "flake"
+	case resHardFail:
+		return "hard failure"
+	}
+	return ""
+}
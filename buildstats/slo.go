@@ -0,0 +1,111 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"math"
+	"sort"
+)
+
+// sloRecentWindow is the number of most recent revisions used to
+// estimate a builder's current pass rate when projecting how long it
+// will take to get back above the SLO.
+const sloRecentWindow = 20
+
+// An sloMiss reports a builder whose pass rate is below the SLO.
+type sloMiss struct {
+	label  string
+	sum    sum
+	recent sum
+
+	// need is the number of additional revisions the builder
+	// needs at its recent pass rate to get back to the SLO, or -1
+	// if it won't at that rate.
+	need int
+}
+
+// findSLOMisses returns the builders in g whose pass rate is below
+// target, which is a fraction between 0 and 1, worst first.
+func findSLOMisses(g *grid, target float64) []sloMiss {
+	var misses []sloMiss
+	for label, s := range g.labels {
+		if s.total == 0 || passRate(s) >= target {
+			continue
+		}
+		results := g.labelResults(label)
+		m := sloMiss{label: label, sum: s}
+		// Count the last sloRecentWindow revisions with results.
+		for i := len(results) - 1; i >= 0 && m.recent.total < sloRecentWindow; i-- {
+			m.recent.add(results[i])
+		}
+		m.need = sloRecovery(s, passRate(m.recent), target)
+		misses = append(misses, m)
+	}
+	sort.Slice(misses, func(i, j int) bool {
+		if misses[i].sum != misses[j].sum {
+			return !misses[i].sum.less(misses[j].sum)
+		}
+		return misses[i].label < misses[j].label
+	})
+	return misses
+}
+
+func passRate(s sum) float64 {
+	return 1 - s.failureRate()
+}
+
+// sloRecovery returns the number of additional revisions passing at
+// rate it takes for s to reach a pass rate of target, or -1 if rate
+// isn't high enough to ever get there.
+func sloRecovery(s sum, rate, target float64) int {
+	// Solve (passes + rate*n) / (total + n) >= target for n.
+	passes := float64(s.total - s.fails)
+	deficit := target*float64(s.total) - passes
+	if deficit <= 0 {
+		return 0
+	}
+	if rate <= target {
+		return -1
+	}
+	return int(math.Ceil(deficit / (rate - target)))
+}
+
+// recovery returns a description of how long m will take to recover.
+func (m sloMiss) recovery() string {
+	if m.need < 0 {
+		return "never at current rate"
+	}
+	return fmt.Sprintf("%d revisions", m.need)
+}
+
+// printSLOHTML writes the builders in misses as an HTML list to w.
+func printSLOHTML(w io.Writer, misses []sloMiss, target float64) {
+	if len(misses) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "<h2>Builders below %.1f%% pass rate</h2>\n<ul>\n", 100*target)
+	for _, m := range misses {
+		fmt.Fprintf(w, "<li><b>%s</b>: %.1f%% passing (%d/%d), %.1f%% in the last %d; recovers in %s</li>\n", html.EscapeString(m.label), 100*passRate(m.sum), m.sum.total-m.sum.fails, m.sum.total, 100*passRate(m.recent), m.recent.total, m.recovery())
+	}
+	fmt.Fprintf(w, "</ul>\n")
+}
+
+// printSLOMarkdown writes the builders in misses as a Markdown table
+// to w.
+func printSLOMarkdown(w io.Writer, misses []sloMiss, target float64) {
+	if len(misses) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "## Builders below %.1f%% pass rate\n\n", 100*target)
+	fmt.Fprintf(w, "| Builder | Pass rate | Recent | Recovers in |\n")
+	fmt.Fprintf(w, "|---|--:|--:|--:|\n")
+	for _, m := range misses {
+		fmt.Fprintf(w, "| %s | %.1f%% (%d/%d) | %.1f%% | %s |\n", m.label, 100*passRate(m.sum), m.sum.total-m.sum.fails, m.sum.total, 100*passRate(m.recent), m.recovery())
+	}
+	fmt.Fprintf(w, "\n")
+}
@@ -15,10 +15,11 @@ import (
 
 type compiler struct {
 	names map[string]queryNode
+	loc   *time.Location // Time zone of date literals
 }
 
-func newCompiler(names map[string]queryNode) *compiler {
-	return &compiler{names}
+func newCompiler(names map[string]queryNode, loc *time.Location) *compiler {
+	return &compiler{names, loc}
 }
 
 func (c *compiler) compile(expr string) (boolNode, error) {
@@ -155,6 +156,35 @@ func (c *compiler) sameType(ast *ast.BinaryExpr, x, y queryNode, typs ...string)
 	}
 }
 
+// dateLit returns a timeNode for the date literal lit, or panics
+// with a compileError if lit isn't a valid date.
+func (c *compiler) dateLit(lit *ast.BasicLit) timeNode {
+	t, err := parseDate(constant.StringVal(constant.MakeFromLiteral(lit.Value, lit.Kind, 0)), c.loc)
+	if err != nil {
+		c.bad(lit, "%s", err)
+	}
+	return timeNode(func(pi pathInfo) time.Time {
+		return t
+	})
+}
+
+// coerceTime converts n to a timeNode if it's a string literal, so
+// times can be compared directly with date strings. Otherwise, it
+// returns n.
+func (c *compiler) coerceTime(expr ast.Expr, n queryNode) queryNode {
+	for {
+		paren, ok := expr.(*ast.ParenExpr)
+		if !ok {
+			break
+		}
+		expr = paren.X
+	}
+	if lit, ok := expr.(*ast.BasicLit); ok && lit.Kind == token.STRING {
+		return c.dateLit(lit)
+	}
+	return n
+}
+
 // expr type-checks and compiles expr to a queryNode.
 func (c *compiler) expr(expr ast.Expr) queryNode {
 	switch expr := expr.(type) {
@@ -174,6 +204,9 @@ func (c *compiler) expr(expr ast.Expr) queryNode {
 
 	case *ast.BinaryExpr:
 		x, y := c.expr(expr.X), c.expr(expr.Y)
+		if n := c.timeArith(expr, x, y); n != nil {
+			return n
+		}
 		switch expr.Op {
 		case token.ADD:
 			c.sameType(expr, x, y, "number", "string")
@@ -208,6 +241,14 @@ func (c *compiler) expr(expr ast.Expr) queryNode {
 				return x(pi) || y(pi)
 			})
 
+		case token.LSS, token.GTR, token.LEQ, token.GEQ, token.EQL, token.NEQ:
+			if _, ok := x.(timeNode); ok {
+				y = c.coerceTime(expr.Y, y)
+			} else if _, ok := y.(timeNode); ok {
+				x = c.coerceTime(expr.X, x)
+			}
+		}
+		switch expr.Op {
 		case token.LSS, token.GTR, token.LEQ, token.GEQ:
 			c.sameType(expr, x, y, "number", "string", "time")
 			fallthrough
@@ -235,8 +276,14 @@ func (c *compiler) expr(expr ast.Expr) queryNode {
 		}
 		switch id.Name {
 		case "date":
-			// TODO: Parse date argument. Would be nice if
-			// we could constant-fold.
+			if len(expr.Args) != 1 {
+				c.bad(expr, "date takes one argument")
+			}
+			lit, ok := expr.Args[0].(*ast.BasicLit)
+			if !ok || lit.Kind != token.STRING {
+				c.bad(expr, "argument to date must be a string literal")
+			}
+			return c.dateLit(lit)
 		}
 		c.bad(expr, "undefined: %s", id.Name)
 
@@ -271,3 +318,38 @@ func (c *compiler) expr(expr ast.Expr) queryNode {
 	c.bad(expr, "unsupported expression %s", expr)
 	return nil
 }
+
+// timeArith compiles time arithmetic: a time plus or minus a number
+// of nanoseconds is a time, and the difference of two times is a
+// number. If expr isn't time arithmetic, it returns nil.
+func (c *compiler) timeArith(expr *ast.BinaryExpr, x, y queryNode) queryNode {
+	switch expr.Op {
+	case token.ADD:
+		// Addition is commutative.
+		yExpr := expr.Y
+		if _, ok := y.(timeNode); ok {
+			x, y, yExpr = y, x, expr.X
+		}
+		if x, ok := x.(timeNode); ok {
+			y := c.number(yExpr, y)
+			return timeNode(func(pi pathInfo) time.Time {
+				return x(pi).Add(duration(y(pi)))
+			})
+		}
+	case token.SUB:
+		x, ok := x.(timeNode)
+		if !ok {
+			return nil
+		}
+		if y, ok := y.(timeNode); ok {
+			return numberNode(func(pi pathInfo) constant.Value {
+				return constant.MakeInt64(int64(x(pi).Sub(y(pi))))
+			})
+		}
+		y := c.number(expr.Y, y)
+		return timeNode(func(pi pathInfo) time.Time {
+			return x(pi).Add(-duration(y(pi)))
+		})
+	}
+	return nil
+}
@@ -4,7 +4,12 @@
 
 package dashquery
 
-import "testing"
+import (
+	"testing"
+	"time"
+
+	"golang.org/x/build/types"
+)
 
 func TestEval(t *testing.T) {
 	try := func(expr string, want bool) {
@@ -40,3 +45,47 @@ func TestEval(t *testing.T) {
 	try(`+1 == 0+1`, true)
 	try(`!(1==1) == (1==2)`, true)
 }
+
+func TestEvalTime(t *testing.T) {
+	est := time.FixedZone("EST", -5*60*60)
+	pi := pathInfo{buildRevCache: &types.BuildRevision{Date: "2024-01-02T03:00:00Z"}}
+	try := func(opts CompileOptions, expr string, want bool) {
+		t.Helper()
+		q, err := CompileWith(expr, opts)
+		if err != nil {
+			t.Errorf("%s: unexpected compile error %s", expr, err)
+			return
+		}
+		if have := q.fn(pi); have != want {
+			t.Errorf("%s: want %v, have %v", expr, want, have)
+		}
+	}
+	utc := CompileOptions{}
+	try(utc, `time == date("2024-01-02T03:00:00Z")`, true)
+	try(utc, `time == date("2024-01-01T22:00:00-05:00")`, true)
+	try(utc, `time > "2024-01-02"`, true)
+	try(utc, `"2024-01-03" > time`, true)
+	try(utc, `time >= ("2024-01-02 03:00")`, true)
+	try(utc, `time < "2024-01-02 02:59:59"`, false)
+	try(utc, `time - date("2024-01-01") == 27*hours`, true)
+	try(utc, `time + 21*hours == date("2024-01-03")`, true)
+	try(utc, `1*day + time == date("2024-01-03T03:00:00Z")`, true)
+	try(utc, `time - 3*hours == "2024-01-02"`, true)
+	try(utc, `time < now`, true)
+	// Dates without a time zone are in opts.Location.
+	try(CompileOptions{Location: est}, `time < "2024-01-02"`, true)
+	try(CompileOptions{Location: est}, `time == "2024-01-01 22:00"`, true)
+
+	for _, expr := range []string{
+		`time > date("yesterday")`,
+		`time > "2024-13-01"`,
+		`time > date(1)`,
+		`time > 1`,
+		`time + time == time`,
+		`1 - time == time`,
+	} {
+		if _, err := Compile(expr); err == nil {
+			t.Errorf("%s: want compile error", expr)
+		}
+	}
+}
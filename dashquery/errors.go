@@ -0,0 +1,61 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dashquery
+
+import (
+	"fmt"
+	"strings"
+)
+
+// A CompileError is returned by Compile for a query that can't be
+// parsed or type-checked.
+type CompileError struct {
+	Expr string // The query expression
+	Err  error  // The parse or type error
+}
+
+func (e *CompileError) Error() string {
+	return fmt.Sprintf("compiling query %q: %s", e.Expr, e.Err)
+}
+
+func (e *CompileError) Unwrap() error {
+	return e.Err
+}
+
+// A PathError records an error evaluating a query for a log path,
+// such as a revision's metadata being missing or corrupt.
+type PathError struct {
+	Path string // The log path
+	Err  error  // The cause
+}
+
+func (e *PathError) Error() string {
+	return e.Path + ": " + e.Err.Error()
+}
+
+func (e *PathError) Unwrap() error {
+	return e.Err
+}
+
+// An ErrorList is returned by StreamPaths with
+// StreamOptions.KeepGoing if any paths or revision directories could
+// not be read. Each error is a *PathError, or an *fs.PathError for a
+// revision directory that couldn't be listed.
+type ErrorList []error
+
+func (l ErrorList) Error() string {
+	switch len(l) {
+	case 0:
+		return "no errors"
+	case 1:
+		return l[0].Error()
+	}
+	var buf strings.Builder
+	fmt.Fprintf(&buf, "%d errors:", len(l))
+	for _, err := range l {
+		fmt.Fprintf(&buf, "\n\t%s", err)
+	}
+	return buf.String()
+}
@@ -7,10 +7,10 @@ package dashquery
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"go/constant"
 	"io/ioutil"
-	"os"
 	"path/filepath"
 	"runtime"
 	"sort"
@@ -21,11 +21,45 @@ import (
 	"golang.org/x/sync/errgroup"
 )
 
+// Compile compiles a query expression. If expr is invalid, it returns
+// a *CompileError. Dates without a time zone are in UTC.
 func Compile(expr string) (*Query, error) {
-	c := newCompiler(builtins)
+	return CompileWith(expr, CompileOptions{})
+}
+
+// CompileOptions controls CompileWith.
+type CompileOptions struct {
+	// Location is the time zone of date literals and revision
+	// dates that don't specify one. If nil, it is UTC.
+	Location *time.Location
+}
+
+// CompileWith is like Compile, but with options.
+//
+// Queries may refer to the commit date of a revision as "time" and
+// compare it with date literals, which are either written as
+// date("2024-01-02") or as strings compared directly with a time,
+// such as time >= "2024-01-02T15:04:05Z". Date literals may be RFC
+// 3339 date-times, or dates with an optional time but no time zone.
+// A time plus or minus a number of nanoseconds, such as
+// now - 7*days, is a time, and the difference between two times is a
+// number of nanoseconds.
+func CompileWith(expr string, opts CompileOptions) (*Query, error) {
+	loc := opts.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	names := make(map[string]queryNode)
+	for name, node := range builtins {
+		names[name] = node
+	}
+	for name, node := range timeBuiltins(loc) {
+		names[name] = node
+	}
+	c := newCompiler(names, loc)
 	fn, err := c.compile(expr)
 	if err != nil {
-		return nil, err
+		return nil, &CompileError{expr, err}
 	}
 	return &Query{fn}, nil
 }
@@ -63,13 +97,7 @@ var builtins = map[string]queryNode{
 	"day":     constNum(int64(24 * time.Hour)),
 	"days":    constNum(int64(24 * time.Hour)),
 
-	"age": numberNode(func(pi pathInfo) constant.Value {
-		date, err := time.Parse(time.RFC3339, pi.buildRev().Date)
-		if err != nil {
-			return constant.MakeInt64(0)
-		}
-		return constant.MakeInt64(int64(startTime().Sub(date)))
-	}),
+	// "age", "time", and "now" are in timeBuiltins.
 
 	"builder": stringNode(func(pi pathInfo) string {
 		return pi.builder
@@ -93,7 +121,7 @@ var builtins = map[string]queryNode{
 		return pi.builder[i : i+i2]
 	}),
 
-	// TODO: From .rev.json: repo, revision, date, branch, author
+	// TODO: From .rev.json: repo, revision, branch, author
 	// TODO: File content matching
 }
 
@@ -105,24 +133,39 @@ type pathInfo struct {
 	builder       string
 	revPath       string
 	buildRevCache *types.BuildRevision
+
+	// err, if non-nil, points to the first error evaluating the
+	// query for this path.
+	err *error
 }
 
 func (pi *pathInfo) buildRev() *types.BuildRevision {
 	if pi.buildRevCache == nil {
 		pi.buildRevCache = new(types.BuildRevision)
-		data, err := ioutil.ReadFile(filepath.Join(pi.revPath, ".rev.json"))
-		if err != nil {
-			fmt.Fprintln(os.Stderr, err)
-		} else {
+		path := filepath.Join(pi.revPath, ".rev.json")
+		data, err := ioutil.ReadFile(path)
+		if err == nil {
 			err = json.Unmarshal(data, pi.buildRevCache)
 			if err != nil {
-				fmt.Fprintln(os.Stderr, err)
+				err = fmt.Errorf("decoding %s: %w", path, err)
 			}
 		}
+		if err != nil {
+			pi.fail(err)
+		}
 	}
 	return pi.buildRevCache
 }
 
+// fail records an error evaluating the query for pi. Evaluation
+// continues with zero values, but the path is reported as an error
+// rather than a match or non-match.
+func (pi *pathInfo) fail(err error) {
+	if pi.err != nil && *pi.err == nil {
+		*pi.err = &PathError{filepath.Join(pi.revPath, pi.builder), err}
+	}
+}
+
 func RevDir() string {
 	return filepath.Join(xdgCacheDir(), "fetchlogs", "rev")
 }
@@ -149,21 +192,67 @@ func revs() ([]string, error) {
 // to fn. Paths are returned in descending order by date (most recent
 // first).
 func (q *Query) AllPaths(fn func(string) error) error {
+	return q.StreamPaths(context.Background(), StreamOptions{}, fn)
+}
+
+// StreamOptions controls StreamPaths.
+type StreamOptions struct {
+	// Limit, if positive, stops the query after this many
+	// matches.
+	Limit int
+
+	// Progress, if non-nil, is called after each revision is
+	// scanned. It is called from the same goroutine as the match
+	// callback.
+	Progress func(Progress)
+
+	// KeepGoing, if true, skips paths that can't be evaluated and
+	// revision directories that can't be read, rather than
+	// stopping at the first error. StreamPaths then returns their
+	// errors as an ErrorList.
+	KeepGoing bool
+}
+
+// Progress reports how far a query has gotten.
+type Progress struct {
+	Revs      int // Revisions scanned so far
+	TotalRevs int // Total revisions to scan
+	Matches   int // Matches so far
+}
+
+// errLimit stops a query that has reached StreamOptions.Limit.
+var errLimit = errors.New("match limit reached")
+
+// StreamPaths is like AllPaths, but stops early if ctx is canceled or
+// after opts.Limit matches, and reports progress to opts.Progress. If
+// ctx is canceled, it returns ctx.Err().
+//
+// If the query can't be evaluated for a path, StreamPaths returns a
+// *PathError, unless opts.KeepGoing is set.
+func (q *Query) StreamPaths(ctx context.Context, opts StreamOptions, fn func(string) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	revs, err := revs()
 	if err != nil {
 		return err
 	}
 
+	type evalResult struct {
+		match bool
+		err   error
+	}
 	type task struct {
 		pi    pathInfo
-		reply chan bool
+		reply chan evalResult
 	}
 	nworkers := 2 * runtime.GOMAXPROCS(-1)
 	tasks := make(chan task)
 	replies := make(chan task, nworkers)
-	g, ctx := errgroup.WithContext(context.Background())
+	g, ctx := errgroup.WithContext(ctx)
 
-	// Feeder.
+	// Feeder. Each revision ends with a task with no builder so
+	// the aggregator can count scanned revisions.
 	g.Go(func() error {
 		defer close(tasks)
 		defer close(replies)
@@ -174,7 +263,16 @@ func (q *Query) AllPaths(fn func(string) error) error {
 
 			logs, err := ioutil.ReadDir(rev)
 			if err != nil {
-				return err
+				// Pass the error to the aggregator,
+				// which decides whether to keep going.
+				task := task{pathInfo{revPath: rev}, make(chan evalResult, 1)}
+				task.reply <- evalResult{err: err}
+				logs = nil
+				select {
+				case replies <- task:
+				case <-ctx.Done():
+					return ctx.Err()
+				}
 			}
 
 			for _, log := range logs {
@@ -183,13 +281,26 @@ func (q *Query) AllPaths(fn func(string) error) error {
 				}
 				pi.builder = log.Name()
 
-				task := task{pi, make(chan bool)}
+				// The reply channel is buffered so
+				// workers don't block if the
+				// aggregator stops early.
+				task := task{pi, make(chan evalResult, 1)}
 				select {
 				case tasks <- task:
-					replies <- task
 				case <-ctx.Done():
 					return ctx.Err()
 				}
+				select {
+				case replies <- task:
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}
+
+			select {
+			case replies <- task{pathInfo{revPath: rev}, nil}:
+			case <-ctx.Done():
+				return ctx.Err()
 			}
 		}
 		return nil
@@ -203,25 +314,62 @@ func (q *Query) AllPaths(fn func(string) error) error {
 				if !ok {
 					break
 				}
-				task.reply <- q.fn(task.pi)
+				var err error
+				pi := task.pi
+				pi.err = &err
+				match := q.fn(pi)
+				task.reply <- evalResult{match, err}
 			}
 			return nil
 		})
 	}
 
 	// Aggregator.
+	var errs ErrorList
 	g.Go(func() error {
+		progress := Progress{TotalRevs: len(revs)}
 		for reply := range replies {
-			if <-reply.reply {
+			if reply.reply == nil {
+				// End of revision.
+				progress.Revs++
+				if opts.Progress != nil {
+					opts.Progress(progress)
+				}
+				continue
+			}
+			var res evalResult
+			select {
+			case res = <-reply.reply:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			if res.err != nil {
+				if !opts.KeepGoing {
+					return res.err
+				}
+				errs = append(errs, res.err)
+				continue
+			}
+			if res.match {
 				pi := reply.pi
 				err := fn(filepath.Join(pi.revPath, pi.builder))
 				if err != nil {
 					return err
 				}
+				progress.Matches++
+				if opts.Limit > 0 && progress.Matches >= opts.Limit {
+					return errLimit
+				}
 			}
 		}
 		return nil
 	})
 
-	return g.Wait()
+	if err := g.Wait(); err != nil && err != errLimit {
+		return err
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
 }
@@ -0,0 +1,132 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dashquery
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestStreamPaths(t *testing.T) {
+	cache := t.TempDir()
+	t.Setenv("XDG_CACHE_HOME", cache)
+	for _, rev := range []string{"2015-01-01T00:00:00-a", "2015-01-02T00:00:00-b", "2015-01-03T00:00:00-c"} {
+		dir := filepath.Join(RevDir(), rev)
+		if err := os.MkdirAll(dir, 0777); err != nil {
+			t.Fatal(err)
+		}
+		for _, builder := range []string{"linux-amd64", "windows-386"} {
+			if err := os.WriteFile(filepath.Join(dir, builder), nil, 0666); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	q, err := Compile(`os == "linux"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stream := func(ctx context.Context, limit int) ([]string, []Progress, error) {
+		var paths []string
+		var progress []Progress
+		opts := StreamOptions{
+			Limit: limit,
+			Progress: func(p Progress) {
+				progress = append(progress, p)
+			},
+		}
+		err := q.StreamPaths(ctx, opts, func(path string) error {
+			rel, _ := filepath.Rel(RevDir(), path)
+			paths = append(paths, rel)
+			return nil
+		})
+		return paths, progress, err
+	}
+
+	paths, progress, err := stream(context.Background(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"2015-01-03T00:00:00-c/linux-amd64", "2015-01-02T00:00:00-b/linux-amd64", "2015-01-01T00:00:00-a/linux-amd64"}
+	if !reflect.DeepEqual(paths, want) {
+		t.Errorf("got paths %v, want %v", paths, want)
+	}
+	if len(progress) != 3 || progress[2] != (Progress{Revs: 3, TotalRevs: 3, Matches: 3}) {
+		t.Errorf("got progress %v, want 3 updates ending in 3/3 revs, 3 matches", progress)
+	}
+
+	// Stop after a limit.
+	paths, _, err = stream(context.Background(), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(paths, want[:2]) {
+		t.Errorf("with limit 2, got paths %v, want %v", paths, want[:2])
+	}
+
+	// Cancellation.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, _, err := stream(ctx, 0); err != context.Canceled {
+		t.Errorf("with canceled context, got error %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestStreamPathsErrors(t *testing.T) {
+	cache := t.TempDir()
+	t.Setenv("XDG_CACHE_HOME", cache)
+	good := filepath.Join(RevDir(), "2015-01-02T00:00:00-b")
+	bad := filepath.Join(RevDir(), "2015-01-01T00:00:00-a")
+	for _, dir := range []string{good, bad} {
+		if err := os.MkdirAll(dir, 0777); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "linux-amd64"), nil, 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(good, ".rev.json"), []byte(`{"date": "2015-01-02T00:00:00Z"}`), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Compile(`1 +`); err == nil {
+		t.Errorf("want compile error")
+	} else if _, ok := err.(*CompileError); !ok {
+		t.Errorf("got compile error %T, want *CompileError", err)
+	}
+
+	q, err := Compile(`age > 0`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var paths []string
+	stream := func(opts StreamOptions) error {
+		paths = nil
+		return q.StreamPaths(context.Background(), opts, func(path string) error {
+			paths = append(paths, path)
+			return nil
+		})
+	}
+	badPath := filepath.Join(bad, "linux-amd64")
+
+	// By default, stop at the first error.
+	err = stream(StreamOptions{})
+	if pe, ok := err.(*PathError); !ok || pe.Path != badPath || !os.IsNotExist(pe.Err) {
+		t.Errorf("got error %v, want *PathError for %s", err, badPath)
+	}
+
+	// With KeepGoing, collect errors.
+	err = stream(StreamOptions{KeepGoing: true})
+	if l, ok := err.(ErrorList); !ok || len(l) != 1 {
+		t.Errorf("with KeepGoing, got error %v, want ErrorList of 1 error", err)
+	}
+	if want := []string{filepath.Join(good, "linux-amd64")}; !reflect.DeepEqual(paths, want) {
+		t.Errorf("with KeepGoing, got paths %v, want %v", paths, want)
+	}
+}
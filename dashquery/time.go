@@ -0,0 +1,83 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dashquery
+
+import (
+	"fmt"
+	"go/constant"
+	"math"
+	"time"
+)
+
+// dateLayouts are the layouts accepted for date literals, in addition
+// to RFC 3339. Dates in these layouts have no time zone.
+var dateLayouts = []string{
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// parseDate parses a date literal, which may be an RFC 3339 date-time
+// or one of dateLayouts. Dates without a time zone are interpreted in
+// loc.
+func parseDate(s string, loc *time.Location) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		return t, nil
+	}
+	for _, layout := range dateLayouts {
+		if t, err := time.ParseInLocation(layout, s, loc); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("bad date %q; want RFC 3339 or YYYY-MM-DD[ HH:MM[:SS]]", s)
+}
+
+// timeBuiltins returns the builtins that depend on the time zone loc.
+func timeBuiltins(loc *time.Location) map[string]queryNode {
+	return map[string]queryNode{
+		"time": timeNode(func(pi pathInfo) time.Time {
+			return pi.date(loc)
+		}),
+		"now": timeNode(func(pi pathInfo) time.Time {
+			return startTime()
+		}),
+		"age": numberNode(func(pi pathInfo) constant.Value {
+			date := pi.date(loc)
+			if date.IsZero() {
+				return constant.MakeInt64(0)
+			}
+			return constant.MakeInt64(int64(startTime().Sub(date)))
+		}),
+	}
+}
+
+// date returns the commit date of pi's revision, or the zero time if
+// it's missing or malformed. The dashboard records dates in RFC 3339,
+// but dates without a time zone are interpreted in loc.
+func (pi *pathInfo) date(loc *time.Location) time.Time {
+	t, err := parseDate(pi.buildRev().Date, loc)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+// duration converts a number of nanoseconds to a time.Duration,
+// rounding toward zero.
+func duration(v constant.Value) time.Duration {
+	if i, ok := constant.Int64Val(constant.ToInt(v)); ok {
+		return time.Duration(i)
+	}
+	f, _ := constant.Float64Val(v)
+	if math.Abs(f) >= math.MaxInt64 {
+		if f < 0 {
+			return math.MinInt64
+		}
+		return math.MaxInt64
+	}
+	return time.Duration(f)
+}
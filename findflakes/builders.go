@@ -0,0 +1,115 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import "sort"
+
+// A builderFlake is the flake test of a failure class over just the
+// builds of one builder.
+//
+// Builders run at very different rates, so a failure that only
+// happens on a rarely run builder has a low per-revision failure
+// probability even if it fails most times that builder runs. Testing
+// each builder's runs separately counts only the revisions that
+// builder ran on as trials.
+type builderFlake struct {
+	Builder string
+
+	// Trials are the indexes in the failure class's Revs of the
+	// revisions this builder finished a build of.
+	Trials []int
+
+	// Test is the flake test of this builder's failures. Times in
+	// Test are indexes into Trials.
+	Test *FlakeTestResult
+
+	// Latest is the latest flake region (Test.All[0]).
+	Latest *FlakeRegion
+
+	// Current is the probability that this failure is still
+	// happening on this builder.
+	Current float64
+}
+
+// Runs returns the number of runs of the builder in its latest flake
+// region.
+func (bf *builderFlake) Runs() int {
+	return bf.Latest.Last - bf.Latest.First + 1
+}
+
+// newBuilderFlakes performs a flake test of failures for each
+// builder they happened on. It returns the results sorted by the
+// chance the failure is still happening, most likely first.
+func newBuilderFlakes(revs []*Revision, failures []*failure) []*builderFlake {
+	byBuilder := make(map[string][]*failure)
+	for _, f := range failures {
+		byBuilder[f.Build.Builder] = append(byBuilder[f.Build.Builder], f)
+	}
+
+	var out []*builderFlake
+	for builder, bfailures := range byBuilder {
+		bf := &builderFlake{Builder: builder}
+		trialOf := make(map[int]int)
+		for t, rev := range revs {
+			for _, build := range rev.Builds {
+				if build.Builder == builder && build.Status != BuildRunning {
+					trialOf[t] = len(bf.Trials)
+					bf.Trials = append(bf.Trials, t)
+					break
+				}
+			}
+		}
+
+		times := []int{}
+		for _, f := range bfailures {
+			trial := trialOf[f.T]
+			if len(times) == 0 || times[len(times)-1] != trial {
+				times = append(times, trial)
+			}
+		}
+		bf.Test = FlakeTest(times)
+		bf.Latest = &bf.Test.All[0]
+		bf.Current = bf.Latest.StillHappening(len(bf.Trials) - 1)
+		out = append(out, bf)
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Current != out[j].Current {
+			return out[i].Current > out[j].Current
+		}
+		if pi, pj := out[i].Latest.FailureProbability, out[j].Latest.FailureProbability; pi != pj {
+			return pi > pj
+		}
+		return out[i].Builder < out[j].Builder
+	})
+	return out
+}
+
+// weighted returns the chance that fc is still happening and its
+// failure probability, taking whichever of the pooled flake test and
+// the per-builder flake tests is most likely still happening.
+func (fc *failureClass) weighted() (current, prob float64) {
+	current, prob = fc.Current, fc.Latest.FailureProbability
+	for _, bf := range fc.Builders {
+		if bf.Current > current {
+			current, prob = bf.Current, bf.Latest.FailureProbability
+		}
+	}
+	return
+}
+
+// WeightedCurrent returns the chance that fc is still happening,
+// accounting for per-builder flake tests.
+func (fc *failureClass) WeightedCurrent() float64 {
+	current, _ := fc.weighted()
+	return current
+}
+
+// WeightedProbability returns the failure probability of fc from
+// the same flake test as WeightedCurrent.
+func (fc *failureClass) WeightedProbability() float64 {
+	_, prob := fc.weighted()
+	return prob
+}
@@ -0,0 +1,164 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"container/heap"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// orderRevisions returns revs in a deterministic topological order of
+// the commit graph, with parents before children. Among revisions
+// whose parents have all been ordered, it picks the oldest first
+// (breaking ties by hash), so a linear history comes out in date
+// order and the commits on either side of a merge are interleaved
+// by date.
+//
+// Parents that aren't in revs are ignored, so revisions whose parent
+// metadata is missing are ordered by date alone.
+func orderRevisions(revs []*Revision) []*Revision {
+	byHash := make(map[string]*Revision, len(revs))
+	for _, rev := range revs {
+		byHash[rev.Revision] = rev
+	}
+
+	// Build the commit DAG.
+	children := make(map[*Revision][]*Revision)
+	nParents := make(map[*Revision]int)
+	for _, rev := range revs {
+		for _, p := range rev.Parents {
+			if prev := byHash[p]; prev != nil && prev != rev {
+				children[prev] = append(children[prev], rev)
+				nParents[rev]++
+			}
+		}
+	}
+
+	// Kahn's algorithm, using a heap to pick the oldest ready
+	// revision.
+	var ready revHeap
+	for _, rev := range revs {
+		if nParents[rev] == 0 {
+			ready = append(ready, rev)
+		}
+	}
+	heap.Init(&ready)
+	out := make([]*Revision, 0, len(revs))
+	for len(ready) > 0 {
+		rev := heap.Pop(&ready).(*Revision)
+		out = append(out, rev)
+		for _, child := range children[rev] {
+			if nParents[child]--; nParents[child] == 0 {
+				heap.Push(&ready, child)
+			}
+		}
+	}
+
+	if len(out) < len(revs) {
+		// The parent metadata has a cycle, so it must be
+		// corrupt. Fall back to date order for what's left.
+		var rest revHeap
+		for _, rev := range revs {
+			if nParents[rev] > 0 {
+				rest = append(rest, rev)
+			}
+		}
+		log.Printf("warning: revision parents form a cycle; ordering %d revisions by date", len(rest))
+		heap.Init(&rest)
+		for len(rest) > 0 {
+			out = append(out, heap.Pop(&rest).(*Revision))
+		}
+	}
+	return out
+}
+
+// revHeap is a min-heap of revisions ordered by date, then hash.
+type revHeap []*Revision
+
+func (h revHeap) Len() int { return len(h) }
+
+func (h revHeap) Less(i, j int) bool {
+	if !h[i].Date.Equal(h[j].Date) {
+		return h[i].Date.Before(h[j].Date)
+	}
+	return h[i].Revision < h[j].Revision
+}
+
+func (h revHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *revHeap) Push(x interface{}) { *h = append(*h, x.(*Revision)) }
+
+func (h *revHeap) Pop() interface{} {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
+
+// fillParents fills in the parents of revisions whose metadata
+// doesn't record them, using the git repository repo. Revisions that
+// aren't in repo keep no parents.
+func fillParents(repo string, revs []*Revision) error {
+	var input bytes.Buffer
+	byHash := make(map[string]*Revision)
+	for _, rev := range revs {
+		if rev.Parents == nil {
+			byHash[rev.Revision] = rev
+			fmt.Fprintf(&input, "%s\n", rev.Revision)
+		}
+	}
+	if len(byHash) == 0 {
+		return nil
+	}
+
+	// Unknown revisions make rev-list fail, so check them in
+	// bulk first and only ask for the ones that exist.
+	cmd := exec.Command("git", "-C", repo, "cat-file", "--batch-check")
+	cmd.Stdin = &input
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return fmt.Errorf("git cat-file failed: %v", err)
+	}
+	input.Reset()
+	missing := 0
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		fs := strings.Fields(scanner.Text())
+		if len(fs) >= 2 && fs[1] == "commit" {
+			fmt.Fprintf(&input, "%s\n", fs[0])
+		} else {
+			missing++
+		}
+	}
+	if missing > 0 {
+		log.Printf("warning: %d revisions are not in %s; ordering them by date", missing, repo)
+	}
+
+	cmd = exec.Command("git", "-C", repo, "rev-list", "--no-walk", "--parents", "--stdin")
+	cmd.Stdin = &input
+	cmd.Stderr = os.Stderr
+	out, err = cmd.Output()
+	if err != nil {
+		return fmt.Errorf("git rev-list failed: %v", err)
+	}
+	scanner = bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		fs := strings.Fields(scanner.Text())
+		if len(fs) == 0 {
+			continue
+		}
+		if rev := byHash[fs[0]]; rev != nil {
+			rev.Parents = append([]string{}, fs[1:]...)
+		}
+	}
+	return scanner.Err()
+}
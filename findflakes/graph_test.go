@@ -0,0 +1,159 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/build/types"
+)
+
+// testRevs returns revisions described by specs of the form
+// "name@hour[:parent,parent...]".
+func testRevs(specs ...string) []*Revision {
+	var revs []*Revision
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, spec := range specs {
+		var parents []string
+		if i := strings.Index(spec, ":"); i >= 0 {
+			spec, parents = spec[:i], strings.Split(spec[i+1:], ",")
+		}
+		i := strings.Index(spec, "@")
+		h, _ := time.ParseDuration(spec[i+1:] + "h")
+		rev := &Revision{
+			BuildRevision: types.BuildRevision{Revision: spec[:i]},
+			Date:          base.Add(h),
+			Parents:       parents,
+		}
+		revs = append(revs, rev)
+	}
+	return revs
+}
+
+func revNames(revs []*Revision) string {
+	var names []string
+	for _, rev := range revs {
+		names = append(names, rev.Revision)
+	}
+	return strings.Join(names, " ")
+}
+
+func TestOrderRevisions(t *testing.T) {
+	for _, test := range []struct {
+		name  string
+		specs []string
+		want  string
+	}{
+		{"linear", []string{"c@3:b", "a@1", "b@2:a"}, "a b c"},
+		{"no parents", []string{"c@3", "a@1", "b@2"}, "a b c"},
+		{"same date", []string{"b@1", "a@1"}, "a b"},
+		// A merge: a side branch forked from a, committed before
+		// b, and was merged into main after c. The side branch's
+		// second commit is dated before its parent, as happens
+		// with rebased commits, and must still follow it.
+		//
+		//   a - b - c - m - d
+		//    \         /
+		//     s1 ---- s2
+		{"merge", []string{
+			"a@1",
+			"b@3:a",
+			"c@5:b",
+			"m@6:c,s2",
+			"d@7:m",
+			"s1@2:a",
+			"s2@0:s1",
+		}, "a s1 s2 b c m d"},
+		// Two merges of the same branch. Dates interleave the
+		// branches, but every merge follows both its parents.
+		//
+		//   a --- b --- m1 --- m2
+		//    \         /      /
+		//     x ------ y --- z
+		{"repeated merge", []string{
+			"a@0",
+			"x@1:a",
+			"b@2:a",
+			"y@3:x",
+			"m1@4:b,y",
+			"z@5:y",
+			"m2@4:m1,z",
+		}, "a x b y m1 z m2"},
+		// Parents outside the set are ignored, and revisions
+		// without metadata sort in by date.
+		{"missing", []string{"b@2:a", "c@3:b", "q@1:zzz", "r@4"}, "q b c r"},
+		// A corrupt cycle falls back to date order.
+		{"cycle", []string{"a@1", "b@2:c", "c@3:b"}, "a b c"},
+	} {
+		got := revNames(orderRevisions(testRevs(test.specs...)))
+		if got != test.want {
+			t.Errorf("%s: got %s, want %s", test.name, got, test.want)
+		}
+	}
+}
+
+func TestFillParents(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+	dir := t.TempDir()
+	git := func(args ...string) string {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+		cmd.Env = append(os.Environ(),
+			"GIT_AUTHOR_NAME=test", "GIT_AUTHOR_EMAIL=test@example.com",
+			"GIT_COMMITTER_NAME=test", "GIT_COMMITTER_EMAIL=test@example.com")
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+	git("init", "-q", "-b", "main")
+	commit := func(msg string) string {
+		git("commit", "-q", "--allow-empty", "-m", msg)
+		return git("rev-parse", "HEAD")
+	}
+	a := commit("a")
+	git("checkout", "-q", "-b", "side")
+	s := commit("s")
+	git("checkout", "-q", "main")
+	b := commit("b")
+	git("merge", "-q", "--no-ff", "-m", "m", "side")
+	m := git("rev-parse", "HEAD")
+
+	// Give the revisions dates that put the merge first, so only
+	// the parents can order them. The unknown revision and the
+	// revision that already has parents are left alone.
+	revs := testRevs(m+"@0", s+"@1", b+"@2", a+"@3", "0123456789abcdef@4", "known@5:"+a)
+	if err := fillParents(dir, revs); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := strings.Join(revs[0].Parents, " "), b+" "+s; got != want {
+		t.Errorf("merge parents are %s, want %s", got, want)
+	}
+	if revs[3].Parents == nil || len(revs[3].Parents) != 0 {
+		t.Errorf("root commit parents are %#v, want empty", revs[3].Parents)
+	}
+	if revs[4].Parents != nil {
+		t.Errorf("unknown revision has parents %v", revs[4].Parents)
+	}
+	if got := strings.Join(revs[5].Parents, " "); got != a {
+		t.Errorf("known parents changed to %s", got)
+	}
+
+	got := orderRevisions(revs)
+	want := []string{a, s, b, m}
+	for i, rev := range got[:4] {
+		if rev.Revision != want[i] {
+			t.Errorf("got order %s, want %s first", revNames(got), strings.Join(want, " "))
+			break
+		}
+	}
+}
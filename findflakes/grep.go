@@ -0,0 +1,172 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// maxSnippets limits the number of snippets extracted from each log.
+const maxSnippets = 10
+
+// grepList is a flag.Value that collects repeated -grep patterns.
+type grepList []string
+
+func (l *grepList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *grepList) Set(x string) error {
+	*l = append(*l, x)
+	return nil
+}
+
+// A grepper matches logs against a set of patterns and extracts the
+// matching lines.
+type grepper struct {
+	res []*regexp.Regexp
+
+	// any indicates that a log matches if it matches any of res,
+	// rather than all of them.
+	any bool
+
+	// context is the number of lines of context to extract
+	// around each match, or -1 to not extract matching lines.
+	context int
+}
+
+func newGrepper(patterns []string, any bool, context int) (*grepper, error) {
+	g := &grepper{any: any, context: context}
+	for _, pat := range patterns {
+		re, err := regexp.Compile(pat)
+		if err != nil {
+			return nil, err
+		}
+		g.res = append(g.res, re)
+	}
+	return g, nil
+}
+
+// match reports whether data matches g's patterns.
+func (g *grepper) match(data []byte) bool {
+	for _, re := range g.res {
+		if re.Match(data) == g.any {
+			return g.any
+		}
+	}
+	return !g.any
+}
+
+// snippets returns the lines of data matched by any of g's patterns,
+// each with g.context lines of context. Overlapping snippets are
+// merged.
+func (g *grepper) snippets(data []byte) []string {
+	// Find the line ranges [start, end) of all matches.
+	type span struct{ start, end int }
+	var spans []span
+	for _, re := range g.res {
+		for _, m := range re.FindAllIndex(data, -1) {
+			start, end := lineOf(data, m[0]), lineOf(data, m[1]-1)+1
+			if end <= start {
+				// Empty match.
+				end = start + 1
+			}
+			spans = append(spans, span{start, end})
+		}
+	}
+	if len(spans) == 0 {
+		return nil
+	}
+	sort.Slice(spans, func(i, j int) bool { return spans[i].start < spans[j].start })
+
+	lines := strings.SplitAfter(string(data), "\n")
+	var out []string
+	cur := span{-1, -1}
+	flush := func() {
+		if cur.end >= 0 && len(out) < maxSnippets {
+			out = append(out, strings.Join(lines[cur.start:cur.end], ""))
+		}
+	}
+	for _, s := range spans {
+		s.start -= g.context
+		if s.start < 0 {
+			s.start = 0
+		}
+		s.end += g.context
+		if s.end > len(lines) {
+			s.end = len(lines)
+		}
+		if s.start <= cur.end {
+			if s.end > cur.end {
+				cur.end = s.end
+			}
+			continue
+		}
+		flush()
+		cur = s
+	}
+	flush()
+	return out
+}
+
+// lineOf returns the 0-based line number of byte offset off in data.
+func lineOf(data []byte, off int) int {
+	if off < 0 {
+		off = 0
+	}
+	return bytes.Count(data[:off], []byte("\n"))
+}
+
+// canonSnippet matches numbers and hexadecimal values, which often
+// differ between otherwise identical log lines.
+var canonSnippet = regexp.MustCompile(`0x[0-9a-fA-F]+|[0-9]+`)
+
+// printTextSnippets prints the distinct snippets of failures, with
+// the number of builds each appeared in. Snippets that differ only in
+// numbers are considered the same.
+func printTextSnippets(w io.Writer, failures []*failure) {
+	type group struct {
+		text        string
+		first, last *failure
+		builds      int
+	}
+	groups := make(map[string]*group)
+	var order []*group
+	for _, f := range failures {
+		seen := make(map[string]bool)
+		for _, s := range f.Snippets {
+			key := canonSnippet.ReplaceAllString(s, "N")
+			g := groups[key]
+			if g == nil {
+				g = &group{text: s, first: f}
+				groups[key] = g
+				order = append(order, g)
+			}
+			if !seen[key] {
+				seen[key] = true
+				g.builds++
+				g.last = f
+			}
+		}
+	}
+	if len(order) == 0 {
+		return
+	}
+	sort.SliceStable(order, func(i, j int) bool { return order[i].builds > order[j].builds })
+
+	fmt.Fprintf(w, "\nMatching lines (%d distinct):\n", len(order))
+	for _, g := range order {
+		fmt.Fprintf(w, "\n%d build(s), first %s on %s, last %s on %s\n", g.builds, g.first.Rev, g.first.Build.Builder, g.last.Rev, g.last.Build.Builder)
+		for _, line := range strings.SplitAfter(strings.TrimSuffix(g.text, "\n"), "\n") {
+			fmt.Fprintf(w, "  %s", line)
+		}
+		fmt.Fprintf(w, "\n")
+	}
+}
@@ -85,17 +85,29 @@ a.hash {
     <script src="https://ajax.googleapis.com/ajax/libs/jquery/1.8.2/jquery.min.js"></script>
   </head>
   <body>
+    {{with .NewClasses}}
+    <table id="new" class="lined">
+      <caption>Failures first observed recently, most recent first.</caption>
+      <thead>
+        <tr><th>First observed</th><th class="pct">Failures</th><th style="width:100%">Failure</th></tr>
+      </thead>
+      {{range .}}
+      <tr><td style="white-space:nowrap">{{template "observation" (index (groupByT .Failures) (index .Failures 0).T)}}</td><td class="pct">{{len .Failures}}</td><td>{{.Class.String}}</td></tr>
+      {{end}}
+    </table>
+    {{end}}
     <table id="failures" class="lined">
-      <caption>Test failures as of {{(lastRev .).Date.Format "02 Jan 15:04 2006"}}, sorted by chance the failure is still happening. Click row for details and culprits.</caption>
+      <caption>Test failures as of {{(lastRev .Classes).Date.Format "02 Jan 15:04 2006"}}, sorted by chance the failure is still happening. Click row for details and culprits.</caption>
       <thead>
         <tr><th></th><th class="pct">P(current)</th><th class="pct">P(failure)</th><th style="width:100%">Failure</th></tr>
       </thead>
-      {{range $i, $class := .}}
+      {{range $i, $class := .Classes}}
       {{$failuresByT := groupByT .Failures}}
-      <tr><td class="plus">+</td><td class="pct">{{pct .Current}}</td><td class="pct">{{pct .Latest.FailureProbability}}</td><td>{{.Class.String}}</td></tr>
+      <tr><td class="plus">+</td><td class="pct">{{pct .WeightedCurrent}}</td><td class="pct">{{pct .WeightedProbability}}</td><td>{{.Class.String}}</td></tr>
       <tr class="expand"><td></td><td colspan="3">
         <table>
           <tr><th>Chance failure is still happening</th><td>{{pct .Current}}</td></tr>
+          {{with .Class.Stack}}<tr><th>Stack</th><td><pre>{{.}}</pre></td></tr>{{end}}
           {{with .Latest}}
           <tr><th>Failure probability</th><td>{{pct .FailureProbability}} ({{.Failures}} of {{numCommits .}} commits)</td></tr>
           {{if eq (numCommits .) 1}}
@@ -120,6 +132,9 @@ a.hash {
           </tr>
           {{end}}{{/* numCommits == 1*/}}
           {{end}}{{/* with .Latest */}}
+          {{range .Builders}}
+            <tr><th>On {{.Builder}}</th><td>{{if eq .Runs 1}}isolated failure{{else}}{{pct .Latest.FailureProbability}} failure probability ({{.Latest.Failures}} of {{.Runs}} runs); {{pct .Current}} chance still happening{{end}}</td></tr>
+          {{end}}
           {{with (slice .Test.All 1 (len .Test.All))}}
             <tr><th>{{len .}} past failure(s)</th><td><a href="#" class="toggleRows">show</a></td></tr>
             {{range .}}
@@ -128,10 +143,33 @@ a.hash {
           {{else}}
             <tr><th>No known past failures</th></tr>
           {{end}}
+          {{with .Diff}}
+            <tr><th>Compared with</th><td>{{template "revDate" .Base.Revision}} on <a href="{{.Base.LogURL}}">{{.Base.Builder}}</a></td></tr>
+            {{range .Warnings}}
+              <tr><th></th><td><code>+ {{.}}</code></td></tr>
+            {{end}}
+            {{range .Timings}}
+              <tr><th></th><td><code>~ {{.}}</code></td></tr>
+            {{end}}
+            {{if not (or .Warnings .Timings)}}
+              <tr><th></th><td>No new warnings or timing changes</td></tr>
+            {{end}}
+          {{end}}
         </table>
       </td></tr>
       {{end}}
     </table>
+    {{with .Suppressions}}
+    <table id="suppressions" class="lined">
+      <caption>Failures excluded by suppression rules.</caption>
+      <thead>
+        <tr><th class="pct">Count</th><th style="width:100%">Rule</th></tr>
+      </thead>
+      {{range .}}
+      <tr><td class="pct">{{.Count}}</td><td>{{.Rule}}</td></tr>
+      {{end}}
+    </table>
+    {{end}}
     <script>
 $("#failures").click(function(ev) {
     var target = $(ev.target);
@@ -216,8 +254,12 @@ var htmlFuncs = template.FuncMap(map[string]interface{}{
 
 var htmlTemplate = template.Must(template.New("report").Funcs(htmlFuncs).Parse(htmlReport))
 
-func printHTMLReport(w io.Writer, classes []*failureClass) {
-	err := htmlTemplate.Execute(w, classes)
+func printHTMLReport(w io.Writer, classes, newClasses []*failureClass, suppressions []*suppressRule) {
+	err := htmlTemplate.Execute(w, struct {
+		Classes      []*failureClass
+		NewClasses   []*failureClass
+		Suppressions []*suppressRule
+	}{classes, newClasses, suppressions})
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -0,0 +1,239 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxDiffBack is how many revisions before a failure findBaseBuild
+// will search for a passing build to compare against.
+const maxDiffBack = 50
+
+// maxDiffLines limits the number of warnings and timing changes in a
+// logDiff.
+const maxDiffLines = 10
+
+// A logDiff summarizes the interesting differences between the log of
+// the first failure in a failure class and the log of the previous
+// passing build on the same builder.
+type logDiff struct {
+	// Failure is the first failure in the class.
+	Failure *failure
+
+	// Base is the build Failure is compared against.
+	Base *Build
+
+	// Warnings lists warning lines that appear in Failure's log,
+	// but not in Base's log.
+	Warnings []string
+
+	// Timings lists packages and tests whose running time changed
+	// significantly between Base and Failure.
+	Timings []timingChange
+}
+
+type timingChange struct {
+	Name     string
+	Old, New time.Duration
+}
+
+func (c timingChange) String() string {
+	return c.Name + " " + c.Old.String() + " -> " + c.New.String()
+}
+
+// diffFirstFailure returns the logDiff for the first failure in fc,
+// or nil if there's no passing build to compare against or its log
+// isn't available.
+func diffFirstFailure(fc *failureClass) *logDiff {
+	f := fc.Failures[0]
+	base := findBaseBuild(fc, f)
+	if base == nil {
+		return nil
+	}
+	failLog, err := f.Build.ReadLog()
+	if err != nil {
+		return nil
+	}
+	baseLog, err := readBaseLog(base)
+	if err != nil {
+		log.Printf("warning: reading log of %s on %s: %v", base.Revision, base.Builder, err)
+		return nil
+	}
+	if baseLog == nil {
+		return nil
+	}
+	d := &logDiff{Failure: f, Base: base}
+	d.Warnings = newWarnings(string(baseLog), string(failLog))
+	d.Timings = timingChanges(string(baseLog), string(failLog))
+	return d
+}
+
+// findBaseBuild returns the previous passing build before f on the
+// same builder, or nil if there isn't one within maxDiffBack
+// revisions.
+func findBaseBuild(fc *failureClass, f *failure) *Build {
+	for t := f.T - 1; t >= 0 && t >= f.T-maxDiffBack; t-- {
+		for _, b := range fc.Revs[t].Builds {
+			if b.Builder == f.Build.Builder && b.Status == BuildOK {
+				return b
+			}
+		}
+	}
+	return nil
+}
+
+// readBaseLog returns the log of passing build b, or nil if it isn't
+// available. fetchlogs only saves the logs of failed builds, so this
+// fetches b.LogURL and caches it. It uses a log fetchlogs did save if
+// there is one.
+func readBaseLog(b *Build) ([]byte, error) {
+	if data, err := b.ReadLog(); err == nil {
+		return data, nil
+	}
+	if b.LogURL == "" {
+		return nil, nil
+	}
+
+	dir := filepath.Join(xdgCacheDir(), "findflakes", "baselog")
+	path := filepath.Join(dir, fmt.Sprintf("%x", sha256.Sum256([]byte(b.LogURL))))
+	if data, err := ioutil.ReadFile(path); err == nil {
+		return data, nil
+	}
+
+	client := http.Client{Timeout: time.Minute}
+	resp, err := client.Get(b.LogURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching %s: %s", b.LogURL, resp.Status)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	// Failing to cache the log isn't fatal.
+	if err := xdgCreateDir(dir); err == nil {
+		if err := ioutil.WriteFile(path+".tmp", data, 0666); err == nil {
+			os.Rename(path+".tmp", path)
+		}
+	}
+	return data, nil
+}
+
+var (
+	warningRe = regexp.MustCompile(`(?i)\bwarning\b|DATA RACE|\bdeprecated\b`)
+
+	// canonNumRe matches numbers, including hexadecimal, so
+	// warnings that differ only in addresses, PIDs, or line
+	// numbers compare equal.
+	canonNumRe = regexp.MustCompile(`(0x)?[0-9a-fA-F]*[0-9][0-9a-fA-F]*`)
+)
+
+// newWarnings returns the warning lines in failLog that don't appear
+// in baseLog, ignoring differences in numbers.
+func newWarnings(baseLog, failLog string) []string {
+	have := make(map[string]bool)
+	for _, line := range strings.Split(baseLog, "\n") {
+		if warningRe.MatchString(line) {
+			have[canonNumRe.ReplaceAllString(line, "N")] = true
+		}
+	}
+	var out []string
+	for _, line := range strings.Split(failLog, "\n") {
+		if !warningRe.MatchString(line) {
+			continue
+		}
+		key := canonNumRe.ReplaceAllString(line, "N")
+		if have[key] {
+			continue
+		}
+		have[key] = true
+		out = append(out, strings.TrimSpace(line))
+		if len(out) == maxDiffLines {
+			break
+		}
+	}
+	return out
+}
+
+var (
+	// pkgTimeRe matches the summary line of a package's tests,
+	// such as "ok  	fmt	0.123s".
+	pkgTimeRe = regexp.MustCompile(`^(?:ok|FAIL)\s+(\S+)\s+([0-9.]+)s`)
+	// testTimeRe matches the result line of a test, such as
+	// "--- PASS: TestFoo (1.23s)".
+	testTimeRe = regexp.MustCompile(`^\s*--- (?:PASS|FAIL): (\S+) \(([0-9.]+)s\)`)
+)
+
+// logTimings returns the running time of each package and test in
+// log.
+func logTimings(log string) map[string]time.Duration {
+	out := make(map[string]time.Duration)
+	for _, line := range strings.Split(log, "\n") {
+		m := pkgTimeRe.FindStringSubmatch(line)
+		if m == nil {
+			m = testTimeRe.FindStringSubmatch(line)
+		}
+		if m == nil {
+			continue
+		}
+		secs, err := strconv.ParseFloat(m[2], 64)
+		if err != nil {
+			continue
+		}
+		out[m[1]] = time.Duration(secs * float64(time.Second))
+	}
+	return out
+}
+
+// timingChanges returns the packages and tests whose running time
+// changed by at least 2x and at least a second between baseLog and
+// failLog, largest change first.
+func timingChanges(baseLog, failLog string) []timingChange {
+	abs := func(d time.Duration) time.Duration {
+		if d < 0 {
+			return -d
+		}
+		return d
+	}
+	old, new := logTimings(baseLog), logTimings(failLog)
+	var out []timingChange
+	for name, nt := range new {
+		ot, ok := old[name]
+		if !ok {
+			continue
+		}
+		if abs(nt-ot) >= time.Second && (nt >= 2*ot || ot >= 2*nt) {
+			out = append(out, timingChange{name, ot, nt})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		di, dj := abs(out[i].New-out[i].Old), abs(out[j].New-out[j].Old)
+		if di != dj {
+			return di > dj
+		}
+		return out[i].Name < out[j].Name
+	})
+	if len(out) > maxDiffLines {
+		out = out[:maxDiffLines]
+	}
+	return out
+}
@@ -8,6 +8,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"log"
 	"os"
 	"path/filepath"
 	"strings"
@@ -20,6 +21,10 @@ type Revision struct {
 	types.BuildRevision
 	Date time.Time
 
+	// Parents are the hashes of this revision's parent commits,
+	// or nil if they aren't known.
+	Parents []string
+
 	Builds []*Build
 
 	path string
@@ -65,9 +70,22 @@ func (b *Build) ReadLog() ([]byte, error) {
 	return ioutil.ReadFile(b.LogPath())
 }
 
+// revMeta is the revision metadata stored in .rev.json. fetchlogs
+// doesn't record the revision's parents, so Parents is only set if
+// something else added them; otherwise main fills them in from the
+// -repo git repository.
+type revMeta struct {
+	types.BuildRevision
+	Parents []string `json:"parents,omitempty"`
+}
+
 // LoadRevisions loads all saved build revisions from revDir, which
 // must be the "rev" directory written by fetchlogs. The returned
-// revisions are ordered from oldest to newest.
+// revisions are in topological order from oldest to newest, as
+// determined by orderRevisions.
+//
+// Revisions with missing or unreadable metadata (for example,
+// because fetchlogs was interrupted) are skipped with a warning.
 func LoadRevisions(revDir string) ([]*Revision, error) {
 	revFiles, err := ioutil.ReadDir(revDir)
 	if err != nil {
@@ -75,6 +93,7 @@ func LoadRevisions(revDir string) ([]*Revision, error) {
 	}
 
 	revs := []*Revision{}
+	skipped := 0
 	for _, revFile := range revFiles {
 		if !revFile.IsDir() {
 			continue
@@ -83,20 +102,34 @@ func LoadRevisions(revDir string) ([]*Revision, error) {
 		rev := &Revision{path: filepath.Join(revDir, revFile.Name())}
 
 		// Load revision metadata.
+		var meta revMeta
 		var builders []string
-		err1 := readJSONFile(filepath.Join(rev.path, ".rev.json"), &rev.BuildRevision)
+		err1 := readJSONFile(filepath.Join(rev.path, ".rev.json"), &meta)
 		err2 := readJSONFile(filepath.Join(rev.path, ".builders.json"), &builders)
 		if os.IsNotExist(err1) || os.IsNotExist(err2) {
+			skipped++
 			continue
 		} else if err1 != nil {
-			return nil, err1
+			log.Printf("warning: %s: %v", rev.path, err1)
+			skipped++
+			continue
 		} else if err2 != nil {
-			return nil, err2
+			log.Printf("warning: %s: %v", rev.path, err2)
+			skipped++
+			continue
 		}
+		rev.BuildRevision, rev.Parents = meta.BuildRevision, meta.Parents
 
 		rev.Date, err = time.Parse(time.RFC3339, rev.BuildRevision.Date)
 		if err != nil {
-			return nil, err
+			log.Printf("warning: %s: bad date: %v", rev.path, err)
+			skipped++
+			continue
+		}
+		if len(rev.Revision) < 7 || len(rev.Results) < len(builders) {
+			log.Printf("warning: %s: malformed revision metadata", rev.path)
+			skipped++
+			continue
 		}
 
 		rev.Builds = make([]*Build, len(builders))
@@ -123,8 +156,11 @@ func LoadRevisions(revDir string) ([]*Revision, error) {
 
 		revs = append(revs, rev)
 	}
+	if skipped > 0 {
+		log.Printf("warning: skipped %d revisions with missing or bad metadata in %s", skipped, revDir)
+	}
 
-	return revs, nil
+	return orderRevisions(revs), nil
 }
 
 func readJSONFile(path string, v interface{}) error {
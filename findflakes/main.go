@@ -9,7 +9,6 @@ import (
 	"log"
 	"os"
 	"path/filepath"
-	"regexp"
 	"runtime"
 	"runtime/pprof"
 	"sort"
@@ -20,17 +19,36 @@ import (
 )
 
 var (
-	flagRevDir = flag.String("dir", defaultRevDir(), "search logs under `directory`")
-	flagBranch = flag.String("branch", "master", "analyze commits to `branch`")
-	flagHTML   = flag.Bool("html", false, "print an HTML report")
-	flagLimit  = flag.Int("limit", 0, "process only most recent `N` revisions")
+	flagRevDir   = flag.String("dir", defaultRevDir(), "search logs under `directory`")
+	flagBranch   = flag.String("branch", "master", "analyze commits to `branch`")
+	flagHTML     = flag.Bool("html", false, "print an HTML report")
+	flagLimit    = flag.Int("limit", 0, "process only most recent `N` revisions")
+	flagMerges   = flag.String("merges", "", "add revisions merged into -branch from other branches, using the git repository in `dir`")
+	flagRepo     = flag.String("repo", "", "order revisions using commit parents from the git repository in `dir` (default: the -merges repository)")
+	flagSuppress = flag.String("suppress", "", "exclude known failures listed in `file` from the report")
+	flagNew      = flag.Int("new", 0, "list failures first seen in the most recent `N` revisions separately")
+	flagDiff     = flag.Bool("diff", false, "compare the log of each class's first failure with an earlier build on the same builder")
+	flagBuilders = flag.Bool("by-builder", false, "also test each builder's runs separately, so failures on rarely run builders aren't drowned out")
+
+	flagByStack     = flag.Bool("classify-stack", false, "classify failures with tracebacks by their normalized stack instead of their message")
+	flagStackDepth  = flag.Int("stack-depth", 5, "with -classify-stack, compare the top `N` stack frames")
+	flagMaxDistance = flag.Float64("max-distance", 0, "merge failure classes at the same location whose messages are within distance `d` (0 to 1) of each other")
+	flagDistance    = flag.String("distance", "jaccard", "measure message distance for -max-distance using `metric`: jaccard (token sets) or lcs (token sequences)")
+	flagIDF         = flag.Bool("idf", false, "with -max-distance, weight message tokens by how rare they are across all failures")
 
 	// TODO: Is this really just a separate mode? Should we have
 	// subcommands?
-	flagGrep  = flag.String("grep", "", "show analysis for logs matching `regexp`")
-	flagPaths = flag.Bool("paths", false, "read dir-relative paths of logs with failures from stdin (useful with greplogs -l)")
+	flagGrep      grepList
+	flagGrepAny   = flag.Bool("grep-any", false, "with multiple -grep patterns, match logs that match any pattern instead of all")
+	flagShowLines = flag.Bool("show-lines", false, "in -grep mode, print the distinct matching lines across all builds")
+	flagContext   = flag.Int("C", 0, "with -show-lines, print `N` lines of context around each match")
+	flagPaths     = flag.Bool("paths", false, "read dir-relative paths of logs with failures from stdin (useful with greplogs -l)")
 )
 
+func init() {
+	flag.Var(&flagGrep, "grep", "show analysis for logs matching `regexp`; may be repeated to match logs that match all of them")
+}
+
 func defaultRevDir() string {
 	return filepath.Join(xdgCacheDir(), "fetchlogs", "rev")
 }
@@ -39,10 +57,6 @@ func defaultRevDir() string {
 // in the log with links to past instances of that failure. This just
 // uses log analysis.
 
-// TODO: If we were careful about merges, we could potentially use
-// information from other branches to add additional samples between
-// merge points.
-
 // TODO: Consider each build a separate event, rather than each
 // revision. It doesn't matter what "order" they're in, though we
 // should randomize it for each revision. History subdivision should
@@ -107,6 +121,21 @@ func main() {
 		log.Fatal(err)
 	}
 
+	// fetchlogs metadata doesn't record parents, in which case
+	// LoadRevisions orders revisions by date. That's wrong
+	// for branches with merges, so fill in the parents from git
+	// if we can.
+	repo := *flagRepo
+	if repo == "" {
+		repo = *flagMerges
+	}
+	if repo != "" {
+		if err := fillParents(repo, allRevs); err != nil {
+			log.Fatal(err)
+		}
+		allRevs = orderRevisions(allRevs)
+	}
+
 	// Filter to revisions on this branch.
 	revs := []*Revision{}
 	for _, rev := range allRevs {
@@ -123,18 +152,31 @@ func main() {
 		revs = revs[len(revs)-*flagLimit:]
 	}
 
-	if *flagGrep != "" {
+	// Add samples from other branches between merge points.
+	if *flagMerges != "" {
+		revs, err = mergeRevisions(*flagMerges, revs, allRevs)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if len(flagGrep) > 0 {
 		// Grep mode.
-		re, err := regexp.Compile(*flagGrep)
+		context := -1
+		if *flagShowLines {
+			context = *flagContext
+		}
+		g, err := newGrepper(flagGrep, *flagGrepAny, context)
 		if err != nil {
 			log.Fatal(err)
 		}
-		failures := grepFailures(revs, re)
+		failures := grepFailures(revs, g)
 		if len(failures) == 0 {
 			return
 		}
 		fc := newFailureClass(revs, failures)
 		printTextFlakeReport(os.Stdout, fc)
+		printTextSnippets(os.Stdout, failures)
 		return
 	}
 
@@ -156,16 +198,27 @@ func main() {
 	// Extract failures from logs.
 	failures := extractFailures(revs)
 
+	// Drop suppressed failures.
+	var rules []*suppressRule
+	if *flagSuppress != "" {
+		rules, err = readSuppressions(*flagSuppress)
+		if err != nil {
+			log.Fatal(err)
+		}
+		failures = suppressFailures(rules, failures)
+	}
+
 	// Classify failures.
 	lfailures := make([]*loganal.Failure, len(failures))
 	for i, f := range failures {
 		lfailures[i] = f.Failure
 	}
-	failureClasses := loganal.Classify(lfailures)
+	failureClasses := loganal.ClassifyWith(lfailures, classifyOptions())
 
 	// Gather failures from each class and perform flakiness
 	// tests.
 	classes := []*failureClass{}
+	newClasses := []*failureClass{}
 	for class, indexes := range failureClasses {
 		classFailures := []*failure{}
 		for _, fi := range indexes {
@@ -174,26 +227,51 @@ func main() {
 		fc := newFailureClass(revs, classFailures)
 		fc.Class = class
 
+		// Collect new failure classes before trimming so
+		// fresh regressions aren't hidden by the thresholds.
+		if *flagNew > 0 && fc.CommitsAgo() < *flagNew {
+			newClasses = append(newClasses, fc)
+		}
+
 		// Trim failure classes below thresholds. We leave out
 		// classes with extremely low failure probabilities
 		// because the chance that these are still happening
 		// takes a long time to decay and there's almost
-		// nothing we can do for culprit analysis.
-		if fc.Current < 0.05 || fc.Latest.FailureProbability < 0.01 {
+		// nothing we can do for culprit analysis. With
+		// -by-builder, a class passes if it passes on any
+		// builder.
+		if current, prob := fc.weighted(); current < 0.05 || prob < 0.01 {
 			continue
 		}
 
 		classes = append(classes, fc)
 	}
 
+	// Compare first failures with earlier logs.
+	if *flagDiff {
+		for _, fc := range classes {
+			fc.Diff = diffFirstFailure(fc)
+		}
+		for _, fc := range newClasses {
+			if fc.Diff == nil {
+				fc.Diff = diffFirstFailure(fc)
+			}
+		}
+	}
+
 	// Sort failure classes by likelihood that failure is still
 	// happening.
 	sort.Sort(sort.Reverse(currentSorter(classes)))
 
+	// Sort new failure classes by recency.
+	sort.Sort(newSorter(newClasses))
+
 	if *flagHTML {
-		printHTMLReport(os.Stdout, classes)
+		printHTMLReport(os.Stdout, classes, newClasses, rules)
 	} else {
+		printTextNewClasses(os.Stdout, newClasses, *flagNew)
 		printTextReport(os.Stdout, classes)
+		printTextSuppressions(os.Stdout, rules)
 	}
 }
 
@@ -294,15 +372,45 @@ func extractFailures(revs []*Revision) []*failure {
 	})
 }
 
-func grepFailures(revs []*Revision, re *regexp.Regexp) []*failure {
+func grepFailures(revs []*Revision, g *grepper) []*failure {
 	return processFailureLogs(revs, func(build *Build, data []byte) []*failure {
-		if !re.Match(data) {
+		if !g.match(data) {
 			return nil
 		}
-		return []*failure{new(failure)}
+		f := new(failure)
+		if g.context >= 0 {
+			f.Snippets = g.snippets(data)
+		}
+		return []*failure{f}
 	})
 }
 
+// classifyOptions returns the loganal classification options given by
+// the command-line flags.
+func classifyOptions() loganal.ClassifyOptions {
+	opts := loganal.ClassifyOptions{
+		ByStack:     *flagByStack,
+		StackDepth:  *flagStackDepth,
+		MaxDistance: *flagMaxDistance,
+		IDF:         *flagIDF,
+	}
+	switch *flagDistance {
+	case "jaccard":
+		opts.Distance = loganal.DistanceJaccard
+	case "lcs":
+		opts.Distance = loganal.DistanceLCS
+	default:
+		log.Fatalf("unknown -distance metric %q", *flagDistance)
+	}
+	if opts.StackDepth < 1 {
+		log.Fatal("-stack-depth must be at least 1")
+	}
+	if opts.MaxDistance < 0 || opts.MaxDistance > 1 {
+		log.Fatal("-max-distance must be between 0 and 1")
+	}
+	return opts
+}
+
 type failure struct {
 	*loganal.Failure
 
@@ -310,6 +418,10 @@ type failure struct {
 	CommitsAgo int
 	Rev        *Revision
 	Build      *Build
+
+	// Snippets are the matching lines of the log, with context,
+	// in grep mode with -show-lines.
+	Snippets []string
 }
 
 type failureClass struct {
@@ -335,6 +447,15 @@ type failureClass struct {
 	// Current is the probability that this failure is still
 	// happening.
 	Current float64
+
+	// Builders is the flake test of each builder this failure
+	// happened on, or nil without -by-builder.
+	Builders []*builderFlake
+
+	// Diff compares the first failure's log with an earlier
+	// build, or is nil if not requested or there was no build to
+	// compare against.
+	Diff *logDiff
 }
 
 func newFailureClass(revs []*Revision, failures []*failure) *failureClass {
@@ -352,6 +473,9 @@ func newFailureClass(revs []*Revision, failures []*failure) *failureClass {
 	fc.Test = FlakeTest(times)
 	fc.Latest = &fc.Test.All[0]
 	fc.Current = fc.Latest.StillHappening(len(revs) - 1)
+	if *flagBuilders {
+		fc.Builders = newBuilderFlakes(revs, failures)
+	}
 	return &fc
 }
 
@@ -362,11 +486,13 @@ func (s currentSorter) Len() int {
 }
 
 func (s currentSorter) Less(i, j int) bool {
-	if s[i].Current != s[j].Current {
-		return s[i].Current < s[j].Current
+	ci, pi := s[i].weighted()
+	cj, pj := s[j].weighted()
+	if ci != cj {
+		return ci < cj
 	}
-	if s[i].Latest.FailureProbability != s[j].Latest.FailureProbability {
-		return s[i].Latest.FailureProbability < s[j].Latest.FailureProbability
+	if pi != pj {
+		return pi < pj
 	}
 	return s[i].Class.String() < s[j].Class.String()
 }
@@ -374,3 +500,30 @@ func (s currentSorter) Less(i, j int) bool {
 func (s currentSorter) Swap(i, j int) {
 	s[i], s[j] = s[j], s[i]
 }
+
+// CommitsAgo returns how many commits ago fc was first observed.
+func (fc *failureClass) CommitsAgo() int {
+	return fc.Failures[0].CommitsAgo
+}
+
+// newSorter sorts failure classes by how recently they were first
+// observed, most recent first.
+type newSorter []*failureClass
+
+func (s newSorter) Len() int {
+	return len(s)
+}
+
+func (s newSorter) Less(i, j int) bool {
+	if s[i].CommitsAgo() != s[j].CommitsAgo() {
+		return s[i].CommitsAgo() < s[j].CommitsAgo()
+	}
+	if len(s[i].Failures) != len(s[j].Failures) {
+		return len(s[i].Failures) > len(s[j].Failures)
+	}
+	return s[i].Class.String() < s[j].Class.String()
+}
+
+func (s newSorter) Swap(i, j int) {
+	s[i], s[j] = s[j], s[i]
+}
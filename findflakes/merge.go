@@ -0,0 +1,84 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// mergeRevisions augments branch, a sequence of revisions on one
+// branch ordered from oldest to newest, with revisions from other
+// branches that were merged into it. Merged revisions are inserted
+// immediately before the merge commit that brought them in, in
+// topological order. This is useful for rarely-built branches that
+// periodically merge from master, since the merged revisions provide
+// additional samples of the code that ended up on the branch.
+//
+// repo must be a git checkout containing all of the revisions. all is
+// the set of all loaded revisions; merged revisions that have no
+// logs are skipped.
+func mergeRevisions(repo string, branch, all []*Revision) ([]*Revision, error) {
+	if len(branch) < 2 {
+		return branch, nil
+	}
+
+	byHash := make(map[string]*Revision)
+	for _, rev := range all {
+		byHash[rev.Revision] = rev
+	}
+
+	// Find the merge commits along the branch's first-parent
+	// history. Merges into the oldest revision predate the
+	// window we're analyzing, so we ignore them.
+	oldest, newest := branch[0].Revision, branch[len(branch)-1].Revision
+	out, err := gitLines(repo, "rev-list", "--merges", "--first-parent", newest, "^"+oldest)
+	if err != nil {
+		return nil, err
+	}
+	merges := make(map[string]bool)
+	for _, hash := range out {
+		merges[hash] = true
+	}
+
+	seen := make(map[*Revision]bool)
+	for _, rev := range branch {
+		seen[rev] = true
+	}
+	var revs []*Revision
+	for _, rev := range branch {
+		if merges[rev.Revision] {
+			// List the commits this merge brought in,
+			// parents before children.
+			out, err := gitLines(repo, "rev-list", "--topo-order", "--reverse", rev.Revision+"^@", "^"+rev.Revision+"^1")
+			if err != nil {
+				return nil, err
+			}
+			for _, hash := range out {
+				mrev := byHash[hash]
+				if mrev == nil || seen[mrev] {
+					continue
+				}
+				seen[mrev] = true
+				revs = append(revs, mrev)
+			}
+		}
+		revs = append(revs, rev)
+	}
+	return revs, nil
+}
+
+// gitLines runs git in repo and returns its output split into lines.
+func gitLines(repo string, args ...string) ([]string, error) {
+	cmd := exec.Command("git", append([]string{"-C", repo}, args...)...)
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git %s failed: %v", strings.Join(args, " "), err)
+	}
+	return strings.Fields(string(out)), nil
+}
@@ -0,0 +1,104 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// A suppressRule excludes matching failures from the report.
+type suppressRule struct {
+	// Rule is the rule as written in the suppression file.
+	Rule string
+
+	// Count is the number of failures suppressed by this rule.
+	Count int
+
+	builder *regexp.Regexp // nil matches all builders
+	re      *regexp.Regexp
+}
+
+// readSuppressions reads a suppression file. Each line of the file is
+// a regexp that is matched against the failure fingerprint (as shown
+// in the report), optionally preceded by "builder=regexp" to restrict
+// the rule to matching builders. Blank lines and lines starting with
+// "#" are ignored.
+func readSuppressions(path string) ([]*suppressRule, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var rules []*suppressRule
+	scanner := bufio.NewScanner(f)
+	for lineno := 1; scanner.Scan(); lineno++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		rule := &suppressRule{Rule: line}
+		if strings.HasPrefix(line, "builder=") {
+			fs := strings.SplitN(line, " ", 2)
+			if len(fs) != 2 {
+				return nil, fmt.Errorf("%s:%d: missing failure regexp", path, lineno)
+			}
+			rule.builder, err = regexp.Compile(strings.TrimPrefix(fs[0], "builder="))
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: %v", path, lineno, err)
+			}
+			line = strings.TrimSpace(fs[1])
+		}
+		rule.re, err = regexp.Compile(line)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, lineno, err)
+		}
+		rules = append(rules, rule)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// suppressFailures returns the failures that don't match any rule and
+// updates each rule's Count. A failure matching several rules is
+// counted against the first.
+func suppressFailures(rules []*suppressRule, failures []*failure) []*failure {
+	if len(rules) == 0 {
+		return failures
+	}
+	out := []*failure{}
+outer:
+	for _, f := range failures {
+		fp := f.Failure.String()
+		for _, rule := range rules {
+			if rule.builder != nil && !rule.builder.MatchString(f.Build.Builder) {
+				continue
+			}
+			if rule.re.MatchString(fp) {
+				rule.Count++
+				continue outer
+			}
+		}
+		out = append(out, f)
+	}
+	return out
+}
+
+func printTextSuppressions(w io.Writer, rules []*suppressRule) {
+	if len(rules) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "Suppressed failures:\n")
+	for _, rule := range rules {
+		fmt.Fprintf(w, "  %5d %s\n", rule.Count, rule.Rule)
+	}
+}
@@ -7,6 +7,7 @@ package main
 import (
 	"fmt"
 	"io"
+	"strings"
 )
 
 func round(x float64) int {
@@ -27,11 +28,25 @@ func pct(x float64) string {
 func printTextReport(w io.Writer, classes []*failureClass) {
 	for _, fc := range classes {
 		fmt.Fprintf(w, "%s\n", fc.Class)
+		if fc.Class.Stack != "" {
+			fmt.Fprintf(w, "Stack:\n  %s\n", strings.ReplaceAll(fc.Class.Stack, "\n", "\n  "))
+		}
 		printTextFlakeReport(w, fc)
 		fmt.Fprintln(w)
 	}
 }
 
+func printTextNewClasses(w io.Writer, classes []*failureClass, window int) {
+	if len(classes) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "New in the last %d commits:\n", window)
+	for _, fc := range classes {
+		fmt.Fprintf(w, "  %3d commits ago, %3d failures: %s\n", fc.CommitsAgo(), len(fc.Failures), fc.Class)
+	}
+	fmt.Fprintln(w)
+}
+
 func printTextFlakeReport(w io.Writer, fc *failureClass) {
 	// TODO: Report deterministic failures better.
 	//
@@ -50,6 +65,17 @@ func printTextFlakeReport(w io.Writer, fc *failureClass) {
 		}
 	}
 
+	if len(fc.Builders) > 0 {
+		fmt.Fprintf(w, "By builder:\n")
+		for _, bf := range fc.Builders {
+			if bf.Latest.First == bf.Latest.Last {
+				fmt.Fprintf(w, "  %s: isolated failure (%d runs ago)\n", bf.Builder, len(bf.Trials)-bf.Latest.Last-1)
+				continue
+			}
+			fmt.Fprintf(w, "  %s: %s failure probability (%d of %d runs), %s chance still happening\n", bf.Builder, pct(bf.Latest.FailureProbability), bf.Latest.Failures, bf.Runs(), pct(bf.Current))
+		}
+	}
+
 	if len(fc.Test.All) > 1 {
 		fmt.Fprintf(w, "Past failures:\n")
 		for _, reg := range fc.Test.All[1:] {
@@ -64,4 +90,21 @@ func printTextFlakeReport(w io.Writer, fc *failureClass) {
 	} else {
 		fmt.Fprintf(w, "No known past failures\n")
 	}
+
+	if d := fc.Diff; d != nil {
+		printTextLogDiff(w, d)
+	}
+}
+
+func printTextLogDiff(w io.Writer, d *logDiff) {
+	fmt.Fprintf(w, "First failure on %s compared with %s:\n", d.Failure.Build.Builder, d.Base.Revision)
+	if len(d.Warnings) == 0 && len(d.Timings) == 0 {
+		fmt.Fprintf(w, "  No new warnings or timing changes\n")
+	}
+	for _, warn := range d.Warnings {
+		fmt.Fprintf(w, "  + %s\n", warn)
+	}
+	for _, c := range d.Timings {
+		fmt.Fprintf(w, "  ~ %s\n", c)
+	}
 }
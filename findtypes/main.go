@@ -48,7 +48,7 @@ func main() {
 	failure := parseGreyobject(failFile)
 	failFile.Close()
 	if failure.words == nil {
-		log.Fatal("failed to parse failure message in %s", failPath)
+		log.Fatalf("failed to parse failure message in %s", failPath)
 	}
 	fmt.Print("failure:")
 	for i, known := range failure.words {
@@ -0,0 +1,241 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// Funcdata and pcdata indexes. These must match
+// cmd/internal/objabi/funcdata.go.
+const (
+	funcdataArgsPointerMaps   = 0
+	funcdataLocalsPointerMaps = 1
+	funcdataArgInfo           = 5
+
+	pcdataStackMapIndex = 1
+)
+
+// Bytes returns the contents of s decoded from its hex dump.
+func (s Sym) Bytes() []byte {
+	var data []byte
+	for _, line := range strings.Split(s.data, "\n") {
+		// Data lines look like "\t0x0010 05 00 ...   ascii",
+		// where the hex bytes are padded to 16 columns.
+		if !strings.HasPrefix(line, "\t0x") {
+			continue
+		}
+		f := strings.SplitN(line[1:], " ", 2)
+		if len(f) != 2 || len(f[0]) != 6 {
+			continue
+		}
+		hexPart := f[1]
+		if len(hexPart) > 16*3 {
+			hexPart = hexPart[:16*3]
+		}
+		for _, b := range strings.Fields(hexPart) {
+			v, err := hex.DecodeString(b)
+			if err != nil || len(v) != 1 {
+				// Not a data line.
+				break
+			}
+			data = append(data, v[0])
+		}
+	}
+	return data
+}
+
+// A stackMap is a decoded runtime.stackmap: a sequence of pointer
+// bitmaps, one per stack map index.
+type stackMap struct {
+	nbit    int
+	bitmaps [][]byte
+}
+
+func decodeStackMap(data []byte) (*stackMap, error) {
+	if len(data) < 8 {
+		return nil, fmt.Errorf("stack map too short")
+	}
+	n := int(int32(binary.LittleEndian.Uint32(data)))
+	nbit := int(int32(binary.LittleEndian.Uint32(data[4:])))
+	data = data[8:]
+	size := (nbit + 7) / 8
+	if n < 0 || nbit < 0 || len(data) < n*size {
+		return nil, fmt.Errorf("malformed stack map (%d bitmaps of %d bits, %d bytes)", n, nbit, len(data))
+	}
+	sm := &stackMap{nbit: nbit}
+	for i := 0; i < n; i++ {
+		sm.bitmaps = append(sm.bitmaps, data[i*size:(i+1)*size])
+	}
+	return sm, nil
+}
+
+// bits formats bitmap i as a string of 0s and 1s, one per pointer
+// word, starting with the lowest address.
+func (sm *stackMap) bits(i int) string {
+	if sm == nil {
+		return "-"
+	}
+	if i < 0 || i >= len(sm.bitmaps) {
+		return "?"
+	}
+	if sm.nbit == 0 {
+		return "(none)"
+	}
+	var buf strings.Builder
+	for bit := 0; bit < sm.nbit; bit++ {
+		if sm.bitmaps[i][bit/8]&(1<<(bit%8)) != 0 {
+			buf.WriteByte('1')
+		} else {
+			buf.WriteByte('0')
+		}
+	}
+	return buf.String()
+}
+
+// decodeArgInfo formats an arginfo1 funcdata blob as a list of
+// offset:size pairs, using the encoding from
+// cmd/compile/internal/liveness/arg.go.
+func decodeArgInfo(data []byte) string {
+	var parts []string
+	for i := 0; i < len(data); i++ {
+		switch data[i] {
+		case 0xff: // end of sequence
+			return strings.Join(parts, " ")
+		case 0xfe: // start of aggregate
+			parts = append(parts, "{")
+		case 0xfd: // end of aggregate
+			parts = append(parts, "}")
+		case 0xfc: // more args than can be printed
+			parts = append(parts, "...")
+		case 0xfb: // offset too large
+			parts = append(parts, "?")
+			i++
+		default:
+			if i+1 >= len(data) {
+				return strings.Join(parts, " ") + " (truncated)"
+			}
+			parts = append(parts, fmt.Sprintf("%d:%d", data[i], data[i+1]))
+			i++
+		}
+	}
+	return strings.Join(parts, " ") + " (unterminated)"
+}
+
+// PrintDecoded prints the human-readable form of funcdata symbols,
+// such as stack maps and argument layouts, as comments. It prints
+// nothing for other symbols.
+func (s Sym) PrintDecoded(w io.Writer) {
+	switch {
+	case strings.HasPrefix(s.name, "gclocals·"):
+		sm, err := decodeStackMap(s.Bytes())
+		if err != nil {
+			fmt.Fprintf(w, "# %s\n", err)
+			return
+		}
+		fmt.Fprintf(w, "# stack map: %d bitmaps of %d words\n", len(sm.bitmaps), sm.nbit)
+		for i := range sm.bitmaps {
+			fmt.Fprintf(w, "#\t%d: %s\n", i, sm.bits(i))
+		}
+	case strings.HasSuffix(s.name, ".arginfo1"):
+		fmt.Fprintf(w, "# arg layout (offset:size): %s\n", decodeArgInfo(s.Bytes()))
+	}
+}
+
+var (
+	insnRe     = regexp.MustCompile(`^\t(0x[0-9a-f]+) [0-9]+ \(([^)]+)\)\t(.*)$`)
+	funcdataRe = regexp.MustCompile(`^FUNCDATA \$([0-9]+), ([^\s]+)\(SB\)`)
+	pcdataRe   = regexp.MustCompile(`^PCDATA \$([0-9]+), \$(-?[0-9]+)`)
+)
+
+// PrintLiveness prints the pointer liveness of function s's arguments
+// and locals at each point where its stack map changes, using the
+// funcdata symbols in syms.
+func (s Sym) PrintLiveness(w io.Writer, syms map[string]Sym) {
+	kind, _, ok := s.Header()
+	if !ok || kind != "STEXT" {
+		return
+	}
+
+	type row struct {
+		pc, pos, insn string
+		idx           int
+	}
+	var rows []row
+	var args, locals *stackMap
+	var argInfo string
+	idx, pending := -1, false
+	for _, line := range strings.Split(s.data, "\n") {
+		m := insnRe.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		insn := strings.ReplaceAll(m[3], "\t", " ")
+		if fm := funcdataRe.FindStringSubmatch(insn); fm != nil {
+			sym, ok := syms[fm[2]]
+			if !ok {
+				continue
+			}
+			var err error
+			switch n, _ := strconv.Atoi(fm[1]); n {
+			case funcdataArgsPointerMaps:
+				args, err = decodeStackMap(sym.Bytes())
+			case funcdataLocalsPointerMaps:
+				locals, err = decodeStackMap(sym.Bytes())
+			case funcdataArgInfo:
+				argInfo = decodeArgInfo(sym.Bytes())
+			}
+			if err != nil {
+				fmt.Fprintf(w, "# %s: %s\n", fm[2], err)
+			}
+			continue
+		}
+		if pm := pcdataRe.FindStringSubmatch(insn); pm != nil {
+			if n, _ := strconv.Atoi(pm[1]); n == pcdataStackMapIndex {
+				idx, _ = strconv.Atoi(pm[2])
+				pending = true
+			}
+			continue
+		}
+		if pending {
+			// Attribute the stack map to the first real
+			// instruction it applies to.
+			pending = false
+			if idx >= 0 {
+				pos := m[2]
+				if filepath.IsAbs(pos) {
+					pos = filepath.Base(pos)
+				}
+				rows = append(rows, row{m[1], pos, insn, idx})
+			}
+		}
+	}
+
+	words := func(sm *stackMap) string {
+		if sm == nil {
+			return "unknown"
+		}
+		return fmt.Sprintf("%d words", sm.nbit)
+	}
+	fmt.Fprintf(w, "# liveness for %s (args: %s, locals: %s)\n", s.name, words(args), words(locals))
+	if argInfo != "" {
+		fmt.Fprintf(w, "# arg layout (offset:size): %s\n", argInfo)
+	}
+	tw := tabwriter.NewWriter(w, 1, 4, 1, ' ', 0)
+	fmt.Fprintf(tw, "#\tpc\tpos\tmap\targs\tlocals\tinstruction\n")
+	for _, r := range rows {
+		fmt.Fprintf(tw, "#\t%s\t%s\t%d\t%s\t%s\t%s\n", r.pc, r.pos, r.idx, args.bits(r.idx), locals.bits(r.idx), r.insn)
+	}
+	tw.Flush()
+	fmt.Fprintf(w, "\n")
+}
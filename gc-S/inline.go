@@ -0,0 +1,88 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// diagRe matches a compiler diagnostic, such as the output of
+// compile -m.
+var diagRe = regexp.MustCompile(`^(\S+):(\d+):(\d+): (.*)$`)
+
+// An inlineInfo maps function names to the compiler's inlining
+// decision for each function, as reported by compile -m. Functions
+// are named as in compile -S output, such as "main.(*T).m", if the
+// diagnostic was preceded by a go build package header, and also
+// without their package, such as "(*T).m". The compiler names the
+// symbols of main packages "main" rather than by their path, so the
+// names without packages are needed to find those. If functions in
+// different packages have the same name without their packages, that
+// name maps to "".
+type inlineInfo map[string]string
+
+var (
+	canInlineRe    = regexp.MustCompile(`^can inline (\S+)(?: with cost (\d+))?`)
+	cannotInlineRe = regexp.MustCompile(`^cannot inline (\S+): (.*)$`)
+)
+
+// addDiag records the inlining decision in compile -m diagnostic msg
+// for a function in package pkg. It ignores other diagnostics.
+func (m inlineInfo) addDiag(pkg, msg string) {
+	var name, verdict string
+	if sub := canInlineRe.FindStringSubmatch(msg); sub != nil {
+		name, verdict = sub[1], "inlinable"
+		if sub[2] != "" {
+			verdict += fmt.Sprintf(" (cost %s)", sub[2])
+		}
+		verdict += " but not inlined here"
+	} else if sub := cannotInlineRe.FindStringSubmatch(msg); sub != nil {
+		name, verdict = sub[1], "not inlinable: "+sub[2]
+	} else {
+		return
+	}
+	if pkg != "" {
+		m[pkg+"."+name] = verdict
+	}
+	if old, ok := m[name]; ok && old != verdict {
+		// Ambiguous.
+		verdict = ""
+	}
+	m[name] = verdict
+}
+
+// lookup returns the inlining decision for the function sym.
+func (m inlineInfo) lookup(sym string) (string, bool) {
+	if v, ok := m[sym]; ok {
+		return v, true
+	}
+	// Try without the package path.
+	name := sym
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+	if i := strings.Index(name, "."); i >= 0 {
+		v := m[name[i+1:]]
+		return v, v != ""
+	}
+	return "", false
+}
+
+var callRe = regexp.MustCompile(`(?m)^(\t0x[0-9a-f]+ [0-9]+ \([^)]*\)\s+CALL\s+(\S+)\(SB\).*)$`)
+
+// withInlining returns s with each direct call annotated with the
+// compiler's inlining decision for the callee.
+func (s Sym) withInlining(m inlineInfo) Sym {
+	s.data = callRe.ReplaceAllStringFunc(s.data, func(line string) string {
+		callee := callRe.FindStringSubmatch(line)[2]
+		if v, ok := m.lookup(callee); ok {
+			return line + "\t// " + v
+		}
+		return line
+	})
+	return s
+}
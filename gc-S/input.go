@@ -0,0 +1,83 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// readSyms returns the symbols from the compile -S output in files,
+// or from standard input if there are no files. If build is not "",
+// it instead builds the packages matching build with -S and reads the
+// compiler output. If deps is set, this includes all of their
+// dependencies. If inl is non-nil, readSyms records inlining
+// decisions in it (see parseSyms), and builds with -m=2 so there are
+// some.
+func readSyms(files []string, build string, deps bool, inl inlineInfo) <-chan Sym {
+	if build != "" {
+		return parseSyms(bytes.NewReader(buildS(strings.Fields(build), deps, inl != nil)), inl)
+	}
+	if len(files) == 0 {
+		return parseSyms(os.Stdin, inl)
+	}
+	ch := make(chan Sym)
+	go func() {
+		defer close(ch)
+		for _, path := range files {
+			f, err := os.Open(path)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			for sym := range parseSyms(f, inl) {
+				ch <- sym
+			}
+			f.Close()
+		}
+	}()
+	return ch
+}
+
+// buildS runs go build on pkgs with -S, and -m=2 if inl is set, and
+// returns the compiler's output.
+func buildS(pkgs []string, deps, inl bool) []byte {
+	flags := "-S"
+	if inl {
+		flags += " -m=2"
+	}
+	gcflags := "-gcflags=" + flags
+	if deps {
+		gcflags = "-gcflags=all=" + flags
+	}
+	// go build replays cached compiler output, so this works even
+	// if nothing needs to be rebuilt.
+	args := append([]string{"build", "-o", os.DevNull, gcflags}, pkgs...)
+	cmd := exec.Command("go", args...)
+	var out bytes.Buffer
+	cmd.Stderr = &out
+	if err := cmd.Run(); err != nil {
+		os.Stderr.Write(out.Bytes())
+		fmt.Fprintf(os.Stderr, "go %s: %s\n", strings.Join(args, " "), err)
+		os.Exit(1)
+	}
+	return out.Bytes()
+}
+
+// packageHeader returns the package path from a "# path" line, which
+// go build prints before the compiler output of each package.
+func packageHeader(line string) (string, bool) {
+	path := strings.TrimPrefix(line, "# ")
+	if path == line || path == "" || strings.ContainsAny(path, " \t") {
+		return "", false
+	}
+	if path == "command-line-arguments" {
+		path = "main"
+	}
+	return path, true
+}
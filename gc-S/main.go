@@ -4,6 +4,32 @@
 
 // gc-S reads the output of compile -S to find a symbol and symbols it
 // references.
+//
+// gc-S reads compile -S output from standard input or from the files
+// named after the regexp, in which case it merges the symbols from all
+// of the files. With -build, it instead runs go build -gcflags=-S on
+// the given packages and reads the compiler output for all of them,
+// so it can trace references across packages. With -deps, it also
+// compiles their dependencies with -S, so it can trace into, for
+// example, the runtime.
+//
+// With -sizes, gc-S instead prints the encoded size of each symbol,
+// including its funcdata. With -diff, it compares the sizes of
+// symbols in two compile -S outputs.
+//
+// With -funcdata, gc-S also decodes the funcdata of the symbols it
+// prints. For each matched function, it prints which argument and
+// local pointer words are live at each stack map (that is, at each
+// call), and it prints stack map symbols (gclocals·*) and argument
+// layouts (*.arginfo1) in human-readable form rather than only as
+// hex. This is useful for reviewing compiler liveness changes.
+//
+// With -inl, gc-S annotates each direct CALL in the printed assembly
+// with the compiler's inlining decision for the callee: why it can't
+// be inlined, or that it could have been but wasn't at this call. It
+// reads these decisions from compile -m output mixed into the input,
+// such as from go build -gcflags='-S -m=2'. With -build, it compiles
+// with -m=2 itself.
 package main
 
 import (
@@ -21,43 +47,105 @@ import (
 
 func main() {
 	flag.Usage = func() {
-		fmt.Fprintf(os.Stderr, "Usage: <compile -S output> | %s regexp\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Usage: <compile -S output> | %s [-funcdata] [-inl] regexp\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s [-funcdata] [-inl] regexp files...\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s -build packages [-deps] [-funcdata] [-inl] regexp\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s -sizes [-build packages | files...]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s -diff old.S new.S\n", os.Args[0])
 		flag.PrintDefaults()
 	}
 
+	flagSizes := flag.Bool("sizes", false, "print symbol sizes, largest first")
+	flagDiff := flag.Bool("diff", false, "print symbol size changes between two compile -S outputs")
+	flagFuncdata := flag.Bool("funcdata", false, "decode stack maps and argument layouts and print liveness of matched functions")
+	flagBuild := flag.String("build", "", "run go build -gcflags=-S on `packages` instead of reading compile -S output")
+	flagDeps := flag.Bool("deps", false, "with -build, also compile the packages' dependencies with -S")
+	flagInl := flag.Bool("inl", false, "annotate calls with the compiler's inlining decisions from compile -m output (with -build, compile with -m=2)")
 	flag.Parse()
-	if flag.NArg() != 1 {
+	switch {
+	case *flagSizes && *flagDiff:
+		flag.Usage()
+		os.Exit(1)
+	case *flagSizes:
+		if *flagBuild != "" && flag.NArg() != 0 {
+			flag.Usage()
+			os.Exit(1)
+		}
+		printSizes(os.Stdout, symSizes(readSyms(flag.Args(), *flagBuild, *flagDeps, nil)))
+		return
+	case *flagDiff:
+		if flag.NArg() != 2 {
+			flag.Usage()
+			os.Exit(1)
+		}
+		printSizeDiff(os.Stdout, readSizes(flag.Arg(0)), readSizes(flag.Arg(1)))
+		return
+	}
+	if flag.NArg() < 1 || (*flagBuild != "" && flag.NArg() != 1) {
 		flag.Usage()
 		os.Exit(1)
 	}
-	regexp, err := regexp.Compile(os.Args[1])
+	regexp, err := regexp.Compile(flag.Arg(0))
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "regexp error: %s\n", err)
 		os.Exit(1)
 	}
 
-	symCh := parseSyms(os.Stdin)
+	var inl inlineInfo
+	if *flagInl {
+		inl = make(inlineInfo)
+	}
+	symCh := readSyms(flag.Args()[1:], *flagBuild, *flagDeps, inl)
+
+	print := func(sym Sym) {
+		if inl != nil {
+			sym = sym.withInlining(inl)
+		}
+		sym.Print(os.Stdout)
+		if *flagFuncdata {
+			sym.PrintDecoded(os.Stdout)
+		}
+	}
 
 	// Collect all symbols. For matching symbols, print them immediately and add
-	// them as roots to the trace.
+	// them as roots to the trace. Decoding liveness requires the
+	// funcdata symbols, which follow the function, and the inlining
+	// decisions may come after the calls, so with -funcdata or
+	// -inl, print them once we've read everything.
+	deferPrint := *flagFuncdata || inl != nil
 	syms := make(map[string]Sym)
 	q := []string{}
 	printed := make(map[string]bool) // false = added, not printed
 	for sym := range symCh {
+		if _, ok := syms[sym.name]; ok {
+			// Duplicate symbols, such as type descriptors,
+			// appear in every package that uses them.
+			continue
+		}
 		if regexp.MatchString(sym.name) {
-			sym.Print(os.Stdout)
+			if !deferPrint {
+				print(sym)
+			}
 			printed[sym.name] = true
 			q = append(q, sym.name)
 		}
 		syms[sym.name] = sym
 	}
+	if deferPrint {
+		for _, name := range q {
+			print(syms[name])
+			if *flagFuncdata {
+				syms[name].PrintLiveness(os.Stdout, syms)
+			}
+		}
+	}
 
 	// Trace referenced symbols.
 	for len(q) > 0 {
 		if sym, ok := syms[q[0]]; ok {
 			if !printed[q[0]] {
 				printed[q[0]] = true
-				sym.Print(os.Stdout)
+				print(sym)
 			}
 			for _, ref := range sym.Refs() {
 				if _, ok := printed[ref]; ok {
@@ -76,7 +164,11 @@ type Sym struct {
 	data string
 }
 
-func parseSyms(r io.Reader) <-chan Sym {
+// parseSyms parses compile -S output from r. Compiler diagnostics
+// mixed into the output are skipped, and if inl is non-nil, their
+// inlining decisions are recorded in inl before the channel is
+// closed.
+func parseSyms(r io.Reader, inl inlineInfo) <-chan Sym {
 	ch := make(chan Sym)
 	go func() {
 		defer close(ch)
@@ -84,18 +176,35 @@ func parseSyms(r io.Reader) <-chan Sym {
 		scanner := bufio.NewScanner(r)
 		var accum bytes.Buffer
 		var name string
+		// Older compilers name the symbols of the package being
+		// compiled with a "". prefix. Qualify these with the
+		// package from the go build header, if there is one, so
+		// symbols from different packages don't collide.
+		var pkg string
 		flush := func() {
+			// Symbols without names (like SDWARFVAR
+			// symbols) are dropped.
 			if name != "" {
 				ch <- Sym{name, accum.String()}
 				name = ""
-				accum.Reset()
 			}
+			accum.Reset()
 		}
 		for scanner.Scan() {
 			l := scanner.Text()
+			if pkg != "" && !strings.HasPrefix(l, "#") {
+				l = strings.ReplaceAll(l, `"".`, pkg+".")
+			}
 			switch {
 			case strings.HasPrefix(l, "#"):
-				// Ignore
+				if path, ok := packageHeader(l); ok {
+					flush()
+					pkg = path
+				}
+			case diagRe.MatchString(l):
+				if inl != nil {
+					inl.addDiag(pkg, diagRe.FindStringSubmatch(l)[4])
+				}
 			default:
 				flush()
 				name, _, _ = strings.Cut(l, " ")
@@ -107,7 +216,7 @@ func parseSyms(r io.Reader) <-chan Sym {
 		}
 		flush()
 		if err := scanner.Err(); err != nil {
-			fmt.Fprintln(os.Stderr, "reading standard input:", err)
+			fmt.Fprintln(os.Stderr, "reading compile -S output:", err)
 		}
 	}()
 	return ch
@@ -0,0 +1,204 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// A SymSize is the total encoded size of a symbol.
+type SymSize struct {
+	Name string
+	Kind string // STEXT, SRODATA, etc.
+	Size int64
+}
+
+var headerRe = regexp.MustCompile(`^(\S+) (\S+)\b.*\bsize=([0-9]+)`)
+
+// Header returns the kind and size from s's header line.
+func (s Sym) Header() (kind string, size int64, ok bool) {
+	line, _, _ := strings.Cut(s.data, "\n")
+	m := headerRe.FindStringSubmatch(line)
+	if m == nil {
+		return "", 0, false
+	}
+	size, err := strconv.ParseInt(m[3], 10, 64)
+	if err != nil {
+		return "", 0, false
+	}
+	return m[2], size, true
+}
+
+// symSizes sums the sizes of the symbols from syms. The funcdata
+// symbols of a function, such as its stack maps (gclocals·*) and
+// fn.arginfo1, are counted toward that function. A function's funcdata
+// are the symbols named by the FUNCDATA instructions in its body and
+// the non-text symbols named "fn.*". compile -S prints all text
+// symbols before any data symbols, so this can't rely on the order of
+// syms. The compiler shares identical stack maps between functions;
+// these are counted once, on their own.
+func symSizes(syms <-chan Sym) map[string]*SymSize {
+	sizes := make(map[string]*SymSize)
+	add := func(name, kind string, size int64) {
+		ss := sizes[name]
+		if ss == nil {
+			ss = &SymSize{Name: name, Kind: kind}
+			sizes[name] = ss
+		}
+		ss.Size += size
+	}
+
+	// Text symbols reference their funcdata, so collect the text
+	// symbols and their references before attributing the data.
+	type dataSym struct {
+		name, kind string
+		size       int64
+	}
+	var data []dataSym
+	users := make(map[string][]string) // Functions that use each funcdata symbol
+	for sym := range syms {
+		kind, size, ok := sym.Header()
+		if !ok {
+			continue
+		}
+		if kind != "STEXT" {
+			data = append(data, dataSym{sym.name, kind, size})
+			continue
+		}
+		add(sym.name, kind, size)
+		for _, fd := range sym.funcdataSyms() {
+			if u := users[fd]; len(u) == 0 || u[len(u)-1] != sym.name {
+				users[fd] = append(u, sym.name)
+			}
+		}
+	}
+
+	counted := make(map[string]bool)
+	for _, d := range data {
+		var owner string
+		u := users[d.name]
+		if len(u) == 1 {
+			owner = u[0]
+		} else if len(u) == 0 {
+			if i := strings.LastIndex(d.name, "."); i >= 0 {
+				if ss := sizes[d.name[:i]]; ss != nil && ss.Kind == "STEXT" {
+					owner = ss.Name
+				}
+			}
+		}
+		if len(u) > 0 || owner != "" || strings.HasPrefix(d.name, "gclocals·") {
+			// Funcdata symbols are dupok, so the linker
+			// keeps one copy however many packages emit it.
+			if counted[d.name] {
+				continue
+			}
+			counted[d.name] = true
+		}
+		if owner != "" {
+			sizes[owner].Size += d.size
+		} else {
+			add(d.name, d.kind, d.size)
+		}
+	}
+	return sizes
+}
+
+// funcdataSyms returns the symbols referenced by FUNCDATA
+// instructions in text symbol s, in order.
+func (s Sym) funcdataSyms() []string {
+	var names []string
+	for _, line := range strings.Split(s.data, "\n") {
+		m := insnRe.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		if fm := funcdataRe.FindStringSubmatch(strings.ReplaceAll(m[3], "\t", " ")); fm != nil {
+			names = append(names, fm[2])
+		}
+	}
+	return names
+}
+
+func readSizes(path string) map[string]*SymSize {
+	f, err := os.Open(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	defer f.Close()
+	return symSizes(parseSyms(f, nil))
+}
+
+// printSizes prints a table of symbol sizes, largest first, in the
+// same size/type/name order as "go tool nm -size -sort size".
+func printSizes(w io.Writer, sizes map[string]*SymSize) {
+	list := make([]*SymSize, 0, len(sizes))
+	var total int64
+	for _, ss := range sizes {
+		list = append(list, ss)
+		total += ss.Size
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Size != list[j].Size {
+			return list[i].Size > list[j].Size
+		}
+		return list[i].Name < list[j].Name
+	})
+	for _, ss := range list {
+		fmt.Fprintf(w, "%10d %-8s %s\n", ss.Size, ss.Kind, ss.Name)
+	}
+	fmt.Fprintf(w, "%10d %-8s %s\n", total, "", "total")
+}
+
+// printSizeDiff prints the per-symbol size changes from old to new,
+// largest change first. Symbols whose size didn't change are
+// omitted.
+func printSizeDiff(w io.Writer, old, new map[string]*SymSize) {
+	type delta struct {
+		name     string
+		old, new int64
+	}
+	var deltas []delta
+	var oldTotal, newTotal int64
+	for name, ss := range old {
+		oldTotal += ss.Size
+		d := delta{name: name, old: ss.Size}
+		if ss2, ok := new[name]; ok {
+			d.new = ss2.Size
+		}
+		if d.old != d.new {
+			deltas = append(deltas, d)
+		}
+	}
+	for name, ss := range new {
+		newTotal += ss.Size
+		if _, ok := old[name]; !ok {
+			deltas = append(deltas, delta{name: name, new: ss.Size})
+		}
+	}
+	abs := func(x int64) int64 {
+		if x < 0 {
+			return -x
+		}
+		return x
+	}
+	sort.Slice(deltas, func(i, j int) bool {
+		di, dj := abs(deltas[i].new-deltas[i].old), abs(deltas[j].new-deltas[j].old)
+		if di != dj {
+			return di > dj
+		}
+		return deltas[i].name < deltas[j].name
+	})
+	for _, d := range deltas {
+		fmt.Fprintf(w, "%+10d %10d %10d %s\n", d.new-d.old, d.old, d.new, d.name)
+	}
+	fmt.Fprintf(w, "%+10d %10d %10d %s\n", newTotal-oldTotal, oldTotal, newTotal, "total")
+}
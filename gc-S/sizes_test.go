@@ -0,0 +1,76 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import "testing"
+
+// testdata/sizes.txt is the output of go build -gcflags=-S for:
+//
+//	package a
+//
+//	//go:noinline
+//	func g(p *int) *int { return p }
+//
+//	//go:noinline
+//	func h() {}
+//
+//	func F(p, q *int) int {
+//		x := g(p)
+//		y := g(q)
+//		return *x + *y
+//	}
+//
+//	func G(s []*int) *int {
+//		var r *int
+//		for _, p := range s {
+//			r = g(p)
+//		}
+//		h()
+//		return r
+//	}
+//
+//	//go:noinline
+//	func k() {}
+func TestSymSizes(t *testing.T) {
+	sizes := readSizes("testdata/sizes.txt")
+	for _, test := range []struct {
+		name string
+		size int64
+	}{
+		// Each function's text plus its stack maps, arginfo1,
+		// and argliveinfo.
+		{"example.com/gcs/a.g", 1 + 10 + 8 + 3 + 2},
+		{"example.com/gcs/a.F", 86 + 12 + 12 + 5 + 3},
+		{"example.com/gcs/a.G", 133 + 11 + 11 + 9 + 3},
+		// h and k share their (empty) stack map, which is
+		// counted once on its own.
+		{"example.com/gcs/a.h", 1},
+		{"example.com/gcs/a.k", 1},
+		{"gclocals·g5+hNtRBP6YXNjfog7aZjQ==", 8},
+		{"type:[]*int", 56},
+	} {
+		ss := sizes[test.name]
+		if ss == nil {
+			t.Errorf("no size for %s", test.name)
+			continue
+		}
+		if ss.Size != test.size {
+			t.Errorf("%s has size %d, want %d", test.name, ss.Size, test.size)
+		}
+	}
+	for _, name := range []string{"example.com/gcs/a.g.arginfo1", "example.com/gcs/a.F.argliveinfo", "gclocals·XfZysXE/I2f5m114Zl/iuw=="} {
+		if sizes[name] != nil {
+			t.Errorf("funcdata symbol %s counted on its own", name)
+		}
+	}
+	var total int64
+	for _, ss := range sizes {
+		total += ss.Size
+	}
+	// The total is the sum of all symbols in the input.
+	if want := int64(1 + 1 + 86 + 133 + 1 + 8 + 8 + 9 + 56 + 56 + 10 + 8 + 3 + 2 + 8 + 12 + 12 + 5 + 3 + 11 + 11 + 9 + 3); total != want {
+		t.Errorf("total size %d, want %d", total, want)
+	}
+}
@@ -0,0 +1,310 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/aclements/go-misc/git-p/internal/chain"
+	"golang.org/x/term"
+)
+
+// openRemote returns the Gerrit server of remote and the commits of
+// all of remote's refs.
+func openRemote(remote string) (*chain.Gerrit, []string) {
+	gerrit, err := chain.NewGerrit(chain.Git("config", "remote."+remote+".url"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	upstreams := chain.Lines(chain.Git("for-each-ref", "--format", "%(objectname)", "refs/remotes/"+remote+"/"))
+	if len(upstreams) == 0 {
+		log.Fatalf("no refs for remote %s", remote)
+	}
+	return gerrit, upstreams
+}
+
+// plainUnlessTerminal turns off color if stdout isn't a terminal.
+func plainUnlessTerminal() {
+	if !term.IsTerminal(1) || os.Getenv("TERM") == "" || os.Getenv("TERM") == "dumb" {
+		style = nil
+	}
+}
+
+// confirm asks the user a yes or no question and reports whether
+// they answered yes.
+func confirm(question string) bool {
+	fmt.Printf("%s [y/N] ", question)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true
+	}
+	return false
+}
+
+// branchArg returns the full ref name of the branch named by the
+// command-line arguments args, which may be empty to mean the current
+// branch.
+func branchArg(args []string) string {
+	if len(args) == 0 {
+		head, err := chain.TryGit("symbolic-ref", "HEAD")
+		if err != nil {
+			log.Fatal("HEAD is not a branch")
+		}
+		return head
+	}
+	branch, err := chain.TryGit("rev-parse", "--symbolic-full-name", args[0])
+	if err != nil || !strings.HasPrefix(branch, "refs/heads/") {
+		log.Fatalf("%s is not a local branch", args[0])
+	}
+	return branch
+}
+
+// printChain prints the status of the commits on c. If rebase is
+// set, it also warns about commits that need to be rebased.
+func printChain(c *chain.Chain, rebase bool) {
+	fmt.Printf("%s%s%s\n", style["branch"], strings.TrimPrefix(c.Branch, "refs/heads/"), style["reset"])
+	for i, change := range c.Changes {
+		var warning string
+		if rebase {
+			warning = c.RebaseWarning(i)
+		}
+		out, _ := formatChange(c.Commits[i], change, nil, nil, false, warning, false, nil)
+		fmt.Print(out)
+	}
+	fmt.Printf("\n")
+}
+
+// mailMain implements "git-p mail", which mails the commits on a
+// branch to Gerrit.
+func mailMain(args []string) {
+	fs := flag.NewFlagSet("mail", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s mail [flags] [branch]\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Mail the commits on branch (default: the current branch) to Gerrit.\n\n")
+		fs.PrintDefaults()
+	}
+	flagReviewers := fs.String("r", "", "request review from the comma-separated `emails`")
+	flagYes := fs.Bool("y", false, "mail without asking for confirmation")
+	flagDryRun := fs.Bool("n", false, "print the git push command without running it")
+	fs.Parse(args)
+	if fs.NArg() > 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	plainUnlessTerminal()
+
+	remote := "origin"
+	gerrit, upstreams := openRemote(remote)
+	c := chain.LoadChain(gerrit, branchArg(fs.Args()), remote, upstreams)
+	if len(c.Commits) == 0 {
+		fmt.Printf("No commits to mail.\n")
+		return
+	}
+	infos := c.Wait()
+
+	// Gerrit rejects the whole push if any commit can't be
+	// mailed, so check for that up front.
+	nMail := 0
+	for i, commit := range c.Commits {
+		if c.ChangeIDs[i] == "" {
+			log.Fatalf("commit %.10s has no Change-Id; install the commit-msg hook with \"git codereview hooks\"", commit)
+		}
+		info := infos[i]
+		if info == nil || info.CurrentRevision != commit {
+			nMail++
+		}
+		if info == nil {
+			continue
+		}
+		switch info.Status {
+		case "MERGED":
+			log.Fatalf("commit %.10s is CL %d, which was submitted; run %s rebase-chain first", commit, info.Number, os.Args[0])
+		case "ABANDONED":
+			log.Fatalf("commit %.10s is CL %d, which was abandoned; restore it or drop the commit", commit, info.Number)
+		}
+	}
+	if nMail == 0 {
+		fmt.Printf("Nothing to mail; every commit matches its current patch set.\n")
+		return
+	}
+
+	target := strings.TrimPrefix(c.Upstream, "refs/remotes/"+remote+"/")
+	refspec := c.Commits[0] + ":refs/for/" + target
+	if *flagReviewers != "" {
+		var opts []string
+		for _, r := range strings.Split(*flagReviewers, ",") {
+			opts = append(opts, "r="+strings.TrimSpace(r))
+		}
+		refspec += "%" + strings.Join(opts, ",")
+	}
+	cmd := []string{"push", remote, refspec}
+	if *flagDryRun {
+		fmt.Printf("git %s\n", strings.Join(cmd, " "))
+		return
+	}
+
+	printChain(c, true)
+	if !*flagYes && !confirm(fmt.Sprintf("Mail %d commit(s) for %s?", nMail, target)) {
+		fmt.Printf("Not mailing anything.\n")
+		return
+	}
+	// Gerrit prints the CL links, so show git's output.
+	out, err := chain.TryGit(cmd...)
+	fmt.Println(out)
+	if err != nil {
+		os.Exit(1)
+	}
+}
+
+// submitMain implements "git-p submit", which submits a CL.
+func submitMain(args []string) {
+	fs := flag.NewFlagSet("submit", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s submit [flags] CL\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Submit CL, along with any unsubmitted CLs it depends on.\n\n")
+		fs.PrintDefaults()
+	}
+	flagYes := fs.Bool("y", false, "submit without asking for confirmation")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	m := clArgRe.FindStringSubmatch(fs.Arg(0))
+	if m == nil {
+		fmt.Fprintf(os.Stderr, "CL must be a CL number or golang.org/cl/N\n")
+		os.Exit(2)
+	}
+	cl, _ := strconv.Atoi(m[1])
+
+	gerrit, _ := openRemote("origin")
+	query := fmt.Sprintf("project:%s change:%d", gerrit.Project(), cl)
+	results, err := gerrit.QueryChanges(query, chain.ChangeOptions...).Wait()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(results) != 1 {
+		log.Fatalf("CL %d not found in %s", cl, gerrit.Project())
+	}
+	info := results[0]
+	var tryBots *chain.TryBotRuns
+	if chain.NeedTryBots(info) {
+		tryBots = gerrit.QueryTryBots(info)
+	}
+	status, warnings := chain.ChangeStatus(info.CurrentRevision, info, tryBots)
+	fmt.Printf("%-10s CL %d: %s\n", status, cl, info.Subject)
+	for _, w := range warnings {
+		fmt.Printf("    %s\n", w)
+	}
+	if status != "Ready" {
+		log.Fatalf("CL %d is not ready to submit", cl)
+	}
+
+	together, err := gerrit.SubmittedTogether(cl)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(together) > 0 {
+		fmt.Printf("This will also submit:\n")
+		for _, other := range together {
+			fmt.Printf("    CL %d: %s\n", other.Number, other.Subject)
+		}
+	}
+
+	if !*flagYes && !confirm(fmt.Sprintf("Submit CL %d?", cl)) {
+		fmt.Printf("Not submitting.\n")
+		return
+	}
+	info, err = gerrit.Submit(cl)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if info.Status != "MERGED" {
+		log.Fatalf("CL %d is %s after submitting", cl, info.Status)
+	}
+	fmt.Printf("Submitted CL %d.\n", cl)
+}
+
+// rebaseChainMain implements "git-p rebase-chain", which rebases a
+// branch onto its upstream, dropping commits whose CLs were
+// submitted.
+func rebaseChainMain(args []string) {
+	fs := flag.NewFlagSet("rebase-chain", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s rebase-chain [flags] [branch]\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Fetch and rebase branch (default: the current branch) onto its upstream,\ndropping commits whose CLs have been submitted.\n\n")
+		fs.PrintDefaults()
+	}
+	flagDryRun := fs.Bool("n", false, "print the git rebase command without running it")
+	fs.Parse(args)
+	if fs.NArg() > 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	branch := branchArg(fs.Args())
+
+	remote := "origin"
+	if out, err := chain.TryGit("fetch", "--quiet", remote); err != nil {
+		log.Fatalf("fetching %s: %s", remote, out)
+	}
+	gerrit, upstreams := openRemote(remote)
+	c := chain.LoadChain(gerrit, branch, remote, upstreams)
+	if len(c.Commits) == 0 {
+		fmt.Printf("No commits to rebase.\n")
+		return
+	}
+	infos := c.Wait()
+
+	// Gerrit submits CLs by cherry-picking them, so submitted
+	// commits are still on the branch. Drop them from the bottom
+	// of the chain.
+	n := len(c.Commits)
+	drop := 0
+	for drop < n && infos[n-1-drop] != nil && infos[n-1-drop].Status == "MERGED" {
+		drop++
+	}
+	for i, info := range infos[:n-drop] {
+		if info == nil {
+			continue
+		}
+		switch info.Status {
+		case "MERGED":
+			log.Fatalf("commit %.10s is CL %d, which was submitted before the commits below it; rebase interactively", c.Commits[i], info.Number)
+		case "ABANDONED":
+			log.Fatalf("commit %.10s is CL %d, which was abandoned; restore it or drop the commit", c.Commits[i], info.Number)
+		}
+	}
+	base := c.Parents[n-1]
+	if drop > 0 {
+		base = c.Commits[n-drop]
+	}
+	if base == "" {
+		log.Fatalf("%s starts with a root commit", strings.TrimPrefix(branch, "refs/heads/"))
+	}
+
+	cmd := []string{"rebase", "--onto", c.Upstream, base, strings.TrimPrefix(branch, "refs/heads/")}
+	if *flagDryRun {
+		fmt.Printf("git %s\n", strings.Join(cmd, " "))
+		return
+	}
+	for i := n - 1; i >= n-drop; i-- {
+		fmt.Printf("Dropping submitted CL %d: %s\n", infos[i].Number, infos[i].Subject)
+	}
+	fmt.Printf("Rebasing %d commit(s) onto %s\n", n-drop, strings.TrimPrefix(c.Upstream, "refs/remotes/"))
+	// Let git talk to the user directly in case of conflicts.
+	rebase := exec.Command("git", cmd...)
+	rebase.Stdin, rebase.Stdout, rebase.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := rebase.Run(); err != nil {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,116 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+
+	"github.com/aclements/go-misc/git-p/internal/chain"
+)
+
+// clArgRe matches a CL number, optionally in the golang.org/cl/N form
+// git-p prints.
+var clArgRe = regexp.MustCompile(`^(?:(?:https?://)?(?:go\.dev|golang\.org)/cl/)?([0-9]+)/?$`)
+
+// checkoutMain implements "git-p checkout", which checks out the
+// current patch set of a CL in a new worktree and prints its path.
+func checkoutMain(args []string) {
+	fs := flag.NewFlagSet("checkout", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s checkout [flags] CL\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Fetch the current patch set of CL and check it out in a worktree.\n\n")
+		fs.PrintDefaults()
+	}
+	flagDir := fs.String("dir", "", "create the worktree in `dir` [default: next to the main worktree]")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	m := clArgRe.FindStringSubmatch(fs.Arg(0))
+	if m == nil {
+		fmt.Fprintf(os.Stderr, "CL must be a CL number or golang.org/cl/N\n")
+		os.Exit(2)
+	}
+	cl, _ := strconv.Atoi(m[1])
+
+	remote := "origin"
+	gerrit, err := chain.NewGerrit(chain.Git("config", "remote."+remote+".url"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	info, rev := currentPatchSet(gerrit, cl)
+
+	dir := *flagDir
+	if dir == "" {
+		dir = clWorktreeDir(cl)
+	}
+
+	// Fetch the patch set. Gerrit doesn't advertise CL refs, so
+	// fetch it explicitly and keep it under a local ref, which
+	// also keeps it from being garbage collected.
+	ref := fmt.Sprintf("refs/cl/%d/%d", cl, rev.Number)
+	if out, err := chain.TryGit("fetch", "--quiet", remote, "+"+rev.Ref+":"+ref); err != nil {
+		log.Fatalf("fetching %s: %s", rev.Ref, out)
+	}
+
+	if _, err := os.Stat(dir); err == nil {
+		// Reuse an existing worktree for this CL, for example
+		// to pick up a new patch set, as long as that won't
+		// lose any work.
+		if status, err := chain.TryGit("-C", dir, "status", "--porcelain"); err != nil {
+			log.Fatalf("%s exists and is not a git worktree", dir)
+		} else if status != "" {
+			log.Fatalf("%s has local changes; not updating it", dir)
+		}
+		if out, err := chain.TryGit("-C", dir, "checkout", "--quiet", "--detach", ref); err != nil {
+			log.Fatalf("updating %s: %s", dir, out)
+		}
+	} else {
+		if out, err := chain.TryGit("worktree", "add", "--quiet", "--detach", dir, ref); err != nil {
+			log.Fatalf("creating worktree %s: %s", dir, out)
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "CL %d patch set %d: %s\n", cl, rev.Number, info.Subject)
+	fmt.Println(dir)
+}
+
+// currentPatchSet returns the change info and current revision of
+// CL number cl in gerrit's project.
+func currentPatchSet(gerrit *chain.Gerrit, cl int) (*chain.GerritChangeInfo, *chain.GerritRevision) {
+	query := fmt.Sprintf("project:%s change:%d", gerrit.Project(), cl)
+	results, err := gerrit.QueryChanges(query, "CURRENT_REVISION").Wait()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(results) != 1 {
+		log.Fatalf("CL %d not found in %s", cl, gerrit.Project())
+	}
+	info := results[0]
+	rev := info.Revisions[info.CurrentRevision]
+	if rev == nil || rev.Ref == "" {
+		log.Fatalf("CL %d has no current patch set", cl)
+	}
+	return info, rev
+}
+
+// clWorktreeDir returns the default worktree directory for CL number
+// cl. It's a sibling of the main worktree named after the repository
+// and the CL, such as "go-cl12345", so worktrees of different
+// repositories don't collide.
+func clWorktreeDir(cl int) string {
+	// The common dir is the main worktree's .git, even when run
+	// from another worktree.
+	common := chain.Git("rev-parse", "--path-format=absolute", "--git-common-dir")
+	top := filepath.Dir(common)
+	return filepath.Join(filepath.Dir(top), fmt.Sprintf("%s-cl%d", filepath.Base(top), cl))
+}
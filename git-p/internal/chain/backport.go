@@ -0,0 +1,78 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package chain
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const releaseBranchPrefix = "release-branch.go"
+
+// QueryBackports queries Gerrit for cherry-picks of the change with
+// full change ID cid onto release branches. Gerrit cherry-picks keep
+// the Change-Id of the original change, so these are the changes
+// with the same Change-Id on any release branch.
+func (g *Gerrit) QueryBackports(cid string) *GerritChanges {
+	id := cid[strings.LastIndexByte(cid, '~')+1:]
+	return g.QueryChanges(fmt.Sprintf("project:%s change:%s branch:^%s.*", g.project, id, releaseBranchPrefix))
+}
+
+// BackportStatus summarizes the release-branch backports of info.
+// backports is the result of QueryBackports for info.
+func BackportStatus(info *GerritChangeInfo, backports []*GerritChangeInfo) string {
+	var merged, pending []string
+	for _, bp := range backports {
+		if bp.Branch == info.Branch || !strings.HasPrefix(bp.Branch, releaseBranchPrefix) {
+			continue
+		}
+		release := strings.TrimPrefix(bp.Branch, releaseBranchPrefix)
+		switch bp.Status {
+		case "MERGED":
+			merged = append(merged, release)
+		case "NEW":
+			pending = append(pending, release)
+		}
+	}
+	sortReleases(merged)
+	sortReleases(pending)
+
+	var msgs []string
+	if len(merged) > 0 {
+		msgs = append(msgs, "backported to "+strings.Join(merged, "/"))
+	}
+	if len(pending) > 0 {
+		msgs = append(msgs, "backport pending to "+strings.Join(pending, "/"))
+	}
+	if len(msgs) == 0 {
+		return "No backport"
+	}
+	msg := strings.Join(msgs, ", ")
+	return strings.ToUpper(msg[:1]) + msg[1:]
+}
+
+// sortReleases sorts a list of Go releases like "1.24" from newest
+// to oldest.
+func sortReleases(releases []string) {
+	parse := func(r string) []int {
+		var vs []int
+		for _, f := range strings.Split(r, ".") {
+			v, _ := strconv.Atoi(f)
+			vs = append(vs, v)
+		}
+		return vs
+	}
+	sort.Slice(releases, func(i, j int) bool {
+		a, b := parse(releases[i]), parse(releases[j])
+		for k := 0; k < len(a) && k < len(b); k++ {
+			if a[k] != b[k] {
+				return a[k] > b[k]
+			}
+		}
+		return len(a) > len(b)
+	})
+}
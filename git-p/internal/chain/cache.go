@@ -0,0 +1,190 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package chain
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// A changeCache stores Gerrit's responses for individual changes in
+// the user's cache directory, so later runs can revalidate them with
+// a conditional request instead of fetching them again. Submitted and
+// abandoned changes rarely change, so their responses are reused
+// without asking Gerrit at all, unless refresh is set.
+type changeCache struct {
+	dir     string
+	refresh bool
+
+	// sem limits the number of concurrent requests, since cached
+	// queries aren't batched.
+	sem chan struct{}
+}
+
+// A cacheEntry is a cached Gerrit response for one change.
+type cacheEntry struct {
+	ETag string
+	Info json.RawMessage // GerritChangeInfo
+}
+
+// newChangeCache returns a changeCache in the user's cache directory.
+func newChangeCache(refresh bool) (*changeCache, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return nil, err
+	}
+	dir = filepath.Join(dir, "git-p", "changes")
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return nil, err
+	}
+	return &changeCache{dir: dir, refresh: refresh, sem: make(chan struct{}, 10)}, nil
+}
+
+// EnableCache makes g cache its responses for individual changes. If
+// refresh is set, it revalidates responses for submitted and abandoned
+// changes, too.
+func (g *Gerrit) EnableCache(refresh bool) error {
+	c, err := newChangeCache(refresh)
+	if err != nil {
+		return err
+	}
+	g.cache = c
+	return nil
+}
+
+// path returns the file storing the response to a GET of url.
+func (c *changeCache) path(url string) string {
+	return filepath.Join(c.dir, fmt.Sprintf("%x.json", sha256.Sum256([]byte(url))))
+}
+
+func (c *changeCache) get(url string) *cacheEntry {
+	data, err := os.ReadFile(c.path(url))
+	if err != nil {
+		return nil
+	}
+	e := new(cacheEntry)
+	if err := json.Unmarshal(data, e); err != nil {
+		// Probably a truncated write. Refetch it.
+		return nil
+	}
+	return e
+}
+
+func (c *changeCache) put(url string, e *cacheEntry) {
+	data, err := json.Marshal(e)
+	if err != nil {
+		log.Fatal(err)
+	}
+	path := c.path(url)
+	tmp := fmt.Sprintf("%s.%d", path, os.Getpid())
+	if err := os.WriteFile(tmp, data, 0666); err != nil {
+		log.Printf("caching Gerrit response: %v", err)
+		return
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		log.Printf("caching Gerrit response: %v", err)
+	}
+}
+
+// cachedChangeID returns the change ID of query if it queries a single
+// change by ID, which g.cache can answer, or "" otherwise.
+func (g *Gerrit) cachedChangeID(query string) string {
+	if g.cache == nil || !strings.HasPrefix(query, "change:") || strings.ContainsAny(query, " ()") {
+		return ""
+	}
+	return strings.TrimPrefix(query, "change:")
+}
+
+// queryCached completes req, which queries the change with ID cid,
+// using g.cache.
+func (g *Gerrit) queryCached(req *GerritChanges, cid string) {
+	c := g.cache
+	c.sem <- struct{}{}
+	defer func() { <-c.sem }()
+
+	var queryParams []string
+	for _, opt := range req.options {
+		queryParams = append(queryParams, "o="+opt)
+	}
+	changeUrl := g.url + "/changes/" + url.PathEscape(cid) + "?" + strings.Join(queryParams, "&")
+
+	done := func(info json.RawMessage, err error) {
+		if err == nil && info != nil {
+			var ci *GerritChangeInfo
+			if err = json.Unmarshal(info, &ci); err == nil {
+				req.result = []*GerritChangeInfo{ci}
+			}
+		}
+		req.err = err
+		close(req.done)
+	}
+
+	e := c.get(changeUrl)
+	if e != nil && !c.refresh {
+		var status struct{ Status string }
+		if json.Unmarshal(e.Info, &status) == nil && (status.Status == "MERGED" || status.Status == "ABANDONED") {
+			done(e.Info, nil)
+			return
+		}
+	}
+
+	httpReq, err := http.NewRequest("GET", changeUrl, nil)
+	if err != nil {
+		done(nil, err)
+		return
+	}
+	if e != nil && e.ETag != "" {
+		httpReq.Header.Set("If-None-Match", e.ETag)
+	}
+	resp, err := http.DefaultClient.Do(httpReq)
+	if err != nil {
+		done(nil, err)
+		return
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		done(nil, err)
+		return
+	}
+	if debugGerrit {
+		log.Printf("GET %s => %s", changeUrl, resp.Status)
+	}
+	switch resp.StatusCode {
+	case http.StatusNotModified:
+		if e == nil {
+			done(nil, fmt.Errorf("%s: unexpected %s", changeUrl, resp.Status))
+			return
+		}
+		done(e.Info, nil)
+		return
+	case http.StatusNotFound:
+		// Like a query with no results.
+		done(nil, nil)
+		return
+	case http.StatusOK:
+	default:
+		done(nil, fmt.Errorf("%s: %s", changeUrl, resp.Status))
+		return
+	}
+	// Strip Gerrit's XSSI protection prefix.
+	i := bytes.IndexByte(body, '\n')
+	if i < 0 || !json.Valid(body[i:]) {
+		done(nil, fmt.Errorf("%s: malformed json response", changeUrl))
+		return
+	}
+	info := json.RawMessage(body[i+1:])
+	c.put(changeUrl, &cacheEntry{ETag: resp.Header.Get("ETag"), Info: info})
+	done(info, nil)
+}
@@ -2,7 +2,7 @@
 // Use of this source code is governed by a BSD-style
 // license that can be found in the LICENSE file.
 
-package main
+package chain
 
 import (
 	"bytes"
@@ -17,6 +17,8 @@ import (
 	"time"
 )
 
+const debugGerrit = false
+
 // GerritChangeInfo is the JSON struct returned by a Gerrit CL query.
 type GerritChangeInfo struct {
 	ID                     string
@@ -27,8 +29,8 @@ type GerritChangeInfo struct {
 	Status                 string
 	Created                string
 	Updated                string
-	Mergeable              bool
-	Submittable            bool // Requires SUBMITTABLE
+	Mergeable              *bool // nil if not computed
+	Submittable            bool  // Requires SUBMITTABLE
 	Insertions             int
 	Deletions              int
 	UnresolvedCommentCount int `json:"unresolved_comment_count"`
@@ -82,6 +84,20 @@ type Gerrit struct {
 	url     string
 	project string
 	req     chan<- *GerritChanges
+
+	// cache, if non-nil, caches the results of queries for a
+	// single change.
+	cache *changeCache
+}
+
+// URL returns the URL of g's Gerrit server.
+func (g *Gerrit) URL() string {
+	return g.url
+}
+
+// Project returns the name of the Gerrit project g works with.
+func (g *Gerrit) Project() string {
+	return g.project
 }
 
 func NewGerrit(gerritUrl string) (*Gerrit, error) {
@@ -110,7 +126,7 @@ func NewGerrit(gerritUrl string) (*Gerrit, error) {
 	url.Host = url.Host[:i] + "-review" + url.Host[i:]
 
 	ch := make(chan *GerritChanges, 10)
-	g := &Gerrit{url.String(), project, ch}
+	g := &Gerrit{url: url.String(), project: project, req: ch}
 	go func() {
 		done := false
 		for !done {
@@ -157,6 +173,10 @@ func (req *GerritChanges) Wait() ([]*GerritChangeInfo, error) {
 
 func (g *Gerrit) QueryChanges(query string, options ...string) *GerritChanges {
 	req := &GerritChanges{query: query, options: options, done: make(chan struct{})}
+	if cid := g.cachedChangeID(query); cid != "" {
+		go g.queryCached(req, cid)
+		return req
+	}
 	g.req <- req
 	return req
 }
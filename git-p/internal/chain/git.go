@@ -2,7 +2,7 @@
 // Use of this source code is governed by a BSD-style
 // license that can be found in the LICENSE file.
 
-package main
+package chain
 
 import (
 	"bytes"
@@ -14,8 +14,8 @@ import (
 	"strings"
 )
 
-// git runs git with args and returns its output.
-func git(args ...string) string {
+// Git runs git with args and returns its output.
+func Git(args ...string) string {
 	cmd := exec.Command("git", args...)
 	out, err := cmd.Output()
 	if err != nil {
@@ -27,9 +27,9 @@ func git(args ...string) string {
 	return strings.TrimSuffix(string(out), "\n")
 }
 
-// tryGit runs git with args and returns its output and a non-nil
+// TryGit runs git with args and returns its output and a non-nil
 // error if the command exits with a non-zero status.
-func tryGit(args ...string) (string, error) {
+func TryGit(args ...string) (string, error) {
 	cmd := exec.Command("git", args...)
 	out, err := cmd.CombinedOutput()
 	if _, ok := err.(*exec.ExitError); err != nil && !ok {
@@ -38,7 +38,8 @@ func tryGit(args ...string) (string, error) {
 	return strings.TrimSuffix(string(out), "\n"), err
 }
 
-func lines(s string) []string {
+// Lines splits s into lines.
+func Lines(s string) []string {
 	lines := strings.Split(s, "\n")
 	if len(lines) > 0 && lines[len(lines)-1] == "" {
 		lines = lines[:len(lines)-1]
@@ -46,9 +47,9 @@ func lines(s string) []string {
 	return lines
 }
 
-// upstreamOf returns the full upstream ref name of the given ref, or
+// UpstreamOf returns the full upstream ref name of the given ref, or
 // "".
-func upstreamOf(ref string) string {
+func UpstreamOf(ref string) string {
 	// This fails with code 128 and "fatal: no upstream configured
 	// for branch 'xxx'" if there's no upstream. It also fails
 	// with 128 and "fatal: HEAD does not point to a branch" if
@@ -57,7 +58,7 @@ func upstreamOf(ref string) string {
 	// The @{u} syntax requires a branchname, not a refname, so
 	// strip the ref to a branch name.
 	ref = strings.TrimPrefix(ref, "refs/heads/")
-	out, err := tryGit("rev-parse", "--symbolic-full-name", ref+"@{u}")
+	out, err := TryGit("rev-parse", "--symbolic-full-name", ref+"@{u}")
 	if err != nil {
 		return ""
 	}
@@ -101,7 +102,7 @@ func gitPatchID(commit string) (string, error) {
 // gitCommitMessage returns the commit message for commit.
 func gitCommitMessage(commit string) (string, error) {
 	// Get the commit object.
-	obj, err := tryGit("cat-file", "commit", commit)
+	obj, err := TryGit("cat-file", "commit", commit)
 	if err != nil {
 		return "", fmt.Errorf("bad revision %q", commit)
 	}
@@ -135,9 +136,9 @@ func canonGerritMessage(msg string) string {
 	return msg + "\n"
 }
 
-// changeIds returns the full Gerrit change IDs of each commit. The
+// ChangeIds returns the full Gerrit change IDs of each commit. The
 // change ID will be "" if missing.
-func changeIds(project, forBranch string, commits []string) []string {
+func ChangeIds(project, forBranch string, commits []string) []string {
 	if i := strings.LastIndexByte(forBranch, '/'); i >= 0 {
 		forBranch = forBranch[i+1:]
 	}
@@ -0,0 +1,152 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package chain
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+)
+
+// buildbucketURL is the pRPC endpoint for searching the LUCI builds
+// that run Go's TryBots.
+const buildbucketURL = "https://cr-buildbucket.appspot.com/prpc/buildbucket.v2.Builds/SearchBuilds"
+
+// LUCIBuild is the JSON struct for the fields of a Buildbucket Build
+// that git-p requests.
+type LUCIBuild struct {
+	Builder struct {
+		Project string
+		Bucket  string
+		Builder string
+	}
+	Status string // Such as "STARTED", "SUCCESS", or "FAILURE"
+}
+
+// TryBotRuns is a pending query for the LUCI builds of a patch set.
+type TryBotRuns struct {
+	builds []*LUCIBuild
+	err    error
+	done   chan struct{}
+}
+
+func (r *TryBotRuns) Wait() ([]*LUCIBuild, error) {
+	<-r.done
+	return r.builds, r.err
+}
+
+// NeedTryBots returns whether ChangeStatus needs the LUCI builds of
+// info's current patch set, which is when its TryBots failed and it
+// hasn't been submitted or abandoned.
+func NeedTryBots(info *GerritChangeInfo) bool {
+	tbr := info.Labels["LUCI-TryBot-Result"]
+	return info.Status == "NEW" && tbr != nil && tbr.Rejected != nil
+}
+
+// QueryTryBots starts a query for the LUCI builds of info's current
+// patch set. info must be retrieved with CURRENT_REVISION.
+func (g *Gerrit) QueryTryBots(info *GerritChangeInfo) *TryBotRuns {
+	r := &TryBotRuns{done: make(chan struct{})}
+	go func() {
+		r.builds, r.err = g.searchBuilds(info.Number, info.Revisions[info.CurrentRevision].Number)
+		close(r.done)
+	}()
+	return r
+}
+
+func (g *Gerrit) searchBuilds(change, patchSet int) ([]*LUCIBuild, error) {
+	u, err := url.Parse(g.url)
+	if err != nil {
+		return nil, err
+	}
+	// Buildbucket uses the proto3 JSON mapping, which encodes
+	// int64 fields as strings.
+	type gerritChange struct {
+		Host     string `json:"host"`
+		Project  string `json:"project"`
+		Change   string `json:"change"`
+		Patchset string `json:"patchset"`
+	}
+	var req struct {
+		Predicate struct {
+			GerritChanges []gerritChange `json:"gerritChanges"`
+		} `json:"predicate"`
+		Fields    string `json:"fields"`
+		PageSize  int    `json:"pageSize"`
+		PageToken string `json:"pageToken,omitempty"`
+	}
+	req.Predicate.GerritChanges = []gerritChange{{u.Host, g.project, strconv.Itoa(change), strconv.Itoa(patchSet)}}
+	req.Fields = "builds.*.builder,builds.*.status,nextPageToken"
+	req.PageSize = 1000
+
+	var builds []*LUCIBuild
+	for {
+		body, err := json.Marshal(&req)
+		if err != nil {
+			return nil, err
+		}
+		httpReq, err := http.NewRequest("POST", buildbucketURL, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		httpReq.Header.Set("Content-Type", "application/json")
+		httpReq.Header.Set("Accept", "application/json")
+		resp, err := http.DefaultClient.Do(httpReq)
+		if err != nil {
+			return nil, err
+		}
+		body, err = io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		if debugGerrit {
+			log.Printf("POST %s (CL %d) => %s", buildbucketURL, change, resp.Status)
+		}
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("searching LUCI builds: %s", resp.Status)
+		}
+		// Strip the XSSI protection prefix.
+		body = bytes.TrimPrefix(body, []byte(")]}'"))
+		var page struct {
+			Builds        []*LUCIBuild
+			NextPageToken string
+		}
+		if err := json.Unmarshal(body, &page); err != nil {
+			return nil, fmt.Errorf("searching LUCI builds: malformed json response")
+		}
+		builds = append(builds, page.Builds...)
+		if page.NextPageToken == "" {
+			return builds, nil
+		}
+		req.PageToken = page.NextPageToken
+	}
+}
+
+// failedBuilders returns the sorted names of the builders whose most
+// recent build in builds failed. Buildbucket returns builds newest
+// first, so builders that passed on a retry aren't included.
+func failedBuilders(builds []*LUCIBuild) []string {
+	seen := make(map[string]bool)
+	var failed []string
+	for _, b := range builds {
+		name := b.Builder.Builder
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		if b.Status == "FAILURE" || b.Status == "INFRA_FAILURE" {
+			failed = append(failed, name)
+		}
+	}
+	sort.Strings(failed)
+	return failed
+}
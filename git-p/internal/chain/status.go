@@ -0,0 +1,344 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package chain computes the status of chains of local commits and
+// their Gerrit CLs. It's the model behind git-p's listing and its
+// subcommands that act on chains.
+package chain
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// A Chain is the sequence of commits on a local branch that aren't in
+// any upstream, along with their CLs.
+type Chain struct {
+	Branch string // Full ref name
+
+	// Upstream is the full ref name of Branch's upstream. If
+	// Branch has no upstream, it's the remote's master branch and
+	// HaveUpstream is false.
+	Upstream     string
+	HaveUpstream bool
+
+	// Commits are the commits on Branch, newest first, and
+	// Parents are the first parent of each commit ("" for a root
+	// commit).
+	Commits, Parents []string
+
+	// ChangeIDs are the full Gerrit change IDs of each commit, or
+	// "" for a commit without a Change-Id.
+	ChangeIDs []string
+
+	// Changes are the queries for the CL of each commit. A query
+	// is nil if the commit has no change ID or the chain was
+	// loaded without Gerrit.
+	Changes []*GerritChanges
+}
+
+// LoadChain returns the chain of commits on branch that aren't in any
+// of upstreams, which are the commits of remote. If g is not nil, it
+// starts querying g for their CLs with ChangeOptions.
+func LoadChain(g *Gerrit, branch, remote string, upstreams []string) *Chain {
+	c := &Chain{Branch: branch}
+
+	// Get the Gerrit upstream name so we can construct full
+	// Change-IDs.
+	c.Upstream = UpstreamOf(branch)
+	if c.Upstream == "" {
+		c.Upstream = "refs/remotes/" + remote + "/master"
+	} else {
+		c.HaveUpstream = true
+	}
+
+	c.Commits, c.Parents = branchCommits(branch, upstreams)
+
+	// Get Change-Ids from these commits.
+	var project string
+	if g != nil {
+		project = g.project
+	}
+	c.ChangeIDs = ChangeIds(project, c.Upstream, c.Commits)
+
+	// Start fetching information on all of these changes.
+	c.Changes = make([]*GerritChanges, len(c.ChangeIDs))
+	if g != nil {
+		for i, cid := range c.ChangeIDs {
+			// TODO: Would this be simpler with a single big OR query?
+			if cid != "" {
+				c.Changes[i] = g.QueryChanges("change:"+cid, ChangeOptions...)
+			}
+		}
+	}
+	return c
+}
+
+// Wait waits for the CL queries of c and returns the CL of each
+// commit, or nil for a commit that has no CL.
+func (c *Chain) Wait() []*GerritChangeInfo {
+	infos := make([]*GerritChangeInfo, len(c.Changes))
+	for i, change := range c.Changes {
+		if change == nil {
+			continue
+		}
+		results, err := change.Wait()
+		if err != nil {
+			log.Fatal(err)
+		}
+		if len(results) > 1 {
+			log.Fatalf("multiple changes found for commit %s", c.Commits[i])
+		}
+		if len(results) == 1 {
+			infos[i] = results[0]
+		}
+	}
+	return infos
+}
+
+// LocalBranches returns the full ref names of all local branches
+// that don't match any of the shell patterns in ignores, sorted by
+// most recent commit date.
+func LocalBranches(ignores []string) []string {
+	branches := Lines(Git("for-each-ref", "--format", "%(refname)", "--sort", "-committerdate", "refs/heads/"))
+	if len(ignores) == 0 {
+		return branches
+	}
+	nBranches := []string{}
+branchLoop:
+	for _, b := range branches {
+		for _, ig := range ignores {
+			if m, _ := filepath.Match(ig, b); m {
+				continue branchLoop
+			}
+			if m, _ := filepath.Match("refs/heads/"+ig, b); m {
+				continue branchLoop
+			}
+		}
+		nBranches = append(nBranches, b)
+	}
+	return nBranches
+}
+
+// branchCommits returns the commits on branch that aren't in any of
+// upstreams, newest first, and the first parent of each commit ("" for
+// a root commit).
+func branchCommits(branch string, upstreams []string) (commits, parents []string) {
+	// TODO: This can be quite slow (50–100 ms). git is clearly
+	// reasonably clever about this, but it has to expand the
+	// exclusion list and can't share work across all of these
+	// branches. Maybe this should fully expand the exclusion set
+	// just once, do limited rev-lists, and cut them off at the
+	// exclusion set.
+	args := []string{"rev-list", "--parents", branch}
+	for _, u := range upstreams {
+		args = append(args, "^"+u)
+	}
+	args = append(args, "--")
+	for _, line := range Lines(Git(args...)) {
+		fs := strings.Fields(line)
+		commits = append(commits, fs[0])
+		if len(fs) > 1 {
+			parents = append(parents, fs[1])
+		} else {
+			parents = append(parents, "")
+		}
+	}
+	return commits, parents
+}
+
+// RebaseWarning returns a warning if c.Commits[i] needs to be
+// rebased because its parent is neither in c.Upstream nor a pending
+// change, or "" if it does not.
+func (c *Chain) RebaseWarning(i int) string {
+	parent := c.Parents[i]
+	if parent == "" {
+		// Root commit.
+		return ""
+	}
+	for j, commit := range c.Commits {
+		if commit != parent {
+			continue
+		}
+		// The parent is also on this branch. That's fine as
+		// long as it hasn't been submitted or abandoned.
+		if c.Changes[j] == nil {
+			return ""
+		}
+		results, err := c.Changes[j].Wait()
+		if err != nil || len(results) != 1 {
+			return ""
+		}
+		switch results[0].Status {
+		case "MERGED":
+			return fmt.Sprintf("Needs rebase (parent CL %d was submitted)", results[0].Number)
+		case "ABANDONED":
+			return fmt.Sprintf("Needs rebase (parent CL %d was abandoned)", results[0].Number)
+		}
+		return ""
+	}
+	if _, err := TryGit("merge-base", "--is-ancestor", parent, c.Upstream); err != nil {
+		return fmt.Sprintf("Needs rebase (parent %.10s is not in %s)", parent, strings.TrimPrefix(c.Upstream, "refs/remotes/"))
+	}
+	return ""
+}
+
+var labelMsg = regexp.MustCompile(`^Patch Set [0-9]+: [-a-zA-Z]+\+[0-9]$`)
+
+// ChangeStatus returns the status of info, the CL of local commit
+// commit, and any warnings about it. info must be retrieved with
+// ChangeOptions. If tryBots is not nil, it must be the result of
+// QueryTryBots for info, and is used to report which TryBots failed.
+func ChangeStatus(commit string, info *GerritChangeInfo, tryBots *TryBotRuns) (status string, warnings []string) {
+	// TODO: Show attention information?
+
+	// Check for warnings on current PS. (Requires
+	// CURRENT_REVISION or ALL_REVISIONS option.)
+	curPatchSet := info.Revisions[info.CurrentRevision].Number
+	// Are there unmailed changes?
+	if info.CurrentRevision != commit {
+		// How serious are the differences with the mailed changes?
+		pid1, err1 := gitPatchID(info.CurrentRevision)
+		pid2, err2 := gitPatchID(commit)
+		if !(err1 == nil && err2 == nil && pid1 == pid2) {
+			// The patches are different.
+			warnings = append(warnings, "Local commit differs from mailed commit")
+		} else {
+			msg1, err1 := gitCommitMessage(info.CurrentRevision)
+			msg2, err2 := gitCommitMessage(commit)
+			if !(err1 == nil && err2 == nil && canonGerritMessage(msg1) == canonGerritMessage(msg2)) {
+				// Patches are the same, but the
+				// commit message has changed.
+				warnings = append(warnings, "Local commit message differs")
+			}
+		}
+	}
+	// Are there rejections?
+	rejected := false
+	for labelName, label := range info.Labels {
+		if !label.Optional && label.Rejected != nil {
+			if labelName == "Do-Not-Submit" {
+				warnings = append(warnings, "Marked \"Do not submit\"")
+			} else {
+				warnings = append(warnings, fmt.Sprintf("Rejected by %s", label.Rejected.Name))
+				rejected = true
+			}
+		}
+	}
+	// Are there unresolved comments?
+	//
+	// TODO: Don't count the unresolved comment from a running trybot run.
+	// Unfortunately, to see whether a comment is resolved or not, we have to
+	// request all of the comments using the /changes/{change-id}/comments
+	// endpoint. We can't just get them in the ChangeInfo.
+	//
+	// TODO: If an unresolved comment is resolved by an unpublished draft, count
+	// that separately.
+	if info.UnresolvedCommentCount > 0 {
+		msg := fmt.Sprintf("%d unresolved comment thread", info.UnresolvedCommentCount)
+		if info.UnresolvedCommentCount > 1 {
+			msg += "s"
+		}
+		warnings = append(warnings, msg)
+	}
+	// Are there comments on the latest PS? (Requires
+	// MESSAGES option.)
+	nComments := 0
+	commentUsers, commentUsersSet := []string{}, map[string]bool{}
+	for _, msg := range info.Messages {
+		if msg.PatchSet != curPatchSet || !IsHumanMessage(msg) {
+			continue
+		}
+		nComments++
+		// Requires DETAILED_ACCOUNTS
+		if !commentUsersSet[msg.Author.Name] {
+			commentUsersSet[msg.Author.Name] = true
+			commentUsers = append(commentUsers, msg.Author.Name)
+		}
+	}
+	if nComments > 0 {
+		msg := "1 comment"
+		if nComments > 1 {
+			msg = fmt.Sprintf("%d comments", nComments)
+		}
+		msg += " on latest PS from " + strings.Join(commentUsers, ", ")
+		warnings = append(warnings, msg)
+	}
+	// Does it merge cleanly? (Gerrit only reports this if it's
+	// been computed.)
+	if info.Status == "NEW" && info.Mergeable != nil && !*info.Mergeable {
+		warnings = append(warnings, "Needs rebase (merge conflict)")
+	}
+	// Check trybot status. (Requires LABELS option.)
+	if tbr := info.Labels["LUCI-TryBot-Result"]; tbr != nil && tbr.Rejected != nil {
+		msg := "TryBots failed"
+		if tryBots != nil {
+			builds, err := tryBots.Wait()
+			if err != nil {
+				msg += fmt.Sprintf(" (%v)", err)
+			} else if failed := failedBuilders(builds); len(failed) > 0 {
+				msg += " on " + strings.Join(failed, ", ")
+			}
+		}
+		warnings = append(warnings, msg)
+	} else if tbr == nil || tbr.Approved == nil {
+		// TryBots haven't run. If it's submitted, we don't care.
+		if info.Status != "MERGED" {
+			// Are they running?
+			msg := "TryBots not run"
+			if cq := info.Labels["Commit-Queue"]; cq != nil {
+				for _, vote := range cq.All {
+					if vote.Value == 1 {
+						msg = "TryBots running"
+						break
+					}
+				}
+			}
+			warnings = append(warnings, msg)
+		}
+	}
+
+	switch info.Status {
+	default:
+		status = fmt.Sprintf("Unknown status %q", info.Status)
+	case "MERGED":
+		status = "Submitted"
+	case "ABANDONED":
+		status = "Abandoned"
+	case "DRAFT":
+		status = "Draft"
+	case "NEW":
+		// Submittable? (Requires SUBMITTABLE option.)
+		status = "Pending"
+		if rejected {
+			status = "Rejected"
+		} else if info.Submittable {
+			status = "Ready"
+		}
+	}
+
+	return status, warnings
+}
+
+// IsHumanMessage reports whether msg is a comment by a person, as
+// opposed to an automated comment or a vote with no message.
+func IsHumanMessage(msg *GerritChangeMessageInfo) bool {
+	// Ignore automated comments, including TryBot comments.
+	if strings.HasPrefix(msg.Tag, "autogenerated:") {
+		return false
+	}
+	// Ignore label-only messages (ugh, why aren't these
+	// better marked?)
+	if labelMsg.MatchString(msg.Message) {
+		return false
+	}
+	// Some messages have no author?
+	return msg.Author != nil
+}
+
+// ChangeOptions are the query options needed by ChangeStatus.
+var ChangeOptions = []string{"SUBMITTABLE", "LABELS", "CURRENT_REVISION", "MESSAGES", "DETAILED_ACCOUNTS"}
@@ -0,0 +1,152 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package chain
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// SubmittedTogether returns the changes Gerrit would submit along with
+// CL number cl, such as its unsubmitted ancestors, not including cl
+// itself.
+func (g *Gerrit) SubmittedTogether(cl int) ([]*GerritChangeInfo, error) {
+	var infos []*GerritChangeInfo
+	if err := g.do("GET", g.changeURL(cl, "submitted_together"), nil, &infos); err != nil {
+		return nil, err
+	}
+	var out []*GerritChangeInfo
+	for _, info := range infos {
+		if info.Number != cl {
+			out = append(out, info)
+		}
+	}
+	return out, nil
+}
+
+// Submit submits CL number cl and returns its new state. Gerrit also
+// submits the changes reported by SubmittedTogether.
+//
+// Submitting requires authentication, which uses the Gerrit cookie in
+// the file named by git's http.cookiefile setting, as set up by
+// https://go.dev/doc/contribute#config_git_auth.
+func (g *Gerrit) Submit(cl int) (*GerritChangeInfo, error) {
+	var info *GerritChangeInfo
+	if err := g.do("POST", g.changeURL(cl, "submit"), struct{}{}, &info); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// changeURL returns the authenticated REST URL of endpoint of CL
+// number cl.
+func (g *Gerrit) changeURL(cl int, endpoint string) string {
+	id := url.PathEscape(fmt.Sprintf("%s~%d", g.project, cl))
+	return g.url + "/a/changes/" + id + "/" + endpoint
+}
+
+// do performs an authenticated Gerrit REST request and decodes the
+// JSON response into result. If body is not nil, it's sent as JSON.
+func (g *Gerrit) do(method, reqURL string, body, result interface{}) error {
+	var r io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		r = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, reqURL, r)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	cookie, err := g.authCookie()
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Cookie", cookie)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	data, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		// Gerrit explains errors in plain text.
+		return fmt.Errorf("%s %s: %s: %s", method, reqURL, resp.Status, strings.TrimSpace(string(data)))
+	}
+	// Strip Gerrit's XSSI protection prefix.
+	if i := bytes.IndexByte(data, '\n'); i >= 0 {
+		data = data[i+1:]
+	}
+	if err := json.Unmarshal(data, result); err != nil {
+		return fmt.Errorf("%s %s: malformed json response", method, reqURL)
+	}
+	return nil
+}
+
+// authCookie returns the Cookie header for authenticating to g, from
+// the cookie file named by git's http.cookiefile setting.
+func (g *Gerrit) authCookie() (string, error) {
+	// --path expands a leading ~.
+	path, err := TryGit("config", "--path", "http.cookiefile")
+	if err != nil || path == "" {
+		return "", fmt.Errorf("authenticating to Gerrit requires a cookie file in git config http.cookiefile")
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	// The review host (go-review.googlesource.com) accepts the
+	// cookie of the git host (go.googlesource.com) or of all of
+	// googlesource.com.
+	u, err := url.Parse(g.url)
+	if err != nil {
+		return "", err
+	}
+	host := u.Hostname()
+	hosts := []string{host, strings.Replace(host, "-review.", ".", 1)}
+
+	// Cookie files use the Netscape format: domain, include
+	// subdomains, path, secure, expiry, name, and value,
+	// separated by tabs.
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimPrefix(scanner.Text(), "#HttpOnly_")
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		fs := strings.Split(line, "\t")
+		if len(fs) != 7 {
+			continue
+		}
+		domain := fs[0]
+		for _, h := range hosts {
+			if domain == h || strings.HasPrefix(domain, ".") && strings.HasSuffix(h, domain) {
+				return fs[5] + "=" + fs[6], nil
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	return "", fmt.Errorf("no cookie for %s in %s", host, path)
+}
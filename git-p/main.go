@@ -20,15 +20,81 @@
 // * It checks if there are any comments on the latest version of the
 // CL, which may indicate it needs changes even if it is submittable.
 //
-// * It checks if the trybots are sad or weren't run.
+// * It checks if the trybots are sad or weren't run. If they failed,
+// it lists the failing builders from the LUCI builds of the current
+// patch set.
+//
+// * It checks if the CL needs to be rebased, either because its
+// parent is not in the upstream branch or a pending CL (for example,
+// because the parent CL was submitted or abandoned), or because
+// Gerrit reports a merge conflict.
+//
+// With -backports, git-p also shows which release branches each
+// submitted CL has been cherry-picked to, such as "Backported to
+// 1.24/1.23" or "No backport". This is useful when managing stacks of
+// fixes that need backporting.
+//
+// With -size, git-p shows the size of each CL after its subject, as
+// a size class from XS to XL followed by the lines inserted and
+// deleted, such as "M +52/-10". Mailed CLs show the size Gerrit
+// reports for the current patch set, and other commits show the size
+// of the local commit. This helps prioritize reviews.
+//
+// git-p remembers the status of each CL it shows in the user's cache
+// directory. When a CL has changed since the last run, git-p marks it
+// with a "*" and lists what changed, such as "Since last run: 2 new
+// comments, TryBots failed" or "newly submittable". With
+// -changed-only, it shows only these CLs, which is handy for a daily
+// glance at what needs attention.
 //
 // The output is color-coded by status: green indicates a CL is
 // submittable and has no warnings, yellow indicates a CL has
 // warnings, and red indicates a CL has been rejected. Submitted CLs
 // are greyed out.
 //
+// git-p caches Gerrit's response for each CL in the user's cache
+// directory and revalidates it on later runs, so Gerrit only sends
+// the CLs that changed. It doesn't ask Gerrit about submitted and
+// abandoned CLs at all; -refresh revalidates those too.
+//
+// git-p processes up to -j branches in parallel, though it always
+// prints branches in order.
+//
 // git-p uses the git pager if one is configured.
 //
+// "git-p checkout CL" fetches the current patch set of CL and checks
+// it out in a new worktree next to the main worktree, such as
+// ../go-cl12345, and prints the worktree's path. This makes it easy to
+// build or test a CL under review:
+//
+//	cd $(git-p checkout 38582)
+//
+// If the worktree already exists and has no local changes, it is
+// updated to the current patch set.
+//
+// "git-p prune" finds local branches whose every commit is a
+// submitted or abandoned CL, shows them with their CL links, and
+// deletes them after asking for confirmation (or immediately with
+// -y). It skips the current branch, branches matching -ignore, and
+// branches with unmailed commits.
+//
+// "git-p mail [branch]" mails the commits on branch (by default, the
+// current branch) that are new or differ from their CL's current patch
+// set, after showing their status and asking for confirmation. -r
+// requests review from a comma-separated list of reviewers.
+//
+// "git-p submit CL" submits CL if git-p reports it as Ready. It lists
+// any other CLs Gerrit will submit along with it, such as unsubmitted
+// CLs it depends on, and asks for confirmation. Submitting uses the
+// Gerrit cookie in git's http.cookiefile.
+//
+// "git-p rebase-chain [branch]" fetches from origin and rebases branch
+// onto its upstream, dropping the commits at the bottom of the chain
+// whose CLs have been submitted.
+//
+// The Gerrit and chain logic behind these commands is in
+// internal/chain.
+//
 // Currently git-p only supports the main Go repository.
 //
 // Example output
@@ -66,25 +132,68 @@ import (
 	"log"
 	"os"
 	"path/filepath"
-	"regexp"
+	"runtime"
 	"strings"
 	"unicode/utf8"
-)
 
-const debugGerrit = false
+	"github.com/aclements/go-misc/git-p/internal/chain"
+)
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "checkout" {
+		checkoutMain(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "prune" {
+		pruneMain(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "mail" {
+		mailMain(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "submit" {
+		submitMain(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "rebase-chain" {
+		rebaseChainMain(os.Args[2:])
+		return
+	}
+
 	flag.Usage = func() {
-		fmt.Fprintf(os.Stderr, "Usage: %s [flags] [branches...]\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Usage: %s [flags] [branches...]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s checkout [flags] CL\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s prune [flags]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s mail [flags] [branch]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s submit [flags] CL\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s rebase-chain [flags] [branch]\n\n", os.Args[0])
 		fmt.Fprintf(os.Stderr, "With no arguments, list the current branch.\n\n")
 		flag.PrintDefaults()
 	}
-	defIgnore, _ := tryGit("config", "p.ignore")
+	defIgnore, _ := chain.TryGit("config", "p.ignore")
 	flagIgnore := flag.String("ignore", defIgnore, "ignore branches matching shell `pattern` [git config p.ignore]")
 	flagLocal := flag.Bool("l", false, "local state only; don't query Gerrit")
 	flagAll := flag.Bool("a", false, "list all branches from newest to oldest")
+	flagBackports := flag.Bool("backports", false, "show release-branch backports of submitted CLs")
+	flagSize := flag.Bool("size", false, "show the size and diffstat of each CL")
+	flagChangedOnly := flag.Bool("changed-only", false, "show only CLs that changed since the last run")
+	flagRefresh := flag.Bool("refresh", false, "revalidate all cached Gerrit responses, including those of submitted and abandoned CLs")
+	defJobs := runtime.NumCPU()
+	if defJobs > 8 {
+		defJobs = 8
+	}
+	flagJobs := flag.Int("j", defJobs, "process up to `n` branches in parallel")
 	flag.Parse()
 	branches := flag.Args()
+	if *flagJobs < 1 {
+		fmt.Fprintf(os.Stderr, "-j must be at least 1\n")
+		os.Exit(1)
+	}
+	if *flagChangedOnly && *flagLocal {
+		fmt.Fprintf(os.Stderr, "cannot use both -changed-only and -l\n")
+		os.Exit(1)
+	}
 	ignores := strings.Fields(*flagIgnore)
 
 	if *flagAll {
@@ -100,7 +209,7 @@ func main() {
 
 	// Check the branch names.
 	for _, b := range branches {
-		if out, err := tryGit("rev-parse", b, "--"); err != nil {
+		if out, err := chain.TryGit("rev-parse", b, "--"); err != nil {
 			fmt.Printf("%s\n", out)
 			os.Exit(1)
 		}
@@ -121,18 +230,26 @@ func main() {
 
 	// Find the Gerrit host name.
 	remote := "origin"
-	gerritUrl := git("config", "remote."+remote+".url")
+	gerritUrl := chain.Git("config", "remote."+remote+".url")
 
 	// Get commits that are available from the Gerrit remote.
-	upstreams := lines(git("for-each-ref", "--format", "%(objectname)", "refs/remotes/"+remote+"/"))
+	upstreams := chain.Lines(chain.Git("for-each-ref", "--format", "%(objectname)", "refs/remotes/"+remote+"/"))
 	if len(upstreams) == 0 {
 		log.Fatalf("no refs for remote %s", remote)
 	}
 
-	var gerrit *Gerrit
+	var gerrit *chain.Gerrit
+	var seen *seenCLs
 	if !*flagLocal {
 		var err error
-		gerrit, err = NewGerrit(gerritUrl)
+		gerrit, err = chain.NewGerrit(gerritUrl)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := gerrit.EnableCache(*flagRefresh); err != nil {
+			log.Printf("not caching Gerrit responses: %v", err)
+		}
+		seen, err = loadSeenCLs(gerrit, *flagChangedOnly)
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -146,34 +263,19 @@ func main() {
 	// back-pressure from a pager), don't start new showBranches.
 	// This avoids making lots of ultimately ignored requests to
 	// Gerrit.
-	limit := make(chan struct{}, 3)
+	limit := make(chan struct{}, *flagJobs+2)
+	// Run the local git work of up to -j branches at a time.
+	workers := make(chan struct{}, *flagJobs)
 
 	var head string
 	if len(branches) == 0 {
 		// Resolve HEAD and show it first regardless of age.
-		head, _ = tryGit("symbolic-ref", "HEAD")
+		head, _ = chain.TryGit("symbolic-ref", "HEAD")
 		if head != "" {
-			token = showBranch(gerrit, head, "HEAD", remote, upstreams, token, limit)
+			token = showBranch(gerrit, head, "HEAD", remote, upstreams, *flagBackports, *flagSize, seen, token, limit, workers)
 		}
 
-		// Get all local branches, sorted by most recent commit date.
-		branches = lines(git("for-each-ref", "--format", "%(refname)", "--sort", "-committerdate", "refs/heads/"))
-		if len(ignores) > 0 {
-			nBranches := []string{}
-		branchLoop:
-			for _, b := range branches {
-				for _, ig := range ignores {
-					if m, _ := filepath.Match(ig, b); m {
-						continue branchLoop
-					}
-					if m, _ := filepath.Match("refs/heads/"+ig, b); m {
-						continue branchLoop
-					}
-				}
-				nBranches = append(nBranches, b)
-			}
-			branches = nBranches
-		}
+		branches = chain.LocalBranches(ignores)
 	}
 
 	// Show all branches.
@@ -181,227 +283,125 @@ func main() {
 		if branch == head {
 			continue
 		}
-		token = showBranch(gerrit, branch, "", remote, upstreams, token, limit)
+		token = showBranch(gerrit, branch, "", remote, upstreams, *flagBackports, *flagSize, seen, token, limit, workers)
 	}
 
 	<-token
-}
 
-func showBranch(gerrit *Gerrit, branch, extra string, remote string, upstreams []string, token, limit chan struct{}) chan struct{} {
-	// Don't start too many showBranches.
-	limit <- struct{}{}
-
-	// Get the Gerrit upstream name so we can construct full
-	// Change-IDs.
-	var haveUpstream bool
-	upstream := upstreamOf(branch)
-	if upstream == "" {
-		upstream = "refs/remotes/" + remote + "/master"
-	} else {
-		haveUpstream = true
-	}
-
-	// Get commits from the branch to any upstream.
-	//
-	// TODO: This can be quite slow (50–100 ms). git is clearly
-	// reasonably clever about this, but it has to expand the
-	// exclusion list and can't share work across all of these
-	// branches. Maybe this should fully expand the exclusion set
-	// just once, do limited rev-lists, and cut them off at the
-	// exclusion set.
-	args := []string{"rev-list", branch}
-	for _, u := range upstreams {
-		args = append(args, "^"+u)
-	}
-	args = append(args, "--")
-	commits := lines(git(args...))
-
-	// Get Change-Ids from these commits.
-	cids := changeIds(gerrit.project, upstream, commits)
-
-	// Fetch information on all of these changes.
-	//
-	// We need DETAILED_LABELS to get numeric values of labels.
-	changes := make([]*GerritChanges, len(cids))
-	if gerrit != nil {
-		for i, cid := range cids {
-			// TODO: Would this be simpler with a single big OR query?
-			if cid != "" {
-				changes[i] = gerrit.QueryChanges("change:"+cid, printChangeOptions...)
-			}
+	if seen != nil {
+		if err := seen.Save(); err != nil {
+			log.Printf("saving CL states: %v", err)
 		}
 	}
+}
 
-	if len(changes) == 0 {
-		<-limit
-		return token
-	}
+func showBranch(gerrit *chain.Gerrit, branch, extra string, remote string, upstreams []string, backports, sizes bool, seen *seenCLs, token, limit, workers chan struct{}) chan struct{} {
+	// Don't start too many showBranches.
+	limit <- struct{}{}
 
 	done := make(chan struct{})
 	go func() {
+		out := branchStatus(gerrit, branch, extra, remote, upstreams, backports, sizes, seen, workers)
 		<-token
-		// Print changes.
-		fmt.Printf("%s%s%s", style["branch"], strings.TrimPrefix(branch, "refs/heads/"), style["reset"])
-		if extra != "" {
-			fmt.Printf(" (%s%s%s)", style["symbolic-ref"], extra, style["reset"])
-		}
-		if haveUpstream {
-			fmt.Printf(" for %s", strings.TrimPrefix(upstream, "refs/remotes/"+remote+"/"))
-		}
-		fmt.Printf("\n")
-		for i, change := range changes {
-			printChange(commits[i], change, gerrit == nil)
-		}
-		fmt.Println()
+		fmt.Print(out)
 		<-limit
 		done <- struct{}{}
 	}()
 	return done
 }
 
-var labelMsg = regexp.MustCompile(`^Patch Set [0-9]+: [-a-zA-Z]+\+[0-9]$`)
-var trybotFailures = regexp.MustCompile(`(?m)^Failed on ([^:]+):`)
-
-func changeStatus(commit string, info *GerritChangeInfo) (status string, warnings []string) {
-	// TODO: Show attention information?
-
-	// Check for warnings on current PS. (Requires
-	// CURRENT_REVISION or ALL_REVISIONS option.)
-	curPatchSet := info.Revisions[info.CurrentRevision].Number
-	// Are there unmailed changes?
-	if info.CurrentRevision != commit {
-		// How serious are the differences with the mailed changes?
-		pid1, err1 := gitPatchID(info.CurrentRevision)
-		pid2, err2 := gitPatchID(commit)
-		if !(err1 == nil && err2 == nil && pid1 == pid2) {
-			// The patches are different.
-			warnings = append(warnings, "Local commit differs from mailed commit")
-		} else {
-			msg1, err1 := gitCommitMessage(info.CurrentRevision)
-			msg2, err2 := gitCommitMessage(commit)
-			if !(err1 == nil && err2 == nil && canonGerritMessage(msg1) == canonGerritMessage(msg2)) {
-				// Patches are the same, but the
-				// commit message has changed.
-				warnings = append(warnings, "Local commit message differs")
-			}
-		}
+// branchStatus returns the formatted status of all of the commits on
+// branch, or "" if there are none. If seen.onlyChanged is set, it
+// includes only the CLs that changed since the last run. It holds a
+// slot in workers while running git commands.
+func branchStatus(gerrit *chain.Gerrit, branch, extra string, remote string, upstreams []string, backports, sizes bool, seen *seenCLs, workers chan struct{}) string {
+	workers <- struct{}{}
+	c := chain.LoadChain(gerrit, branch, remote, upstreams)
+	<-workers
+	if len(c.Commits) == 0 {
+		return ""
 	}
-	// Are there rejections?
-	rejected := false
-	for labelName, label := range info.Labels {
-		if !label.Optional && label.Rejected != nil {
-			if labelName == "Do-Not-Submit" {
-				warnings = append(warnings, "Marked \"Do not submit\"")
-			} else {
-				warnings = append(warnings, fmt.Sprintf("Rejected by %s", label.Rejected.Name))
-				rejected = true
+
+	backportChanges := make([]*chain.GerritChanges, len(c.ChangeIDs))
+	if gerrit != nil && backports {
+		for i, cid := range c.ChangeIDs {
+			if cid != "" {
+				// We don't know yet if this change is
+				// submitted, so query regardless to
+				// keep the pipeline full.
+				backportChanges[i] = gerrit.QueryBackports(cid)
 			}
 		}
 	}
-	// Are there unresolved comments?
-	//
-	// TODO: Don't count the unresolved comment from a running trybot run.
-	// Unfortunately, to see whether a comment is resolved or not, we have to
-	// request all of the comments using the /changes/{change-id}/comments
-	// endpoint. We can't just get them in the ChangeInfo.
-	//
-	// TODO: If an unresolved comment is resolved by an unpublished draft, count
-	// that separately.
-	if info.UnresolvedCommentCount > 0 {
-		msg := fmt.Sprintf("%d unresolved comment thread", info.UnresolvedCommentCount)
-		if info.UnresolvedCommentCount > 1 {
-			msg += "s"
-		}
-		warnings = append(warnings, msg)
-	}
-	// Are there comments on the latest PS? (Requires
-	// MESSAGES option.)
-	nComments := 0
-	commentUsers, commentUsersSet := []string{}, map[string]bool{}
-	for _, msg := range info.Messages {
-		if msg.PatchSet != curPatchSet {
-			continue
-		}
-		// Ignore automated comments, including TryBot comments.
-		if strings.HasPrefix(msg.Tag, "autogenerated:") {
-			continue
-		}
-		// Ignore label-only messages (ugh, why aren't these
-		// better marked?)
-		if labelMsg.MatchString(msg.Message) {
-			continue
-		}
-		// Some messages have no author?
-		if msg.Author == nil {
+
+	// Wait for Gerrit before taking a worker slot again so slow
+	// queries don't hold up other branches' git work.
+	infos := c.Wait()
+	for _, bp := range backportChanges {
+		if bp == nil {
 			continue
 		}
-		nComments++
-		// Requires DETAILED_ACCOUNTS
-		if !commentUsersSet[msg.Author.Name] {
-			commentUsersSet[msg.Author.Name] = true
-			commentUsers = append(commentUsers, msg.Author.Name)
+		if _, err := bp.Wait(); err != nil {
+			log.Fatal(err)
 		}
 	}
-	if nComments > 0 {
-		msg := "1 comment"
-		if nComments > 1 {
-			msg = fmt.Sprintf("%d comments", nComments)
-		}
-		msg += " on latest PS from " + strings.Join(commentUsers, ", ")
-		warnings = append(warnings, msg)
-	}
-	// Check trybot status. (Requires LABELS option.)
-	if tbr := info.Labels["LUCI-TryBot-Result"]; tbr != nil && tbr.Rejected != nil {
-		// TODO: Use checks API to see what failed?
-		warnings = append(warnings, "TryBots failed")
-	} else if tbr == nil || tbr.Approved == nil {
-		// TryBots haven't run. If it's submitted, we don't care.
-		if info.Status != "MERGED" {
-			// Are they running?
-			msg := "TryBots not run"
-			if cq := info.Labels["Commit-Queue"]; cq != nil {
-				for _, vote := range cq.All {
-					if vote.Value == 1 {
-						msg = "TryBots running"
-						break
-					}
-				}
-			}
-			warnings = append(warnings, msg)
+
+	// Find out which TryBots failed. This is rare, so it's
+	// fine to wait for it after the changes.
+	tryBots := make([]*chain.TryBotRuns, len(infos))
+	for i, info := range infos {
+		if info != nil && chain.NeedTryBots(info) {
+			tryBots[i] = gerrit.QueryTryBots(info)
 		}
 	}
 
-	switch info.Status {
-	default:
-		status = fmt.Sprintf("Unknown status %q", info.Status)
-	case "MERGED":
-		status = "Submitted"
-	case "ABANDONED":
-		status = "Abandoned"
-	case "DRAFT":
-		status = "Draft"
-	case "NEW":
-		// Submittable? (Requires SUBMITTABLE option.)
-		status = "Pending"
-		if rejected {
-			status = "Rejected"
-		} else if info.Submittable {
-			status = "Ready"
+	workers <- struct{}{}
+	defer func() { <-workers }()
+	var out strings.Builder
+	fmt.Fprintf(&out, "%s%s%s", style["branch"], strings.TrimPrefix(branch, "refs/heads/"), style["reset"])
+	if extra != "" {
+		fmt.Fprintf(&out, " (%s%s%s)", style["symbolic-ref"], extra, style["reset"])
+	}
+	if c.HaveUpstream {
+		fmt.Fprintf(&out, " for %s", strings.TrimPrefix(c.Upstream, "refs/remotes/"+remote+"/"))
+	}
+	fmt.Fprintf(&out, "\n")
+	nShown := 0
+	for i, change := range c.Changes {
+		rebase := c.RebaseWarning(i)
+		status, changed := formatChange(c.Commits[i], change, backportChanges[i], tryBots[i], gerrit == nil, rebase, sizes, seen)
+		if seen != nil && seen.onlyChanged && !changed {
+			continue
 		}
+		out.WriteString(status)
+		nShown++
 	}
-
-	return status, warnings
+	if nShown == 0 {
+		return ""
+	}
+	fmt.Fprintf(&out, "\n")
+	return out.String()
 }
 
-var printChangeOptions = []string{"SUBMITTABLE", "LABELS", "CURRENT_REVISION", "MESSAGES", "DETAILED_ACCOUNTS"}
-
-// printChange prints a summary of change's status and warnings.
+// formatChange returns a summary of change's status and warnings.
+//
+// change must be retrieved with options chain.ChangeOptions. If
+// rebase is not "", it is shown as a warning unless the change has
+// already been submitted or abandoned. If backports is not nil, it
+// must be the result of QueryBackports for change and, if the change
+// has been submitted, formatChange includes its backport status. If
+// tryBots is not nil, it must be the result of QueryTryBots for
+// change. If sizes is true, it includes the size of the change.
 //
-// change must be retrieved with options printChangeOptions.
-func printChange(commit string, change *GerritChanges, local bool) {
-	logMsg := git("log", "-n1", "--oneline", commit)
+// If seen is not nil, formatChange records the change's status in it
+// and marks the change with a "*" and a list of what changed if it
+// changed since the last run. It reports whether it did.
+func formatChange(commit string, change, backports *chain.GerritChanges, tryBots *chain.TryBotRuns, local bool, rebase string, sizes bool, seen *seenCLs) (string, bool) {
+	logMsg := chain.Git("log", "-n1", "--oneline", commit)
 
 	status, warnings, link := "Not mailed", []string(nil), ""
+	var info *chain.GerritChangeInfo
+	var news []string
 	if change != nil {
 		results, err := change.Wait()
 		if err != nil {
@@ -411,13 +411,27 @@ func printChange(commit string, change *GerritChanges, local bool) {
 			log.Fatalf("multiple changes found for commit %s", commit)
 		}
 		if len(results) == 1 {
-			status, warnings = changeStatus(commit, results[0])
+			info = results[0]
+			status, warnings = chain.ChangeStatus(commit, results[0], tryBots)
+			if seen != nil {
+				news = seen.update(results[0], status)
+			}
+			if backports != nil && results[0].Status == "MERGED" {
+				bps, err := backports.Wait()
+				if err != nil {
+					log.Fatal(err)
+				}
+				warnings = append(warnings, chain.BackportStatus(results[0], bps))
+			}
 			//link = fmt.Sprintf("[%s/c/%d]", gerritUrl, results[0].Number)
 			link = fmt.Sprintf(" [go.dev/cl/%d]", results[0].Number)
 		}
 	} else if local {
 		status = ""
 	}
+	if rebase != "" && status != "Submitted" && status != "Abandoned" {
+		warnings = append(warnings, rebase)
+	}
 
 	var control, eControl string
 	if len(warnings) != 0 {
@@ -438,12 +452,29 @@ func printChange(commit string, change *GerritChanges, local bool) {
 	if status != "" {
 		hdr = fmt.Sprintf("%-10s %s", status, logMsg)
 	}
-	hdrMax := 80 - len(link) - 2
+	var size string
+	if sizes {
+		var ins, del int
+		if info != nil {
+			ins, del = info.Insertions, info.Deletions
+		} else {
+			ins, del = gitDiffStat(commit)
+		}
+		size = fmt.Sprintf(" %-12s", formatSize(ins, del))
+	}
+	hdrMax := 80 - len(link) - len(size) - 2
 	if utf8.RuneCountInString(hdr) > hdrMax {
 		hdr = fmt.Sprintf("%*.*s…", hdrMax-1, hdrMax-1, hdr)
 	}
-	fmt.Printf("  %s%-*s%s%s\n", control, hdrMax, hdr, eControl, link)
+	mark := " "
+	if len(news) > 0 {
+		mark = "*"
+		warnings = append([]string{"Since last run: " + strings.Join(news, ", ")}, warnings...)
+	}
+	var out strings.Builder
+	fmt.Fprintf(&out, "%s %s%-*s%s%s%s\n", mark, control, hdrMax, hdr, eControl, size, link)
 	for _, w := range warnings {
-		fmt.Printf("    %s\n", w)
+		fmt.Fprintf(&out, "    %s\n", w)
 	}
+	return out.String(), len(news) > 0
 }
@@ -0,0 +1,136 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/aclements/go-misc/git-p/internal/chain"
+)
+
+// clSnapshot is the state of a CL as of the last time git-p showed it.
+type clSnapshot struct {
+	Status   string // As shown by formatChange, such as "Pending"
+	Comments int    // Human comments on all patch sets
+	TryBots  string // "passed", "failed", or "" if there's no result
+}
+
+// seenCLs records the state of each CL git-p shows, so the next run
+// can point out what changed. It's kept in the user's cache directory
+// and is safe for concurrent use.
+type seenCLs struct {
+	path string
+	host string // Prefix of keys, since CL IDs are per Gerrit host
+
+	// onlyChanged means to show only the CLs that changed.
+	onlyChanged bool
+
+	mu   sync.Mutex
+	prev map[string]clSnapshot // nil if there is no earlier run
+	cur  map[string]clSnapshot
+}
+
+// loadSeenCLs reads the CL states recorded by earlier runs.
+func loadSeenCLs(gerrit *chain.Gerrit, onlyChanged bool) (*seenCLs, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return nil, err
+	}
+	s := &seenCLs{
+		path:        filepath.Join(dir, "git-p", "seen.json"),
+		host:        gerrit.URL(),
+		onlyChanged: onlyChanged,
+		cur:         make(map[string]clSnapshot),
+	}
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return s, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &s.prev); err != nil {
+		return nil, fmt.Errorf("%s: %v", s.path, err)
+	}
+	for k, v := range s.prev {
+		s.cur[k] = v
+	}
+	return s, nil
+}
+
+// Save records the states of the CLs shown by this run, along with
+// the earlier states of CLs it didn't show.
+func (s *seenCLs) Save() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, err := json.MarshalIndent(s.cur, "", "\t")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0777); err != nil {
+		return err
+	}
+	// Write atomically so concurrent runs don't corrupt it.
+	tmp := s.path + fmt.Sprintf(".%d", os.Getpid())
+	if err := os.WriteFile(tmp, data, 0666); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
+
+// update records that info is being shown with status and returns
+// what changed since the last run, or nil if nothing did. A CL that
+// no earlier run showed counts as changed, unless there was no
+// earlier run at all.
+func (s *seenCLs) update(info *chain.GerritChangeInfo, status string) []string {
+	snap := clSnapshot{Status: status}
+	for _, msg := range info.Messages {
+		if chain.IsHumanMessage(msg) {
+			snap.Comments++
+		}
+	}
+	if tbr := info.Labels["LUCI-TryBot-Result"]; tbr != nil {
+		if tbr.Rejected != nil {
+			snap.TryBots = "failed"
+		} else if tbr.Approved != nil {
+			snap.TryBots = "passed"
+		}
+	}
+
+	key := fmt.Sprintf("%s %d", s.host, info.Number)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cur[key] = snap
+	if s.prev == nil {
+		return nil
+	}
+	old, ok := s.prev[key]
+	if !ok {
+		return []string{"new CL"}
+	}
+
+	var changes []string
+	if n := snap.Comments - old.Comments; n == 1 {
+		changes = append(changes, "1 new comment")
+	} else if n > 1 {
+		changes = append(changes, fmt.Sprintf("%d new comments", n))
+	}
+	if snap.TryBots != "" && snap.TryBots != old.TryBots {
+		changes = append(changes, "TryBots "+snap.TryBots)
+	}
+	if snap.Status != old.Status {
+		if snap.Status == "Ready" {
+			changes = append(changes, "newly submittable")
+		} else {
+			changes = append(changes, fmt.Sprintf("%s (was %s)", snap.Status, old.Status))
+		}
+	}
+	return changes
+}
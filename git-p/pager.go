@@ -10,6 +10,7 @@ import (
 	"os/exec"
 	"syscall"
 
+	"github.com/aclements/go-misc/git-p/internal/chain"
 	"golang.org/x/term"
 )
 
@@ -38,7 +39,7 @@ func setupPager() (inTerm bool) {
 		return false
 	}
 
-	pagerCmd := git("var", "GIT_PAGER")
+	pagerCmd := chain.Git("var", "GIT_PAGER")
 	if pagerCmd == "" {
 		return true
 	}
@@ -0,0 +1,116 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/aclements/go-misc/git-p/internal/chain"
+)
+
+// pruneMain implements "git-p prune", which deletes local branches
+// whose commits have all been submitted or abandoned in Gerrit.
+func pruneMain(args []string) {
+	fs := flag.NewFlagSet("prune", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s prune [flags]\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Delete local branches whose CLs have all been submitted or abandoned.\n\n")
+		fs.PrintDefaults()
+	}
+	defIgnore, _ := chain.TryGit("config", "p.ignore")
+	flagIgnore := fs.String("ignore", defIgnore, "ignore branches matching shell `pattern` [git config p.ignore]")
+	flagYes := fs.Bool("y", false, "delete branches without asking for confirmation")
+	fs.Parse(args)
+	if fs.NArg() != 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	plainUnlessTerminal()
+
+	remote := "origin"
+	gerrit, upstreams := openRemote(remote)
+	// git won't delete the current branch.
+	head, _ := chain.TryGit("symbolic-ref", "HEAD")
+
+	// Query the CLs of every commit on every branch up front so
+	// the queries are batched.
+	var cands []*chain.Chain
+branchLoop:
+	for _, branch := range chain.LocalBranches(strings.Fields(*flagIgnore)) {
+		if branch == head {
+			continue
+		}
+		c := chain.LoadChain(gerrit, branch, remote, upstreams)
+		// Branches with no commits of their own may be
+		// intentionally empty, so leave them alone.
+		if len(c.Commits) == 0 {
+			continue
+		}
+		for _, cid := range c.ChangeIDs {
+			if cid == "" {
+				// This commit was never mailed.
+				continue branchLoop
+			}
+		}
+		cands = append(cands, c)
+	}
+
+	var prune []string
+	for _, c := range cands {
+		if !allClosed(c.Changes) {
+			continue
+		}
+		prune = append(prune, c.Branch)
+		printChain(c, false)
+	}
+	if len(prune) == 0 {
+		fmt.Printf("No branches to prune.\n")
+		return
+	}
+
+	if !*flagYes && !confirm(fmt.Sprintf("Delete %d branch(es)?", len(prune))) {
+		fmt.Printf("Not deleting anything.\n")
+		return
+	}
+	failed := false
+	for _, branch := range prune {
+		// These commits aren't merged as far as git is
+		// concerned, so this needs -D. git prints the old
+		// commit so the branch can be recovered.
+		out, err := chain.TryGit("branch", "-D", strings.TrimPrefix(branch, "refs/heads/"))
+		fmt.Println(out)
+		if err != nil {
+			failed = true
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// allClosed reports whether every change in changes was found and has
+// been submitted or abandoned.
+func allClosed(changes []*chain.GerritChanges) bool {
+	for _, change := range changes {
+		results, err := change.Wait()
+		if err != nil {
+			log.Fatal(err)
+		}
+		if len(results) != 1 {
+			return false
+		}
+		switch results[0].Status {
+		case "MERGED", "ABANDONED":
+		default:
+			return false
+		}
+	}
+	return true
+}
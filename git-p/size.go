@@ -0,0 +1,59 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/aclements/go-misc/git-p/internal/chain"
+)
+
+// sizeClasses are the CL size classes, by the maximum number of
+// lines a CL in that class changes. Larger CLs are "XL".
+var sizeClasses = []struct {
+	max  int
+	name string
+}{
+	{9, "XS"},
+	{29, "S"},
+	{99, "M"},
+	{499, "L"},
+}
+
+// formatSize returns a compact indicator of the size of a CL with
+// ins inserted and del deleted lines, such as "M +52/-10".
+func formatSize(ins, del int) string {
+	class := "XL"
+	for _, c := range sizeClasses {
+		if ins+del <= c.max {
+			class = c.name
+			break
+		}
+	}
+	return fmt.Sprintf("%s +%d/-%d", class, ins, del)
+}
+
+// gitDiffStat returns the number of lines inserted and deleted by
+// commit relative to its first parent. Binary files don't count.
+func gitDiffStat(commit string) (ins, del int) {
+	out := chain.Git("diff-tree", "--numstat", "--root", "-r", "--no-commit-id", commit, "--")
+	for _, line := range chain.Lines(out) {
+		fs := strings.SplitN(line, "\t", 3)
+		if len(fs) != 3 {
+			continue
+		}
+		i, err1 := strconv.Atoi(fs[0])
+		d, err2 := strconv.Atoi(fs[1])
+		if err1 != nil || err2 != nil {
+			// Binary file.
+			continue
+		}
+		ins += i
+		del += d
+	}
+	return ins, del
+}
@@ -1,6 +1,6 @@
 module github.com/aclements/go-misc
 
-go 1.17
+go 1.25.0
 
 require (
 	github.com/aclements/go-gg v0.0.0-20170323211221-abd1f791f5ee
@@ -8,7 +8,9 @@ require (
 	golang.org/x/build v0.0.0-20210804225706-d1bc548deb19
 	golang.org/x/crypto v0.0.0-20210711020723-a769d52b0f97
 	golang.org/x/image v0.0.0-20210628002857-a66eb6448b8d
-	golang.org/x/tools v0.1.5
+	golang.org/x/sync v0.21.0
+	golang.org/x/term v0.0.0-20201126162022-7de9c90e9dd1
+	golang.org/x/tools v0.47.0
 )
 
 require (
@@ -18,8 +20,7 @@ require (
 	github.com/gonum/internal v0.0.0-20181124074243-f884aa714029 // indirect
 	github.com/gonum/lapack v0.0.0-20181123203213-e4cdc5a0bff9 // indirect
 	github.com/gonum/matrix v0.0.0-20181209220409-c518dec07be9 // indirect
-	golang.org/x/mod v0.4.2 // indirect
-	golang.org/x/sys v0.0.0-20210630005230-0f9fa26af87c // indirect
-	golang.org/x/term v0.0.0-20201126162022-7de9c90e9dd1 // indirect
+	golang.org/x/mod v0.37.0 // indirect
+	golang.org/x/sys v0.46.0 // indirect
 	golang.org/x/xerrors v0.0.0-20200804184101-5ec99f83aff1 // indirect
 )
@@ -0,0 +1,216 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/scanner"
+	"go/token"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// A completer completes identifiers at the goi prompt. It completes
+// package paths in import declarations, exported members after
+// "pkg.", and otherwise identifiers defined in the session and the
+// names of imported packages.
+type completer struct {
+	mu      sync.Mutex
+	paths   []string            // Sorted import paths in the module graph
+	members map[string][]string // Import path -> sorted exported names
+	idents  map[string]bool     // Identifiers declared in this session
+}
+
+func newCompleter() *completer {
+	c := &completer{
+		members: make(map[string][]string),
+		idents:  make(map[string]bool),
+	}
+	// Loading the package list can take a while, so do it in
+	// the background.
+	go c.loadPaths()
+	return c
+}
+
+func (c *completer) loadPaths() {
+	cfg := &packages.Config{Mode: packages.NeedName}
+	// "all" fails outside a module, so load it separately and
+	// ignore errors.
+	var paths []string
+	for _, pattern := range []string{"std", "all"} {
+		pkgs, err := packages.Load(cfg, pattern)
+		if err != nil {
+			continue
+		}
+		for _, pkg := range pkgs {
+			if len(pkg.Errors) == 0 {
+				paths = append(paths, pkg.PkgPath)
+			}
+		}
+	}
+	sort.Strings(paths)
+	c.mu.Lock()
+	c.paths = paths
+	c.mu.Unlock()
+}
+
+// pkgMembers returns the sorted exported names of package path.
+func (c *completer) pkgMembers(path string) []string {
+	c.mu.Lock()
+	names, ok := c.members[path]
+	c.mu.Unlock()
+	if ok {
+		return names
+	}
+
+	cfg := &packages.Config{Mode: packages.NeedName | packages.NeedTypes}
+	pkgs, err := packages.Load(cfg, path)
+	if err == nil && len(pkgs) == 1 && pkgs[0].Types != nil {
+		for _, name := range pkgs[0].Types.Scope().Names() {
+			if token.IsExported(name) {
+				names = append(names, name)
+			}
+		}
+	}
+	c.mu.Lock()
+	c.members[path] = names
+	c.mu.Unlock()
+	return names
+}
+
+// addSource records the identifiers declared by src.
+func (c *completer) addSource(src string) {
+	var s scanner.Scanner
+	fs := token.NewFileSet()
+	s.Init(fs.AddFile("<stdin>", -1, len(src)), []byte(src), nil, 0)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var prev token.Token
+	var prevLit string
+	for {
+		_, tok, lit := s.Scan()
+		if tok == token.EOF {
+			break
+		}
+		switch {
+		case tok == token.IDENT && (prev == token.VAR || prev == token.CONST || prev == token.TYPE || prev == token.FUNC):
+			c.idents[lit] = true
+		case tok == token.DEFINE && prev == token.IDENT:
+			c.idents[prevLit] = true
+		}
+		prev, prevLit = tok, lit
+	}
+}
+
+// importedPackages returns a map from package name to import path
+// for the imports declared in this session.
+func importedPackages() map[string]string {
+	pkgs := make(map[string]string)
+	for _, imp := range imports {
+		f, err := parser.ParseFile(token.NewFileSet(), "", "package p;"+imp, parser.ImportsOnly)
+		if err != nil {
+			continue
+		}
+		ast.Inspect(f, func(n ast.Node) bool {
+			spec, ok := n.(*ast.ImportSpec)
+			if !ok {
+				return true
+			}
+			p, err := strconv.Unquote(spec.Path.Value)
+			if err != nil {
+				return false
+			}
+			// TODO: Use the real package name.
+			name := path.Base(p)
+			if spec.Name != nil {
+				name = spec.Name.Name
+			}
+			pkgs[name] = p
+			return false
+		})
+	}
+	return pkgs
+}
+
+// complete is a golang.org/x/term AutoCompleteCallback. On tab, it
+// extends the word before the cursor by the longest common prefix of
+// its completions.
+func (c *completer) complete(line string, pos int, key rune) (newLine string, newPos int, ok bool) {
+	if key != '\t' {
+		return "", 0, false
+	}
+
+	// Find the word before the cursor.
+	start := pos
+	for start > 0 {
+		r := rune(line[start-1])
+		if !(r == '_' || r == '.' || r == '/' || unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			break
+		}
+		start--
+	}
+	word := line[start:pos]
+
+	var cands []string
+	if start > 0 && line[start-1] == '"' && strings.HasPrefix(strings.TrimSpace(line), "import") {
+		// Complete an import path.
+		c.mu.Lock()
+		cands = c.paths
+		c.mu.Unlock()
+	} else if i := strings.LastIndexByte(word, '.'); i >= 0 {
+		// Complete a package member.
+		if p, ok := importedPackages()[word[:i]]; ok {
+			for _, name := range c.pkgMembers(p) {
+				cands = append(cands, word[:i+1]+name)
+			}
+		}
+	} else {
+		// Complete a session identifier or package name.
+		c.mu.Lock()
+		for id := range c.idents {
+			cands = append(cands, id)
+		}
+		c.mu.Unlock()
+		for name := range importedPackages() {
+			cands = append(cands, name)
+		}
+	}
+
+	completion, ok := commonPrefix(word, cands)
+	if !ok || completion == word {
+		return "", 0, false
+	}
+	return line[:start] + completion + line[pos:], start + len(completion), true
+}
+
+// commonPrefix returns the longest common prefix of the candidates
+// that start with prefix.
+func commonPrefix(prefix string, cands []string) (string, bool) {
+	var common string
+	found := false
+	for _, cand := range cands {
+		if !strings.HasPrefix(cand, prefix) {
+			continue
+		}
+		if !found {
+			common, found = cand, true
+			continue
+		}
+		n := 0
+		for n < len(common) && n < len(cand) && common[n] == cand[n] {
+			n++
+		}
+		common = common[:n]
+	}
+	return common, found
+}
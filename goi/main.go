@@ -6,6 +6,7 @@ package main
 
 import (
 	"bufio"
+	"flag"
 	"fmt"
 	"go/scanner"
 	"go/token"
@@ -17,12 +18,31 @@ import (
 	"path/filepath"
 	"plugin"
 	"strings"
+
+	"golang.org/x/term"
 )
 
 func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [-restore session.goi]\n\nAt the prompt, \":save file\" saves the session to file.\n\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	restore := flag.String("restore", "", "replay the session saved in `file` before reading input")
+	flag.Parse()
+	if flag.NArg() > 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
 	f := os.Stdin
+	c := newCompleter()
+	if *restore != "" {
+		if err := restoreSession(*restore, c); err != nil {
+			log.Fatalf("error restoring session: %s", err)
+		}
+	}
 	for {
-		src, err := readLine(f)
+		src, err := readLine(f, c)
 		if err != nil {
 			if err == io.EOF {
 				break
@@ -30,20 +50,11 @@ func main() {
 			fmt.Fprintf(os.Stderr, "error reading %s: %s\n", f, err)
 			os.Exit(1)
 		}
-		_ = src
-
-		src = transform(src)
-
-		so := compile(src)
-		if so == "" {
+		if strings.HasPrefix(strings.TrimSpace(src), ":") {
+			command(src)
 			continue
 		}
-
-		run(so)
-
-		// TODO: Declare global exported functions to access
-		// all unexported variables and fields. How do I get
-		// at types?
+		eval(src, c)
 	}
 
 	if tempDir != "" {
@@ -51,12 +62,56 @@ func main() {
 	}
 }
 
+// eval compiles and runs src. If src compiles, it records it in the
+// session history and returns true.
+func eval(src string, c *completer) bool {
+	c.addSource(src)
+
+	so := compile(transform(src))
+	if so == "" {
+		return false
+	}
+	history = append(history, src)
+
+	run(so)
+
+	// TODO: Declare global exported functions to access
+	// all unexported variables and fields. How do I get
+	// at types?
+	return true
+}
+
 var index int
 
-func readLine(r io.Reader) (string, error) {
+// terminal is the line editor used if stdin is a terminal. It's
+// shared across lines so it keeps history.
+var terminal *term.Terminal
+
+func readLine(f *os.File, c *completer) (string, error) {
 	// TODO: Continuation lines.
+	fd := int(f.Fd())
+	if term.IsTerminal(fd) {
+		// Put the terminal in raw mode only while reading so
+		// output from compiled code isn't mangled.
+		oldState, err := term.MakeRaw(fd)
+		if err == nil {
+			defer term.Restore(fd, oldState)
+			if terminal == nil {
+				terminal = term.NewTerminal(struct {
+					io.Reader
+					io.Writer
+				}{f, os.Stdout}, "> ")
+				terminal.AutoCompleteCallback = c.complete
+			}
+			line, err := terminal.ReadLine()
+			if err != nil {
+				return "", err
+			}
+			return line + "\n", nil
+		}
+	}
 	fmt.Printf("> ")
-	r2 := bufio.NewReader(r)
+	r2 := bufio.NewReader(f)
 	return r2.ReadString('\n')
 }
 
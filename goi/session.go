@@ -0,0 +1,77 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// A session file records the lines entered in a goi session, one per
+// line, in the order they were entered. Only lines that compiled are
+// recorded. Blank lines and lines starting with "//" are ignored, so
+// session files can be annotated by hand.
+//
+// Restoring a session replays its lines. Imports are restored
+// directly, and other lines are compiled and run again, so their side
+// effects happen again. Each line runs in its own plugin, so goi
+// doesn't keep variable values between lines, and there are no values
+// to save.
+
+// history is the source of each line entered in this session that
+// compiled.
+var history []string
+
+// saveSession writes this session's history to path.
+func saveSession(path string) error {
+	var buf strings.Builder
+	fmt.Fprintf(&buf, "// goi session\n")
+	for _, src := range history {
+		buf.WriteString(src)
+		if !strings.HasSuffix(src, "\n") {
+			buf.WriteString("\n")
+		}
+	}
+	return os.WriteFile(path, []byte(buf.String()), 0666)
+}
+
+// restoreSession replays the session saved in path.
+func restoreSession(path string, c *completer) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for lineno := 1; scanner.Scan(); lineno++ {
+		src := scanner.Text()
+		if trimmed := strings.TrimSpace(src); trimmed == "" || strings.HasPrefix(trimmed, "//") {
+			continue
+		}
+		if !eval(src+"\n", c) {
+			return fmt.Errorf("%s:%d: failed to compile %q", path, lineno, src)
+		}
+	}
+	return scanner.Err()
+}
+
+// command runs the goi command line, which starts with ":".
+func command(line string) {
+	fs := strings.Fields(line)
+	switch {
+	case fs[0] == ":save" && len(fs) == 2:
+		if err := saveSession(fs[1]); err != nil {
+			fmt.Fprintf(os.Stderr, "error saving session: %s\n", err)
+			return
+		}
+		fmt.Printf("saved %d lines to %s\n", len(history), fs[1])
+	case fs[0] == ":save":
+		fmt.Fprintf(os.Stderr, "usage: :save file\n")
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %s\n", fs[0])
+	}
+}
@@ -0,0 +1,258 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+
+	"golang.org/x/build/buildlet"
+)
+
+// A backend creates and manages gomote instances.
+type backend interface {
+	// Create creates a new instance of builder type kind and
+	// returns its name.
+	Create(kind string) (string, error)
+
+	// List returns the names of all of the user's instances.
+	List() ([]string, error)
+
+	// Instance returns a client for the named instance. It may
+	// not check that the instance exists.
+	Instance(name string) instance
+}
+
+// An instance is a client for a single gomote instance.
+type instance interface {
+	// Check returns an error if the instance doesn't exist or
+	// isn't responding.
+	Check(ctx context.Context) error
+
+	// Destroy destroys the instance.
+	Destroy() error
+
+	// PutDir replaces directory dir, relative to the instance's
+	// work directory, with the contents of the gzipped tar file
+	// at path tgz.
+	PutDir(ctx context.Context, tgz, dir string) error
+}
+
+// Backend names, as recorded in Config.Backend.
+const (
+	// backendCoordinator is the legacy coordinator HTTP API, used
+	// through the buildlet package. Configs from before backends
+	// existed have an empty Backend, which means this.
+	backendCoordinator = "coordinator"
+
+	// backendGomote is the gomote gRPC service, used through the
+	// gomote command. The gRPC client isn't importable from
+	// outside x/build, but the gomote command speaks it and its
+	// output is stable enough to parse.
+	backendGomote = "gomote"
+)
+
+// defaultBackend returns the backend to use for a new pool. It
+// prefers the gomote gRPC service if the gomote command is
+// installed, and falls back to the coordinator otherwise.
+func defaultBackend() string {
+	if _, err := exec.LookPath("gomote"); err == nil {
+		return backendGomote
+	}
+	return backendCoordinator
+}
+
+var backendCache backend
+var backendOnce sync.Once
+
+// getBackend returns the backend with the given name. It must always
+// be called with the same name.
+func getBackend(name string) backend {
+	backendOnce.Do(func() {
+		switch name {
+		case "", backendCoordinator:
+			backendCache = &coordinatorBackend{}
+		case backendGomote:
+			backendCache = &gomoteBackend{cmd: "gomote"}
+		default:
+			log.Fatalf("unknown backend %q", name)
+		}
+	})
+	return backendCache
+}
+
+// coordinatorBackend uses the legacy coordinator API.
+type coordinatorBackend struct {
+	once  sync.Once
+	coord *buildlet.CoordinatorClient
+}
+
+func (c *coordinatorBackend) get() *buildlet.CoordinatorClient {
+	c.once.Do(func() {
+		coord, err := buildlet.NewCoordinatorClientFromFlags()
+		if err != nil {
+			log.Fatalf("error connecting to coordinator: %v", err)
+		}
+		c.coord = coord
+	})
+	return c.coord
+}
+
+func (c *coordinatorBackend) Create(kind string) (string, error) {
+	client, err := c.get().CreateBuildlet(kind)
+	if err != nil {
+		return "", err
+	}
+	return client.RemoteName(), nil
+}
+
+func (c *coordinatorBackend) List() ([]string, error) {
+	rbs, err := c.get().RemoteBuildlets()
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, rb := range rbs {
+		names = append(names, rb.Name)
+	}
+	return names, nil
+}
+
+func (c *coordinatorBackend) Instance(name string) instance {
+	client, err := c.get().NamedBuildlet(name)
+	if err != nil {
+		log.Fatalf("error getting buildlet %s client: %s", name, err)
+	}
+	return coordinatorInstance{client}
+}
+
+type coordinatorInstance struct {
+	client *buildlet.Client
+}
+
+func (i coordinatorInstance) Check(ctx context.Context) error {
+	// NamedBuildlet doesn't even validate, so get the status and
+	// then ping the buildlet to really check it.
+	if _, err := i.client.Status(ctx); err != nil {
+		return err
+	}
+	return i.client.ListDir(ctx, ".", buildlet.ListDirOpts{}, func(buildlet.DirEntry) {})
+}
+
+func (i coordinatorInstance) Destroy() error {
+	return i.client.Close()
+}
+
+func (i coordinatorInstance) PutDir(ctx context.Context, tgz, dir string) error {
+	f, err := os.Open(tgz)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := i.client.RemoveAll(ctx, dir); err != nil {
+		return err
+	}
+	return i.client.PutTar(ctx, f, dir)
+}
+
+// gomoteBackend uses the gomote gRPC service by running the gomote
+// command.
+type gomoteBackend struct {
+	cmd string
+}
+
+// run runs the gomote command with args and returns its standard
+// output.
+func (g *gomoteBackend) run(ctx context.Context, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, g.cmd, args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			return "", fmt.Errorf("gomote %s: %w", strings.Join(args, " "), err)
+		}
+		return "", fmt.Errorf("gomote %s: %w\n%s", strings.Join(args, " "), err, msg)
+	}
+	return string(out), nil
+}
+
+func (g *gomoteBackend) Create(kind string) (string, error) {
+	out, err := g.run(context.Background(), "create", kind)
+	if err != nil {
+		return "", err
+	}
+	// The instance name is the last line of output.
+	fs := strings.Fields(out)
+	if len(fs) == 0 {
+		return "", fmt.Errorf("gomote create %s: no instance name in output", kind)
+	}
+	return fs[len(fs)-1], nil
+}
+
+func (g *gomoteBackend) List() ([]string, error) {
+	out, err := g.run(context.Background(), "list")
+	if err != nil {
+		return nil, err
+	}
+	// Each line is "name<TAB>builder type<TAB>host type<TAB>expires in...".
+	var names []string
+	for _, line := range strings.Split(out, "\n") {
+		if fs := strings.Fields(line); len(fs) > 0 {
+			names = append(names, fs[0])
+		}
+	}
+	return names, nil
+}
+
+func (g *gomoteBackend) Instance(name string) instance {
+	return gomoteInstance{g, name}
+}
+
+type gomoteInstance struct {
+	g    *gomoteBackend
+	name string
+}
+
+func (i gomoteInstance) Check(ctx context.Context) error {
+	// gRPC gomotes expire on their own, so first make sure it
+	// still exists.
+	names, err := i.g.List()
+	if err != nil {
+		return err
+	}
+	found := false
+	for _, name := range names {
+		if name == i.name {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return fmt.Errorf("instance %s no longer exists", i.name)
+	}
+	_, err = i.g.run(ctx, "ls", i.name)
+	return err
+}
+
+func (i gomoteInstance) Destroy() error {
+	_, err := i.g.run(context.Background(), "destroy", i.name)
+	return err
+}
+
+func (i gomoteInstance) PutDir(ctx context.Context, tgz, dir string) error {
+	if _, err := i.g.run(ctx, "rm", i.name, dir); err != nil {
+		return err
+	}
+	_, err := i.g.run(ctx, "puttar", "-dir="+dir, i.name, tgz)
+	return err
+}
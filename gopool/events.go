@@ -0,0 +1,136 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"time"
+)
+
+// Kinds of events recorded in the pool's event log.
+const (
+	EventCreate     = "create"      // Created a buildlet
+	EventCreateFail = "create-fail" // Failed to create a buildlet
+	EventSetupFail  = "setup-fail"  // Setup command failed; buildlet destroyed
+	EventGet        = "get"         // Checked out a buildlet
+	EventPut        = "put"         // Checked a buildlet back in
+	EventDiscard    = "discard"     // Destroyed a broken or failed buildlet
+	EventReap       = "reap"        // Destroyed an abandoned buildlet
+)
+
+// An Event is one record in the pool's event log.
+type Event struct {
+	Time     time.Time
+	PID      int
+	Kind     string
+	Buildlet string `json:",omitempty"`
+	Detail   string `json:",omitempty"`
+}
+
+func (p *Pool) eventsPath() string {
+	return path.Join(p.path, "events.log")
+}
+
+// logEvent appends an event to the pool's event log. Errors are
+// reported but otherwise ignored, since the log is only for
+// diagnosis.
+func (p *Pool) logEvent(kind, buildlet, detail string) {
+	ev := Event{Time: time.Now(), PID: os.Getpid(), Kind: kind, Buildlet: buildlet, Detail: detail}
+	data, err := json.Marshal(&ev)
+	if err != nil {
+		log.Fatal(err)
+	}
+	// Each event is written with a single append, so events from
+	// concurrent gopool processes don't interleave.
+	f, err := os.OpenFile(p.eventsPath(), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
+	if err != nil {
+		log.Printf("error writing event log: %s", err)
+		return
+	}
+	defer f.Close()
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		log.Printf("error writing event log: %s", err)
+	}
+}
+
+// readEvents reads an event log. It skips malformed lines, such as a
+// line truncated by a crash.
+func readEvents(r io.Reader) ([]Event, error) {
+	var events []Event
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		var ev Event
+		if err := json.Unmarshal(scanner.Bytes(), &ev); err != nil {
+			continue
+		}
+		events = append(events, ev)
+	}
+	return events, scanner.Err()
+}
+
+// String formats ev as a line of "gopool history" output.
+func (ev Event) String() string {
+	s := fmt.Sprintf("%s %7d %-11s %s", ev.Time.Local().Format("2006-01-02 15:04:05"), ev.PID, ev.Kind, ev.Buildlet)
+	if ev.Detail != "" {
+		s += ": " + ev.Detail
+	}
+	return s
+}
+
+func cmdHistory(args []string) {
+	var n int
+	flags := flag.NewFlagSet("history", flag.ExitOnError)
+	flags.IntVar(&n, "n", 0, "print only the last `n` events (0 for all)")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), `Usage: %s history [flags]
+
+Print the pool's event log, oldest first. Each line gives the time,
+the PID of the gopool process, the kind of event, the buildlet, and
+any details, such as why a buildlet was destroyed. The kinds of
+events are:
+
+  create       created a buildlet
+  create-fail  failed to create a buildlet
+  setup-fail   the setup command failed, so the buildlet was destroyed
+  get          checked out a buildlet
+  put          checked a buildlet back in
+  discard      destroyed a buildlet that was broken or whose command failed
+  reap         destroyed a buildlet that was abandoned or whose lease expired
+
+`, os.Args[0])
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() != 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	p := &Pool{path: poolPath}
+	f, err := os.Open(p.eventsPath())
+	if os.IsNotExist(err) {
+		return
+	} else if err != nil {
+		log.Fatal(err)
+	}
+	defer f.Close()
+	events, err := readEvents(f)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if n > 0 && len(events) > n {
+		events = events[len(events)-n:]
+	}
+	for _, ev := range events {
+		fmt.Println(ev)
+	}
+}
@@ -4,6 +4,17 @@
 
 package main
 
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
 // TODO: Test reusing
 
 // TODO: Test setup command
@@ -11,3 +22,325 @@ package main
 // TODO: Test killing client in the middle
 
 // TODO: Test client with failed commands
+
+func TestHasTags(t *testing.T) {
+	for _, test := range []struct {
+		have, want []string
+		res        bool
+	}{
+		{nil, nil, true},
+		{[]string{"a"}, nil, true},
+		{nil, []string{"a"}, false},
+		{[]string{"a", "b"}, []string{"b"}, true},
+		{[]string{"a", "b"}, []string{"b", "c"}, false},
+	} {
+		if got := hasTags(test.have, test.want); got != test.res {
+			t.Errorf("hasTags(%v, %v) = %v, want %v", test.have, test.want, got, test.res)
+		}
+	}
+}
+
+func TestDecodeConfig(t *testing.T) {
+	// Unversioned configs are migrated.
+	cfg, err := decodeConfig([]byte(`{"Kind":"host-linux-amd64","Max":5,"Free":["a"]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Version != configVersion || cfg.Kind != "host-linux-amd64" || cfg.Max != 5 || len(cfg.Free) != 1 {
+		t.Errorf("bad migrated config %+v", cfg)
+	}
+
+	// Version 1 recorded creations by PID.
+	cfg, err = decodeConfig([]byte(`{"Version":1,"Creating":[123]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Creating) != 1 || cfg.Creating[0] != (creation{"", 123}) || cfg.Creating[0].lockName() != "creating-123" {
+		t.Errorf("bad migrated Creating %+v", cfg.Creating)
+	}
+	if name := (creation{"h1", 123}).lockName(); name != "creating-h1-123" {
+		t.Errorf("lock name %q, want creating-h1-123", name)
+	}
+
+	// Configs from the future are rejected.
+	_, err = decodeConfig([]byte(`{"Version":1000}`))
+	if err == nil || !strings.Contains(err.Error(), "upgrade gopool") {
+		t.Errorf("want error for future version, got %v", err)
+	}
+
+	_, err = decodeConfig([]byte(`{"Version":"x"}`))
+	if err == nil {
+		t.Errorf("want error for bad version")
+	}
+}
+
+func TestGomoteBackend(t *testing.T) {
+	// Fake the gomote command with a script that keeps its
+	// instances in a file.
+	dir := t.TempDir()
+	script := filepath.Join(dir, "gomote")
+	err := ioutil.WriteFile(script, []byte(`#!/bin/sh
+state="$(dirname "$0")/instances"
+case "$1" in
+create) echo "# still creating..." >&2; echo "user-$2-0"; echo "user-$2-0	$2	host-$2	expires in 30m0s" >> "$state" ;;
+list) cat "$state" 2>/dev/null ;;
+ls) grep -q "^$2	" "$state" || { echo "instance not found" >&2; exit 1; } ;;
+destroy) grep -v "^$2	" "$state" > "$state.tmp"; mv "$state.tmp" "$state" ;;
+*) exit 2 ;;
+esac
+`), 0777)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := &gomoteBackend{cmd: script}
+
+	name, err := g.Create("linux-amd64")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name != "user-linux-amd64-0" {
+		t.Errorf("Create returned %q, want user-linux-amd64-0", name)
+	}
+	names, err := g.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 1 || names[0] != name {
+		t.Errorf("List returned %q, want [%s]", names, name)
+	}
+
+	inst := g.Instance(name)
+	if err := inst.Check(context.Background()); err != nil {
+		t.Errorf("Check failed: %v", err)
+	}
+	if err := inst.Destroy(); err != nil {
+		t.Fatal(err)
+	}
+	if err := inst.Check(context.Background()); err == nil || !strings.Contains(err.Error(), "no longer exists") {
+		t.Errorf("Check of destroyed instance: got %v, want no longer exists", err)
+	}
+}
+
+func TestPush(t *testing.T) {
+	// Fake the gomote command with a script that logs pushes.
+	dir := t.TempDir()
+	script := filepath.Join(dir, "gomote")
+	err := ioutil.WriteFile(script, []byte(`#!/bin/sh
+case "$1" in
+rm) ;;
+puttar) echo "$2 $3" >> "$(dirname "$0")/pushes" ;;
+*) exit 2 ;;
+esac
+`), 0777)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := filepath.Join(dir, "goroot")
+	if err := os.MkdirAll(filepath.Join(src, "bin"), 0777); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(src, "bin", "go"), []byte("v1"), 0777); err != nil {
+		t.Fatal(err)
+	}
+
+	writeTestConfig(t, dir, &Config{InUse: []string{"vm"}})
+	b := &Buildlet{Name: "vm", path: filepath.Join(dir, "vm"), backend: &gomoteBackend{cmd: script}}
+	pushes := func() int {
+		data, _ := ioutil.ReadFile(filepath.Join(dir, "pushes"))
+		return strings.Count(string(data), "-dir=go vm\n")
+	}
+	push := func(want bool) {
+		t.Helper()
+		pushed, err := b.Push(src, "go")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pushed != want {
+			t.Errorf("Push returned %v, want %v", pushed, want)
+		}
+	}
+
+	push(true)
+	push(false)
+	if n := pushes(); n != 1 {
+		t.Errorf("got %d pushes, want 1", n)
+	}
+	tags := b.State().Tags
+	if len(tags) != 1 || !strings.HasPrefix(tags[0], "pushed:go=") {
+		t.Errorf("got tags %v, want one pushed:go= tag", tags)
+	}
+
+	// Changing the contents pushes again and replaces the tag.
+	if err := ioutil.WriteFile(filepath.Join(src, "bin", "go"), []byte("v2"), 0777); err != nil {
+		t.Fatal(err)
+	}
+	push(true)
+	if n := pushes(); n != 2 {
+		t.Errorf("got %d pushes, want 2", n)
+	}
+	if tags2 := b.State().Tags; len(tags2) != 1 || tags2[0] == tags[0] {
+		t.Errorf("got tags %v after change, want one new tag", tags2)
+	}
+}
+
+func TestPushFree(t *testing.T) {
+	dir := t.TempDir()
+	script := filepath.Join(dir, "gomote")
+	err := ioutil.WriteFile(script, []byte(`#!/bin/sh
+echo "$@" >> "$(dirname "$0")/calls"
+`), 0777)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := filepath.Join(dir, "goroot")
+	if err := os.MkdirAll(src, 0777); err != nil {
+		t.Fatal(err)
+	}
+
+	// A free buildlet may be handed out by Get at any time, so
+	// push refuses to touch it.
+	writeTestConfig(t, dir, &Config{InUse: []string{"vm1"}, Free: []string{"vm2"}})
+	p := &Pool{path: dir, backend: &gomoteBackend{cmd: script}}
+	if _, err := p.push("vm2", src, "go"); err == nil {
+		t.Errorf("push to free buildlet succeeded")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "calls")); err == nil {
+		t.Errorf("push to free buildlet ran gomote")
+	}
+	if st := p.buildletByName("vm2").State(); len(st.Tags) != 0 {
+		t.Errorf("free buildlet has tags %v", st.Tags)
+	}
+
+	// The buildlet checked out by our caller is pushed and keeps
+	// the tag, so it isn't pushed again.
+	for _, want := range []bool{true, false} {
+		pushed, err := p.push("vm1", src, "go")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pushed != want {
+			t.Errorf("push returned %v, want %v", pushed, want)
+		}
+	}
+}
+
+// writeTestConfig writes cfg as the config of the pool in dir.
+func writeTestConfig(t *testing.T, dir string, cfg *Config) {
+	t.Helper()
+	cfg.Version = configVersion
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "config"), data, 0666); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUpdateStateReaped(t *testing.T) {
+	dir := t.TempDir()
+	writeTestConfig(t, dir, &Config{InUse: []string{"vm"}})
+	b := &Buildlet{Name: "vm", path: filepath.Join(dir, "vm"), lease: time.Minute}
+	b.extendLease()
+	if b.State().LeaseExpiry.IsZero() {
+		t.Fatalf("extendLease didn't set the lease of an in-use buildlet")
+	}
+
+	// Reap it, as discardLocked would.
+	writeTestConfig(t, dir, &Config{})
+	b.removeState()
+	b.extendLease()
+	if _, err := os.Stat(b.statePath()); !os.IsNotExist(err) {
+		t.Errorf("extendLease recreated the state of a reaped buildlet")
+	}
+}
+
+func TestEvents(t *testing.T) {
+	p := &Pool{path: t.TempDir()}
+	p.logEvent(EventCreate, "vm1", "host-linux-amd64")
+	p.logEvent(EventGet, "vm1", "")
+	p.logEvent(EventReap, "vm1", "lease expired")
+
+	// Append a truncated line, as if a write was interrupted.
+	f, err := os.OpenFile(p.eventsPath(), os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"Time":"2026-`)
+	f.Close()
+
+	f, err = os.Open(p.eventsPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	events, err := readEvents(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var kinds []string
+	for _, ev := range events {
+		if ev.PID != os.Getpid() || ev.Buildlet != "vm1" || ev.Time.IsZero() {
+			t.Errorf("bad event %+v", ev)
+		}
+		kinds = append(kinds, ev.Kind)
+	}
+	if got, want := strings.Join(kinds, " "), "create get reap"; got != want {
+		t.Errorf("got events %s, want %s", got, want)
+	}
+	if s := events[2].String(); !strings.HasSuffix(s, " reap        vm1: lease expired") {
+		t.Errorf("String() = %q, want suffix %q", s, " reap        vm1: lease expired")
+	}
+}
+
+func TestLockfileLocker(t *testing.T) {
+	lockPath := filepath.Join(t.TempDir(), "lock")
+	l := &lockfileLocker{host: "test"}
+
+	h1, err := l.TryLock(lockPath)
+	if err != nil || h1 == nil {
+		t.Fatalf("TryLock on free lock = %v, %v", h1, err)
+	}
+	if h, err := l.TryLock(lockPath); err != nil || h != nil {
+		t.Fatalf("TryLock on held lock = %v, %v, want nil, nil", h, err)
+	}
+	h1.Unlock()
+
+	h2, err := l.Lock(lockPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h1.Token() == 0 || h2.Token() <= h1.Token() {
+		t.Errorf("got tokens %d then %d, want increasing non-zero tokens", h1.Token(), h2.Token())
+	}
+	h2.Unlock()
+
+	// A lock whose holder stopped updating its heartbeat can be
+	// broken, and the new holder gets a newer token.
+	stale := lockRecord{Host: "other", PID: 1, Token: 100, Heartbeat: time.Now().Add(-2 * lockStale)}
+	data, err := json.Marshal(&stale)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(lockPath+".held", data, 0666); err != nil {
+		t.Fatal(err)
+	}
+	h3, err := l.TryLock(lockPath)
+	if err != nil || h3 == nil {
+		t.Fatalf("TryLock on stale lock = %v, %v", h3, err)
+	}
+	if h3.Token() <= h2.Token() {
+		t.Errorf("got token %d after breaking lock, want > %d", h3.Token(), h2.Token())
+	}
+	h3.Unlock()
+
+	// But a lock with a recent heartbeat can't be.
+	stale.Heartbeat = time.Now()
+	data, _ = json.Marshal(&stale)
+	if err := ioutil.WriteFile(lockPath+".held", data, 0666); err != nil {
+		t.Fatal(err)
+	}
+	if h, err := l.TryLock(lockPath); err != nil || h != nil {
+		t.Fatalf("TryLock on live lock = %v, %v, want nil, nil", h, err)
+	}
+}
@@ -0,0 +1,351 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"io/ioutil"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Locking schemes, as recorded in Config.Locking.
+const (
+	// lockingFlock uses flock(2) on the lock files. A lock is
+	// released when its holder exits, however it exits, but flock
+	// isn't reliable on shared filesystems like NFS. Configs from
+	// before locking schemes existed have an empty Locking, which
+	// means this.
+	lockingFlock = "flock"
+
+	// lockingLockfile creates a separate file to hold a lock. It
+	// only relies on exclusive create and rename, so it works on
+	// shared filesystems, and lets several machines share a pool.
+	// See lockfileLocker.
+	lockingLockfile = "lockfile"
+)
+
+// A locker acquires exclusive locks named by paths in the pool
+// directory.
+type locker interface {
+	// Lock acquires the lock at path, waiting until it's free.
+	Lock(path string) (heldLock, error)
+
+	// TryLock acquires the lock at path if it's free. If it's
+	// held, it returns nil, nil.
+	TryLock(path string) (heldLock, error)
+}
+
+// A heldLock is a lock acquired by a locker.
+type heldLock interface {
+	Unlock()
+
+	// Token returns the lock's fencing token. Each holder of a
+	// lock gets a larger token than every earlier holder, so a
+	// token can show that a holder lost the lock to a later one.
+	// It's 0 if the locking scheme has no tokens.
+	Token() uint64
+}
+
+func getLocker(name string) locker {
+	switch name {
+	case "", lockingFlock:
+		return flockLocker{}
+	case lockingLockfile:
+		return newLockfileLocker()
+	}
+	log.Fatalf("unknown locking scheme %q", name)
+	panic("unreachable")
+}
+
+// flockLocker is the lockingFlock scheme.
+type flockLocker struct{}
+
+func (flockLocker) Lock(path string) (heldLock, error) {
+	l, err := LockFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func (flockLocker) TryLock(path string) (heldLock, error) {
+	l, err := TryLockFile(path)
+	if l == nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func (fl *FileLock) Token() uint64 { return 0 }
+
+// Timing of the lockingLockfile scheme.
+const (
+	// lockHeartbeat is how often a lock holder updates its
+	// record.
+	lockHeartbeat = 10 * time.Second
+
+	// lockStale is how long a lock can go without a heartbeat
+	// before another process may break it. It allows for clock
+	// skew between machines.
+	lockStale = 6 * lockHeartbeat
+
+	// lockPoll is how often Lock checks a held lock.
+	lockPoll = 250 * time.Millisecond
+)
+
+// A lockfileLocker implements the lockingLockfile scheme. The lock
+// at path is held by whoever creates path+".held", which records the
+// holder's host and PID and a heartbeat that the holder updates
+// every lockHeartbeat. If the heartbeat is more than lockStale old,
+// the holder is presumed dead and another process may break the
+// lock. Breaking takes path+".break", so that two processes can't
+// both break a lock and one of them remove the other's new lock.
+//
+// A holder that stalls for more than lockStale, for example because
+// its machine was suspended, may find its lock broken. It exits
+// when its heartbeat discovers this. Until then, it may still write
+// what the lock protects, so writers should also check the lock's
+// token, which counts acquisitions of the lock in path+".fence".
+type lockfileLocker struct {
+	host string
+}
+
+// A lockRecord is the contents of a lockfileLocker's held file.
+type lockRecord struct {
+	Host      string
+	PID       int
+	Token     uint64
+	Heartbeat time.Time
+}
+
+func newLockfileLocker() *lockfileLocker {
+	host, err := os.Hostname()
+	if err != nil {
+		log.Fatal(err)
+	}
+	return &lockfileLocker{host: host}
+}
+
+func (l *lockfileLocker) Lock(path string) (heldLock, error) {
+	for {
+		h, err := l.TryLock(path)
+		if h != nil || err != nil {
+			return h, err
+		}
+		time.Sleep(lockPoll)
+	}
+}
+
+func (l *lockfileLocker) TryLock(path string) (heldLock, error) {
+	for {
+		f, err := os.OpenFile(path+".held", os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0666)
+		if err == nil {
+			f.Close()
+			return l.acquired(path)
+		}
+		if !errors.Is(err, fs.ErrExist) {
+			return nil, err
+		}
+		broke, err := l.breakStale(path)
+		if err != nil || !broke {
+			return nil, err
+		}
+	}
+}
+
+// acquired sets up the lock at path after creating its held file.
+func (l *lockfileLocker) acquired(path string) (heldLock, error) {
+	token, err := readFence(path)
+	if err != nil {
+		os.Remove(path + ".held")
+		return nil, err
+	}
+	token++
+	if err := ioutil.WriteFile(path+".fence", []byte(fmt.Sprintf("%d\n", token)), 0666); err != nil {
+		os.Remove(path + ".held")
+		return nil, err
+	}
+	h := &lockfileHeld{
+		l:    l,
+		path: path,
+		rec:  lockRecord{Host: l.host, PID: os.Getpid(), Token: token, Heartbeat: time.Now()},
+		stop: make(chan struct{}),
+	}
+	if err := h.write(); err != nil {
+		os.Remove(path + ".held")
+		return nil, err
+	}
+	h.wg.Add(1)
+	go h.heartbeat()
+	return h, nil
+}
+
+// readFence returns the last token of the lock at path.
+func readFence(path string) (uint64, error) {
+	data, err := ioutil.ReadFile(path + ".fence")
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+	token, err := strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("bad lock fence %s: %w", path+".fence", err)
+	}
+	return token, nil
+}
+
+// readLockRecord reads the held file of the lock at path. If the
+// holder hasn't written its record yet, it returns a record with just
+// the time the file was created as the heartbeat.
+func readLockRecord(path string) (lockRecord, error) {
+	var rec lockRecord
+	data, err := ioutil.ReadFile(path + ".held")
+	if err != nil {
+		return rec, err
+	}
+	if json.Unmarshal(data, &rec) == nil {
+		return rec, nil
+	}
+	fi, err := os.Stat(path + ".held")
+	if err != nil {
+		return rec, err
+	}
+	return lockRecord{Heartbeat: fi.ModTime()}, nil
+}
+
+// breakStale breaks the lock at path if its holder is presumed dead.
+// It reports whether the lock may now be free.
+func (l *lockfileLocker) breakStale(path string) (bool, error) {
+	rec, err := readLockRecord(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		// Unlocked since we tried.
+		return true, nil
+	} else if err != nil {
+		return false, err
+	}
+	if time.Since(rec.Heartbeat) < lockStale {
+		return false, nil
+	}
+
+	// Keep other processes from breaking the lock at the same
+	// time. If a breaker died while breaking it, clean up after
+	// it. Either way, leave it to the next try.
+	brk := path + ".break"
+	f, err := os.OpenFile(brk, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0666)
+	if errors.Is(err, fs.ErrExist) {
+		if fi, err := os.Stat(brk); err == nil && time.Since(fi.ModTime()) > lockStale {
+			os.Remove(brk)
+		}
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	f.Close()
+	defer os.Remove(brk)
+
+	// Make sure it's still the same stale lock.
+	rec2, err := readLockRecord(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return true, nil
+	} else if err != nil {
+		return false, err
+	}
+	if rec2 != rec {
+		return false, nil
+	}
+	log.Printf("breaking stale lock %s held by %s pid %d (last heartbeat %s)", path, rec.Host, rec.PID, rec.Heartbeat.Format(time.RFC3339))
+	if err := os.Remove(path + ".held"); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return false, err
+	}
+	return true, nil
+}
+
+// A lockfileHeld is a lock held under the lockingLockfile scheme.
+type lockfileHeld struct {
+	l    *lockfileLocker
+	path string
+
+	mu  sync.Mutex // Protects rec
+	rec lockRecord
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+// write writes h's record to its held file.
+func (h *lockfileHeld) write() error {
+	h.mu.Lock()
+	data, err := json.Marshal(&h.rec)
+	h.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	tmp := fmt.Sprintf("%s.held.%s.%d", h.path, h.l.host, os.Getpid())
+	if err := ioutil.WriteFile(tmp, data, 0666); err != nil {
+		return err
+	}
+	return os.Rename(tmp, h.path+".held")
+}
+
+// stillHeld returns an error if h's lock was broken.
+func (h *lockfileHeld) stillHeld() error {
+	rec, err := readLockRecord(h.path)
+	if err != nil {
+		return fmt.Errorf("lock %s was broken: %w", h.path, err)
+	}
+	if rec.Token != h.rec.Token || rec.Host != h.rec.Host || rec.PID != h.rec.PID {
+		return fmt.Errorf("lock %s was broken and is now held by %s pid %d", h.path, rec.Host, rec.PID)
+	}
+	return nil
+}
+
+func (h *lockfileHeld) heartbeat() {
+	defer h.wg.Done()
+	ticker := time.NewTicker(lockHeartbeat)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-h.stop:
+			return
+		case <-ticker.C:
+		}
+		if err := h.stillHeld(); err != nil {
+			// Whatever this process is doing under the
+			// lock is no longer safe.
+			log.Fatal(err)
+		}
+		h.mu.Lock()
+		h.rec.Heartbeat = time.Now()
+		h.mu.Unlock()
+		if err := h.write(); err != nil {
+			log.Printf("updating lock %s: %s", h.path, err)
+		}
+	}
+}
+
+func (h *lockfileHeld) Unlock() {
+	close(h.stop)
+	h.wg.Wait()
+	if err := h.stillHeld(); err != nil {
+		log.Print(err)
+		return
+	}
+	if err := os.Remove(h.path + ".held"); err != nil {
+		log.Printf("unlocking %s: %s", h.path, err)
+	}
+}
+
+func (h *lockfileHeld) Token() uint64 {
+	return h.rec.Token
+}
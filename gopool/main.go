@@ -10,6 +10,7 @@ import (
 	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
 	"os"
@@ -18,6 +19,7 @@ import (
 	"strings"
 	"sync"
 	"syscall"
+	"time"
 
 	"golang.org/x/build/buildlet"
 )
@@ -34,6 +36,9 @@ func main() {
 		fmt.Fprintf(w, "  create   create a new buildlet pool\n")
 		fmt.Fprintf(w, "  destroy  destroy the buildlet pool\n")
 		fmt.Fprintf(w, "  run      run a command with a buildlet from the pool\n")
+		fmt.Fprintf(w, "  logs     print the setup log of a buildlet\n")
+		fmt.Fprintf(w, "  push     push a directory to a buildlet if it has changed\n")
+		fmt.Fprintf(w, "  history  print the pool's event log\n")
 	}
 	flag.StringVar(&poolPath, "pool-path", defaultPoolPath(), "pool state `directory`")
 	flag.Parse()
@@ -58,10 +63,27 @@ func main() {
 	case "run":
 		cmdRun(args)
 		return
+
+	case "logs":
+		cmdLogs(args)
+		return
+
+	case "push":
+		cmdPush(args)
+		return
+
+	case "history":
+		cmdHistory(args)
+		return
 	}
 }
 
 func defaultPoolPath() string {
+	// Commands run by gopool get the pool path so they can run
+	// gopool subcommands on the same pool.
+	if path := os.Getenv("GOPOOL_PATH"); path != "" {
+		return path
+	}
 	const fallback = "/tmp/gopool"
 	uid := os.Getuid()
 	if uid <= 0 {
@@ -74,18 +96,72 @@ func defaultPoolPath() string {
 	return path + "/gopool"
 }
 
+// configVersion is the current version of the Config format. Bump
+// this and add a migration to configMigrations when making an
+// incompatible change to Config.
+const configVersion = 2
+
 type Config struct {
+	// Version is the format version of this config. Configs
+	// written before versioning was introduced have version 0.
+	Version int
+
 	Setup struct {
 		Cmd string
 		Env []string
 		Dir string
 	}
+
+	// Health is a shell command run with $VM and $VM_TAGS set
+	// before handing out a free buildlet. If it fails, the
+	// buildlet is destroyed. It runs in the Setup environment.
+	Health string
+
+	// Backend is the gomote service used to create buildlets:
+	// "gomote" for the gomote gRPC service or "coordinator" (or
+	// "") for the legacy coordinator.
+	Backend string
+
 	Kind string
 	Max  int
 
+	// Lease is how long a checked-out buildlet may go without
+	// its lease being extended before it can be reaped, even if
+	// its lock is still held. If 0, leases never expire.
+	Lease time.Duration
+
+	// Locking is the scheme used to lock the pool and its
+	// buildlets: "flock" (or "") or "lockfile". Pools shared by
+	// several machines over NFS must use "lockfile". See
+	// lockingFlock and lockingLockfile.
+	Locking string
+
+	// Fence is the fencing token of the pool lock held by the
+	// last process to write this config. flush refuses to write a
+	// config if it no longer holds the newest token. It is 0 if
+	// the locking scheme has no tokens.
+	Fence uint64
+
 	Free     []string
 	InUse    []string
-	Creating []int
+	Creating []creation
+}
+
+// A creation is a buildlet being created by the process PID on Host.
+// Pools may be shared by several machines, so PIDs alone aren't
+// unique.
+type creation struct {
+	Host string
+	PID  int
+}
+
+// lockName returns the name of the lock file held by c's creator.
+func (c creation) lockName() string {
+	if c.Host == "" {
+		// Migrated from version 1.
+		return fmt.Sprintf("creating-%d", c.PID)
+	}
+	return fmt.Sprintf("creating-%s-%d", c.Host, c.PID)
 }
 
 func (c *Config) dropInUse(name string) {
@@ -98,9 +174,18 @@ func (c *Config) dropInUse(name string) {
 	}
 }
 
-func (c *Config) dropCreating(pid int) {
-	for i, pid2 := range c.Creating {
-		if pid == pid2 {
+func (c *Config) isInUse(name string) bool {
+	for _, name2 := range c.InUse {
+		if name == name2 {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Config) dropCreating(cr creation) {
+	for i, cr2 := range c.Creating {
+		if cr == cr2 {
 			copy(c.Creating[i:], c.Creating[i+1:])
 			c.Creating = c.Creating[:len(c.Creating)-1]
 			break
@@ -108,12 +193,78 @@ func (c *Config) dropCreating(pid int) {
 	}
 }
 
-func cmdCreate(args []string) {
+// configMigrations[i] migrates a config from version i to version
+// i+1. Migrations operate on the generic JSON form of the config so
+// they can handle fields that no longer exist in Config.
+var configMigrations = []func(cfg map[string]interface{}) error{
+	// Version 0 is the unversioned format. Fields have only been
+	// added since then and their zero values preserve the old
+	// behavior, so there's nothing to do.
+	0: func(cfg map[string]interface{}) error { return nil },
+
+	// Version 1 recorded creations as bare PIDs. Those were
+	// created on some unknown host, so leave Host empty.
+	1: func(cfg map[string]interface{}) error {
+		old, _ := cfg["Creating"].([]interface{})
+		var creating []interface{}
+		for _, pid := range old {
+			pid, ok := pid.(float64)
+			if !ok {
+				return fmt.Errorf("bad Creating entry %v", pid)
+			}
+			creating = append(creating, map[string]interface{}{"Host": "", "PID": pid})
+		}
+		cfg["Creating"] = creating
+		return nil
+	},
+}
+
+// decodeConfig decodes a pool config in any supported version,
+// migrating it to the current version.
+func decodeConfig(data []byte) (*Config, error) {
+	var raw map[string]interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	version := 0
+	if v, ok := raw["Version"]; ok {
+		fv, ok := v.(float64)
+		if !ok || fv != float64(int(fv)) || fv < 0 {
+			return nil, fmt.Errorf("bad config version %v", v)
+		}
+		version = int(fv)
+	}
+	if version > configVersion {
+		return nil, fmt.Errorf("config version %d is newer than this gopool supports (version %d); upgrade gopool", version, configVersion)
+	}
+	for ; version < configVersion; version++ {
+		if err := configMigrations[version](raw); err != nil {
+			return nil, fmt.Errorf("migrating config from version %d: %w", version, err)
+		}
+	}
+	raw["Version"] = configVersion
+
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return nil, err
+	}
 	var cfg Config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+func cmdCreate(args []string) {
+	cfg := Config{Version: configVersion}
 
 	flags := flag.NewFlagSet("create", flag.ExitOnError)
 	flags.StringVar(&cfg.Setup.Cmd, "setup", "", "run shell command `cmd` to set up new instances; $VM will be set to the buildlet name")
+	flags.StringVar(&cfg.Health, "health", "", "run shell command `cmd` to check a free buildlet before using it; $VM and $VM_TAGS will be set")
+	flags.StringVar(&cfg.Backend, "backend", defaultBackend(), "create buildlets using `service`: gomote (gRPC, via the gomote command) or coordinator (legacy)")
 	flags.IntVar(&cfg.Max, "max", 10, "create at most `n` buildlets at once")
+	flags.DurationVar(&cfg.Lease, "lease", 10*time.Minute, "reap checked-out buildlets whose lease hasn't been extended for `duration` (0 to disable)")
+	flags.StringVar(&cfg.Locking, "locking", lockingFlock, "lock the pool using `scheme`: flock, or lockfile for pools shared by several machines over NFS")
 	flags.Usage = func() {
 		fmt.Fprintf(flags.Output(), "Usage: %s create [flags] <type>\n", os.Args[0])
 		flags.PrintDefaults()
@@ -124,6 +275,12 @@ func cmdCreate(args []string) {
 		os.Exit(2)
 	}
 	cfg.Kind = flags.Arg(0)
+	if cfg.Backend != backendGomote && cfg.Backend != backendCoordinator {
+		log.Fatalf("unknown backend %q", cfg.Backend)
+	}
+	if cfg.Locking != lockingFlock && cfg.Locking != lockingLockfile {
+		log.Fatalf("unknown locking scheme %q", cfg.Locking)
+	}
 
 	err := os.MkdirAll(poolPath, 0777)
 	if err != nil {
@@ -189,30 +346,40 @@ func (fl *FileLock) Unlock() {
 	fl.f = nil
 }
 
-var coordCache *buildlet.CoordinatorClient
-var coordOnce sync.Once
-
-func getCoordinator() *buildlet.CoordinatorClient {
-	coordOnce.Do(func() {
-		coord, err := buildlet.NewCoordinatorClientFromFlags()
-		if err != nil {
-			log.Fatalf("error connecting to coordinator: %v", err)
-		}
-		coordCache = coord
-	})
-	return coordCache
-}
-
 type Pool struct {
 	path     string
-	lockFile *FileLock
+	locks    locker // Set by lock
+	lockFile heldLock
+	backend  backend // Set by lock
 }
 
 type Buildlet struct {
 	Name     string
 	path     string
-	lockFile *FileLock
-	client   *buildlet.Client
+	locks    locker // If nil, use flock
+	lockFile heldLock
+	backend  backend
+	inst     instance
+	lease    time.Duration
+}
+
+// BuildletState is the persistent per-buildlet state. It is stored
+// next to the buildlet's lock file and survives across Get and Put.
+type BuildletState struct {
+	// Tags records expensive setup states of this buildlet (for
+	// example, "has-go-tip-built") so that Get can select a
+	// buildlet that already has them.
+	Tags []string
+
+	// LeaseExpiry is the time at which a checked-out buildlet
+	// may be reaped if its lease isn't extended. It is zero if
+	// the buildlet is free or has no lease.
+	LeaseExpiry time.Time
+}
+
+// LeaseExpired returns whether st has a lease that expired before now.
+func (st BuildletState) LeaseExpired(now time.Time) bool {
+	return !st.LeaseExpiry.IsZero() && st.LeaseExpiry.Before(now)
 }
 
 // OpenPool locks the pool and loads its configuration.
@@ -223,22 +390,156 @@ func OpenPool(poolPath string) *Pool {
 }
 
 func (p *Pool) buildletByName(name string) *Buildlet {
-	return &Buildlet{name, path.Join(p.path, name), nil, nil}
+	return &Buildlet{Name: name, path: path.Join(p.path, name), locks: p.locks, backend: p.backend}
 }
 
-func (b *Buildlet) Client() *buildlet.Client {
-	if b.client == nil {
-		var err error
-		b.client, err = getCoordinator().NamedBuildlet(b.Name)
-		if err != nil {
-			log.Fatalf("error getting buildlet %s client: %s", b.Name, err)
+func (b *Buildlet) locker() locker {
+	if b.locks == nil {
+		return flockLocker{}
+	}
+	return b.locks
+}
+
+func (b *Buildlet) statePath() string {
+	return b.path + ".state"
+}
+
+// logPath returns the path of the file that records the output of
+// b's setup command.
+func (b *Buildlet) logPath() string {
+	return b.path + ".log"
+}
+
+// State loads the persistent state of b. The caller must hold either
+// the pool lock or b's lock.
+func (b *Buildlet) State() BuildletState {
+	var st BuildletState
+	data, err := ioutil.ReadFile(b.statePath())
+	if os.IsNotExist(err) {
+		return st
+	} else if err != nil {
+		log.Fatal(err)
+	}
+	if err := json.Unmarshal(data, &st); err != nil {
+		log.Fatalf("error reading buildlet %s state: %s", b.Name, err)
+	}
+	return st
+}
+
+// updateState atomically applies f to the persistent state of b.
+// Unlike State and setState, this is safe to call from a command
+// running under a buildlet, which doesn't hold b's lock itself.
+//
+// If b is no longer in use, it was reaped and destroyed, possibly
+// while its holder was stalled, so updateState drops the update
+// rather than recreate b's state.
+func (b *Buildlet) updateState(f func(st *BuildletState)) {
+	lock := b.lockState()
+	defer lock.Unlock()
+	// discardLocked drops b from InUse before it takes this lock
+	// to remove b's state, so this can't race with reaping. The
+	// config is replaced atomically, so reading it doesn't need
+	// the pool lock.
+	if !loadConfig(path.Dir(b.path)).isInUse(b.Name) {
+		log.Printf("buildlet %s was reaped; dropping state update", b.Name)
+		return
+	}
+	st := b.State()
+	f(&st)
+	b.setState(st)
+}
+
+// removeState removes the persistent state of b.
+func (b *Buildlet) removeState() {
+	lock := b.lockState()
+	os.Remove(b.statePath())
+	lock.Unlock()
+	os.Remove(b.statePath() + ".lock")
+}
+
+// lockState acquires the lock on b's persistent state.
+func (b *Buildlet) lockState() heldLock {
+	lockPath := b.statePath() + ".lock"
+	lf, err := os.OpenFile(lockPath, os.O_CREATE|os.O_RDONLY, 0666)
+	if err != nil {
+		log.Fatal(err)
+	}
+	lf.Close()
+	lock, err := b.locker().Lock(lockPath)
+	if err != nil {
+		log.Fatalf("locking buildlet %s state: %s", b.Name, err)
+	}
+	return lock
+}
+
+func (b *Buildlet) setState(st BuildletState) {
+	data, err := json.Marshal(&st)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := ioutil.WriteFile(b.statePath()+".tmp", data, 0666); err != nil {
+		log.Fatal(err)
+	}
+	if err := os.Rename(b.statePath()+".tmp", b.statePath()); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// hasTags returns whether have contains every tag in want.
+func hasTags(have, want []string) bool {
+	for _, w := range want {
+		found := false
+		for _, h := range have {
+			if h == w {
+				found = true
+				break
+			}
 		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// extendLease extends b's lease to its lease duration from now. Like
+// updateState, it doesn't need b's lock, and does nothing if b has
+// been reaped.
+func (b *Buildlet) extendLease() {
+	if b.lease == 0 {
+		return
 	}
-	return b.client
+	b.updateState(func(st *BuildletState) {
+		st.LeaseExpiry = time.Now().Add(b.lease)
+	})
+}
+
+// keepLease periodically extends b's lease until stop is closed.
+func (b *Buildlet) keepLease(stop <-chan struct{}) {
+	if b.lease == 0 {
+		return
+	}
+	ticker := time.NewTicker(b.lease / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			b.extendLease()
+		}
+	}
+}
+
+func (b *Buildlet) Instance() instance {
+	if b.inst == nil {
+		b.inst = b.backend.Instance(b.Name)
+	}
+	return b.inst
 }
 
 func (b *Buildlet) lock() {
-	lock, err := LockFile(b.path)
+	lock, err := b.locker().Lock(b.path)
 	if err != nil {
 		log.Fatalf("locking buildlet %s: %s", b.Name, err)
 	}
@@ -246,7 +547,7 @@ func (b *Buildlet) lock() {
 }
 
 func (b *Buildlet) tryLock() bool {
-	lock, err := TryLockFile(b.path)
+	lock, err := b.locker().TryLock(b.path)
 	if err != nil {
 		log.Fatalf("locking buildlet %s: %s", b.Name, err)
 	}
@@ -265,24 +566,39 @@ func (p *Pool) lock() *Config {
 		panic("pool already locked")
 	}
 
-	// Lock the pool.
-	lock, err := LockFile(path.Join(poolPath, "lock"))
+	// Lock the pool. The locking scheme is fixed when the pool is
+	// created, so it's safe to read it from the config before
+	// locking, and flush replaces the config atomically.
+	if p.locks == nil {
+		p.locks = getLocker(loadConfig(poolPath).Locking)
+	}
+	lock, err := p.locks.Lock(path.Join(poolPath, "lock"))
 	if err != nil {
 		log.Fatalf("locking pool: %s", err)
 	}
 	p.lockFile = lock
 
-	// Load config.
-	f, err := os.Open(path.Join(poolPath, "config"))
+	// Load config. If it's from an older version of gopool, this
+	// migrates it and the next flush will save it in the current
+	// format.
+	cfg := loadConfig(poolPath)
+	p.backend = getBackend(cfg.Backend)
+	return cfg
+}
+
+// loadConfig reads the config of the pool in dir. flush replaces the
+// config atomically, so this is safe without the pool lock, though
+// the result may then be stale.
+func loadConfig(dir string) *Config {
+	data, err := ioutil.ReadFile(path.Join(dir, "config"))
 	if err != nil {
 		log.Fatal(err)
 	}
-	defer f.Close()
-	var cfg Config
-	if err := json.NewDecoder(f).Decode(&cfg); err != nil {
+	cfg, err := decodeConfig(data)
+	if err != nil {
 		log.Fatalf("error reading pool config: %s", err)
 	}
-	return &cfg
+	return cfg
 }
 
 func (p *Pool) unlock() {
@@ -296,8 +612,27 @@ func (p *Pool) unlock() {
 
 // flush saves the pool state.
 func (p *Pool) flush(cfg *Config) {
-	// Write config to a temporary file.
 	configPath := path.Join(poolPath, "config")
+
+	// If the locking scheme has fencing tokens, make sure nobody
+	// has taken the pool lock from us, for example because we
+	// stalled and it looked stale.
+	if token := p.lockFile.Token(); token != 0 {
+		data, err := ioutil.ReadFile(configPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		cur, err := decodeConfig(data)
+		if err != nil {
+			log.Fatalf("error reading pool config: %s", err)
+		}
+		if cur.Fence > token {
+			log.Fatalf("lost pool lock: config was written under lock token %d, but we hold token %d", cur.Fence, token)
+		}
+		cfg.Fence = token
+	}
+
+	// Write config to a temporary file.
 	f, err := os.Create(configPath + ".tmp")
 	if err != nil {
 		log.Fatal(err)
@@ -326,26 +661,35 @@ func (p *Pool) reap() {
 		// See if this buildlet is still locked.
 		b := p.buildletByName(name)
 		if !b.tryLock() {
+			// It's still locked, but if its lease
+			// expired, whoever holds the lock is gone or
+			// stuck, so reclaim it anyway.
+			if b.State().LeaseExpired(time.Now()) {
+				log.Printf("reaping buildlet %s with expired lease", name)
+				p.logEvent(EventReap, name, "lease expired")
+				p.discardLocked(cfg, b)
+			}
 			continue
 		}
 
 		// Found an "in use" buildlet that isn't locked, which
 		// means it got abandoned.
 		log.Printf("reaping abandoned buildlet %s", name)
+		p.logEvent(EventReap, name, "abandoned")
 		p.discardLocked(cfg, b)
 	}
 
-	creating := append([]int(nil), cfg.Creating...)
-	for _, pid := range creating {
-		cpath := path.Join(p.path, fmt.Sprintf("creating-%d", pid))
-		l, err := TryLockFile(cpath)
+	creating := append([]creation(nil), cfg.Creating...)
+	for _, cr := range creating {
+		cpath := path.Join(p.path, cr.lockName())
+		l, err := p.locks.TryLock(cpath)
 		if err != nil {
 			log.Fatal(err)
 		}
 		if l == nil {
 			continue
 		}
-		cfg.dropCreating(pid)
+		cfg.dropCreating(cr)
 		p.flush(cfg)
 		l.Unlock()
 		os.Remove(cpath)
@@ -354,30 +698,68 @@ func (p *Pool) reap() {
 
 func (p *Pool) discardLocked(cfg *Config, b *Buildlet) {
 	// Destroy the buildlet.
-	client := b.Client()
-	// TODO: Check if the buildlet is still around and retry the Close?
-	client.Close()
+	//
+	// TODO: Check if the buildlet is still around and retry the
+	// Destroy?
+	if err := b.Instance().Destroy(); err != nil {
+		log.Printf("error destroying buildlet %s: %s", b.Name, err)
+	}
+	// Drop b from InUse before removing its state so that a
+	// holder that's still using b can't recreate it. See
+	// updateState.
 	cfg.dropInUse(b.Name)
-	b.unlock()
 	p.flush(cfg)
+	b.removeState()
+	os.Remove(b.logPath())
+	if b.lockFile != nil {
+		b.unlock()
+	}
 }
 
-func (p *Pool) Put(b *Buildlet) {
+// Put returns b to the free list. Any tags are added to b's
+// persistent tags.
+//
+// If b was reaped while in use, it has been destroyed, so Put just
+// releases it.
+func (p *Pool) Put(b *Buildlet, tags ...string) {
 	cfg := p.lock()
 	defer p.unlock()
+	if !cfg.isInUse(b.Name) {
+		log.Printf("buildlet %s was reaped while in use; not returning it to the pool", b.Name)
+		b.unlock()
+		return
+	}
+	b.updateState(func(st *BuildletState) {
+		for _, tag := range tags {
+			if !hasTags(st.Tags, []string{tag}) {
+				st.Tags = append(st.Tags, tag)
+			}
+		}
+		st.LeaseExpiry = time.Time{}
+	})
 	cfg.Free = append(cfg.Free, b.Name)
 	cfg.dropInUse(b.Name)
 	b.unlock()
 	p.flush(cfg)
+	detail := ""
+	if len(tags) > 0 {
+		detail = "added tags " + strings.Join(tags, ",")
+	}
+	p.logEvent(EventPut, b.Name, detail)
 }
 
-func (p *Pool) Discard(b *Buildlet) {
+// Discard destroys b, recording why in the event log.
+func (p *Pool) Discard(b *Buildlet, why string) {
 	cfg := p.lock()
 	defer p.unlock()
+	p.logEvent(EventDiscard, b.Name, why)
 	p.discardLocked(cfg, b)
 }
 
-func (p *Pool) Get() (*Buildlet, error) {
+// Get returns a buildlet from the pool, creating one if necessary. If
+// any tags are given, Get prefers a free buildlet that has all of
+// them, but will fall back to any free buildlet.
+func (p *Pool) Get(tags ...string) (*Buildlet, error) {
 	const maxCreateTries = 5
 	createTries := 0
 
@@ -399,14 +781,18 @@ func (p *Pool) Get() (*Buildlet, error) {
 			}
 
 			// Record our intent to create this buildlet.
-			pid := os.Getpid()
-			cpath := path.Join(p.path, fmt.Sprintf("creating-%d", pid))
+			host, err := os.Hostname()
+			if err != nil {
+				log.Fatal(err)
+			}
+			cr := creation{host, os.Getpid()}
+			cpath := path.Join(p.path, cr.lockName())
 			touch(cpath)
-			clock, err := LockFile(cpath)
+			clock, err := p.locks.Lock(cpath)
 			if err != nil {
 				log.Fatal(err)
 			}
-			cfg.Creating = append(cfg.Creating, pid)
+			cfg.Creating = append(cfg.Creating, cr)
 			p.flush(cfg)
 
 			// Start a new gomote.
@@ -415,41 +801,54 @@ func (p *Pool) Get() (*Buildlet, error) {
 			// buildlet because this can take a while.
 			log.Printf("creating %s buildlet", cfg.Kind)
 			p.unlock()
-			client, err := getCoordinator().CreateBuildlet(cfg.Kind)
+			name, err := p.backend.Create(cfg.Kind)
 			cfg = p.lock()
 			if err != nil {
 				log.Printf("error creating buildlet: %s", err)
+				p.logEvent(EventCreateFail, "", err.Error())
 				continue
 			}
-			name := client.RemoteName()
 			log.Printf("created buildlet %s", name)
+			p.logEvent(EventCreate, name, cfg.Kind)
 
 			// Add it to the in-use list ASAP so it gets
 			// reaped in case something goes wrong during
 			// setup. Also drop ourselves from creating.
 			cfg.InUse = append(cfg.InUse, name)
-			cfg.dropCreating(pid)
+			cfg.dropCreating(cr)
 			p.flush(cfg)
 			clock.Unlock()
 			os.Remove(cpath)
 			b := p.buildletByName(name)
 			touch(b.path)
 
-			// Set it up.
+			// Set it up. Send the output to a log file
+			// rather than our stdout, where it would get
+			// mixed up with the output of the command
+			// using the buildlet.
 			if cfg.Setup.Cmd != "" {
+				logFile, err := os.Create(b.logPath())
+				if err != nil {
+					log.Fatal(err)
+				}
 				cmd := exec.Command("/bin/sh", "-c", cfg.Setup.Cmd)
 				cmd.Dir = cfg.Setup.Dir
-				cmd.Env = append(cfg.Setup.Env, "VM="+name)
-				cmd.Stdout = os.Stdout
-				cmd.Stderr = os.Stderr
+				cmd.Env = append(cfg.Setup.Env, "VM="+name, "GOPOOL_PATH="+poolPath)
+				cmd.Stdout = logFile
+				cmd.Stderr = logFile
+				log.Printf("setting up buildlet %s (log in %s)", name, b.logPath())
 
 				p.unlock()
-				err := cmd.Run()
+				err = cmd.Run()
+				logFile.Close()
 				cfg = p.lock()
 
 				if err != nil {
-					log.Printf("setup command failed: %s", err)
-					client.Close()
+					// Leave the log behind for
+					// debugging.
+					log.Printf("setup command failed: %s; see %s or run %s logs %s", err, b.logPath(), os.Args[0], name)
+					p.logEvent(EventSetupFail, name, err.Error())
+					b.Instance().Destroy()
 					cfg.dropInUse(name)
 					p.flush(cfg)
 					continue
@@ -462,30 +861,63 @@ func (p *Pool) Get() (*Buildlet, error) {
 			p.flush(cfg)
 		}
 
-		// Get a buildlet from the free list.
-		name := cfg.Free[len(cfg.Free)-1]
-		cfg.Free = cfg.Free[:len(cfg.Free)-1]
+		// Get a buildlet from the free list, preferring the
+		// most recently used one with the requested tags.
+		idx := len(cfg.Free) - 1
+		if len(tags) > 0 {
+			for i := len(cfg.Free) - 1; i >= 0; i-- {
+				if hasTags(p.buildletByName(cfg.Free[i]).State().Tags, tags) {
+					idx = i
+					break
+				}
+			}
+		}
+		name := cfg.Free[idx]
+		cfg.Free = append(cfg.Free[:idx], cfg.Free[idx+1:]...)
 
 		b := p.buildletByName(name)
 		b.lock()
 
-		// Get buildlet status. (NamedBuildlet doesn't even validate.)
-		client := b.Client()
-		ctx := context.TODO()
-		_, err := client.Status(ctx)
+		// Check that the buildlet is still alive.
+		err := b.Instance().Check(context.TODO())
+		if err == nil && cfg.Health != "" {
+			// Mark it in use so nobody else takes it
+			// while we drop the lock to check it.
+			cfg.InUse = append(cfg.InUse, name)
+			p.flush(cfg)
+			cmd := exec.Command("/bin/sh", "-c", cfg.Health)
+			cmd.Dir = cfg.Setup.Dir
+			cmd.Env = append(cfg.Setup.Env, "VM="+name, "VM_TAGS="+strings.Join(b.State().Tags, ","), "GOPOOL_PATH="+poolPath)
+			cmd.Stdout = os.Stderr
+			cmd.Stderr = os.Stderr
+
+			p.unlock()
+			err = cmd.Run()
+			cfg = p.lock()
+
+			if err != nil {
+				err = fmt.Errorf("health check failed: %w", err)
+			} else {
+				cfg.dropInUse(name)
+			}
+		}
 		if err == nil {
-			// Ping the buildlet to really check it.
-			err = client.ListDir(ctx, ".", buildlet.ListDirOpts{}, func(buildlet.DirEntry) {})
-			if err == nil {
-				// Found a good one!
-				cfg.InUse = append(cfg.InUse, name)
-				p.flush(cfg)
-				return b, nil
+			// Found a good one!
+			cfg.InUse = append(cfg.InUse, name)
+			p.flush(cfg)
+			b.lease = cfg.Lease
+			b.extendLease()
+			detail := ""
+			if t := b.State().Tags; len(t) > 0 {
+				detail = "tags " + strings.Join(t, ",")
 			}
+			p.logEvent(EventGet, name, detail)
+			return b, nil
 		}
 
 		// Destroy the broken buildlet.
 		log.Printf("buildlet %s broken: %s", name, err)
+		p.logEvent(EventDiscard, name, err.Error())
 		p.discardLocked(cfg, b)
 	}
 }
@@ -499,8 +931,9 @@ func (p *Pool) Destroy() {
 	for _, name := range all {
 		log.Printf("destroying %s", name)
 		b := p.buildletByName(name)
-		client := b.Client()
-		client.Close()
+		if err := b.Instance().Destroy(); err != nil {
+			log.Printf("error destroying %s: %s", name, err)
+		}
 	}
 
 	// Destroy the pool.
@@ -526,16 +959,31 @@ func cmdDestroy(args []string) {
 }
 
 func cmdRun(args []string) {
+	var getTags, putTags string
 	flags := flag.NewFlagSet("run", flag.ExitOnError)
+	flags.StringVar(&getTags, "tags", "", "prefer a gomote with all of the comma-separated `tags`")
+	flags.StringVar(&putTags, "put-tags", "", "on success, add comma-separated `tags` to the gomote")
 	flags.Usage = func() {
-		fmt.Fprintf(flags.Output(), `Usage: %s run command...
+		fmt.Fprintf(flags.Output(), `Usage: %s run [flags] command...
 
 Check out a gomote from the pool, creating one if necessary, and
-invoke command as a shell command with $VM set to the gomote's name.
+invoke command as a shell command with $VM set to the gomote's name
+and $VM_TAGS set to the gomote's comma-separated tags.
 
 If the command exits successfully, the gomote will be checked back in
-to the pool. Otherwise, it will be destroyed.
+to the pool. Otherwise, it will be destroyed. While command is
+running, run periodically extends the gomote's lease so it isn't
+reaped.
+
+Tags record expensive setup states of a gomote, such as a built
+toolchain. With -tags, run prefers a gomote that already has those
+tags, but may still return one without them, so command should check
+$VM_TAGS to decide what setup it needs to do. $GOPOOL_PATH is set to
+the pool path, so command can use "%[1]s push" to push files to the
+gomote only if they've changed.
+
 `, os.Args[0])
+		flags.PrintDefaults()
 	}
 	flags.Parse(args)
 	if flags.NArg() == 0 {
@@ -546,29 +994,78 @@ to the pool. Otherwise, it will be destroyed.
 
 	// Get a buildlet.
 	p := OpenPool(poolPath)
-	buildlet, err := p.Get()
+	buildlet, err := p.Get(splitTags(getTags)...)
 	if err != nil {
 		log.Fatal(err)
 	}
 
 	// Run command.
 	cmd := exec.Command("/bin/sh", "-c", arg)
-	cmd.Env = append(os.Environ(), "VM="+buildlet.Name)
+	cmd.Env = append(os.Environ(), "VM="+buildlet.Name, "VM_TAGS="+strings.Join(buildlet.State().Tags, ","), "GOPOOL_PATH="+poolPath)
 	cmd.Stdin = os.Stdin
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
+	stopLease := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		buildlet.keepLease(stopLease)
+	}()
 	err = cmd.Run()
+	close(stopLease)
+	wg.Wait()
 	if err == nil {
 		// Check the buildlet back in.
-		p.Put(buildlet)
+		p.Put(buildlet, splitTags(putTags)...)
 	} else {
 		// Destroy the buildlet.
 		fmt.Fprintf(os.Stderr, "%s (destroying buildlet)\n", err)
-		p.Discard(buildlet)
+		p.Discard(buildlet, fmt.Sprintf("command failed: %s", err))
 		os.Exit(1)
 	}
 }
 
+func cmdLogs(args []string) {
+	flags := flag.NewFlagSet("logs", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), `Usage: %s logs name
+
+Print the output of the setup command for buildlet name. The log
+of a buildlet whose setup failed is kept until the pool is destroyed.
+`, os.Args[0])
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	name := flags.Arg(0)
+	if name == "" || strings.Contains(name, "/") {
+		log.Fatalf("bad buildlet name %q", name)
+	}
+
+	b := (&Pool{path: poolPath}).buildletByName(name)
+	f, err := os.Open(b.logPath())
+	if os.IsNotExist(err) {
+		log.Fatalf("no setup log for buildlet %s", name)
+	} else if err != nil {
+		log.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := io.Copy(os.Stdout, f); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func splitTags(tags string) []string {
+	if tags == "" {
+		return nil
+	}
+	return strings.Split(tags, ",")
+}
+
 func touch(path string) {
 	if err := ioutil.WriteFile(path, nil, 0666); err != nil {
 		log.Fatal(err)
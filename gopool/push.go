@@ -0,0 +1,227 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// pushTagPrefix is the prefix of the tags that record the content
+// hash of a directory pushed to a buildlet. The full tag is
+// "pushed:DIR=HASH".
+const pushTagPrefix = "pushed:"
+
+func cmdPush(args []string) {
+	flags := flag.NewFlagSet("push", flag.ExitOnError)
+	vm := flags.String("vm", os.Getenv("VM"), "push to buildlet `name`")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), `Usage: %s push [flags] local-dir [remote-dir]
+
+Push local-dir, such as a built Go toolchain, to remote-dir in the
+work directory of a buildlet checked out from the pool, replacing
+anything already there. remote-dir defaults to the base name of
+local-dir.
+
+push records a hash of local-dir's contents in the buildlet's tags
+as pushed:remote-dir=hash and skips the push if the buildlet already
+has the same contents. This makes it cheap to push unconditionally
+from a setup or run command, where $VM names the buildlet and
+$GOPOOL_PATH names the pool.
+
+`, os.Args[0])
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() < 1 || flags.NArg() > 2 || *vm == "" {
+		flags.Usage()
+		os.Exit(2)
+	}
+	local := flags.Arg(0)
+	remote := filepath.Base(local)
+	if flags.NArg() == 2 {
+		remote = flags.Arg(1)
+	}
+
+	// The buildlet is checked out by whoever is running us, so
+	// don't use OpenPool, which may reap it.
+	p := &Pool{path: poolPath}
+	pushed, err := p.push(*vm, local, remote)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if !pushed {
+		log.Printf("%s is already up to date on %s", remote, *vm)
+	}
+}
+
+// push pushes local to remote on the buildlet name, which must be
+// checked out from p. Free buildlets aren't ours to modify: Get in
+// another process may hand one out mid-push, and updateState drops
+// their tags, so they would be pushed again every time.
+func (p *Pool) push(name, local, remote string) (bool, error) {
+	cfg := loadConfig(p.path)
+	if !cfg.isInUse(name) {
+		return false, fmt.Errorf("buildlet %s is not checked out from the pool at %s", name, p.path)
+	}
+	if p.locks == nil {
+		p.locks = getLocker(cfg.Locking)
+	}
+	if p.backend == nil {
+		p.backend = getBackend(cfg.Backend)
+	}
+	return p.buildletByName(name).Push(local, remote)
+}
+
+// Push pushes the contents of local directory local to directory
+// remote on b unless b's tags say it already has them. It reports
+// whether it pushed.
+func (b *Buildlet) Push(local, remote string) (bool, error) {
+	hash, err := hashDir(local)
+	if err != nil {
+		return false, err
+	}
+	prefix := pushTagPrefix + remote + "="
+	tag := prefix + hash
+	if hasTags(b.State().Tags, []string{tag}) {
+		return false, nil
+	}
+
+	tgz, err := ioutil.TempFile("", "gopool-push-*.tar.gz")
+	if err != nil {
+		return false, err
+	}
+	defer os.Remove(tgz.Name())
+	err = writeTarGz(tgz, local)
+	if err2 := tgz.Close(); err == nil {
+		err = err2
+	}
+	if err != nil {
+		return false, fmt.Errorf("archiving %s: %w", local, err)
+	}
+
+	// Drop the old tag first in case the push fails partway.
+	b.updateState(func(st *BuildletState) {
+		st.Tags = dropTagPrefix(st.Tags, prefix)
+	})
+	log.Printf("pushing %s to %s:%s", local, b.Name, remote)
+	if err := b.Instance().PutDir(context.Background(), tgz.Name(), remote); err != nil {
+		return false, fmt.Errorf("pushing %s to %s: %w", local, b.Name, err)
+	}
+	b.updateState(func(st *BuildletState) {
+		st.Tags = append(dropTagPrefix(st.Tags, prefix), tag)
+	})
+	return true, nil
+}
+
+// dropTagPrefix returns tags without the tags that start with prefix.
+func dropTagPrefix(tags []string, prefix string) []string {
+	var out []string
+	for _, tag := range tags {
+		if !strings.HasPrefix(tag, prefix) {
+			out = append(out, tag)
+		}
+	}
+	return out
+}
+
+// hashDir returns a hash of the names, modes, and contents of the
+// files under dir.
+func hashDir(dir string) (string, error) {
+	h := sha256.New()
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(h, "%q %v\n", filepath.ToSlash(rel), info.Mode())
+		switch {
+		case info.Mode()&os.ModeSymlink != 0:
+			target, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(h, "%q\n", target)
+		case info.Mode().IsRegular():
+			f, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			fmt.Fprintf(h, "%d\n", info.Size())
+			if _, err := io.Copy(h, f); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", h.Sum(nil))[:16], nil
+}
+
+// writeTarGz writes the files under dir to w as a gzipped tar file
+// with paths relative to dir.
+func writeTarGz(w io.Writer, dir string) error {
+	zw := gzip.NewWriter(w)
+	tw := tar.NewWriter(zw)
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil || rel == "." {
+			return err
+		}
+		var link string
+		if info.Mode()&os.ModeSymlink != 0 {
+			if link, err = os.Readlink(path); err != nil {
+				return err
+			}
+		}
+		hdr, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		hdr.Name = filepath.ToSlash(rel)
+		if info.IsDir() {
+			hdr.Name += "/"
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(tw, f)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return zw.Close()
+}
@@ -5,7 +5,9 @@
 package loganal
 
 import (
+	"math"
 	"regexp"
+	"sort"
 	"strings"
 )
 
@@ -56,66 +58,379 @@ func (f *Failure) canonicalFields() []string {
 	return fields
 }
 
+// ClassifyOptions controls how ClassifyWith groups failures.
+type ClassifyOptions struct {
+	// ByStack groups failures that have a traceback by their
+	// package, test, and the top StackDepth frames of their
+	// Stack, regardless of their messages. This separates
+	// distinct panics that happen to have the same message and
+	// location, and keeps together a crash whose message varies.
+	// Failures without a traceback are grouped by message.
+	ByStack bool
+
+	// StackDepth is the number of frames compared by ByStack. If
+	// 0, it defaults to 5.
+	StackDepth int
+
+	// MaxDistance is the largest distance between the messages of
+	// two failure classes with the same package, test, and
+	// location for them to be merged. Distances range from 0 for
+	// messages with the same tokens to 1 for messages with no
+	// tokens in common. If 0, only failures with the same
+	// canonical message are grouped.
+	MaxDistance float64
+
+	// Distance is the metric used for MaxDistance.
+	Distance Distance
+
+	// IDF weights message tokens by their inverse document
+	// frequency across the classified failures when computing
+	// distances, so tokens that appear in many failures, like
+	// "error", count for less than distinctive ones.
+	IDF bool
+}
+
+// A Distance is a metric for the distance between two failure
+// messages, computed over their tokens.
+type Distance int
+
+const (
+	// DistanceJaccard is the weighted Jaccard distance between
+	// the messages' multisets of tokens. It ignores token order.
+	DistanceJaccard Distance = iota
+
+	// DistanceLCS is one minus the weight of the longest common
+	// subsequence of the messages' tokens, relative to their
+	// average weight. It's sensitive to token order.
+	DistanceLCS
+)
+
 // Classify groups a set of failures in to canonicalized failure
 // classes. The returned map maps from each failure class to the
 // indexes of the input failures in that class. Each input failure
 // will be in exactly one failure class.
 func Classify(fs []*Failure) map[Failure][]int {
+	return ClassifyWith(fs, ClassifyOptions{})
+}
+
+// ClassifyWith is like Classify, but controlled by opts.
+func ClassifyWith(fs []*Failure, opts ClassifyOptions) map[Failure][]int {
+	depth := opts.StackDepth
+	if depth == 0 {
+		depth = 5
+	}
+
 	// Map maximally canonicalized failures to input indexes.
 	canon := map[Failure][]int{}
+	var keys []Failure // In order of first appearance
 	for i, f := range fs {
 		// TODO: Match up nearby line numbers?
 		key := Failure{
-			Package:  f.Package,
-			Test:     f.Test,
-			Message:  f.canonicalMessage(),
-			Function: f.Function,
-			File:     f.File,
+			Package: f.Package,
+			Test:    f.Test,
+		}
+		if opts.ByStack && f.Stack != "" {
+			key.Stack = topFrames(f.Stack, depth)
+		} else {
+			key.Message = f.canonicalMessage()
+			key.Function = f.Function
+			key.File = f.File
 		}
 
+		if canon[key] == nil {
+			keys = append(keys, key)
+		}
 		canon[key] = append(canon[key], i)
 	}
 
+	if opts.MaxDistance > 0 {
+		keys = mergeSimilar(fs, canon, keys, opts)
+	}
+
 	// De-canonicalize fields that all of the failures in a class
 	// have a common.
-	out := make(map[Failure][]int, len(canon))
-	for key, class := range canon {
-		if len(class) == 1 {
-			out[key] = class
-			continue
-		}
+	out := make(map[Failure][]int, len(keys))
+	for _, key := range keys {
+		class := canon[key]
+		key = classKey(fs, class, key.Stack)
+		// Merged classes may de-canonicalize to the same key.
+		out[key] = append(out[key], class...)
+	}
+	return out
+}
 
-		// Does the message need de-canonicalization?
-		if key.Message != fs[class[0]].Message {
-			fields := fs[class[0]].canonicalFields()
-			for _, fi := range class[1:] {
-				nfields := fs[fi].canonicalFields()
-				for i, field := range fields {
-					if field != nfields[i] {
-						fields[i] = "…"
-					}
+// classKey returns the failure class of the failures in class, which
+// are in increasing order.
+func classKey(fs []*Failure, class []int, stack string) Failure {
+	f0 := fs[class[0]]
+	key := Failure{
+		Package:  f0.Package,
+		Test:     f0.Test,
+		Message:  f0.canonicalMessage(),
+		Function: f0.Function,
+		File:     f0.File,
+		Stack:    stack,
+	}
+	if len(class) == 1 {
+		return key
+	}
+
+	// Does the message need de-canonicalization?
+	sameCanon := true
+	for _, fi := range class[1:] {
+		if fs[fi].canonicalMessage() != key.Message {
+			sameCanon = false
+			break
+		}
+	}
+	if !sameCanon {
+		// The class was merged from failures with different
+		// messages. Keep the tokens they have in common.
+		msg := messageTokens(f0.Message)
+		for _, fi := range class[1:] {
+			msg = mergeTokens(msg, messageTokens(fs[fi].Message))
+		}
+		key.Message = strings.Join(msg, "")
+	} else if key.Message != f0.Message {
+		fields := f0.canonicalFields()
+		for _, fi := range class[1:] {
+			nfields := fs[fi].canonicalFields()
+			for i, field := range fields {
+				if field != nfields[i] {
+					fields[i] = "…"
 				}
 			}
-			key.Message = strings.Join(fields, "")
 		}
+		key.Message = strings.Join(fields, "")
+	}
 
-		// De-canonicalize Line, OS, and Arch.
-		line, os, arch := fs[class[0]].Line, fs[class[0]].OS, fs[class[0]].Arch
-		for _, fi := range class[1:] {
-			if fs[fi].Line != line {
-				line = 0
+	// De-canonicalize Line, OS, and Arch, and clear the location
+	// if it varies, which it can when classifying by stack.
+	line, os, arch := f0.Line, f0.OS, f0.Arch
+	for _, fi := range class[1:] {
+		f := fs[fi]
+		if f.Function != key.Function || f.File != key.File {
+			key.Function, key.File = "", ""
+		}
+		if f.Line != line {
+			line = 0
+		}
+		if f.OS != os {
+			os = ""
+		}
+		if f.Arch != arch {
+			arch = ""
+		}
+	}
+	key.Line, key.OS, key.Arch = line, os, arch
+	return key
+}
+
+// topFrames returns the first n frames of a normalized stack.
+func topFrames(stack string, n int) string {
+	frames := strings.SplitN(stack, "\n", n+1)
+	if len(frames) > n {
+		frames = frames[:n]
+	}
+	return strings.Join(frames, "\n")
+}
+
+var (
+	// wordToken matches the tokens of a message that are
+	// compared by distance metrics.
+	wordToken = regexp.MustCompile(`[\pL\pN_]+|…`)
+
+	// anyToken splits a message into words and the text
+	// between them.
+	anyToken = regexp.MustCompile(`[\pL\pN_]+|…|[^\pL\pN_…]+`)
+)
+
+// messageTokens splits msg into tokens that concatenate to msg.
+func messageTokens(msg string) []string {
+	return anyToken.FindAllString(msg, -1)
+}
+
+// mergeTokens returns the longest common subsequence of a and b, with
+// "…" in place of the tokens that are only in one of them.
+func mergeTokens(a, b []string) []string {
+	lcs := lcsTable(a, b, func(string) float64 { return 1 })
+	var out []string
+	gap := false
+	for i, j := 0, 0; i < len(a) || j < len(b); {
+		if i < len(a) && j < len(b) && a[i] == b[j] {
+			if gap {
+				out = append(out, "…")
+				gap = false
 			}
-			if fs[fi].OS != os {
-				os = ""
+			out = append(out, a[i])
+			i, j = i+1, j+1
+		} else if j == len(b) || (i < len(a) && lcs[i+1][j] >= lcs[i][j+1]) {
+			gap = true
+			i++
+		} else {
+			gap = true
+			j++
+		}
+	}
+	if gap {
+		out = append(out, "…")
+	}
+	return out
+}
+
+// lcsTable returns a table where t[i][j] is the weight of the longest
+// common subsequence of a[i:] and b[j:].
+func lcsTable(a, b []string, weight func(string) float64) [][]float64 {
+	t := make([][]float64, len(a)+1)
+	for i := range t {
+		t[i] = make([]float64, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				t[i][j] = t[i+1][j+1] + weight(a[i])
+			} else if t[i+1][j] >= t[i][j+1] {
+				t[i][j] = t[i+1][j]
+			} else {
+				t[i][j] = t[i][j+1]
 			}
-			if fs[fi].Arch != arch {
-				arch = ""
+		}
+	}
+	return t
+}
+
+// mergeSimilar merges failure classes with the same package, test,
+// and location whose messages are within opts.MaxDistance of each
+// other. It updates canon and returns the remaining keys.
+func mergeSimilar(fs []*Failure, canon map[Failure][]int, keys []Failure, opts ClassifyOptions) []Failure {
+	// Tokenize the message of each class.
+	tokens := make([][]string, len(keys))
+	for i, key := range keys {
+		tokens[i] = wordToken.FindAllString(key.Message, -1)
+	}
+
+	// Weight tokens.
+	weight := func(string) float64 { return 1 }
+	if opts.IDF {
+		df := map[string]int{}
+		for _, f := range fs {
+			seen := map[string]bool{}
+			for _, tok := range wordToken.FindAllString(f.canonicalMessage(), -1) {
+				if !seen[tok] {
+					seen[tok] = true
+					df[tok]++
+				}
 			}
 		}
-		key.Line, key.OS, key.Arch = line, os, arch
+		n := float64(len(fs))
+		weight = func(tok string) float64 {
+			return math.Log(1 + n/float64(df[tok]))
+		}
+	}
+	dist := func(a, b []string) float64 {
+		if opts.Distance == DistanceLCS {
+			return lcsDistance(a, b, weight)
+		}
+		return jaccardDistance(a, b, weight)
+	}
 
-		out[key] = class
+	// Merge classes using single linkage. Classes grouped by
+	// stack are left alone.
+	type location struct{ pkg, test, fn, file string }
+	buckets := map[location][]int{}
+	for i, key := range keys {
+		if key.Stack != "" {
+			continue
+		}
+		loc := location{key.Package, key.Test, key.Function, key.File}
+		buckets[loc] = append(buckets[loc], i)
+	}
+	parent := make([]int, len(keys))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(i int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+	for _, bucket := range buckets {
+		for x, i := range bucket {
+			for _, j := range bucket[x+1:] {
+				ri, rj := find(i), find(j)
+				if ri == rj || dist(tokens[i], tokens[j]) > opts.MaxDistance {
+					continue
+				}
+				if rj < ri {
+					ri, rj = rj, ri
+				}
+				parent[rj] = ri
+			}
+		}
 	}
 
+	// Combine merged classes into the first class of each set.
+	var out []Failure
+	for i, key := range keys {
+		r := find(i)
+		if r == i {
+			out = append(out, key)
+			continue
+		}
+		rkey := keys[r]
+		canon[rkey] = append(canon[rkey], canon[key]...)
+		delete(canon, key)
+	}
+	for _, key := range out {
+		sort.Ints(canon[key])
+	}
 	return out
 }
+
+// jaccardDistance returns the weighted Jaccard distance between the
+// multisets of tokens a and b.
+func jaccardDistance(a, b []string, weight func(string) float64) float64 {
+	counts := map[string][2]int{}
+	for _, tok := range a {
+		c := counts[tok]
+		c[0]++
+		counts[tok] = c
+	}
+	for _, tok := range b {
+		c := counts[tok]
+		c[1]++
+		counts[tok] = c
+	}
+	var inter, union float64
+	for tok, c := range counts {
+		lo, hi := c[0], c[1]
+		if lo > hi {
+			lo, hi = hi, lo
+		}
+		w := weight(tok)
+		inter += float64(lo) * w
+		union += float64(hi) * w
+	}
+	if union == 0 {
+		return 0
+	}
+	return 1 - inter/union
+}
+
+// lcsDistance returns the distance between token sequences a and b
+// based on the weight of their longest common subsequence.
+func lcsDistance(a, b []string, weight func(string) float64) float64 {
+	var total float64
+	for _, tok := range a {
+		total += weight(tok)
+	}
+	for _, tok := range b {
+		total += weight(tok)
+	}
+	if total == 0 {
+		return 0
+	}
+	return 1 - 2*lcsTable(a, b, weight)[0][0]/total
+}
@@ -0,0 +1,166 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package loganal
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestMergeTokens(t *testing.T) {
+	for _, test := range []struct {
+		a, b, want string
+	}{
+		{"", "", ""},
+		{"same message", "same message", "same message"},
+		{"read tcp: connection reset", "read udp: connection reset", "read …: connection reset"},
+		{"timeout after x", "timeout", "timeout…"},
+		{"unexpected EOF", "EOF", "…EOF"},
+		{"want a got b", "want c got d", "want … got …"},
+		{"abc", "xyz", "…"},
+	} {
+		got := strings.Join(mergeTokens(messageTokens(test.a), messageTokens(test.b)), "")
+		if got != test.want {
+			t.Errorf("mergeTokens(%q, %q) = %q, want %q", test.a, test.b, got, test.want)
+		}
+	}
+}
+
+func TestDistance(t *testing.T) {
+	one := func(string) float64 { return 1 }
+	for _, test := range []struct {
+		a, b         string
+		jaccard, lcs float64
+	}{
+		{"", "", 0, 0},
+		{"a b c", "a b c", 0, 0},
+		{"a b c", "x y z", 1, 1},
+		// Jaccard ignores order, LCS doesn't.
+		{"a b c d", "d c b a", 0, 0.75},
+		// One token different out of four.
+		{"a b c d", "a b c x", 1 - 3.0/5, 1 - 6.0/8},
+		// Repeated tokens count as a multiset.
+		{"a a b", "a b", 1 - 2.0/3, 1 - 4.0/5},
+	} {
+		a, b := wordToken.FindAllString(test.a, -1), wordToken.FindAllString(test.b, -1)
+		if got := jaccardDistance(a, b, one); math.Abs(got-test.jaccard) > 1e-9 {
+			t.Errorf("jaccardDistance(%q, %q) = %v, want %v", test.a, test.b, got, test.jaccard)
+		}
+		if got := lcsDistance(a, b, one); math.Abs(got-test.lcs) > 1e-9 {
+			t.Errorf("lcsDistance(%q, %q) = %v, want %v", test.a, test.b, got, test.lcs)
+		}
+	}
+}
+
+// classes returns a sorted, printable form of a classification.
+func classes(m map[Failure][]int) []string {
+	var out []string
+	for f, idxs := range m {
+		s := fmt.Sprintf("%s %s %q %v", f.Package, f.Test, f.Message, idxs)
+		if f.Stack != "" {
+			s += " stack=" + strings.ReplaceAll(f.Stack, "\n", ",")
+		}
+		out = append(out, s)
+	}
+	sort.Strings(out)
+	return out
+}
+
+func TestClassifyWith(t *testing.T) {
+	dial := []*Failure{
+		{Package: "net/http", Test: "TestServer", File: "serve_test.go", Line: 10, Message: "dial tcp 127.0.0.1:41234: connection refused"},
+		{Package: "net/http", Test: "TestServer", File: "serve_test.go", Line: 10, Message: "dial tcp 127.0.0.1:39876: connection refused"},
+		{Package: "net/http", Test: "TestServer", File: "serve_test.go", Line: 10, Message: "dial tcp [::1]:41234: connection reset by peer"},
+		{Package: "net/http", Test: "TestServer", File: "client_test.go", Line: 20, Message: "unexpected status"},
+	}
+	panics := []*Failure{
+		{Package: "os", Test: "TestRead", Function: "os.TestRead", File: "os_test.go", Message: "runtime error: index out of range [3] with length 3",
+			Stack: "os.(*File).read\nos.(*File).Read\nos.TestRead"},
+		{Package: "os", Test: "TestRead", Function: "os.TestRead", File: "os_test.go", Message: "runtime error: index out of range [5] with length 2",
+			Stack: "os.(*File).read\nos.(*File).Read\nos.TestRead"},
+		{Package: "os", Test: "TestRead", Function: "os.TestRead", File: "os_test.go", Message: "runtime error: index out of range [3] with length 3",
+			Stack: "os.readdir\nos.TestRead"},
+		{Package: "os", Test: "TestRead", Function: "os.TestRead", File: "os_test.go", Message: "runtime error: invalid memory address or nil pointer dereference"},
+	}
+
+	for _, test := range []struct {
+		name string
+		fs   []*Failure
+		opts ClassifyOptions
+		want []string
+	}{
+		{"exact", dial, ClassifyOptions{}, []string{
+			`net/http TestServer "dial tcp 127.0.0.1:…: connection refused" [0 1]`,
+			`net/http TestServer "dial tcp [::…]:…: connection reset by peer" [2]`,
+			`net/http TestServer "unexpected status" [3]`,
+		}},
+		// The canonical refused and reset messages have 5 of 12
+		// tokens in common, for a distance of 7/12.
+		{"below threshold", dial, ClassifyOptions{MaxDistance: 0.58}, []string{
+			`net/http TestServer "dial tcp 127.0.0.1:…: connection refused" [0 1]`,
+			`net/http TestServer "dial tcp [::…]:…: connection reset by peer" [2]`,
+			`net/http TestServer "unexpected status" [3]`,
+		}},
+		{"at threshold", dial, ClassifyOptions{MaxDistance: 7.0 / 12}, []string{
+			`net/http TestServer "dial tcp…1…: connection …" [0 1 2]`,
+			`net/http TestServer "unexpected status" [3]`,
+		}},
+		// Failures in different files are never merged.
+		{"other location", dial, ClassifyOptions{MaxDistance: 1}, []string{
+			`net/http TestServer "dial tcp…1…: connection …" [0 1 2]`,
+			`net/http TestServer "unexpected status" [3]`,
+		}},
+		{"by message", panics, ClassifyOptions{}, []string{
+			`os TestRead "runtime error: index out of range […] with length …" [0 1 2]`,
+			`os TestRead "runtime error: invalid memory address or nil pointer dereference" [3]`,
+		}},
+		{"by stack", panics, ClassifyOptions{ByStack: true}, []string{
+			`os TestRead "runtime error: index out of range […] with length …" [0 1] stack=os.(*File).read,os.(*File).Read,os.TestRead`,
+			`os TestRead "runtime error: index out of range […] with length …" [2] stack=os.readdir,os.TestRead`,
+			`os TestRead "runtime error: invalid memory address or nil pointer dereference" [3]`,
+		}},
+		{"stack depth", panics, ClassifyOptions{ByStack: true, StackDepth: 1}, []string{
+			`os TestRead "runtime error: index out of range […] with length …" [0 1] stack=os.(*File).read`,
+			`os TestRead "runtime error: index out of range […] with length …" [2] stack=os.readdir`,
+			`os TestRead "runtime error: invalid memory address or nil pointer dereference" [3]`,
+		}},
+	} {
+		got := classes(ClassifyWith(test.fs, test.opts))
+		if strings.Join(got, "\n") != strings.Join(test.want, "\n") {
+			t.Errorf("%s: got\n\t%s\nwant\n\t%s", test.name, strings.Join(got, "\n\t"), strings.Join(test.want, "\n\t"))
+		}
+	}
+}
+
+func TestClassifyIDF(t *testing.T) {
+	// Every message shares "error reading config", so without IDF
+	// weighting these all look alike. With it, the distinctive
+	// tokens dominate.
+	var fs []*Failure
+	for _, msg := range []string{
+		"error reading config: permission denied",
+		"error reading config: permission denied for user",
+		"error reading config: unexpected EOF",
+		"error reading config: unexpected EOF in header",
+		"error reading config: file not found",
+	} {
+		fs = append(fs, &Failure{Package: "cmd/go", Test: "TestConfig", Message: msg})
+	}
+	for _, test := range []struct {
+		idf  bool
+		want int
+	}{
+		{false, 1},
+		{true, 3},
+	} {
+		got := ClassifyWith(fs, ClassifyOptions{MaxDistance: 0.65, IDF: test.idf})
+		if len(got) != test.want {
+			t.Errorf("IDF=%v: got %d classes, want %d:\n\t%s", test.idf, len(got), test.want, strings.Join(classes(got), "\n\t"))
+		}
+	}
+}
@@ -46,6 +46,12 @@ type Failure struct {
 
 	// OS and Arch are the GOOS and GOARCH of this failure.
 	OS, Arch string
+
+	// Stack is the normalized traceback of the failing goroutine,
+	// if known: the fully qualified names of its functions, one
+	// per line, starting with the function that panicked or threw.
+	// See normalizeStack.
+	Stack string
 }
 
 func (f Failure) String() string {
@@ -255,6 +261,7 @@ func Extract(m string, os, arch string) ([]*Failure, error) {
 			} else if sPanic != nil {
 				f.Function, f.File, f.Line = panicWhere(s[2])
 				f.Message = sPanic[1]
+				f.Stack = normalizeStack(s[2])
 			}
 
 			fs = append(fs, f)
@@ -310,6 +317,7 @@ func Extract(m string, os, arch string) ([]*Failure, error) {
 				Function:    fn,
 				File:        file,
 				Line:        line,
+				Stack:       normalizeStack(traceback),
 			})
 
 		case consume(apiCheckerFailed):
@@ -499,6 +507,10 @@ var (
 	testFromTracebackRe = regexp.MustCompile(`\.(Test[^(\n]+)\(.*\n.*_test\.go`)
 
 	panicWhereRe = regexp.MustCompile(`(?m:^)` + tbEntry)
+
+	// stackHeader matches the line starting a goroutine's
+	// traceback or the system stack.
+	stackHeader = regexp.MustCompile(`(?m)^(?:goroutine [0-9]+.*:|runtime stack:)$`)
 )
 
 // testFromTraceback attempts to return the test name from a
@@ -526,3 +538,48 @@ func panicWhere(tb string) (fn string, file string, line int) {
 	}
 	return "", "", 0
 }
+
+// normalizeStack returns the function names of the first goroutine in
+// traceback tb, one per line. It drops the frames of the panic and
+// throw machinery and everything they called, the testing framework
+// frames at the bottom, and the type arguments of generic functions,
+// since they vary between otherwise identical failures.
+func normalizeStack(tb string) string {
+	// Consider only the first goroutine (or system stack).
+	if loc := stackHeader.FindStringIndex(tb); loc != nil {
+		tb = tb[loc[0]:]
+	}
+	if i := strings.Index(tb, "\n\n"); i >= 0 {
+		tb = tb[:i+1]
+	}
+	var fns []string
+	m := matcher{str: tb}
+	for m.consume(panicWhereRe) {
+		fn := m.groups[1]
+		if panicFrame(fn) {
+			// Anything above this was called by the
+			// panic, such as a deferred call that
+			// re-panicked.
+			fns = fns[:0]
+			continue
+		}
+		if fn == "testing.tRunner" || fn == "runtime.goexit" {
+			break
+		}
+		if i := strings.Index(fn, "["); i >= 0 {
+			if j := strings.LastIndex(fn, "]"); j > i {
+				fn = fn[:i] + fn[j+1:]
+			}
+		}
+		fns = append(fns, fn)
+	}
+	return strings.Join(fns, "\n")
+}
+
+// panicFrame returns whether fn is part of the runtime's panic or
+// throw machinery.
+func panicFrame(fn string) bool {
+	return fn == "panic" || strings.HasPrefix(fn, "runtime.panic") || strings.HasPrefix(fn, "runtime.gopanic") ||
+		strings.HasPrefix(fn, "runtime.throw") || strings.HasPrefix(fn, "runtime.fatal") ||
+		fn == "runtime.sigpanic" || strings.HasPrefix(fn, "runtime.goPanic")
+}
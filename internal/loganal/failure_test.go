@@ -0,0 +1,70 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package loganal
+
+import "testing"
+
+func TestNormalizeStack(t *testing.T) {
+	for _, test := range []struct {
+		name, tb, want string
+	}{
+		{"empty", "", ""},
+		{"test panic", `panic: boom [recovered]
+	panic: boom
+
+goroutine 7 [running]:
+testing.tRunner.func1.2({0x5b2f00, 0x6a1b40})
+	/go/src/testing/testing.go:1545 +0x238
+panic({0x5b2f00?, 0x6a1b40?})
+	/go/src/runtime/panic.go:914 +0x21f
+example.com/pkg.(*T).do(...)
+	/src/pkg/pkg.go:12
+example.com/pkg.helper(0xc000012345)
+	/src/pkg/pkg.go:20 +0x25
+example.com/pkg.TestFoo(0xc0000a4000)
+	/src/pkg/pkg_test.go:8 +0x1d
+testing.tRunner(0xc0000a4000, 0x5d3a28)
+	/go/src/testing/testing.go:1595 +0xff
+created by testing.(*T).Run in goroutine 1
+	/go/src/testing/testing.go:1648 +0x3ad
+`, "example.com/pkg.(*T).do\nexample.com/pkg.helper\nexample.com/pkg.TestFoo"},
+		{"runtime throw", `fatal error: unexpected signal
+
+runtime stack:
+runtime.throw({0x4c3f1e?, 0x0?})
+	/go/src/runtime/panic.go:1077 +0x5c
+runtime.sigpanic()
+	/go/src/runtime/signal_unix.go:845 +0x3e9
+runtime.mallocgc(0x10, 0x0, 0x0)
+	/go/src/runtime/malloc.go:1010 +0x12
+runtime.goexit()
+	/go/src/runtime/asm_amd64.s:1650 +0x1
+
+goroutine 1 [running]:
+main.main()
+	/src/main.go:5 +0x1
+`, "runtime.mallocgc"},
+		{"only first goroutine", `goroutine 5 [running]:
+main.f()
+	/src/main.go:10 +0x1
+main.g()
+	/src/main.go:20 +0x1
+
+goroutine 6 [chan receive]:
+main.h()
+	/src/main.go:30 +0x1
+`, "main.f\nmain.g"},
+		{"generic", `goroutine 1 [running]:
+example.com/pkg.Map[go.shape.int,go.shape.string](...)
+	/src/pkg/map.go:3
+example.com/pkg.(*List[go.shape.int]).Push(0xc000010000)
+	/src/pkg/list.go:9 +0x1
+`, "example.com/pkg.Map\nexample.com/pkg.(*List).Push"},
+	} {
+		if got := normalizeStack(test.tb); got != test.want {
+			t.Errorf("%s: got\n%s\nwant\n%s", test.name, got, test.want)
+		}
+	}
+}
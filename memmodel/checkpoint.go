@@ -0,0 +1,161 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+)
+
+// A shardFlag is a flag.Value that selects shard I of N of the
+// generated programs.
+type shardFlag struct {
+	I, N int
+}
+
+func (f *shardFlag) String() string {
+	if f.N == 0 {
+		return "0/1"
+	}
+	return fmt.Sprintf("%d/%d", f.I, f.N)
+}
+
+func (f *shardFlag) Set(s string) error {
+	var i, n int
+	if _, err := fmt.Sscanf(s, "%d/%d", &i, &n); err != nil || n < 1 || i < 0 || i >= n {
+		return fmt.Errorf("shard must be i/n with 0 <= i < n")
+	}
+	f.I, f.N = i, n
+	return nil
+}
+
+// has reports whether the program with the given index in
+// GenerateProgs order is in shard f. Programs are assigned to shards
+// round-robin, which keeps the shards balanced even though
+// GenerateProgs produces larger programs later.
+func (f *shardFlag) has(index int) bool {
+	return f.N <= 1 || index%f.N == f.I
+}
+
+// A table is a checkpoint of a (possibly sharded) run. It records
+// how far the run got and the counterexamples it found, so the run
+// can be resumed, or merged with the tables of other shards.
+type table struct {
+	Shard string `json:"shard"`
+	Progs int    `json:"progs"` // Programs generated so far, in all shards
+	Done  bool   `json:"done"`
+
+	Counterexamples []tableEntry `json:"counterexamples"`
+}
+
+type tableEntry struct {
+	Weaker   string `json:"weaker"`
+	Stronger string `json:"stronger"`
+	Index    int    `json:"index"` // Index of the original program
+	Prog     Prog   `json:"prog"`  // Possibly reduced program
+}
+
+// newTable returns a table recording counterexamples.
+func newTable(shard *shardFlag, progs int, done bool, counterexamples [][]*Counterexample) *table {
+	t := &table{Shard: shard.String(), Progs: progs, Done: done}
+	for i := range counterexamples {
+		for _, ce := range counterexamples[i] {
+			if ce != nil {
+				t.Counterexamples = append(t.Counterexamples, tableEntry{ce.weaker.String(), ce.stronger.String(), ce.index, ce.p})
+			}
+		}
+	}
+	return t
+}
+
+// writeFile writes t to path. It replaces path atomically, so an
+// interrupted run always leaves a usable checkpoint.
+func (t *table) writeFile(path string) error {
+	data, err := json.MarshalIndent(t, "", "\t")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, append(data, '\n'), 0666); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func readTable(path string) (*table, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	t := new(table)
+	if err := json.Unmarshal(data, t); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return t, nil
+}
+
+// addTo adds the counterexamples in t to counterexamples. If there's
+// already a counterexample for a pair of models, it keeps the one
+// from the earliest program, so merging shards gives the same result
+// as an unsharded run. The outcome sets are recomputed from the
+// programs.
+func (t *table) addTo(counterexamples [][]*Counterexample) error {
+	modelIndex := make(map[string]int)
+	for i, m := range models {
+		modelIndex[m.String()] = i
+	}
+	for _, e := range t.Counterexamples {
+		i, ok1 := modelIndex[e.Weaker]
+		j, ok2 := modelIndex[e.Stronger]
+		if !ok1 || !ok2 {
+			return fmt.Errorf("unknown model in counterexample %s is weaker than %s", e.Weaker, e.Stronger)
+		}
+		if old := counterexamples[i][j]; old != nil && old.index <= e.Index {
+			continue
+		}
+		ce := &Counterexample{p: e.Prog, weaker: models[i], stronger: models[j], index: e.Index}
+		ce.weaker.Eval(&ce.p, &ce.wset)
+		ce.stronger.Eval(&ce.p, &ce.sset)
+		counterexamples[i][j] = ce
+	}
+	return nil
+}
+
+// mergeTables merges the counterexample tables in paths, which are
+// typically written by runs of different shards. It warns if the
+// tables don't cover a complete set of finished shards.
+func mergeTables(paths []string, counterexamples [][]*Counterexample) error {
+	shards := make(map[shardFlag]bool)
+	n := 0
+	for _, path := range paths {
+		t, err := readTable(path)
+		if err != nil {
+			return err
+		}
+		if err := t.addTo(counterexamples); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		var shard shardFlag
+		if err := shard.Set(t.Shard); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		if !t.Done {
+			fmt.Fprintf(os.Stderr, "warning: %s: shard %s is incomplete (%d progs)\n", path, t.Shard, t.Progs)
+		}
+		if n != 0 && shard.N != n {
+			fmt.Fprintf(os.Stderr, "warning: %s: shard %s has a different shard count\n", path, t.Shard)
+		}
+		n = shard.N
+		shards[shard] = true
+	}
+	for i := 0; i < n; i++ {
+		if s := (shardFlag{i, n}); !shards[s] {
+			fmt.Fprintf(os.Stderr, "warning: missing shard %s\n", &s)
+		}
+	}
+	return nil
+}
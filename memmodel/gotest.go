@@ -0,0 +1,172 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+)
+
+// writeGoTestHeader writes the header of a generated Go test file in
+// package pkg.
+func writeGoTestHeader(w io.Writer, pkg string) {
+	fmt.Fprintf(w, `// Code generated by memmodel. DO NOT EDIT.
+
+package %s
+
+import (
+	"runtime"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// litmusIters is the number of times to run each litmus program.
+const litmusIters = 100000
+
+// litmusVar is a shared variable padded to its own cache line.
+type litmusVar struct {
+	v int32
+	_ [60]byte
+}
+
+// litmusRun runs threads concurrently litmusIters times and returns
+// the number of times each outcome returned by result was observed.
+// Before each run, it calls reset.
+func litmusRun(t *testing.T, reset func(), result func() string, threads ...func()) map[string]int {
+	if runtime.GOMAXPROCS(-1) < len(threads) {
+		t.Skipf("need GOMAXPROCS >= %%d", len(threads))
+	}
+	counts := make(map[string]int)
+	for i := 0; i < litmusIters; i++ {
+		reset()
+		// Start all threads as close together as possible
+		// so they actually overlap.
+		var ready int32
+		var wg sync.WaitGroup
+		for _, thread := range threads {
+			wg.Add(1)
+			go func(thread func()) {
+				defer wg.Done()
+				atomic.AddInt32(&ready, 1)
+				for atomic.LoadInt32(&ready) != int32(len(threads)) {
+				}
+				thread()
+			}(thread)
+		}
+		wg.Wait()
+		counts[result()]++
+	}
+	return counts
+}
+
+// litmusReport logs the observed outcomes. Outcomes in interesting
+// are marked with a "*".
+func litmusReport(t *testing.T, counts map[string]int, interesting ...string) {
+	outcomes := make([]string, 0, len(counts))
+	for o := range counts {
+		outcomes = append(outcomes, o)
+	}
+	sort.Strings(outcomes)
+	for _, o := range outcomes {
+		mark := ""
+		for _, o2 := range interesting {
+			if o == o2 {
+				mark = " *"
+			}
+		}
+		t.Logf("%%s %%d%%s", o, counts[o], mark)
+	}
+}
+`, pkg)
+}
+
+// WriteGoTest writes c as a Go test function that runs the
+// counterexample program many times using goroutines and reports the
+// observed outcomes, marking those that are permitted by the weaker
+// model but not the stronger model.
+//
+// If atomic is true, the program's loads and stores use sync/atomic.
+// Since Go's atomics are sequentially consistent, this should only
+// ever observe SC outcomes and is mostly useful as a control.
+// Otherwise, they are plain (racy) memory accesses and can observe
+// the hardware's memory model.
+func (c *Counterexample) WriteGoTest(w io.Writer, name string, atomic bool) {
+	p := &c.p
+	bw := bufio.NewWriter(w)
+	defer bw.Flush()
+
+	// Collect the outcomes that distinguish the models.
+	var interesting []string
+	for o := range c.wset.OutcomeIter() {
+		if !c.sset.Has(o) {
+			interesting = append(interesting, fmt.Sprintf("%q", o.Format(p.NumLoads)))
+		}
+	}
+
+	nvars := 0
+	nthr := 0
+	for tid := range p.Threads {
+		if p.Threads[tid].Ops[0].Type == OpExit {
+			break
+		}
+		nthr++
+		for _, op := range p.Threads[tid].Ops {
+			if op.Type != OpExit && int(op.Var) >= nvars {
+				nvars = int(op.Var) + 1
+			}
+		}
+	}
+
+	fmt.Fprintf(bw, "\n// %s finds outcomes that %s permits but %s does not.\n//\n", name, c.weaker, c.stronger)
+	fmt.Fprintf(bw, "//\t%s\n", strings.Replace(p.String(), "\n", "\n//\t", -1))
+	fmt.Fprintf(bw, "func %s(t *testing.T) {\n", name)
+	fmt.Fprintf(bw, "\tvar x [%d]litmusVar\n", nvars)
+	fmt.Fprintf(bw, "\tvar r [%d]int32\n", p.NumLoads)
+	fmt.Fprintf(bw, "\treset := func() {\n\t\tx = [%d]litmusVar{}\n\t\tr = [%d]int32{}\n\t}\n", nvars, p.NumLoads)
+	fmt.Fprintf(bw, "\tresult := func() string {\n\t\tvar out []byte\n\t\tfor _, v := range r {\n\t\t\tout = append(out, byte('0'+v))\n\t\t}\n\t\treturn string(out)\n\t}\n")
+	fmt.Fprintf(bw, "\tcounts := litmusRun(t, reset, result,\n")
+	for tid := 0; tid < nthr; tid++ {
+		fmt.Fprintf(bw, "\t\tfunc() {\n")
+		for _, op := range p.Threads[tid].Ops {
+			switch op.Type {
+			case OpStore:
+				if atomic {
+					fmt.Fprintf(bw, "\t\t\tatomic.StoreInt32(&x[%d].v, 1)\n", op.Var)
+				} else {
+					fmt.Fprintf(bw, "\t\t\tx[%d].v = 1\n", op.Var)
+				}
+			case OpLoad:
+				if atomic {
+					fmt.Fprintf(bw, "\t\t\tr[%d] = atomic.LoadInt32(&x[%d].v)\n", op.ID, op.Var)
+				} else {
+					fmt.Fprintf(bw, "\t\t\tr[%d] = x[%d].v\n", op.ID, op.Var)
+				}
+			}
+		}
+		fmt.Fprintf(bw, "\t\t},\n")
+	}
+	fmt.Fprintf(bw, "\t)\n")
+	fmt.Fprintf(bw, "\tlitmusReport(t, counts%s)\n", strings.Join(append([]string{""}, interesting...), ", "))
+	fmt.Fprintf(bw, "}\n")
+}
+
+// goTestName returns a Go test function name for a counterexample
+// between two models.
+func goTestName(weaker, stronger Model) string {
+	ident := func(s string) string {
+		return strings.Map(func(r rune) rune {
+			if unicode.IsLetter(r) || unicode.IsDigit(r) {
+				return r
+			}
+			return -1
+		}, s)
+	}
+	return "Test" + ident(weaker.String()) + "Vs" + ident(stronger.String())
+}
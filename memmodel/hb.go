@@ -4,7 +4,11 @@
 
 package main
 
-import "fmt"
+import (
+	"fmt"
+	"io"
+	"strings"
+)
 
 // HBModel is a generalized memory model with a plug-in happens-before
 // graph generator.
@@ -93,6 +97,20 @@ type hbGlobal struct {
 	// vars records the indexes in sequence of loads and stores
 	// that have executed on each variable.
 	vars [MaxVar]varInfo
+
+	// trace, if non-nil, stops the evaluation at the first
+	// execution that permits outcome trace.want and records it.
+	trace *hbTrace
+}
+
+type hbTrace struct {
+	want  Outcome
+	found bool
+
+	// sequence and graph are copies of hbGlobal.sequence and
+	// hbGlobal.graph for the execution that permits want.
+	sequence []PC
+	graph    hbGraph
 }
 
 // An hbGraphNode represents the set of in-edges for a single node in
@@ -264,6 +282,9 @@ func (g *hbGlobal) rec(s hbState) {
 					ns0 := ns
 					ns0.allow0.Set(op, 1)
 					g.rec(ns0)
+					if g.trace != nil && g.trace.found {
+						return
+					}
 
 					// Or 2) the load observes the
 					// store, so it must read 1
@@ -298,6 +319,9 @@ func (g *hbGlobal) rec(s hbState) {
 		}
 
 		g.rec(ns)
+		if g.trace != nil && g.trace.found {
+			return
+		}
 
 		g.sequence = g.sequence[:len(g.sequence)-1]
 		g.graph.nodes = g.graph.nodes[:len(g.graph.nodes)-1]
@@ -315,7 +339,112 @@ func (g *hbGlobal) rec(s hbState) {
 			panic(fmt.Sprintf("no outcome for load: allow0=0x%x allow1=0x%x in program:\n%s", s.allow0, s.allow1, g.p))
 		}
 		g.addOutcomes(0, s.allow0, s.allow1, 0)
+
+		// Each load's result is independent, so this
+		// execution permits an outcome if it permits each of
+		// the outcome's load results.
+		all := Outcome(1)<<uint(g.p.NumLoads) - 1
+		if t := g.trace; t != nil && t.want&^s.allow1 == 0 && (all&^t.want)&^s.allow0 == 0 {
+			t.found = true
+			t.sequence = append([]PC(nil), g.sequence...)
+			t.graph.nodes = append([]hbGraphNode(nil), g.graph.nodes...)
+		}
+	}
+}
+
+func (m HBModel) Trace(w io.Writer, p *Prog, o Outcome) bool {
+	var outcomes OutcomeSet
+	var seqBuf [MaxTotalOps]PC
+	var nodeBuf [MaxTotalOps]hbGraphNode
+	t := &hbTrace{want: o}
+	g := hbGlobal{
+		p:        p,
+		outcomes: &outcomes,
+		model:    m,
+		sequence: seqBuf[:0],
+		graph:    hbGraph{nodeBuf[:0]},
+		trace:    t,
+	}
+	outcomes.Reset(p)
+	g.rec(hbState{})
+	if !t.found {
+		return false
+	}
+
+	var order []string
+	for _, pc := range t.sequence {
+		order = append(order, p.opName(pc))
+	}
+	fmt.Fprintf(w, "interleaving: %s\n", strings.Join(order, ", "))
+
+	// Print the global happens-before edges that aren't implied
+	// by transitivity.
+	fmt.Fprintf(w, "happens-before edges (plus program order):\n")
+	n := 0
+	for j := range t.sequence {
+		for i := 0; i < j; i++ {
+			if !t.graph.happenedBefore(i, j) {
+				continue
+			}
+			implied := false
+			for k := i + 1; k < j && !implied; k++ {
+				implied = t.graph.happenedBefore(i, k) && t.graph.happenedBefore(k, j)
+			}
+			if !implied {
+				fmt.Fprintf(w, "\t%s -> %s\n", p.opName(t.sequence[i]), p.opName(t.sequence[j]))
+				n++
+			}
+		}
+	}
+	if n == 0 {
+		fmt.Fprintf(w, "\t(none)\n")
+	}
+
+	fmt.Fprintf(w, "loads:\n")
+	for l, pc := range t.sequence {
+		if op := p.OpAt(pc); op.Type == OpLoad {
+			fmt.Fprintf(w, "\t%s -> %d: %s\n", p.opName(pc), (o>>op.ID)&1, t.explain(p, l))
+		}
+	}
+	return true
+}
+
+// explain returns why the load at index l in t.sequence can return
+// its result in t.want.
+func (t *hbTrace) explain(p *Prog, l int) string {
+	ld := p.OpAt(t.sequence[l])
+	// Local program order is part of each thread's
+	// happens-before graph.
+	hb := func(i, j int) bool {
+		return t.sequence[i].TID == t.sequence[j].TID && i < j || t.graph.happenedBefore(i, j)
+	}
+	var before, concurrent, after string
+	for st, pc := range t.sequence {
+		if op := p.OpAt(pc); op.Type != OpStore || op.Var != ld.Var {
+			continue
+		}
+		switch {
+		case st < l && hb(st, l):
+			before = p.opName(pc)
+		case st > l && hb(l, st):
+			after = p.opName(pc)
+		default:
+			concurrent = p.opName(pc)
+		}
+	}
+	if (t.want>>ld.ID)&1 == 1 {
+		if before != "" {
+			return fmt.Sprintf("observes %s, which happens before it", before)
+		}
+		return fmt.Sprintf("observes %s, which is concurrent with it", concurrent)
+	}
+	switch {
+	case concurrent != "":
+		return fmt.Sprintf("doesn't observe %s, which is concurrent with it", concurrent)
+	case after != "":
+		return fmt.Sprintf("%s happens after it", after)
 	}
+	return fmt.Sprintf("no store to %d happens before it", ld.Var)
 }
 
 func (g *hbGlobal) addOutcomes(bit uint, allow0, allow1, out Outcome) {
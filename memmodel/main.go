@@ -28,6 +28,45 @@
 // the outcomes allowed by all of the models. This is mostly useful
 // for debugging.
 //
+// With -trace, each example is followed by an execution under the
+// weaker model that produces each outcome the stronger model doesn't
+// permit. For a specific program and outcome,
+//
+//	memmodel trace program outcome
+//
+// prints such an execution under each model. See "memmodel trace -h"
+// for the program syntax. Traces of operational models show the
+// memory and store buffers after each step. Traces of happens-before
+// models show an interleaving, its happens-before graph, and why each
+// load can return its value.
+//
+// Before printing a counterexample, memmodel reduces it by removing
+// threads and operations for as long as the two models still differ
+// on the reduced program. -no-reduce disables this.
+//
+// With -gotest, it writes the example programs as a Go test file in
+// package "litmus". Each test runs its program many times using
+// goroutines and logs the outcomes observed on real hardware, marking
+// those that distinguish the two models (use go test -v to see them).
+// By default, the programs use plain memory accesses. With
+// -gotest-atomic, they use sync/atomic instead.
+//
+//
+// Checkpoints and sharding
+//
+// With -checkpoint file, memmodel periodically saves how far it has
+// gotten and the counterexamples it has found to file. If file
+// already exists, memmodel resumes from it.
+//
+// With -shard i/n, memmodel checks only the i'th of n deterministic
+// slices of the generated programs, so a search can be split across
+// machines. Each shard should save its results with -checkpoint. Then,
+//
+//	memmodel [output flags] merge file...
+//
+// combines the counterexample tables saved by the shards and produces
+// the same output as an unsharded run.
+//
 //
 // Supported memory models
 //
@@ -66,6 +105,7 @@ import (
 	"strings"
 )
 
+// A Model is a memory model. Every Model must also implement Tracer.
 type Model interface {
 	Eval(p *Prog, outcomes *OutcomeSet)
 	String() string
@@ -86,6 +126,7 @@ type Counterexample struct {
 	p                Prog
 	weaker, stronger Model
 	wset, sset       OutcomeSet
+	index            int // Index of the original program in GenerateProgs order
 }
 
 func (c *Counterexample) Print(w io.Writer) {
@@ -94,19 +135,32 @@ func (c *Counterexample) Print(w io.Writer) {
 }
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "trace" {
+		traceMain(os.Args[2:])
+		return
+	}
+
 	flagGraph := flag.String("graph", "", "write model graph to `output` dot file")
 	flagNoSimplify := flag.Bool("no-simplify", false, "disable graph simplification")
 	// TODO: If we were to write the examples repeatedly like we
 	// do for the graph, we could do the same graph reduction and
 	// only print a minimal set of examples.
 	flagExamples := flag.Bool("examples", false, "show examples where models differ")
+	flagTrace := flag.Bool("trace", false, "with -examples, show how the weaker model produces each distinguishing outcome")
 	// TODO: These big tables would be pretty nice if we could
 	// filter out the noise (e.g., only show them when models
 	// disagree, order the columns from stronger to weaker,
 	// collapse equivalent models).
 	flagAllProgs := flag.Bool("all-progs", false, "show all programs and outcomes")
+	flagGoTest := flag.String("gotest", "", "write examples as a Go test to `output` file")
+	flagGoTestAtomic := flag.Bool("gotest-atomic", false, "use sync/atomic for loads and stores in -gotest programs")
+	flagNoReduce := flag.Bool("no-reduce", false, "disable counterexample reduction")
+	flagCheckpoint := flag.String("checkpoint", "", "save progress and counterexamples to `file`, and resume from it if it exists")
+	var shard shardFlag
+	flag.Var(&shard, "shard", "check only shard `i/n` of the generated programs")
 	flag.Parse()
-	if flag.NArg() > 0 {
+	merge := flag.NArg() > 0 && flag.Arg(0) == "merge"
+	if flag.NArg() > 0 && !merge {
 		flag.Usage()
 		os.Exit(2)
 	}
@@ -118,20 +172,99 @@ func main() {
 		counterexamples[i] = make([]*Counterexample, len(models))
 	}
 
-	n := 0
-	outcomes := make([]OutcomeSet, len(models))
-	for p := range GenerateProgs() {
-		if !(*flagAllProgs || *flagExamples) && n%10 == 0 {
-			fmt.Fprintf(os.Stderr, "\r%d progs", n)
+	if merge {
+		if err := mergeTables(flag.Args()[1:], counterexamples); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
 		}
-		n++
+		if *flagExamples {
+			for i := range counterexamples {
+				for _, ce := range counterexamples[i] {
+					if ce != nil {
+						ce.Print(os.Stdout)
+						if *flagTrace {
+							ce.PrintTraces(os.Stdout)
+						}
+						fmt.Println()
+					}
+				}
+			}
+		}
+	} else {
+		search(counterexamples, &shard, *flagCheckpoint, *flagGraph, *flagAllProgs, *flagExamples, *flagTrace, !*flagNoReduce, !*flagNoSimplify)
+	}
+
+	// Write final graph.
+	if *flagGraph != "" {
+		f, err := os.Create(*flagGraph)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		defer f.Close()
+		writeModelGraph(f, counterexamples, !*flagNoSimplify)
+	}
+
+	// Write Go tests.
+	if *flagGoTest != "" {
+		f, err := os.Create(*flagGoTest)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		defer f.Close()
+		writeGoTestHeader(f, "litmus")
+		for i := range counterexamples {
+			for _, ce := range counterexamples[i] {
+				if ce != nil {
+					ce.WriteGoTest(f, goTestName(ce.weaker, ce.stronger), *flagGoTestAtomic)
+				}
+			}
+		}
+	}
+}
+
+// search generates programs in shard, evaluates them under each
+// model, and fills in counterexamples. If checkpoint is non-empty, it
+// resumes from and periodically saves to that file.
+func search(counterexamples [][]*Counterexample, shard *shardFlag, checkpoint, graph string, allProgs, examples, traces, reduce, simplify bool) {
+	skip := 0
+	if checkpoint != "" {
+		t, err := readTable(checkpoint)
+		if err == nil {
+			if t.Shard != shard.String() {
+				fmt.Fprintf(os.Stderr, "%s is for shard %s, not %s\n", checkpoint, t.Shard, shard)
+				os.Exit(1)
+			}
+			if err := t.addTo(counterexamples); err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %s\n", checkpoint, err)
+				os.Exit(1)
+			}
+			skip = t.Progs
+			fmt.Fprintf(os.Stderr, "resuming from %s after %d progs\n", checkpoint, skip)
+		} else if !os.IsNotExist(err) {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+	save := func(n int, done bool) {
+		if checkpoint == "" {
+			return
+		}
+		if err := newTable(shard, n, done, counterexamples).writeFile(checkpoint); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
 
+	outcomes := make([]OutcomeSet, len(models))
+	check := func(p *Prog, index int) {
 		for i, model := range models {
-			model.Eval(&p, &outcomes[i])
+			model.Eval(p, &outcomes[i])
 		}
 
-		if *flagAllProgs {
-			fmt.Println(&p)
+		if allProgs {
+			fmt.Println(p)
 			names := []string{}
 			for _, model := range models {
 				names = append(names, model.String())
@@ -157,45 +290,56 @@ func main() {
 					// that model j does not. (i
 					// is weaker than j.)
 					c := &Counterexample{
-						p, models[i], models[j],
+						*p, models[i], models[j],
 						outcomes[i], outcomes[j],
+						index,
+					}
+					if reduce {
+						c.Reduce()
 					}
 					counterexamples[i][j] = c
-					if *flagExamples {
+					if examples {
 						c.Print(os.Stdout)
+						if traces {
+							c.PrintTraces(os.Stdout)
+						}
 						fmt.Println()
 					}
 				}
-				// TODO: Prefer smaller
-				// counterexamples.
 			}
 		}
+	}
 
-		if n%100 == 0 && *flagGraph != "" {
-			// dot uses inotify wrong, so it doesn't
-			// notice if we write to a temp file and
-			// rename it over the output file.
-			f, err := os.Create(*flagGraph)
-			if err != nil {
-				fmt.Fprintln(os.Stderr, err)
-				os.Exit(1)
-			}
-			writeModelGraph(f, counterexamples, !*flagNoSimplify)
-			f.Close()
+	n := 0
+	for p := range GenerateProgs() {
+		if !(allProgs || examples) && n%10 == 0 {
+			fmt.Fprintf(os.Stderr, "\r%d progs", n)
 		}
-	}
-	fmt.Fprintf(os.Stderr, "\r%d progs\n", n)
+		n++
 
-	// Write final graph.
-	if *flagGraph != "" {
-		f, err := os.Create(*flagGraph)
-		if err != nil {
-			fmt.Fprintln(os.Stderr, err)
-			os.Exit(1)
+		if n > skip && shard.has(n-1) {
+			check(&p, n-1)
+		}
+
+		if n%100 == 0 {
+			save(n, false)
+			if graph != "" {
+				// dot uses inotify wrong, so it
+				// doesn't notice if we write to a
+				// temp file and rename it over the
+				// output file.
+				f, err := os.Create(graph)
+				if err != nil {
+					fmt.Fprintln(os.Stderr, err)
+					os.Exit(1)
+				}
+				writeModelGraph(f, counterexamples, simplify)
+				f.Close()
+			}
 		}
-		defer f.Close()
-		writeModelGraph(f, counterexamples, !*flagNoSimplify)
 	}
+	fmt.Fprintf(os.Stderr, "\r%d progs\n", n)
+	save(n, true)
 }
 
 func writeModelGraph(w io.Writer, counterexamples [][]*Counterexample, simplify bool) {
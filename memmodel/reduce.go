@@ -0,0 +1,120 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+// Reduce shrinks c's program by repeatedly removing threads and
+// operations as long as the weaker model still permits outcomes that
+// the stronger model does not. Each candidate program is re-evaluated
+// under both models, so the reduced counterexample is still valid.
+// The result is minimal in the sense that removing any single thread
+// or operation loses the difference.
+func (c *Counterexample) Reduce() {
+	for c.reduce1() {
+	}
+}
+
+// reduce1 tries to remove one thread or operation from c's program.
+// It reports whether it succeeded.
+func (c *Counterexample) reduce1() bool {
+	nthr := c.p.numThreads()
+	// Try removing whole threads first, since that's the biggest
+	// reduction.
+	for tid := 0; tid < nthr; tid++ {
+		p := c.p
+		p.removeThread(tid)
+		if c.try(&p) {
+			return true
+		}
+	}
+	for tid := 0; tid < nthr; tid++ {
+		for i := 0; i < MaxOps && c.p.Threads[tid].Ops[i].Type != OpExit; i++ {
+			p := c.p
+			p.removeOp(PC{tid, i})
+			if c.try(&p) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// try evaluates p under c's models and, if it still distinguishes
+// them, replaces c's program and outcomes with p's.
+func (c *Counterexample) try(p *Prog) bool {
+	if p.numThreads() < 2 {
+		return false
+	}
+	p.renumber()
+	var wset, sset OutcomeSet
+	c.weaker.Eval(p, &wset)
+	c.stronger.Eval(p, &sset)
+	if wset == sset || !wset.Contains(&sset) {
+		return false
+	}
+	c.p, c.wset, c.sset = *p, wset, sset
+	return true
+}
+
+// numThreads returns the number of non-empty threads in p.
+func (p *Prog) numThreads() int {
+	for tid := range p.Threads {
+		if p.Threads[tid].Ops[0].Type == OpExit {
+			return tid
+		}
+	}
+	return len(p.Threads)
+}
+
+// removeThread removes thread tid from p.
+func (p *Prog) removeThread(tid int) {
+	copy(p.Threads[tid:], p.Threads[tid+1:])
+	p.Threads[len(p.Threads)-1] = Thread{}
+}
+
+// removeOp removes the operation at pc from p. If this leaves the
+// thread empty, it removes the thread.
+func (p *Prog) removeOp(pc PC) {
+	ops := &p.Threads[pc.TID].Ops
+	copy(ops[pc.I:], ops[pc.I+1:])
+	ops[len(ops)-1] = Op{}
+	if ops[0].Type == OpExit {
+		p.removeThread(pc.TID)
+	}
+}
+
+// renumber reassigns load IDs and variable numbers in p so they're
+// dense and in program order, like GenerateProgs produces them.
+func (p *Prog) renumber() {
+	var vars [MaxVar]byte
+	var seen [MaxVar]bool
+	nvars := byte(0)
+	mapVar := func(op *Op) {
+		if !seen[op.Var] {
+			seen[op.Var] = true
+			vars[op.Var] = nvars
+			nvars++
+		}
+		op.Var = vars[op.Var]
+	}
+	// Number stored variables first so stores use increasing
+	// variable numbers.
+	for tid := range p.Threads {
+		for i := range p.Threads[tid].Ops {
+			if op := &p.Threads[tid].Ops[i]; op.Type == OpStore {
+				mapVar(op)
+			}
+		}
+	}
+	p.NumLoads = 0
+	for tid := range p.Threads {
+		for i := range p.Threads[tid].Ops {
+			if op := &p.Threads[tid].Ops[i]; op.Type == OpLoad {
+				mapVar(op)
+				op.ID = byte(p.NumLoads)
+				p.NumLoads++
+			}
+		}
+	}
+}
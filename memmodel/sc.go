@@ -4,6 +4,11 @@
 
 package main
 
+import (
+	"fmt"
+	"io"
+)
+
 // SCModel models all loads and stores as sequentially consistent.
 // That is, there is a total order over all loads and stores. It
 // implements sequential consistency using a direct operational
@@ -19,13 +24,50 @@ func (SCModel) Eval(p *Prog, outcomes *OutcomeSet) {
 	// each load instruction, and at the end of each execution
 	// record the outcome.
 	outcomes.Reset(p)
-	(&scGlobal{p, outcomes}).rec(scState{})
+	(&scGlobal{p, outcomes, nil}).rec(scState{})
+}
+
+func (SCModel) Trace(w io.Writer, p *Prog, o Outcome) bool {
+	var outcomes OutcomeSet
+	outcomes.Reset(p)
+	t := &scTrace{want: o}
+	(&scGlobal{p, &outcomes, t}).rec(scState{})
+	if !t.found {
+		return false
+	}
+	nvars := p.numVars()
+	for i, step := range t.steps {
+		res := ""
+		if op := p.OpAt(step.pc); op.Type == OpLoad {
+			res = fmt.Sprintf("-> %d", step.res)
+		}
+		fmt.Fprintf(w, "%2d. %-12s %-5s  memory %s\n", i+1, p.opName(step.pc), res, step.mem.Format(nvars))
+	}
+	return true
 }
 
 // scGlobal stores state that is global to an SC evaluation.
 type scGlobal struct {
 	p        *Prog
 	outcomes *OutcomeSet
+
+	// trace, if non-nil, records the current execution and stops
+	// the evaluation at the first execution with outcome
+	// trace.want.
+	trace *scTrace
+}
+
+type scTrace struct {
+	want  Outcome
+	steps []scStep
+	found bool
+}
+
+// An scStep records an operation and the memory after it.
+type scStep struct {
+	pc  PC
+	res int // For a load, its result
+	mem MemState
 }
 
 // scState stores the state of a program at a single point during
@@ -50,11 +92,23 @@ func (g *scGlobal) rec(s scState) {
 				ns.outcome |= Outcome(opres) << op.ID
 			}
 			ns.pcs[tid]++
+			if g.trace == nil {
+				g.rec(ns)
+				continue
+			}
+			g.trace.steps = append(g.trace.steps, scStep{PC{tid, s.pcs[tid]}, opres, ns.mem})
 			g.rec(ns)
+			if g.trace.found {
+				return
+			}
+			g.trace.steps = g.trace.steps[:len(g.trace.steps)-1]
 		}
 	}
 	if !any {
 		// This execution is done.
 		g.outcomes.Add(s.outcome)
+		if g.trace != nil && s.outcome == g.trace.want {
+			g.trace.found = true
+		}
 	}
 }
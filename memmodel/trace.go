@@ -0,0 +1,213 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// A Tracer is a Model that can show how a program produces an
+// outcome.
+type Tracer interface {
+	// Trace writes an execution of p that produces outcome o to
+	// w. If the model doesn't permit o, it writes nothing and
+	// returns false.
+	Trace(w io.Writer, p *Prog, o Outcome) bool
+}
+
+// traceMain implements "memmodel trace", which shows how each model
+// produces an outcome of a program.
+func traceMain(args []string) {
+	fs := flag.NewFlagSet("trace", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), `Usage: %s trace [flags] program outcome
+
+Print an execution of program that produces outcome under each model,
+or under just the model named by -model. For operational models, the
+trace shows each step with the memory and, for TSO, store buffer
+contents after it. For happens-before models, it shows the
+interleaving, the happens-before edges, and why each load can read
+its value.
+
+program lists each thread's operations, separated by ";", with
+threads separated by "|". An operation is "st V", which stores 1 to
+variable V, or "L=ld V", which loads V into register L. Registers are
+named a, b, c, and so on, and outcome gives the value of each
+register in that order. For example,
+
+	%s trace 'st 0; a=ld 1 | st 1; b=ld 0' 00
+
+shows the store buffering outcome that TSO permits and SC does not.
+
+`, os.Args[0], os.Args[0])
+		fs.PrintDefaults()
+	}
+	flagModel := fs.String("model", "", "trace only the model named `name`, such as \"TSO\" or \"TSO (HB)\"")
+	fs.Parse(args)
+	if fs.NArg() != 2 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	p, err := parseProg(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	o, err := parseOutcome(fs.Arg(1), p.NumLoads)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("%s\n\noutcome %s\n", p, o.Format(p.NumLoads))
+	found := false
+	for _, model := range models {
+		if *flagModel != "" && !strings.EqualFold(*flagModel, model.String()) {
+			continue
+		}
+		found = true
+		fmt.Printf("\n%s:\n", model)
+		if !model.(Tracer).Trace(os.Stdout, p, o) {
+			fmt.Printf("not permitted\n")
+		}
+	}
+	if !found {
+		fmt.Fprintf(os.Stderr, "unknown model %q\n", *flagModel)
+		os.Exit(1)
+	}
+}
+
+// PrintTraces writes a trace under c's weaker model of each outcome
+// that its stronger model doesn't permit.
+func (c *Counterexample) PrintTraces(w io.Writer) {
+	for o := range c.wset.OutcomeIter() {
+		if c.sset.Has(o) {
+			continue
+		}
+		fmt.Fprintf(w, "%s produces %s:\n", c.weaker, o.Format(c.p.NumLoads))
+		c.weaker.(Tracer).Trace(w, &c.p, o)
+	}
+}
+
+// parseProg parses a program in the syntax described by "memmodel
+// trace -h".
+func parseProg(s string) (*Prog, error) {
+	var p Prog
+	threads := strings.Split(s, "|")
+	if len(threads) > MaxThreads {
+		return nil, fmt.Errorf("program has %d threads; at most %d are supported", len(threads), MaxThreads)
+	}
+	var loads []bool
+	for tid, thread := range threads {
+		ops := strings.Split(thread, ";")
+		if len(ops) > MaxOps {
+			return nil, fmt.Errorf("thread %d has %d operations; at most %d are supported", tid, len(ops), MaxOps)
+		}
+		for i, opStr := range ops {
+			op, err := parseOp(strings.TrimSpace(opStr))
+			if err != nil {
+				return nil, err
+			}
+			if op.Type == OpLoad {
+				for len(loads) <= int(op.ID) {
+					loads = append(loads, false)
+				}
+				if loads[op.ID] {
+					return nil, fmt.Errorf("register %c is loaded more than once", 'a'+op.ID)
+				}
+				loads[op.ID] = true
+			}
+			p.Threads[tid].Ops[i] = op
+		}
+	}
+	for id, ok := range loads {
+		if !ok {
+			return nil, fmt.Errorf("registers must be consecutive, but %c is missing", 'a'+id)
+		}
+	}
+	p.NumLoads = len(loads)
+	return &p, nil
+}
+
+func parseOp(s string) (Op, error) {
+	bad := func() (Op, error) {
+		return Op{}, fmt.Errorf("bad operation %q; want \"st V\" or \"L=ld V\"", s)
+	}
+	parseVar := func(s string) (byte, bool) {
+		v, err := strconv.Atoi(strings.TrimSpace(s))
+		return byte(v), err == nil && v >= 0 && v < MaxVar
+	}
+	if strings.HasPrefix(s, "st ") {
+		v, ok := parseVar(s[len("st "):])
+		if !ok {
+			return bad()
+		}
+		return Op{Type: OpStore, Var: v}, nil
+	}
+	i := strings.Index(s, "=")
+	if i < 0 {
+		return bad()
+	}
+	reg, rest := strings.TrimSpace(s[:i]), strings.TrimSpace(s[i+1:])
+	if len(reg) != 1 || reg[0] < 'a' || reg[0] >= 'a'+MaxTotalOps || !strings.HasPrefix(rest, "ld ") {
+		return bad()
+	}
+	v, ok := parseVar(rest[len("ld "):])
+	if !ok {
+		return bad()
+	}
+	return Op{Type: OpLoad, Var: v, ID: reg[0] - 'a'}, nil
+}
+
+// parseOutcome parses an outcome as printed by Outcome.Format.
+func parseOutcome(s string, numLoads int) (Outcome, error) {
+	if len(s) != numLoads {
+		return 0, fmt.Errorf("outcome %q must have one digit for each of %d registers", s, numLoads)
+	}
+	var o Outcome
+	for i, c := range s {
+		switch c {
+		case '0':
+		case '1':
+			o |= 1 << uint(i)
+		default:
+			return 0, fmt.Errorf("bad outcome %q; want a string of 0s and 1s", s)
+		}
+	}
+	return o, nil
+}
+
+// numVars returns the number of variables used by p.
+func (p *Prog) numVars() int {
+	n := 0
+	for tid := range p.Threads {
+		for _, op := range p.Threads[tid].Ops {
+			if op.Type != OpExit && int(op.Var) >= n {
+				n = int(op.Var) + 1
+			}
+		}
+	}
+	return n
+}
+
+// Format formats the first nvars variables of m, such as "0=1 1=0".
+func (m MemState) Format(nvars int) string {
+	var out []string
+	for v := 0; v < nvars; v++ {
+		out = append(out, fmt.Sprintf("%d=%d", v, (m>>uint(v))&1))
+	}
+	return strings.Join(out, " ")
+}
+
+// opName returns a description of the operation at pc, such as
+// "T1 a=ld 0".
+func (p *Prog) opName(pc PC) string {
+	return fmt.Sprintf("T%d %s", pc.TID, p.OpAt(pc))
+}
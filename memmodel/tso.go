@@ -4,6 +4,12 @@
 
 package main
 
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
 type TSOVariant int
 
 // TSOModel models all loads and stores as TSO operations, possibly
@@ -33,7 +39,34 @@ func (m TSOModel) String() string {
 
 func (m TSOModel) Eval(p *Prog, outcomes *OutcomeSet) {
 	outcomes.Reset(p)
-	(&tsoGlobal{p, outcomes, &m}).rec(tsoState{})
+	(&tsoGlobal{p, outcomes, &m, nil}).rec(tsoState{})
+}
+
+func (m TSOModel) Trace(w io.Writer, p *Prog, o Outcome) bool {
+	var outcomes OutcomeSet
+	outcomes.Reset(p)
+	t := &tsoTrace{want: o}
+	(&tsoGlobal{p, &outcomes, &m, t}).rec(tsoState{})
+	if !t.found {
+		return false
+	}
+	nvars := p.numVars()
+	for i, step := range t.steps {
+		fmt.Fprintf(w, "%2d. %-32s memory %s", i+1, step.desc, step.state.mem.Format(nvars))
+		for tid := range p.Threads {
+			sb := &step.state.sb[tid]
+			if sb.h == sb.t {
+				continue
+			}
+			var buf []string
+			for _, v := range sb.buf[sb.h:sb.t] {
+				buf = append(buf, fmt.Sprintf("st %d", v))
+			}
+			fmt.Fprintf(w, "  T%d buffer [%s]", tid, strings.Join(buf, ", "))
+		}
+		fmt.Fprintf(w, "\n")
+	}
+	return true
 }
 
 // tsoGlobal stores state that is global to a TSO evaluation.
@@ -41,23 +74,70 @@ type tsoGlobal struct {
 	p        *Prog
 	outcomes *OutcomeSet
 	model    *TSOModel
+
+	// trace, if non-nil, records the current execution and stops
+	// the evaluation at the first execution with outcome
+	// trace.want.
+	trace *tsoTrace
+}
+
+type tsoTrace struct {
+	want  Outcome
+	steps []tsoStep
+	found bool
+}
+
+// A tsoStep records an operation or store buffer flush and the
+// machine state after it.
+type tsoStep struct {
+	desc  string
+	state tsoState
+}
+
+// step records a step in g's trace, if any, and evaluates the rest of
+// the execution from state s. It reports whether the trace is done.
+func (g *tsoGlobal) step(s tsoState, desc func() string) bool {
+	if g.trace == nil {
+		g.rec(s)
+		return false
+	}
+	g.trace.steps = append(g.trace.steps, tsoStep{desc(), s})
+	g.rec(s)
+	if g.trace.found {
+		return true
+	}
+	g.trace.steps = g.trace.steps[:len(g.trace.steps)-1]
+	return false
+}
+
+// tsoStoreBuffer is the store buffer of one CPU.
+type tsoStoreBuffer struct {
+	// overlay records all stores performed by this CPU.
+	overlay MemState
+	// buf, h, and t are the store buffer FIFO.
+	buf  [MaxOps]byte
+	h, t int
 }
 
 // tsoState stores the state of a program at a single point during
 // execution.
 type tsoState struct {
-	mem MemState             // Global memory state.
-	sb  [MaxThreads]struct { // Per-CPU store buffer.
-		// overlay records all stores performed by this CPU.
-		overlay MemState
-		// buf, h, and t are the store buffer FIFO.
-		buf  [MaxOps]byte
-		h, t int
-	}
+	mem     MemState                   // Global memory state.
+	sb      [MaxThreads]tsoStoreBuffer // Per-CPU store buffer.
 	pcs     [MaxThreads]int
 	outcome Outcome
 }
 
+// buffered reports whether v has a store in sb's store buffer.
+func (sb *tsoStoreBuffer) buffered(v byte) bool {
+	for _, bv := range sb.buf[sb.h:sb.t] {
+		if bv == v {
+			return true
+		}
+	}
+	return false
+}
+
 func (g *tsoGlobal) rec(s tsoState) {
 	// Pick an op to execute next.
 	var opres int
@@ -68,10 +148,14 @@ func (g *tsoGlobal) rec(s tsoState) {
 			any = true
 			ns := s
 			sb := &ns.sb[tid]
+			note := ""
 			switch op.Type {
 			case OpLoad:
 				if g.model.MFenceLoad {
 					// Flush the store buffer.
+					if sb.h < sb.t {
+						note = " (MFENCE flushes)"
+					}
 					ns.mem |= sb.overlay
 					sb.h, sb.t = 0, 0
 				}
@@ -81,6 +165,13 @@ func (g *tsoGlobal) rec(s tsoState) {
 				// forwarding.
 				_, opres = op.Exec(ns.mem | sb.overlay)
 				ns.outcome |= Outcome(opres) << op.ID
+				if g.trace != nil {
+					if sb.buffered(op.Var) {
+						note = fmt.Sprintf(" -> %d (forwarded)", opres)
+					} else {
+						note += fmt.Sprintf(" -> %d", opres)
+					}
+				}
 			case OpStore:
 				// Write to the store buffer.
 				sb.overlay, _ = op.Exec(sb.overlay)
@@ -91,16 +182,23 @@ func (g *tsoGlobal) rec(s tsoState) {
 					// Flush the store buffer.
 					ns.mem |= sb.overlay
 					sb.h, sb.t = 0, 0
+					note = " (MFENCE flushes)"
 				}
 			}
 			ns.pcs[tid]++
-			g.rec(ns)
+			pc := PC{tid, s.pcs[tid]}
+			if g.step(ns, func() string { return g.p.opName(pc) + note }) {
+				return
+			}
 		}
 	}
 	if !any {
 		// This execution is done. We don't care if there's
 		// stuff in the store buffers.
 		g.outcomes.Add(s.outcome)
+		if g.trace != nil && s.outcome == g.trace.want {
+			g.trace.found = true
+		}
 		return
 	}
 
@@ -109,9 +207,12 @@ func (g *tsoGlobal) rec(s tsoState) {
 		if s.sb[tid].h < s.sb[tid].t {
 			ns := s
 			sb := &ns.sb[tid]
-			ns.mem |= MemState(1 << sb.buf[sb.h])
+			v := sb.buf[sb.h]
+			ns.mem |= MemState(1 << v)
 			sb.h++
-			g.rec(ns)
+			if g.step(ns, func() string { return fmt.Sprintf("T%d flushes st %d", tid, v) }) {
+				return
+			}
 		}
 	}
 }
@@ -0,0 +1,96 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"rsc.io/github"
+)
+
+// auditPrefix starts every audit line written to the notes column,
+// so they can be told apart from notes written by people.
+const auditPrefix = "[minutes3 "
+
+// An audit records what Update did to one issue, so it can be written
+// to the issue's notes column in the spreadsheet.
+type audit struct {
+	date     time.Time
+	from, to string   // Status column; equal if unchanged
+	comments []string // URLs of posted comments
+}
+
+// line formats a as a single line for the notes column.
+func (a *audit) line() string {
+	var buf strings.Builder
+	fmt.Fprintf(&buf, "%s%s] ", auditPrefix, a.date.Format("2006-01-02"))
+	switch {
+	case a.from == a.to:
+		fmt.Fprintf(&buf, "%s (no change)", a.to)
+	case a.to == "none":
+		fmt.Fprintf(&buf, "%s → removed from project", a.from)
+	default:
+		fmt.Fprintf(&buf, "%s → %s", a.from, a.to)
+	}
+	for _, url := range a.comments {
+		fmt.Fprintf(&buf, "; %s", url)
+	}
+	return buf.String()
+}
+
+// update returns notes with a's audit line appended. If notes
+// already has an audit line for the same date and a records no
+// changes, for example because the tool is being rerun after fixing
+// a problem, update returns notes unchanged.
+func (a *audit) update(notes string) string {
+	line := a.line()
+	datePrefix := line[:strings.Index(line, "]")+1]
+	for _, l := range strings.Split(notes, "\n") {
+		if l == line || strings.HasPrefix(l, datePrefix) && a.from == a.to && len(a.comments) == 0 {
+			return notes
+		}
+	}
+	if notes == "" {
+		return line
+	}
+	return notes + "\n" + line
+}
+
+// addComment posts a comment on issue and returns the comment's URL.
+// It's like Client.AddIssueComment, but that doesn't return the
+// comment. With -dry-run, it records the comment in r.plan and
+// returns "". If an earlier run may have posted the comment but
+// didn't record doing so, addComment posts it only if the issue
+// doesn't already have it.
+func (r *Reporter) addComment(issue *github.Issue, text string) (string, error) {
+	graphql := `
+	  mutation($ID: ID!, $Text: String!) {
+	    addComment(input: {subjectId: $ID, body: $Text}) {
+	      commentEdge { node { url } }
+	    }
+	  }
+	`
+	if r.plan != nil {
+		r.plan.add(issue, "comment", "", text)
+		return "", nil
+	}
+	return r.journaled(issue.Number, "comment", text, func(started bool) (string, error) {
+		if started {
+			if ok, err := r.hasComment(issue, text); err != nil || ok {
+				return "", err
+			}
+		}
+		m, err := r.Client.GraphQLMutation(graphql, github.Vars{"ID": issue.ID, "Text": text})
+		if err != nil {
+			return "", err
+		}
+		if p := m.AddComment; p != nil && p.CommentEdge != nil && p.CommentEdge.Node != nil {
+			return string(p.CommentEdge.Node.Url), nil
+		}
+		return "", nil
+	})
+}
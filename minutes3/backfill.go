@@ -0,0 +1,115 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+
+	"google.golang.org/api/sheets/v4"
+)
+
+var (
+	backfill = flag.String("backfill", "", "print the minutes of the meeting on `date` (YYYY-MM-DD) from an archived spreadsheet, without changing anything")
+	snapshot = flag.String("snapshot", "", "with -backfill, read the spreadsheet from `file` (as written by -debugjson=save) instead of the archive")
+)
+
+// archivePath returns the path of the spreadsheet archived for the
+// meeting on date.
+func archivePath(date time.Time) string {
+	return filepath.Join(getCacheDir(), "archive", date.Format("2006-01-02")+".json")
+}
+
+// archiveSpreadsheet saves spreadsheet for the meeting on date, so
+// -backfill can reconstruct its minutes later. It's not an error if
+// this fails.
+func archiveSpreadsheet(date time.Time, spreadsheet *sheets.Spreadsheet) {
+	if date.IsZero() {
+		return
+	}
+	path := archivePath(date)
+	if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+		log.Printf("archiving spreadsheet: %v", err)
+		return
+	}
+	js, err := json.MarshalIndent(spreadsheet, "", "\t")
+	if err == nil {
+		err = os.WriteFile(path, append(js, '\n'), 0666)
+	}
+	if err != nil {
+		log.Printf("archiving spreadsheet: %v", err)
+	}
+}
+
+// runBackfill prints the minutes that would have been posted for the
+// meeting on the -backfill date. It uses only the archived
+// spreadsheet, so it doesn't consult or modify GitHub. Titles come
+// from the spreadsheet, and discussion links come from the comment
+// column, where the original run recorded them.
+func runBackfill() {
+	date, err := time.Parse("2006-01-02", *backfill)
+	if err != nil {
+		log.Fatalf("bad -backfill date: %v", err)
+	}
+	path := *snapshot
+	if path == "" {
+		path = archivePath(date)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	spreadsheet := new(sheets.Spreadsheet)
+	if err := json.Unmarshal(data, spreadsheet); err != nil {
+		log.Fatalf("%s: %v", path, err)
+	}
+	doc := new(Doc)
+	doc.parse(spreadsheet)
+	if got := doc.Date.Format("2006-01-02"); got != *backfill {
+		log.Fatalf("%s is for the meeting on %s, not %s", path, got, *backfill)
+	}
+
+	m := backfillMinutes(doc)
+	fmt.Printf("MINUTES FOR %s (RECONSTRUCTED FROM %s):\n\n", *backfill, path)
+	printMinutes(m, nil)
+}
+
+// backfillMinutes reconstructs the minutes for doc.
+func backfillMinutes(doc *Doc) *Minutes {
+	m := &Minutes{Date: doc.Date}
+	for _, w := range doc.Who {
+		m.Who = append(m.Who, gitWho(w))
+	}
+	sort.Strings(m.Who)
+
+	for _, di := range doc.Issues {
+		ia := parseActions(di)
+		if ia.todo || len(ia.actions) == 0 {
+			log.Printf("#%d: no actions recorded; skipping", di.Number)
+			continue
+		}
+		for i, a := range ia.actions {
+			if a != actionMap["discuss"] {
+				continue
+			}
+			if match := discussionLinkRE.FindStringSubmatch(di.Comment); match != nil {
+				n, _ := strconv.Atoi(match[1])
+				ia.actions[i] = discussionAction(a, n)
+			}
+		}
+		m.Events = append(m.Events, &Event{Column: ia.col, Issue: fmt.Sprint(di.Number), Title: di.Title, Actions: ia.actions})
+	}
+	sort.Slice(m.Events, func(i, j int) bool {
+		return m.Events[i].Title < m.Events[j].Title
+	})
+	return m
+}
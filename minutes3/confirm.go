@@ -0,0 +1,104 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+)
+
+var yes = flag.Bool("yes", false, "close issues and remove them from the project without asking for confirmation")
+
+// confirm asks for confirmation before a run closes issues or
+// removes them from the Proposals project, which are hard to undo.
+// To find exactly which issues a run would close or remove, it first
+// runs RetireOld and Update on a copy of doc as if for -dry-run. If
+// there are any, it lists them and asks whether to proceed, and
+// exits if the answer isn't yes.
+func (r *Reporter) confirm(doc *Doc) {
+	p := r.preview(doc)
+	var list []string
+	for _, ip := range p.issues {
+		for _, m := range ip.muts {
+			var what string
+			switch {
+			case m.what == "state" && m.to == "closed":
+				what = "close"
+			case m.what == "status" && m.to == "(removed from project)":
+				what = "remove from project"
+			default:
+				continue
+			}
+			list = append(list, fmt.Sprintf("\t%s https://go.dev/issue/%d %s\n", what, ip.number, ip.title))
+		}
+	}
+	if len(list) == 0 {
+		return
+	}
+
+	// Stdout is the minutes, so prompt on stderr.
+	fmt.Fprintf(os.Stderr, "This run will make %d destructive GitHub changes:\n%s", len(list), strings.Join(list, ""))
+	fmt.Fprintf(os.Stderr, "Proceed? [y/N] ")
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && err != io.EOF {
+		log.Fatal(err)
+	}
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return
+	}
+	log.Fatalf("no changes made; rerun with -yes to skip confirmation")
+}
+
+// preview runs RetireOld and Update on a copy of doc, recording the
+// GitHub mutations they would make instead of making them, and
+// returns the plan. The messages logged by the real run that repeat
+// the preview's are dropped, so problems are reported only once.
+func (r *Reporter) preview(doc *Doc) *plan {
+	d := *doc
+	d.pending = nil
+	d.Issues = make([]*Issue, len(doc.Issues))
+	for i, di := range doc.Issues {
+		c := *di
+		d.Issues[i] = &c
+	}
+
+	w := &dedupWriter{w: log.Writer(), seen: make(map[string]int), record: true}
+	log.SetOutput(w)
+	saveFailure := failure
+	r.plan = new(plan)
+	r.RetireOld()
+	r.Update(&d)
+	p := r.plan
+	r.plan = nil
+	failure = saveFailure
+	w.record = false
+	return p
+}
+
+// A dedupWriter writes messages to w. While record is set, it
+// remembers them, and once it's cleared, it drops each remembered
+// message the first time it's written again.
+type dedupWriter struct {
+	w      io.Writer
+	seen   map[string]int
+	record bool
+}
+
+func (d *dedupWriter) Write(p []byte) (int, error) {
+	s := string(p)
+	if d.record {
+		d.seen[s]++
+	} else if d.seen[s] > 0 {
+		d.seen[s]--
+		return len(p), nil
+	}
+	return d.w.Write(p)
+}
@@ -9,6 +9,7 @@ import (
 	"encoding/json"
 	"errors"
 	"flag"
+	"fmt"
 	"io/fs"
 	"log"
 	"os"
@@ -43,6 +44,9 @@ type Doc struct {
 	Text   []string // top-level text
 	Who    []string
 	Issues []*Issue
+
+	srv     *sheets.Service // nil if loaded from debug.json
+	pending []*sheets.ValueRange
 }
 
 type Issue struct {
@@ -52,13 +56,21 @@ type Issue struct {
 	Minutes string
 	Comment string
 	Notes   string
+
+	Row int `json:"-"` // 1-based spreadsheet row
 }
 
+const (
+	spreadsheetID = "1EG7oPcLls9HI_exlHLYuwk2YaN4P5mDc4O2vGyRqZHU"
+	sheetTitle    = "Proposals"
+)
+
 var (
 	debugJSON = flag.String("debugjson", "", "json debug mode (save, load)")
 )
 
 func parseDoc() *Doc {
+	d := new(Doc)
 	var spreadsheet *sheets.Spreadsheet
 	if *debugJSON == "load" {
 		spreadsheet = new(sheets.Spreadsheet)
@@ -71,7 +83,7 @@ func parseDoc() *Doc {
 		}
 	} else {
 		scopes := []string{
-			"https://www.googleapis.com/auth/spreadsheets.readonly",
+			"https://www.googleapis.com/auth/spreadsheets",
 		}
 		config := getOAuthConfig(scopes)
 		client := makeOAuthClient(getCacheDir(), config)
@@ -80,9 +92,7 @@ func parseDoc() *Doc {
 			log.Fatalf("Unable to retrieve Docs client: %v", err)
 		}
 
-		id := "1EG7oPcLls9HI_exlHLYuwk2YaN4P5mDc4O2vGyRqZHU"
-
-		spreadsheet, err = srv.Spreadsheets.Get(id).IncludeGridData(true).Do()
+		spreadsheet, err = srv.Spreadsheets.Get(spreadsheetID).IncludeGridData(true).Do()
 		if err != nil {
 			log.Fatalf("Unable to retrieve data from document: %v", err)
 		}
@@ -93,12 +103,28 @@ func parseDoc() *Doc {
 			os.WriteFile("debug.json", js, 0666)
 			os.Exit(0)
 		}
+		d.srv = srv
 	}
 
-	d := new(Doc)
+	d.parse(spreadsheet)
+	if d.Date.IsZero() {
+		log.Printf("spreadsheet Date: missing")
+		failure = true
+	} else if time.Since(d.Date) > 5*24*time.Hour || -time.Since(d.Date) > 24*time.Hour {
+		log.Printf("spreadsheet Date: too old")
+		failure = true
+	}
+	if d.srv != nil {
+		archiveSpreadsheet(d.Date, spreadsheet)
+	}
+	return d
+}
+
+// parse fills in d from the contents of spreadsheet.
+func (d *Doc) parse(spreadsheet *sheets.Spreadsheet) {
 	var sheet *sheets.Sheet
 	for _, s := range spreadsheet.Sheets {
-		if s.Properties.Title == "Proposals" {
+		if s.Properties.Title == sheetTitle {
 			sheet = s
 			break
 		}
@@ -113,11 +139,13 @@ func parseDoc() *Doc {
 		statusColumn  = column + 'B'
 		titleColumn   = column + 'D'
 		detailsColumn = column + 'E'
+		commentColumn = column + 'F'
+		notesColumn   = column + 'G'
 
 		metaColumn      = column + 'B'
 		metaValueColumn = column + 'D'
 
-		maxColumn = column + 'E'
+		maxColumn = column + 'G'
 	)
 	blank := 0
 	meta := true
@@ -167,6 +195,8 @@ func parseDoc() *Doc {
 			issue.Minutes = cells[statusColumn]
 			issue.Title = cells[titleColumn]
 			issue.Details = cells[detailsColumn]
+			issue.Comment = cells[commentColumn]
+			issue.Notes = cells[notesColumn]
 			num := cells[issueColumn]
 			if num == "" && issue == (Issue{}) {
 				blank++
@@ -183,17 +213,68 @@ func parseDoc() *Doc {
 				continue
 			}
 			issue.Number = n
+			issue.Row = r + 1
 			d.Issues = append(d.Issues, &issue)
 		}
 	}
+}
 
-	if d.Date.IsZero() {
-		log.Printf("spreadsheet Date: missing")
-		failure = true
-	} else if time.Since(d.Date) > 5*24*time.Hour || -time.Since(d.Date) > 24*time.Hour {
-		log.Printf("spreadsheet Date: too old")
+// SetComment queues an update of issue's comment column to comment.
+// The update is written to the spreadsheet by Flush.
+func (d *Doc) SetComment(issue *Issue, comment string) {
+	issue.Comment = comment
+	// Column F is commentColumn in parseDoc.
+	d.pending = append(d.pending, &sheets.ValueRange{
+		Range:  fmt.Sprintf("%s!F%d", sheetTitle, issue.Row),
+		Values: [][]interface{}{{comment}},
+	})
+}
+
+// SetMinutes queues an update of issue's minutes column to minutes.
+// The update is written to the spreadsheet by Flush.
+func (d *Doc) SetMinutes(issue *Issue, minutes string) {
+	issue.Minutes = minutes
+	// Column B is statusColumn in parseDoc.
+	d.pending = append(d.pending, &sheets.ValueRange{
+		Range:  fmt.Sprintf("%s!B%d", sheetTitle, issue.Row),
+		Values: [][]interface{}{{minutes}},
+	})
+}
+
+// SetNotes queues an update of issue's notes column to notes.
+// The update is written to the spreadsheet by Flush.
+func (d *Doc) SetNotes(issue *Issue, notes string) {
+	issue.Notes = notes
+	// Column G is notesColumn in parseDoc.
+	d.pending = append(d.pending, &sheets.ValueRange{
+		Range:  fmt.Sprintf("%s!G%d", sheetTitle, issue.Row),
+		Values: [][]interface{}{{notes}},
+	})
+}
+
+// Flush writes all queued updates to the spreadsheet.
+func (d *Doc) Flush() {
+	if len(d.pending) == 0 {
+		return
+	}
+	if d.srv == nil {
+		log.Printf("not writing %d spreadsheet updates in -debugjson=load mode", len(d.pending))
+		d.pending = nil
+		return
+	}
+	if *dryRun {
+		log.Printf("not writing %d spreadsheet updates in -dry-run mode", len(d.pending))
+		d.pending = nil
+		return
+	}
+	req := &sheets.BatchUpdateValuesRequest{
+		ValueInputOption: "RAW",
+		Data:             d.pending,
+	}
+	if _, err := d.srv.Spreadsheets.Values.BatchUpdate(spreadsheetID, req).Do(); err != nil {
+		log.Printf("updating spreadsheet: %v", err)
 		failure = true
+		return
 	}
-
-	return d
+	d.pending = nil
 }
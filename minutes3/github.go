@@ -0,0 +1,223 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// githubTransport is an http.RoundTripper for GitHub API requests that
+// retries requests that fail with a secondary rate limit or a server
+// error, backing off exponentially, and records the primary rate
+// limit reported by GitHub.
+//
+// Mutations are only retried if GitHub explicitly rejected them for
+// rate limiting. After a server or network error, GitHub may have
+// applied the mutation anyway, so retrying could post a comment or
+// make another change twice.
+//
+// rsc.io/github always uses http.DefaultClient, so NewReporter
+// installs this as its transport.
+type githubTransport struct {
+	base       http.RoundTripper
+	maxRetries int
+	minDelay   time.Duration
+	maxDelay   time.Duration
+
+	sleep func(time.Duration) // time.Sleep except in tests
+
+	mu        sync.Mutex
+	remaining int // -1 if unknown
+	limit     int
+	reset     time.Time
+}
+
+func newGitHubTransport(base http.RoundTripper) *githubTransport {
+	return &githubTransport{
+		base:       base,
+		maxRetries: 6,
+		minDelay:   2 * time.Second,
+		maxDelay:   5 * time.Minute,
+		sleep:      time.Sleep,
+		remaining:  -1,
+	}
+}
+
+func (t *githubTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Host != "api.github.com" {
+		return t.base.RoundTrip(req)
+	}
+	mutation := isMutation(req)
+	delay := t.minDelay
+	for try := 0; ; try++ {
+		req2 := req
+		if try > 0 && req.Body != nil {
+			if req.GetBody == nil {
+				// Can't replay the body.
+				return t.base.RoundTrip(req)
+			}
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req2 = req.Clone(req.Context())
+			req2.Body = body
+		}
+
+		resp, err := t.base.RoundTrip(req2)
+		var wait time.Duration
+		var why string
+		if err != nil {
+			if mutation {
+				return nil, err
+			}
+			why = err.Error()
+		} else {
+			t.record(resp.Header)
+			if mutation && resp.StatusCode >= 500 {
+				return resp, nil
+			}
+			wait, why, err = t.retryAfter(resp)
+			if err != nil {
+				return nil, err
+			}
+			if why == "" {
+				return resp, nil
+			}
+		}
+		if try == t.maxRetries {
+			return resp, err
+		}
+		if resp != nil {
+			resp.Body.Close()
+		}
+
+		if wait == 0 {
+			wait = delay
+			delay = min(2*delay, t.maxDelay)
+		}
+		log.Printf("github: %s; retrying in %s", why, wait.Round(time.Second))
+		t.sleep(wait)
+	}
+}
+
+// isMutation reports whether req may change state on GitHub. GraphQL
+// queries are sent as POSTs, so this looks at the query itself. If
+// it can't tell, it assumes req is a mutation.
+func isMutation(req *http.Request) bool {
+	switch req.Method {
+	case "GET", "HEAD":
+		return false
+	case "POST":
+		if req.URL.Path != "/graphql" || req.GetBody == nil {
+			return true
+		}
+	default:
+		return true
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return true
+	}
+	defer body.Close()
+	var gql struct {
+		Query string `json:"query"`
+	}
+	if err := json.NewDecoder(body).Decode(&gql); err != nil {
+		return true
+	}
+	// A GraphQL document with only an anonymous query may omit
+	// the "query" keyword.
+	q := strings.TrimSpace(gql.Query)
+	return !strings.HasPrefix(q, "query") && !strings.HasPrefix(q, "{")
+}
+
+// retryAfter returns whether resp should be retried and why, and how
+// long to wait first, or 0 to use the exponential backoff delay. It
+// buffers resp's body so it can be read again.
+func (t *githubTransport) retryAfter(resp *http.Response) (time.Duration, string, error) {
+	switch {
+	case resp.StatusCode >= 500:
+		return 0, resp.Status, nil
+	case resp.StatusCode != http.StatusForbidden && resp.StatusCode != http.StatusTooManyRequests:
+		return 0, "", nil
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return 0, "", err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	if s := resp.Header.Get("Retry-After"); s != "" {
+		if secs, err := strconv.Atoi(s); err == nil {
+			return time.Duration(secs) * time.Second, resp.Status + " (retry after)", nil
+		}
+	}
+	if bytes.Contains(body, []byte("secondary rate limit")) {
+		return 0, resp.Status + " (secondary rate limit)", nil
+	}
+	if resp.Header.Get("X-RateLimit-Remaining") == "0" {
+		if reset, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+			wait := time.Until(time.Unix(reset, 0)) + time.Second
+			return max(wait, t.minDelay), resp.Status + " (rate limit exhausted)", nil
+		}
+	}
+	return 0, "", nil
+}
+
+// record records the rate limit state reported in h.
+func (t *githubTransport) record(h http.Header) {
+	remaining, err1 := strconv.Atoi(h.Get("X-RateLimit-Remaining"))
+	limit, err2 := strconv.Atoi(h.Get("X-RateLimit-Limit"))
+	reset, err3 := strconv.ParseInt(h.Get("X-RateLimit-Reset"), 10, 64)
+	if err1 != nil || err2 != nil || err3 != nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.remaining, t.limit, t.reset = remaining, limit, time.Unix(reset, 0)
+}
+
+// rateLimit returns the most recently reported GitHub rate limit.
+// remaining is -1 if no limit has been reported.
+func (t *githubTransport) rateLimit() (remaining, limit int, reset time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.remaining, t.limit, t.reset
+}
+
+// maxMutationsPerIssue is an upper bound on the GitHub mutations
+// Update makes for one issue: a check comment, a status change and its
+// comment, a retitle, a remilestone, a close, and three labels.
+const maxMutationsPerIssue = 9
+
+// checkBudget warns if GitHub's remaining rate limit may not cover
+// the mutations needed to apply doc, so a run doesn't stop with
+// changes half-applied.
+func (r *Reporter) checkBudget(doc *Doc) {
+	remaining, limit, reset := r.transport.rateLimit()
+	if remaining < 0 {
+		return
+	}
+	need := maxMutationsPerIssue * len(doc.Issues)
+	for _, item := range r.Items {
+		if r.retire(item) {
+			need++
+		}
+	}
+	if remaining < need {
+		log.Printf("warning: GitHub rate limit has %d of %d points left until %s, but this run may need up to %d", remaining, limit, reset.Format(time.Kitchen), need)
+	}
+}
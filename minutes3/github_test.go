@@ -0,0 +1,205 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRetryAfter(t *testing.T) {
+	reset := time.Now().Add(time.Minute).Unix()
+	for _, test := range []struct {
+		status int
+		header map[string]string
+		body   string
+		wait   time.Duration // -1 means about a minute
+		why    string
+	}{
+		{200, nil, "", 0, ""},
+		{404, nil, "", 0, ""},
+		{500, nil, "", 0, "500 Internal Server Error"},
+		{502, nil, "", 0, "502 Bad Gateway"},
+		// A 403 without a rate limit signal is a real error.
+		{403, nil, "Resource not accessible", 0, ""},
+		{403, map[string]string{"Retry-After": "30"}, "", 30 * time.Second, "403 Forbidden (retry after)"},
+		{429, map[string]string{"Retry-After": "5"}, "", 5 * time.Second, "429 Too Many Requests (retry after)"},
+		{403, map[string]string{"Retry-After": "soon"}, "", 0, ""},
+		{403, nil, `{"message":"You have exceeded a secondary rate limit."}`, 0, "403 Forbidden (secondary rate limit)"},
+		{403, map[string]string{"X-RateLimit-Remaining": "0", "X-RateLimit-Reset": strconv.FormatInt(reset, 10)}, "", -1, "403 Forbidden (rate limit exhausted)"},
+		// A reset in the past waits the minimum delay.
+		{429, map[string]string{"X-RateLimit-Remaining": "0", "X-RateLimit-Reset": "1"}, "", 2 * time.Second, "429 Too Many Requests (rate limit exhausted)"},
+		{403, map[string]string{"X-RateLimit-Remaining": "1", "X-RateLimit-Reset": "1"}, "", 0, ""},
+	} {
+		resp := &http.Response{
+			StatusCode: test.status,
+			Status:     fmt.Sprintf("%d %s", test.status, http.StatusText(test.status)),
+			Header:     make(http.Header),
+			Body:       io.NopCloser(strings.NewReader(test.body)),
+		}
+		for k, v := range test.header {
+			resp.Header.Set(k, v)
+		}
+		tr := newGitHubTransport(nil)
+		wait, why, err := tr.retryAfter(resp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if test.wait == -1 {
+			if wait < 50*time.Second || wait > 70*time.Second {
+				t.Errorf("%d %v: wait %s, want about 1m", test.status, test.header, wait)
+			}
+		} else if wait != test.wait {
+			t.Errorf("%d %v: wait %s, want %s", test.status, test.header, wait, test.wait)
+		}
+		if why != test.why {
+			t.Errorf("%d %v: why %q, want %q", test.status, test.header, why, test.why)
+		}
+		// The body can still be read.
+		if body, _ := io.ReadAll(resp.Body); string(body) != test.body {
+			t.Errorf("%d %v: body %q after retryAfter, want %q", test.status, test.header, body, test.body)
+		}
+	}
+}
+
+func TestIsMutation(t *testing.T) {
+	for _, test := range []struct {
+		method, path, body string
+		want               bool
+	}{
+		{"GET", "/graphql", "", false},
+		{"GET", "/repos/golang/go/issues", "", false},
+		{"POST", "/graphql", `{"query":"\n  query($Org: String!) { x }"}`, false},
+		{"POST", "/graphql", `{"query":"{ viewer { login } }"}`, false},
+		{"POST", "/graphql", `{"query":"\n  mutation($Body: String!) { addComment }"}`, true},
+		{"POST", "/graphql", `not json`, true},
+		{"POST", "/repos/golang/go/issues", `{}`, true},
+		{"PATCH", "/repos/golang/go/issues/1", `{}`, true},
+	} {
+		var body io.Reader
+		if test.body != "" {
+			body = strings.NewReader(test.body)
+		}
+		req, err := http.NewRequest(test.method, "https://api.github.com"+test.path, body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := isMutation(req); got != test.want {
+			t.Errorf("isMutation(%s %s %s) = %v, want %v", test.method, test.path, test.body, got, test.want)
+		}
+	}
+}
+
+// redirectTransport sends all requests to srv.
+type redirectTransport struct {
+	srv *url.URL
+}
+
+func (r redirectTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.URL.Scheme, req.URL.Host = r.srv.Scheme, r.srv.Host
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestGitHubTransportRetry(t *testing.T) {
+	const (
+		query    = `{"query":"query { viewer { login } }"}`
+		mutation = `{"query":"mutation { addComment(input: {}) { clientMutationId } }"}`
+	)
+	// The server sends responses in order. Each is "ok", "500",
+	// "secondary" for a secondary rate limit, or "drop" to close
+	// the connection without responding.
+	for _, test := range []struct {
+		name      string
+		method    string
+		body      string
+		responses []string
+		status    int // 0 for an error
+		requests  int
+		sleeps    []time.Duration
+	}{
+		{"get", "GET", "", []string{"500", "500", "ok"}, 200, 3, []time.Duration{1 * time.Second, 2 * time.Second}},
+		{"query", "POST", query, []string{"500", "ok"}, 200, 2, []time.Duration{1 * time.Second}},
+		{"query-drop", "POST", query, []string{"drop", "ok"}, 200, 2, []time.Duration{1 * time.Second}},
+		{"query-limit", "POST", query, []string{"secondary", "ok"}, 200, 2, []time.Duration{1 * time.Second}},
+		{"give-up", "GET", "", []string{"500", "500", "500", "500", "500"}, 500, 4, []time.Duration{1 * time.Second, 2 * time.Second, 3 * time.Second}},
+		{"mutation", "POST", mutation, []string{"ok"}, 200, 1, nil},
+		{"mutation-500", "POST", mutation, []string{"500", "ok"}, 500, 1, nil},
+		{"mutation-drop", "POST", mutation, []string{"drop", "ok"}, 0, 1, nil},
+		{"mutation-limit", "POST", mutation, []string{"secondary", "secondary", "ok"}, 200, 3, []time.Duration{1 * time.Second, 2 * time.Second}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var n int
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				if string(body) != test.body {
+					t.Errorf("request %d has body %q, want %q", n, body, test.body)
+				}
+				resp := test.responses[n]
+				n++
+				switch resp {
+				case "ok":
+					fmt.Fprintf(w, `{"data":{}}`)
+				case "500":
+					w.WriteHeader(500)
+				case "secondary":
+					w.WriteHeader(403)
+					fmt.Fprintf(w, `{"message":"You have exceeded a secondary rate limit."}`)
+				case "drop":
+					conn, _, err := w.(http.Hijacker).Hijack()
+					if err != nil {
+						t.Fatal(err)
+					}
+					conn.Close()
+				}
+			}))
+			defer srv.Close()
+			srvURL, _ := url.Parse(srv.URL)
+
+			tr := newGitHubTransport(redirectTransport{srvURL})
+			tr.maxRetries = 3
+			tr.minDelay = 1 * time.Second
+			tr.maxDelay = 3 * time.Second
+			var sleeps []time.Duration
+			tr.sleep = func(d time.Duration) { sleeps = append(sleeps, d) }
+
+			var body io.Reader
+			if test.body != "" {
+				body = strings.NewReader(test.body)
+			}
+			req, err := http.NewRequest(test.method, "https://api.github.com/graphql", body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := tr.RoundTrip(req)
+			if test.status == 0 {
+				if err == nil {
+					resp.Body.Close()
+					t.Errorf("got status %d, want error", resp.StatusCode)
+				}
+			} else if err != nil {
+				t.Errorf("got error %v, want status %d", err, test.status)
+			} else {
+				resp.Body.Close()
+				if resp.StatusCode != test.status {
+					t.Errorf("got status %d, want %d", resp.StatusCode, test.status)
+				}
+			}
+			if n != test.requests {
+				t.Errorf("server got %d requests, want %d", n, test.requests)
+			}
+			if fmt.Sprint(sleeps) != fmt.Sprint(test.sleeps) {
+				t.Errorf("slept %v, want %v", sleeps, test.sleeps)
+			}
+		})
+	}
+}
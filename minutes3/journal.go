@@ -0,0 +1,264 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"rsc.io/github"
+)
+
+var resume = flag.Bool("resume", false, "finish the GitHub changes left incomplete by an earlier run for the same meeting")
+
+// A journal records each GitHub mutation of a run before and after
+// making it, so that if a run fails partway, -resume can tell which
+// mutations were made and finish the rest.
+//
+// The journal of the meeting on a date is kept in
+// ~/.cache/proposal-minutes/journal/YYYY-MM-DD.jsonl. Each line is a
+// journalEntry. Runs append to it, so it also records the history of
+// every run for that meeting.
+type journal struct {
+	path string
+	f    *os.File
+
+	// state is the last recorded state of each action. Without
+	// -resume, it's only used to find incomplete actions and is
+	// then cleared, so earlier runs don't affect this one.
+	state map[journalKey]*journalEntry
+
+	// pending lists the actions left incomplete by earlier runs, in
+	// the order they were first attempted.
+	pending []journalKey
+
+	// attempted records the actions attempted by this run.
+	attempted map[journalKey]bool
+}
+
+// A journalKey identifies a GitHub mutation.
+type journalKey struct {
+	Issue  int
+	Action string // status, delete, label, unlabel, close, retitle, milestone, or comment
+	Arg    string `json:",omitempty"` // Status, label, title, milestone, or comment text
+}
+
+// Journal entry states.
+const (
+	journalStart  = "start"
+	journalDone   = "done"
+	journalFailed = "failed"
+)
+
+// A journalEntry is one line of a journal.
+type journalEntry struct {
+	Time time.Time
+	journalKey
+	State string
+	URL   string `json:",omitempty"` // URL of a posted comment
+	Err   string `json:",omitempty"`
+}
+
+// openJournal opens the journal of the meeting on date. If resuming
+// is false and the journal has incomplete actions, it fails, since
+// computing those actions again from the now partly updated issues
+// may lose some of them.
+func openJournal(date time.Time, resuming bool) *journal {
+	j := &journal{
+		path:      filepath.Join(getCacheDir(), "journal", date.Format("2006-01-02")+".jsonl"),
+		state:     make(map[journalKey]*journalEntry),
+		attempted: make(map[journalKey]bool),
+	}
+	data, err := os.ReadFile(j.path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		log.Fatal(err)
+	}
+	if err != nil && resuming {
+		log.Fatalf("-resume: no journal %s", j.path)
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		e := new(journalEntry)
+		if err := json.Unmarshal([]byte(line), e); err != nil {
+			// Probably truncated by a crash.
+			continue
+		}
+		if j.state[e.journalKey] == nil {
+			j.pending = append(j.pending, e.journalKey)
+		}
+		j.state[e.journalKey] = e
+	}
+	pending := j.pending[:0]
+	for _, k := range j.pending {
+		if j.state[k].State != journalDone {
+			pending = append(pending, k)
+		}
+	}
+	j.pending = pending
+
+	if !resuming {
+		if len(j.pending) > 0 {
+			log.Fatalf("%s: %d GitHub changes left incomplete by an earlier run; rerun with -resume to finish them, or remove the journal to start over", j.path, len(j.pending))
+		}
+		j.state = make(map[journalKey]*journalEntry)
+	} else if len(j.pending) == 0 {
+		log.Printf("%s: no incomplete GitHub changes", j.path)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(j.path), 0777); err != nil {
+		log.Fatal(err)
+	}
+	j.f, err = os.OpenFile(j.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return j
+}
+
+// write appends e to the journal. If the journal can't be written,
+// it's not safe to continue, since a later -resume would redo or
+// lose the action.
+func (j *journal) write(e *journalEntry) {
+	e.Time = time.Now()
+	js, err := json.Marshal(e)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if _, err := j.f.Write(append(js, '\n')); err != nil {
+		log.Fatalf("writing journal: %v", err)
+	}
+	j.state[e.journalKey] = e
+}
+
+// do performs the mutation k by calling f, recording it in the
+// journal before and after. If an earlier run already did k, do
+// skips it and returns the comment URL it recorded. f's started
+// argument reports that an earlier run attempted k without recording
+// its success, so k may or may not have happened.
+func (j *journal) do(k journalKey, f func(started bool) (string, error)) (string, error) {
+	j.attempted[k] = true
+	prev := j.state[k]
+	if prev != nil && prev.State == journalDone {
+		return prev.URL, nil
+	}
+	started := prev != nil
+	j.write(&journalEntry{journalKey: k, State: journalStart})
+	url, err := f(started)
+	if err != nil {
+		j.write(&journalEntry{journalKey: k, State: journalFailed, Err: err.Error()})
+		return "", err
+	}
+	j.write(&journalEntry{journalKey: k, State: journalDone, URL: url})
+	return url, nil
+}
+
+// Close closes the journal file.
+func (j *journal) Close() error {
+	return j.f.Close()
+}
+
+// journaled performs a GitHub mutation by calling f, recording it in
+// r.journal if there is one.
+func (r *Reporter) journaled(issue int, action, arg string, f func(started bool) (string, error)) (string, error) {
+	if r.journal == nil {
+		return f(false)
+	}
+	return r.journal.do(journalKey{issue, action, arg}, f)
+}
+
+// Resume finishes the actions left incomplete by earlier runs that
+// this run didn't already redo. Most likely, they were changes
+// Update doesn't redo because it sees they are unnecessary, such as
+// the comment explaining a status change that was made. Each kind of
+// change is safe to make twice except comments, which addComment
+// checks for.
+func (r *Reporter) Resume() {
+	for _, k := range r.journal.pending {
+		if r.journal.attempted[k] {
+			continue
+		}
+		url := "https://go.dev/issue/" + fmt.Sprint(k.Issue)
+		what := k.Action
+		if k.Action != "comment" && k.Arg != "" {
+			what += " " + k.Arg
+		}
+		log.Printf("%s: resuming %s", url, what)
+		item := r.Items[k.Issue]
+		if item == nil {
+			if k.Action == "delete" {
+				// Already deleted.
+				r.journal.do(k, func(bool) (string, error) { return "", nil })
+				continue
+			}
+			log.Printf("%s: %s: not in proposal project", url, what)
+			failure = true
+			continue
+		}
+		if err := r.redo(item, k); err != nil {
+			log.Printf("%s: %s: %v", url, what, err)
+			failure = true
+		}
+	}
+}
+
+// redo makes the change k to item.
+func (r *Reporter) redo(item *github.ProjectItem, k journalKey) error {
+	issue := item.Issue
+	switch k.Action {
+	case "status":
+		f := r.Proposals.FieldByName("Status")
+		o := f.OptionByName(k.Arg)
+		if o == nil {
+			return fmt.Errorf("no such status")
+		}
+		return r.setStatus(item, "", f, o)
+	case "delete":
+		return r.deleteItem(item, "")
+	case "label", "unlabel":
+		lab := r.Labels[k.Arg]
+		if lab == nil {
+			return fmt.Errorf("cannot find label")
+		}
+		if k.Action == "label" {
+			return r.addLabel(issue, lab)
+		}
+		return r.removeLabel(issue, lab)
+	case "close":
+		return r.closeIssue(issue)
+	case "retitle":
+		return r.retitle(issue, k.Arg)
+	case "milestone":
+		if k.Arg != r.Backlog.Title {
+			return fmt.Errorf("unexpected milestone")
+		}
+		return r.remilestone(issue, r.Backlog)
+	case "comment":
+		_, err := r.addComment(issue, k.Arg)
+		return err
+	}
+	return fmt.Errorf("unknown action")
+}
+
+// hasComment reports whether issue already has a comment with body
+// text, for redoing a comment that may or may not have been posted.
+func (r *Reporter) hasComment(issue *github.Issue, text string) (bool, error) {
+	comments, err := r.Client.IssueComments(issue)
+	if err != nil {
+		return false, err
+	}
+	for _, c := range comments {
+		if strings.TrimSpace(c.Body) == strings.TrimSpace(text) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
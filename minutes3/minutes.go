@@ -16,9 +16,12 @@ import (
 	"fmt"
 	"io/fs"
 	"log"
+	"net/http"
 	"os"
 	"path/filepath"
+	"regexp"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -27,6 +30,7 @@ import (
 
 var docjson = flag.Bool("docjson", false, "print google doc info in json")
 var doccsv = flag.Bool("doccsv", false, "print google doc info in json")
+var writeBack = flag.Bool("writeback", false, "write normalized actions back to the spreadsheet's minutes column")
 
 var failure = false
 
@@ -35,6 +39,16 @@ func main() {
 	log.SetFlags(0)
 
 	flag.Parse()
+	if *dryRunFormat != "text" && *dryRunFormat != "markdown" {
+		log.Fatalf("unknown -dry-run-format %q", *dryRunFormat)
+	}
+	if *resume && *dryRun {
+		log.Fatalf("-resume and -dry-run are incompatible")
+	}
+	if *backfill != "" {
+		runBackfill()
+		return
+	}
 	doc := parseDoc()
 	if *docjson {
 		js, err := json.MarshalIndent(doc, "", "\t")
@@ -59,14 +73,43 @@ func main() {
 	if err != nil {
 		log.Fatal(err)
 	}
+	r.checkBudget(doc)
+	if r.plan == nil && !*yes && !*resume {
+		// Changes left by an earlier run were confirmed then.
+		r.confirm(doc)
+	}
+	if r.plan == nil {
+		r.journal = openJournal(doc.Date, *resume)
+		defer r.journal.Close()
+	}
 	r.RetireOld()
 
 	minutes := r.Update(doc)
+	if *resume {
+		r.Resume()
+	}
+	doc.Flush()
 	if failure {
 		return
 	}
-	fmt.Printf("TO POST TO https://go.dev/s/proposal-minutes:\n\n")
+	if r.plan != nil {
+		r.plan.write(os.Stdout, *dryRunFormat == "markdown")
+		fmt.Printf("\nWOULD POST TO %s:\n\n", minutesURL)
+		r.Print(minutes)
+		return
+	}
+	// Print consumes the events, so summarize them first.
+	summary := summarize(minutes)
+	fmt.Printf("TO POST TO %s:\n\n", minutesURL)
 	r.Print(minutes)
+
+	if url := webhookURL(); url != "" {
+		if err := postWebhook(url, summary); err != nil {
+			log.Printf("posting summary to webhook: %v", err)
+		} else {
+			log.Printf("posted summary to webhook")
+		}
+	}
 }
 
 func getConfig(path ...string) string {
@@ -95,6 +138,11 @@ type Reporter struct {
 	Items     map[int]*github.ProjectItem
 	Labels    map[string]*github.Label
 	Backlog   *github.Milestone
+
+	transport      *githubTransport
+	discussionList []*github.Discussion
+	plan           *plan    // non-nil with -dry-run
+	journal        *journal // non-nil without -dry-run
 }
 
 func NewReporter() (*Reporter, error) {
@@ -107,9 +155,17 @@ func NewReporter() (*Reporter, error) {
 	}
 	token = bytes.TrimSpace(token)
 
+	// rsc.io/github always uses http.DefaultClient. Retry
+	// failures there instead of failing partway through a run.
+	t := newGitHubTransport(http.DefaultTransport)
+	http.DefaultClient.Transport = t
+
 	c := github.NewClient(string(token))
 
-	r := &Reporter{Client: c}
+	r := &Reporter{Client: c, transport: t}
+	if *dryRun {
+		r.plan = new(plan)
+	}
 
 	ps, err := r.Client.Projects("golang", "")
 	if err != nil {
@@ -221,81 +277,54 @@ Issues:
 		}
 
 		url := "https://go.dev/issue/" + fmt.Sprint(di.Number)
-		actions := strings.Split(di.Minutes, ";")
-		if len(actions) == 1 && actions[0] == "" {
-			actions = nil
+		ia := parseActions(di)
+		if ia.todo {
+			log.Printf("%s: minutes TODO", url)
+			failure = true
+			continue Issues
 		}
-		if len(actions) == 0 {
-			log.Printf("#%d missing action")
+		if len(ia.actions) == 0 {
+			log.Printf("#%d missing action", di.Number)
 			failure = true
 		}
-		col := "Active"
-		reason := ""
-		check := false
-		for i, a := range actions {
-			a = strings.TrimSpace(a)
-			actions[i] = a
-			switch a {
-			case "TODO":
-				log.Printf("%s: minutes TODO", url)
-				failure = true
-				continue Issues
-			case "accept":
-				a = "accepted"
-			case "decline":
-				a = "declined"
-			case "retract":
-				a = "retracted"
-			case "declined as infeasible":
-				a = "infeasible"
-			case "check":
-				check = true
-				a = "comment"
-			}
+		col, reason, check, actions, normalized := ia.col, ia.reason, ia.check, ia.actions, ia.normalized
 
-			switch a {
-			case "likely accept":
-				col = "Likely Accept"
-			case "likely decline":
-				col = "Likely Decline"
-			case "accepted":
-				col = "Accepted"
-			case "declined":
-				col = "Declined"
-			case "retracted":
-				col = "Declined"
-				reason = "retracted"
-			case "unhold":
-				col = "Active"
-				reason = "unhold"
-			}
-			if strings.HasPrefix(a, "declined") {
-				col = "Declined"
-			}
-			if strings.HasPrefix(a, "duplicate") {
-				col = "Declined"
-				reason = "duplicate"
+		if *writeBack {
+			if m := strings.Join(normalized, "; "); m != di.Minutes {
+				doc.SetMinutes(di, m)
 			}
-			if strings.Contains(a, "infeasible") {
-				col = "Declined"
-				reason = "infeasible"
-			}
-			if a == "obsolete" || strings.Contains(a, "obsoleted") {
-				col = "Declined"
-				reason = "obsolete"
+		}
+
+		for i, a := range actions {
+			if a != actionMap["discuss"] {
+				continue
 			}
-			if strings.HasPrefix(a, "closed") {
-				col = "Declined"
+			n := r.findDiscussion(issue)
+			if n == 0 {
+				log.Printf("%s: no discussion found", url)
+				continue
 			}
-			if strings.HasPrefix(a, "hold") || a == "on hold" {
-				col = "Hold"
+			durl := discussionURL(n)
+			actions[i] = discussionAction(a, n)
+			if !strings.Contains(di.Comment, durl) {
+				comment := durl
+				if di.Comment != "" {
+					comment = di.Comment + "\n" + durl
+				}
+				doc.SetComment(di, comment)
 			}
-			if r := actionMap[a]; r != "" {
-				actions[i] = r
+		}
+
+		ad := &audit{date: doc.Date, from: status.Option.Name, to: col}
+		postComment := func(msg string) {
+			curl, err := r.addComment(issue, msg)
+			if err != nil {
+				log.Printf("%s: posting comment: %v", url, err)
+				failure = true
+				return
 			}
-			if strings.HasPrefix(a, "removed") {
-				col = "none"
-				reason = "removed"
+			if curl != "" {
+				ad.comments = append(ad.comments, curl)
 			}
 		}
 
@@ -321,11 +350,10 @@ Issues:
 			}
 			msg := fmt.Sprintf("%s\n\n%s", checkQuestion, di.Details)
 			// log.Fatalf("wouldpost %s\n%s", url, msg)
-			if err := r.Client.AddIssueComment(issue, msg); err != nil {
-				log.Printf("%s: posting comment: %v", url, err)
-				failure = true
+			postComment(msg)
+			if r.plan == nil {
+				log.Printf("posted %s", url)
 			}
-			log.Printf("posted %s", url)
 		}
 
 		if status.Option.Name != col {
@@ -343,7 +371,7 @@ Issues:
 			}
 			f := r.Proposals.FieldByName("Status")
 			if col == "none" {
-				if err := r.Client.DeleteProjectItem(r.Proposals, item); err != nil {
+				if err := r.deleteItem(item, status.Option.Name); err != nil {
 					log.Printf("%s: deleting proposal item: %v", url, err)
 					failure = true
 					continue
@@ -355,15 +383,12 @@ Issues:
 					failure = true
 					continue
 				}
-				if err := r.Client.SetProjectItemFieldOption(r.Proposals, item, f, o); err != nil {
+				if err := r.setStatus(item, status.Option.Name, f, o); err != nil {
 					log.Printf("%s: moving from %s to %s: %v\n", url, status.Option.Name, col, err)
 					failure = true
 				}
 			}
-			if err := r.Client.AddIssueComment(issue, msg); err != nil {
-				log.Printf("%s: posting comment: %v", url, err)
-				failure = true
-			}
+			postComment(msg)
 		}
 
 		needLabel := func(name string) {
@@ -372,7 +397,7 @@ Issues:
 				if lab == nil {
 					log.Fatalf("%s: cannot find label %s", url, name)
 				}
-				if err := r.Client.AddIssueLabels(issue, lab); err != nil {
+				if err := r.addLabel(issue, lab); err != nil {
 					log.Printf("%s: adding %s: %v", url, name, err)
 					failure = true
 				}
@@ -381,7 +406,7 @@ Issues:
 
 		dropLabel := func(name string) {
 			if lab := issue.LabelByName(name); lab != nil {
-				if err := r.Client.RemoveIssueLabels(issue, lab); err != nil {
+				if err := r.removeLabel(issue, lab); err != nil {
 					log.Printf("%s: removing %s: %v", url, name, err)
 					failure = true
 				}
@@ -398,7 +423,7 @@ Issues:
 
 		forceClose := func() {
 			if !issue.Closed {
-				if err := r.Client.CloseIssue(issue); err != nil {
+				if err := r.closeIssue(issue); err != nil {
 					log.Printf("%s: closing issue: %v", url, err)
 					failure = true
 				}
@@ -407,13 +432,13 @@ Issues:
 
 		if col == "Accepted" {
 			if strings.HasPrefix(issue.Title, "proposal:") {
-				if err := r.Client.RetitleIssue(issue, title); err != nil {
+				if err := r.retitle(issue, title); err != nil {
 					log.Printf("%s: retitling: %v", url, err)
 					failure = true
 				}
 			}
 			if issue.Milestone == nil || issue.Milestone.Title == "Proposal" {
-				if err := r.Client.RemilestoneIssue(issue, r.Backlog); err != nil {
+				if err := r.remilestone(issue, r.Backlog); err != nil {
 					log.Printf("%s: moving out of Proposal milestone: %v", url, err)
 					failure = true
 				}
@@ -427,6 +452,10 @@ Issues:
 		setLabel("Proposal-FinalCommentPeriod", col == "Likely Accept" || col == "Likely Decline")
 		setLabel("Proposal-Hold", col == "Hold")
 
+		if notes := ad.update(di.Notes); notes != di.Notes {
+			doc.SetNotes(di, notes)
+		}
+
 		m.Events = append(m.Events, &Event{Column: col, Issue: fmt.Sprint(di.Number), Title: title, Actions: actions})
 	}
 
@@ -449,7 +478,111 @@ Issues:
 	return m
 }
 
+// issueActions is the result of parsing an issue's minutes column.
+type issueActions struct {
+	col        string   // New project status, or "none" to remove it
+	reason     string   // Reason for the status change, for updateMsg
+	check      bool     // Post the checkQuestion
+	todo       bool     // The minutes say TODO
+	actions    []string // Actions for the posted minutes
+	normalized []string // Actions for -writeback
+}
+
+// parseActions parses the semicolon-separated actions in di's minutes
+// column and determines the issue's new status.
+func parseActions(di *Issue) *issueActions {
+	ia := new(issueActions)
+	actions := strings.Split(di.Minutes, ";")
+	if len(actions) == 1 && actions[0] == "" {
+		actions = nil
+	}
+	col := "Active"
+	reason := ""
+	check := false
+	normalized := make([]string, len(actions))
+	for i, a := range actions {
+		a = strings.TrimSpace(a)
+		actions[i] = a
+		normalized[i] = a
+		switch a {
+		case "TODO":
+			ia.todo = true
+			return ia
+		case "accept":
+			a = "accepted"
+		case "decline":
+			a = "declined"
+		case "retract":
+			a = "retracted"
+		case "declined as infeasible":
+			a = "infeasible"
+		case "check":
+			check = true
+			a = "comment"
+		}
+		if a != "comment" {
+			// Keep "check" since it means more
+			// than "comment".
+			normalized[i] = a
+		}
+
+		switch a {
+		case "likely accept":
+			col = "Likely Accept"
+		case "likely decline":
+			col = "Likely Decline"
+		case "accepted":
+			col = "Accepted"
+		case "declined":
+			col = "Declined"
+		case "retracted":
+			col = "Declined"
+			reason = "retracted"
+		case "unhold":
+			col = "Active"
+			reason = "unhold"
+		}
+		if strings.HasPrefix(a, "declined") {
+			col = "Declined"
+		}
+		if strings.HasPrefix(a, "duplicate") {
+			col = "Declined"
+			reason = "duplicate"
+		}
+		if strings.Contains(a, "infeasible") {
+			col = "Declined"
+			reason = "infeasible"
+		}
+		if a == "obsolete" || strings.Contains(a, "obsoleted") {
+			col = "Declined"
+			reason = "obsolete"
+		}
+		if strings.HasPrefix(a, "closed") {
+			col = "Declined"
+		}
+		if strings.HasPrefix(a, "hold") || a == "on hold" {
+			col = "Hold"
+		}
+		if r := actionMap[a]; r != "" {
+			actions[i] = r
+		}
+		if strings.HasPrefix(a, "removed") {
+			col = "none"
+			reason = "removed"
+		}
+	}
+	ia.col, ia.reason, ia.check = col, reason, check
+	ia.actions, ia.normalized = actions, normalized
+	return ia
+}
+
 func (r *Reporter) Print(m *Minutes) {
+	printMinutes(m, r.discussions())
+}
+
+// printMinutes prints m as Markdown, listing the open discussions that
+// aren't yet proposals first.
+func printMinutes(m *Minutes, discussions []*github.Discussion) {
 	var buf bytes.Buffer
 
 	fmt.Fprintf(&buf, "**%s / ", m.Date.Format("2006-01-02"))
@@ -461,12 +594,8 @@ func (r *Reporter) Print(m *Minutes) {
 	}
 	fmt.Fprintf(&buf, "**\n\n")
 
-	disc, err := r.Client.Discussions("golang", "go")
-	if err != nil {
-		log.Fatal(err)
-	}
 	first := true
-	for _, d := range disc {
+	for _, d := range discussions {
 		if d.Locked {
 			continue
 		}
@@ -522,6 +651,57 @@ func (r *Reporter) Print(m *Minutes) {
 	os.Stdout.Write(buf.Bytes())
 }
 
+// discussions returns the GitHub Discussions in golang/go.
+func (r *Reporter) discussions() []*github.Discussion {
+	if r.discussionList == nil {
+		disc, err := r.Client.Discussions("golang", "go")
+		if err != nil {
+			log.Fatal(err)
+		}
+		r.discussionList = disc
+	}
+	return r.discussionList
+}
+
+func discussionURL(n int) string {
+	return fmt.Sprintf("https://github.com/golang/go/discussions/%d", n)
+}
+
+// discussionAction returns action a with a link to discussion n.
+func discussionAction(a string, n int) string {
+	return fmt.Sprintf("%s in [discussion #%d](%s)", a, n, discussionURL(n))
+}
+
+var discussionLinkRE = regexp.MustCompile(`github\.com/golang/go/discussions/(\d+)`)
+
+// findDiscussion returns the number of the GitHub Discussion
+// corresponding to issue, or 0 if there is none. It prefers a
+// discussion linked from the issue body and otherwise looks for a
+// discussion with the same title.
+func (r *Reporter) findDiscussion(issue *github.Issue) int {
+	if m := discussionLinkRE.FindStringSubmatch(issue.Body); m != nil {
+		n, _ := strconv.Atoi(m[1])
+		return n
+	}
+	title := normalizeTitle(issue.Title)
+	for _, d := range r.discussions() {
+		if normalizeTitle(d.Title) == title {
+			return d.Number
+		}
+	}
+	return 0
+}
+
+// normalizeTitle canonicalizes an issue or discussion title for
+// comparison.
+func normalizeTitle(title string) string {
+	title = strings.ToLower(strings.TrimSpace(title))
+	for _, prefix := range []string{"proposal:", "discussion:"} {
+		title = strings.TrimSpace(strings.TrimPrefix(title, prefix))
+	}
+	return strings.Join(strings.Fields(title), " ")
+}
+
 var markdownEscaper = strings.NewReplacer(
 	"_", `\_`,
 	"*", `\*`,
@@ -533,12 +713,22 @@ func markdownEscape(s string) string {
 	return markdownEscaper.Replace(s)
 }
 
+// retire returns whether item is old enough for RetireOld to remove.
+func (r *Reporter) retire(item *github.ProjectItem) bool {
+	issue := item.Issue
+	return issue.Closed && !issue.ClosedAt.IsZero() && time.Since(issue.ClosedAt) > 365*24*time.Hour
+}
+
 func (r *Reporter) RetireOld() {
 	for _, item := range r.Items {
 		issue := item.Issue
-		if issue.Closed && !issue.ClosedAt.IsZero() && time.Since(issue.ClosedAt) > 365*24*time.Hour {
+		if r.retire(item) {
 			log.Printf("retire #%d", issue.Number)
-			if err := r.Client.DeleteProjectItem(r.Proposals, item); err != nil {
+			from := ""
+			if status := item.FieldByName("Status"); status != nil {
+				from = status.Option.Name
+			}
+			if err := r.deleteItem(item, from); err != nil {
 				log.Printf("#%d: deleting proposal item: %v", issue.Number, err)
 			}
 		}
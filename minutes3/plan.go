@@ -0,0 +1,194 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+
+	"rsc.io/github"
+)
+
+var (
+	dryRun       = flag.Bool("dry-run", false, "print the GitHub changes this run would make instead of making them")
+	dryRunFormat = flag.String("dry-run-format", "text", "print the -dry-run report as `format` text or markdown")
+)
+
+// A plan collects the GitHub mutations a -dry-run would have made.
+type plan struct {
+	issues []*issuePlan
+	byNum  map[int]*issuePlan
+}
+
+// An issuePlan is the planned mutations of one issue, in order.
+type issuePlan struct {
+	number int
+	title  string
+	muts   []mutation
+}
+
+// A mutation is a change to one attribute of an issue, such as its
+// status in the Proposals project or one of its labels. from is ""
+// for an addition, such as a new label or comment, and to is "" for a
+// removal.
+type mutation struct {
+	what     string
+	from, to string
+}
+
+// add records a mutation of issue.
+func (p *plan) add(issue *github.Issue, what, from, to string) {
+	ip := p.byNum[issue.Number]
+	if ip == nil {
+		if p.byNum == nil {
+			p.byNum = make(map[int]*issuePlan)
+		}
+		ip = &issuePlan{number: issue.Number, title: issue.Title}
+		p.byNum[issue.Number] = ip
+		p.issues = append(p.issues, ip)
+	}
+	ip.muts = append(ip.muts, mutation{what, from, to})
+}
+
+// write renders p as a diff of each issue, in text or, if markdown
+// is set, as Markdown.
+func (p *plan) write(w io.Writer, markdown bool) {
+	if len(p.issues) == 0 {
+		fmt.Fprintf(w, "No GitHub changes.\n")
+		return
+	}
+	n := 0
+	for _, ip := range p.issues {
+		n += len(ip.muts)
+	}
+	if markdown {
+		fmt.Fprintf(w, "**%d GitHub changes to %d issues**\n", n, len(p.issues))
+	} else {
+		fmt.Fprintf(w, "%d GitHub changes to %d issues\n", n, len(p.issues))
+	}
+	for _, ip := range p.issues {
+		fmt.Fprintf(w, "\n")
+		if markdown {
+			fmt.Fprintf(w, "### [#%d](https://go.dev/issue/%d) %s\n\n```diff\n", ip.number, ip.number, markdownEscape(ip.title))
+		} else {
+			fmt.Fprintf(w, "#%d %s\n", ip.number, ip.title)
+		}
+		for _, m := range ip.muts {
+			if m.from != "" {
+				writeDiffLines(w, "-", m.what, m.from)
+			}
+			if m.to != "" {
+				writeDiffLines(w, "+", m.what, m.to)
+			}
+		}
+		if markdown {
+			fmt.Fprintf(w, "```\n")
+		}
+	}
+}
+
+// writeDiffLines writes one side of a mutation of what. Multi-line
+// values, such as comments, start on the line after what.
+func writeDiffLines(w io.Writer, sign, what, val string) {
+	if !strings.Contains(val, "\n") {
+		fmt.Fprintf(w, "%s %s: %s\n", sign, what, val)
+		return
+	}
+	fmt.Fprintf(w, "%s %s:\n", sign, what)
+	for _, line := range strings.Split(strings.TrimRight(val, "\n"), "\n") {
+		if line == "" {
+			fmt.Fprintf(w, "%s\n", sign)
+		} else {
+			fmt.Fprintf(w, "%s     %s\n", sign, line)
+		}
+	}
+}
+
+// The following methods make GitHub mutations on behalf of Update and
+// RetireOld. With -dry-run, they record the mutations in r.plan
+// instead. Otherwise, they record them in r.journal.
+
+func (r *Reporter) setStatus(item *github.ProjectItem, from string, f *github.ProjectField, o *github.ProjectFieldOption) error {
+	if r.plan != nil {
+		r.plan.add(item.Issue, "status", from, o.Name)
+		return nil
+	}
+	_, err := r.journaled(item.Issue.Number, "status", o.Name, func(bool) (string, error) {
+		return "", r.Client.SetProjectItemFieldOption(r.Proposals, item, f, o)
+	})
+	return err
+}
+
+func (r *Reporter) deleteItem(item *github.ProjectItem, from string) error {
+	if r.plan != nil {
+		r.plan.add(item.Issue, "status", from, "(removed from project)")
+		return nil
+	}
+	_, err := r.journaled(item.Issue.Number, "delete", "", func(bool) (string, error) {
+		return "", r.Client.DeleteProjectItem(r.Proposals, item)
+	})
+	return err
+}
+
+func (r *Reporter) addLabel(issue *github.Issue, lab *github.Label) error {
+	if r.plan != nil {
+		r.plan.add(issue, "label", "", lab.Name)
+		return nil
+	}
+	_, err := r.journaled(issue.Number, "label", lab.Name, func(bool) (string, error) {
+		return "", r.Client.AddIssueLabels(issue, lab)
+	})
+	return err
+}
+
+func (r *Reporter) removeLabel(issue *github.Issue, lab *github.Label) error {
+	if r.plan != nil {
+		r.plan.add(issue, "label", lab.Name, "")
+		return nil
+	}
+	_, err := r.journaled(issue.Number, "unlabel", lab.Name, func(bool) (string, error) {
+		return "", r.Client.RemoveIssueLabels(issue, lab)
+	})
+	return err
+}
+
+func (r *Reporter) closeIssue(issue *github.Issue) error {
+	if r.plan != nil {
+		r.plan.add(issue, "state", "open", "closed")
+		return nil
+	}
+	_, err := r.journaled(issue.Number, "close", "", func(bool) (string, error) {
+		return "", r.Client.CloseIssue(issue)
+	})
+	return err
+}
+
+func (r *Reporter) retitle(issue *github.Issue, title string) error {
+	if r.plan != nil {
+		r.plan.add(issue, "title", issue.Title, title)
+		return nil
+	}
+	_, err := r.journaled(issue.Number, "retitle", title, func(bool) (string, error) {
+		return "", r.Client.RetitleIssue(issue, title)
+	})
+	return err
+}
+
+func (r *Reporter) remilestone(issue *github.Issue, m *github.Milestone) error {
+	if r.plan != nil {
+		from := "(none)"
+		if issue.Milestone != nil {
+			from = issue.Milestone.Title
+		}
+		r.plan.add(issue, "milestone", from, m.Title)
+		return nil
+	}
+	_, err := r.journaled(issue.Number, "milestone", m.Title, func(bool) (string, error) {
+		return "", r.Client.RemilestoneIssue(issue, m)
+	})
+	return err
+}
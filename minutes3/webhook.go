@@ -0,0 +1,93 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+var webhook = flag.String("webhook", "", "after a successful run, post a summary to the Slack or Matrix webhook `url` (default from ~/.config/proposal-minutes/webhook.url; \"none\" to disable)")
+
+const minutesURL = "https://go.dev/s/proposal-minutes"
+
+// webhookURL returns the webhook to post the summary to, or "" if
+// none is configured.
+func webhookURL() string {
+	switch *webhook {
+	case "none":
+		return ""
+	case "":
+		data, err := os.ReadFile(getConfig("webhook.url"))
+		if err != nil {
+			if !errors.Is(err, fs.ErrNotExist) {
+				log.Printf("reading webhook URL: %v", err)
+			}
+			return ""
+		}
+		return strings.TrimSpace(string(data))
+	}
+	return *webhook
+}
+
+// summarize returns a short plain-text summary of m for the webhook,
+// with the number of issues moved to each column.
+func summarize(m *Minutes) string {
+	counts := make(map[string]int)
+	for _, e := range m.Events {
+		counts[e.Column]++
+	}
+	var parts []string
+	for _, col := range []string{"Accepted", "Declined", "Likely Accept", "Likely Decline", "Active", "Hold"} {
+		if n := counts[col]; n > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", n, strings.ToLower(col)))
+			delete(counts, col)
+		}
+	}
+	other := 0
+	for _, n := range counts {
+		other += n
+	}
+	if other > 0 {
+		parts = append(parts, fmt.Sprintf("%d other", other))
+	}
+	if len(parts) == 0 {
+		parts = append(parts, "no issues")
+	}
+	return fmt.Sprintf("Proposal review minutes for %s: %s.\n%s", m.Date.Format("2006-01-02"), strings.Join(parts, ", "), minutesURL)
+}
+
+// postWebhook posts text to the webhook at url. Slack incoming
+// webhooks and Matrix hookshot webhooks both accept a JSON object
+// with a "text" field.
+func postWebhook(url, text string) error {
+	body, err := json.Marshal(map[string]string{"text": text})
+	if err != nil {
+		return err
+	}
+	// Don't use http.DefaultClient, which retries as if this were
+	// the GitHub API.
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("%s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
@@ -0,0 +1,186 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"debug/dwarf"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// A typeExpr addresses a sub-type of a named type or variable, such
+// as runtime.mheap_.central[3].mcentral.
+type typeExpr struct {
+	root  string
+	steps []exprStep
+}
+
+// An exprStep is either a field selector or an array index.
+type exprStep struct {
+	field   string
+	index   int64
+	isIndex bool
+}
+
+// collectRoots returns the types of all named types and package-level
+// variables in d, indexed by name.
+func collectRoots(d *dwarf.Data) (map[string]dwarf.Offset, error) {
+	roots := make(map[string]dwarf.Offset)
+	r := d.Reader()
+	for {
+		ent, err := r.Next()
+		if err != nil {
+			return nil, err
+		}
+		if ent == nil {
+			break
+		}
+		switch ent.Tag {
+		case dwarf.TagTypedef, dwarf.TagVariable:
+			name, ok := ent.Val(dwarf.AttrName).(string)
+			if !ok {
+				break
+			}
+			if typ, ok := ent.Val(dwarf.AttrType).(dwarf.Offset); ok {
+				if _, ok := roots[name]; !ok || ent.Tag == dwarf.TagTypedef {
+					roots[name] = typ
+				}
+			}
+		}
+		if ent.Tag != dwarf.TagCompileUnit {
+			// Skip local variables.
+			r.SkipChildren()
+		}
+	}
+	return roots, nil
+}
+
+// parseTypeExpr parses s as a type expression rooted at one of roots.
+// It returns false if s isn't a type expression, in which case it
+// should be treated as a type regexp.
+func parseTypeExpr(s string, roots map[string]bool) (*typeExpr, bool) {
+	// Type names contain dots, so find the longest root that's a
+	// prefix of s followed by a valid path.
+	for i := len(s); i > 0; i-- {
+		if i < len(s) && s[i] != '.' && s[i] != '[' {
+			continue
+		}
+		if !roots[s[:i]] {
+			continue
+		}
+		steps, ok := parseSteps(s[i:])
+		if !ok || len(steps) == 0 {
+			continue
+		}
+		return &typeExpr{root: s[:i], steps: steps}, true
+	}
+	return nil, false
+}
+
+func parseSteps(s string) ([]exprStep, bool) {
+	var steps []exprStep
+	for s != "" {
+		switch s[0] {
+		case '.':
+			end := strings.IndexAny(s[1:], ".[")
+			if end < 0 {
+				end = len(s) - 1
+			}
+			name := s[1 : 1+end]
+			if !isIdent(name) {
+				return nil, false
+			}
+			steps = append(steps, exprStep{field: name})
+			s = s[1+end:]
+		case '[':
+			end := strings.IndexByte(s, ']')
+			if end < 0 {
+				return nil, false
+			}
+			idx, err := strconv.ParseInt(s[1:end], 10, 64)
+			if err != nil || idx < 0 {
+				return nil, false
+			}
+			steps = append(steps, exprStep{index: idx, isIndex: true})
+			s = s[end+1:]
+		default:
+			return nil, false
+		}
+	}
+	return steps, true
+}
+
+func isIdent(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if !(r == '_' || r == '·' || 'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || '0' <= r && r <= '9' || r >= 0x80) {
+			return false
+		}
+	}
+	return true
+}
+
+// resolve walks e's steps starting at typ, the type of e's root, and
+// returns the addressed type and its offset from the start of the
+// root.
+func (e *typeExpr) resolve(typ dwarf.Type) (dwarf.Type, int64, error) {
+	var offset int64
+	path := e.root
+	for _, step := range e.steps {
+		under := typ
+		for {
+			switch t := under.(type) {
+			case *dwarf.TypedefType:
+				under = t.Type
+				continue
+			case *dwarf.QualType:
+				under = t.Type
+				continue
+			}
+			break
+		}
+
+		if step.isIndex {
+			arr, ok := under.(*dwarf.ArrayType)
+			if !ok {
+				return nil, 0, fmt.Errorf("cannot index %s of type %s", path, typ)
+			}
+			if arr.Count >= 0 && step.index >= arr.Count {
+				return nil, 0, fmt.Errorf("index %d out of range for %s of type %s", step.index, path, typ)
+			}
+			typ = arr.Type
+			offset += step.index * typ.Size()
+			path += fmt.Sprintf("[%d]", step.index)
+			continue
+		}
+
+		st, ok := under.(*dwarf.StructType)
+		if !ok {
+			if _, ok := under.(*dwarf.PtrType); ok {
+				return nil, 0, fmt.Errorf("cannot select .%s through pointer %s of type %s", step.field, path, typ)
+			}
+			return nil, 0, fmt.Errorf("cannot select .%s from %s of type %s", step.field, path, typ)
+		}
+		var field *dwarf.StructField
+		for _, f := range st.Field {
+			if f.Name == step.field {
+				field = f
+				break
+			}
+		}
+		if field == nil {
+			return nil, 0, fmt.Errorf("%s of type %s has no field %s", path, typ, step.field)
+		}
+		typ = field.Type
+		if st.Kind != "union" {
+			offset += field.ByteOffset
+		}
+		path += "." + step.field
+	}
+	return typ, offset, nil
+}
@@ -0,0 +1,244 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"debug/dwarf"
+	"fmt"
+	"go/format"
+	"io"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// goSrc translates types from a binary into Go declarations with the
+// same memory layout.
+type goSrc struct {
+	// names maps the name of each type being declared to its Go
+	// identifier.
+	names map[string]string
+}
+
+// goIdent returns a Go identifier for the type named name, such as
+// runtime_mcache for runtime.mcache.
+func goIdent(name string) string {
+	ident := strings.Map(func(r rune) rune {
+		if r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return r
+		}
+		return '_'
+	}, name)
+	ident = strings.TrimRight(ident, "_")
+	if ident == "" || unicode.IsDigit(rune(ident[0])) {
+		ident = "_" + ident
+	}
+	return ident
+}
+
+// writeGoSrc writes a Go source file in package pkg declaring types
+// with the same layout as the named types types in binPath. The file
+// also checks at compile time that each type has the size it has in
+// the binary.
+func writeGoSrc(w io.Writer, binPath, pkg string, types map[string]dwarf.Type) error {
+	var names []string
+	for name := range types {
+		// Skip type literals, such as "interface {}", and the
+		// compiler's generic shape types.
+		if pkgOf(name) == "" || strings.Contains(name, " ") || strings.HasPrefix(name, "go.shape.") {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	g := &goSrc{names: make(map[string]string)}
+	used := make(map[string]bool)
+	for _, name := range names {
+		ident := goIdent(name)
+		for i := 2; used[ident]; i++ {
+			ident = fmt.Sprintf("%s_%d", goIdent(name), i)
+		}
+		used[ident] = true
+		g.names[name] = ident
+	}
+
+	var body bytes.Buffer
+	for _, name := range names {
+		fmt.Fprintf(&body, "// %s mirrors %s.\n", g.names[name], name)
+		fmt.Fprintf(&body, "type %s %s\n\n", g.names[name], g.typ(types[name], true))
+	}
+	if len(names) > 0 {
+		fmt.Fprintf(&body, "func _() {\n")
+		fmt.Fprintf(&body, "\t// Check that each type has the size it has in %s.\n", binPath)
+		fmt.Fprintf(&body, "\tvar x [1]struct{}\n")
+		for _, name := range names {
+			fmt.Fprintf(&body, "\t_ = x[unsafe.Sizeof(*new(%s))-%d]\n", g.names[name], types[name].Size())
+		}
+		fmt.Fprintf(&body, "}\n")
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// Code generated by ptype -go-src from %s. DO NOT EDIT.\n\n", binPath)
+	fmt.Fprintf(&buf, "package %s\n\n", pkg)
+	if bytes.Contains(body.Bytes(), []byte("unsafe.")) {
+		fmt.Fprintf(&buf, "import \"unsafe\"\n\n")
+	}
+	buf.Write(body.Bytes())
+
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		// Write it anyway so it's possible to see what went
+		// wrong.
+		w.Write(buf.Bytes())
+		return fmt.Errorf("formatting Go source: %v", err)
+	}
+	_, err = w.Write(src)
+	return err
+}
+
+// typeName returns the name of typ, or "" if it's unnamed.
+func typeName(typ dwarf.Type) string {
+	if s, ok := typ.(*dwarf.StructType); ok && s.StructName != "" {
+		return s.StructName
+	}
+	return typ.Common().Name
+}
+
+// goBasic is the set of predeclared Go types that can appear in DWARF
+// as base types.
+var goBasic = map[string]bool{
+	"bool": true, "uintptr": true,
+	"int": true, "int8": true, "int16": true, "int32": true, "int64": true,
+	"uint": true, "uint8": true, "uint16": true, "uint32": true, "uint64": true,
+	"float32": true, "float64": true, "complex64": true, "complex128": true,
+}
+
+// typ returns a Go type expression with the same layout as typ. If
+// typ is one of the types being declared, this is its identifier
+// unless expand is set.
+func (g *goSrc) typ(typ dwarf.Type, expand bool) string {
+	if ident, ok := g.names[typeName(typ)]; ok && !expand {
+		return ident
+	}
+
+	switch typ := typ.(type) {
+	case *dwarf.QualType:
+		return g.typ(typ.Type, expand)
+
+	case *dwarf.TypedefType:
+		n := typ.Name
+		if isBuiltinName(n) && n != "string" {
+			// Maps, channels, and funcs are all
+			// pointers.
+			return fmt.Sprintf("unsafe.Pointer /* %s */", n)
+		}
+		return g.typ(typ.Type, false)
+
+	case *dwarf.BoolType, *dwarf.CharType, *dwarf.ComplexType, *dwarf.FloatType, *dwarf.IntType, *dwarf.UcharType, *dwarf.UintType:
+		if n := typ.Common().Name; goBasic[n] {
+			return n
+		}
+		return g.basic(typ)
+
+	case *dwarf.EnumType:
+		return g.basic(typ)
+
+	case *dwarf.PtrType:
+		elem := typ.Type
+		if _, ok := elem.(*dwarf.VoidType); ok {
+			return "unsafe.Pointer"
+		}
+		if n := typeName(elem); n != "" && !goBasic[n] && n != "string" && g.names[n] == "" {
+			// We're not declaring the pointed-to type.
+			return fmt.Sprintf("unsafe.Pointer /* *%s */", n)
+		}
+		return "*" + g.typ(elem, false)
+
+	case *dwarf.FuncType:
+		return "unsafe.Pointer /* func */"
+
+	case *dwarf.ArrayType:
+		count := typ.Count
+		if count < 0 {
+			count = 0
+		}
+		return fmt.Sprintf("[%d]%s", count, g.typ(typ.Type, false))
+
+	case *dwarf.StructType:
+		switch {
+		case typ.StructName == "string":
+			return "string"
+		case typ.StructName == "runtime.eface":
+			return "interface{}"
+		case typ.StructName == "runtime.iface":
+			// The first word is an itab, not a type, so
+			// this can't be an interface{}.
+			return "struct {\ntab, data unsafe.Pointer\n}"
+		case strings.HasPrefix(typ.StructName, "[]") && len(typ.Field) > 0:
+			if ptr, ok := typ.Field[0].Type.(*dwarf.PtrType); ok {
+				return "[]" + g.typ(ptr.Type, false)
+			}
+		}
+		if typ.Kind != "struct" || typ.Incomplete {
+			// Go has no unions.
+			return fmt.Sprintf("[%d]byte /* %s %s */", typ.Size(), typ.Kind, typ.StructName)
+		}
+		return g.structType(typ)
+
+	case *dwarf.VoidType, *dwarf.UnspecifiedType:
+		return "struct{}"
+	}
+	return fmt.Sprintf("[%d]byte /* %s */", typ.Size(), typ)
+}
+
+// basic returns the Go integer type of the same size and signedness
+// as typ.
+func (g *goSrc) basic(typ dwarf.Type) string {
+	kind := "uint"
+	switch typ.(type) {
+	case *dwarf.IntType, *dwarf.CharType, *dwarf.EnumType:
+		kind = "int"
+	}
+	switch typ.Size() {
+	case 1, 2, 4, 8:
+		return fmt.Sprintf("%s%d", kind, 8*typ.Size())
+	}
+	return fmt.Sprintf("[%d]byte", typ.Size())
+}
+
+// structType returns a Go struct type with the same layout as typ.
+// Padding between fields and at the end is made explicit with blank
+// fields, so the layout doesn't depend on the Go compiler's
+// alignment rules matching the binary's.
+func (g *goSrc) structType(typ *dwarf.StructType) string {
+	var buf strings.Builder
+	buf.WriteString("struct {\n")
+	var end int64
+	pad := func(offset int64) {
+		if offset > end {
+			fmt.Fprintf(&buf, "_ [%d]byte\n", offset-end)
+			end = offset
+		}
+	}
+	for _, f := range typ.Field {
+		pad(f.ByteOffset)
+		name := f.Name
+		if name != "_" {
+			name = goIdent(name)
+		}
+		if f.BitSize != 0 {
+			fmt.Fprintf(&buf, "// %s is a %d bit field\n", name, f.BitSize)
+		}
+		fmt.Fprintf(&buf, "%s %s\n", name, g.typ(f.Type, false))
+		if size := f.Type.Size(); size > 0 && f.ByteOffset+size > end {
+			end = f.ByteOffset + size
+		}
+	}
+	pad(typ.Size())
+	buf.WriteString("}")
+	return buf.String()
+}
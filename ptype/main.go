@@ -36,6 +36,45 @@
 //             local_nlargefree uintptr                  // offset 656
 //             local_nsmallfree [67]uintptr              // offset 664
 //     }
+//
+// An argument may also be an expression that navigates from a named
+// type or package-level variable into its fields and array elements,
+// such as runtime.mheap_.central[3].mcentral. For these, ptype prints
+// the addressed sub-type with field offsets relative to the start of
+// the named type or variable, which is useful for computing offsets
+// for debugger scripts.
+//
+// With -cacheline N, ptype also marks the boundaries between N byte
+// cache lines, notes fields that straddle a boundary, and reports how
+// many lines each struct touches, assuming the outermost type starts
+// on a cache line boundary. This is useful for finding false sharing
+// and improving layout.
+//
+// Named interface types are printed with their method signatures,
+// which ptype reads from the runtime type descriptors because DWARF
+// doesn't record them. Types in method signatures are qualified by
+// package name, rather than import path.
+//
+// With -watch, ptype keeps running and re-prints the types each time
+// binary is rebuilt. Adding -diff prints only the lines that changed
+// since the previous print, which is convenient when iterating on a
+// struct's layout.
+//
+// With -go-src pkg, ptype instead prints a Go source file in package
+// pkg that declares the named types matching the arguments with the
+// same memory layout they have in binary, for mirroring them with
+// unsafe in debugging tools and tests. The padding between fields is
+// made explicit. Maps, channels, funcs, and pointers to types that
+// aren't being declared become unsafe.Pointer, and unions become byte
+// arrays. The file checks at compile time that each type has the
+// size it has in binary.
+//
+// If binary has no DWARF (for example, it was linked with -ldflags=-w
+// or -s), ptype instead reconstructs types from the runtime type
+// descriptors the linker always includes. This only finds types that
+// have descriptors, so some runtime-internal types are missing, and
+// expressions can't start from variables. This doesn't yet support
+// position-independent binaries.
 package main
 
 import (
@@ -43,55 +82,216 @@ import (
 	"debug/elf"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"regexp"
 	"strings"
+	"time"
 	"unicode/utf8"
 )
 
 func main() {
 	flag.Usage = func() {
-		fmt.Fprintf(os.Stderr, "Usage: %s binary <type-regexp...>\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Usage: %s [flags] binary <type-regexp|expr...>\n", os.Args[0])
+		flag.PrintDefaults()
 	}
+	cacheLine := flag.Int64("cacheline", 0, "annotate boundaries between `size` byte cache lines")
+	flagWatch := flag.Bool("watch", false, "re-print types whenever binary changes")
+	flagDiff := flag.Bool("diff", false, "with -watch, print only what changed since the last print")
+	goPkg := flag.String("go-src", "", "print Go declarations that mirror the types' layout in package `pkg`")
 	flag.Parse()
 	if flag.NArg() < 1 {
 		flag.Usage()
 		os.Exit(2)
 	}
 	binPath := flag.Arg(0)
+	args := flag.Args()[1:]
+
+	print := func(w io.Writer) error {
+		return printTypes(w, binPath, args, *cacheLine, *goPkg)
+	}
+	if *flagWatch {
+		watch(binPath, time.Second/2, *flagDiff, print)
+		return
+	}
+	if err := print(os.Stdout); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// printTypes prints the types in binPath matching args to w. If goPkg
+// isn't "", it prints them as a Go source file in package goPkg.
+func printTypes(w io.Writer, binPath string, args []string, cacheLine int64, goPkg string) error {
+	// Parse binary.
+	f, err := elf.Open(binPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	var src typeSource
+	if d, err := f.DWARF(); err == nil {
+		src = &dwarfTypes{d: d, f: f}
+	} else {
+		// Binaries linked with -ldflags=-w have no DWARF, but
+		// the runtime's type descriptors are always there.
+		rt, rerr := loadRTypes(f)
+		if rerr != nil {
+			return fmt.Errorf("%s: %v; reading runtime type descriptors: %v", binPath, err, rerr)
+		}
+		log.Printf("%s has no DWARF; using runtime type descriptors", binPath)
+		src = rt
+	}
+
+	// Print type expression args.
+	var roots map[string]bool
+	if len(args) > 0 && goPkg == "" {
+		roots, err = src.roots()
+		if err != nil {
+			return err
+		}
+	}
+	var reArgs []string
+	for _, arg := range args {
+		if goPkg != "" {
+			// Expressions don't name types to declare.
+			reArgs = append(reArgs, arg)
+			continue
+		}
+		expr, ok := parseTypeExpr(arg, roots)
+		if !ok {
+			reArgs = append(reArgs, arg)
+			continue
+		}
+		root, err := src.rootType(expr.root)
+		if err != nil {
+			return err
+		}
+		typ, offset, err := expr.resolve(root)
+		if err != nil {
+			return err
+		}
+		p := &typePrinter{w: w, pkg: pkgOf(expr.root), cacheLine: cacheLine}
+		p.offset = []int64{offset}
+		if p.cacheLine > 0 {
+			p.line = offset / p.cacheLine
+		}
+		p.setLineComment("offset %d in %s, %d bytes", offset, expr.root, typ.Size())
+		p.fmt("%s ", arg)
+		p.printType(typ)
+		p.fmt("\n\n")
+	}
+	if len(args) > 0 && len(reArgs) == 0 {
+		return nil
+	}
 
 	// Parse type regexp args.
 	regexps := []*regexp.Regexp{}
-	for _, tre := range flag.Args()[1:] {
+	for _, tre := range reArgs {
 		re, err := regexp.Compile("^" + tre)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "bad regexp %q: %s", tre, err)
-			os.Exit(1)
+			return fmt.Errorf("bad regexp %q: %s", tre, err)
 		}
 		regexps = append(regexps, re)
 	}
 	if len(regexps) == 0 {
 		regexps = append(regexps, regexp.MustCompile(".*"))
 	}
+	want := func(name string) bool {
+		for _, re := range regexps {
+			if re.MatchString(name) {
+				return !isBuiltinName(name)
+			}
+		}
+		return false
+	}
 
-	// Parse binary.
-	f, err := elf.Open(binPath)
-	if err != nil {
-		log.Fatal(err)
+	if goPkg != "" {
+		types := make(map[string]dwarf.Type)
+		err := src.namedTypes(want, func(name string, typ dwarf.Type) {
+			types[name] = typ
+		})
+		if err != nil {
+			return err
+		}
+		return writeGoSrc(w, binPath, goPkg, types)
 	}
-	defer f.Close()
-	d, err := f.DWARF()
+
+	// Print the matching named types.
+	return src.namedTypes(want, func(name string, typ dwarf.Type) {
+		p := &typePrinter{w: w, pkg: pkgOf(name), cacheLine: cacheLine}
+		p.fmt("type %s ", name)
+		if methods := src.interfaceMethods(name); methods != nil {
+			p.printInterface(methods)
+		} else {
+			p.printType(typ)
+		}
+		p.fmt("\n\n")
+	})
+}
+
+// A typeSource provides the types in a binary.
+type typeSource interface {
+	// roots returns the names of the named types and
+	// package-level variables that can start a type expression.
+	roots() (map[string]bool, error)
+
+	// rootType returns the type of the named root.
+	rootType(name string) (dwarf.Type, error)
+
+	// namedTypes calls fn with the underlying type of each named
+	// type whose name satisfies want.
+	namedTypes(want func(name string) bool, fn func(name string, typ dwarf.Type)) error
+
+	// interfaceMethods returns the method signatures of the
+	// named interface type name, or nil if name isn't a
+	// non-empty interface or its methods are unknown. It must be
+	// called from namedTypes's fn.
+	interfaceMethods(name string) []string
+}
+
+// attrGoRuntimeType is the Go-specific DWARF attribute that gives the
+// address of a type's runtime type descriptor.
+const attrGoRuntimeType dwarf.Attr = 0x2904
+
+// dwarfTypes is a typeSource that reads types from DWARF.
+type dwarfTypes struct {
+	d       *dwarf.Data
+	f       *elf.File
+	rootOff map[string]dwarf.Offset
+
+	// descAddr records the type descriptor address or offset
+	// of each named type visited by namedTypes. DWARF doesn't
+	// describe interface methods, but the descriptors do.
+	descAddr map[string]uint64
+	rt       *rtypes
+	rtErr    error
+}
+
+func (t *dwarfTypes) roots() (map[string]bool, error) {
+	var err error
+	t.rootOff, err = collectRoots(t.d)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
+	roots := make(map[string]bool)
+	for name := range t.rootOff {
+		roots[name] = true
+	}
+	return roots, nil
+}
+
+func (t *dwarfTypes) rootType(name string) (dwarf.Type, error) {
+	return t.d.Type(t.rootOff[name])
+}
 
+func (t *dwarfTypes) namedTypes(want func(name string) bool, fn func(name string, typ dwarf.Type)) error {
 	// Find all of the named types.
-	r := d.Reader()
+	r := t.d.Reader()
 	for {
 		ent, err := r.Next()
 		if err != nil {
-			log.Fatal(err)
+			return err
 		}
 		if ent == nil {
 			break
@@ -107,14 +307,7 @@ func main() {
 		}
 
 		// Do we want this type?
-		matched := false
-		for _, re := range regexps {
-			if re.MatchString(name) {
-				matched = true
-				break
-			}
-		}
-		if isBuiltinName(name) || !matched {
+		if !want(name) {
 			r.SkipChildren()
 			continue
 		}
@@ -126,23 +319,49 @@ func main() {
 			continue
 		}
 
-		typ, err := d.Type(base)
+		typ, err := t.d.Type(base)
 		if err != nil {
-			log.Fatal(err)
+			return err
 		}
-
-		pkg := ""
-		if i := strings.LastIndex(name, "."); i >= 0 {
-			pkg = name[:i+1]
+		if addr, ok := ent.Val(attrGoRuntimeType).(uint64); ok {
+			if t.descAddr == nil {
+				t.descAddr = make(map[string]uint64)
+			}
+			t.descAddr[name] = addr
 		}
-
-		p := &typePrinter{pkg: pkg}
-		p.fmt("type %s ", name)
-		p.printType(typ)
-		p.fmt("\n\n")
+		fn(name, typ)
 
 		r.SkipChildren()
 	}
+	return nil
+}
+
+func (t *dwarfTypes) interfaceMethods(name string) []string {
+	addr, ok := t.descAddr[name]
+	if !ok {
+		return nil
+	}
+	if t.rt == nil && t.rtErr == nil {
+		t.rt, t.rtErr = loadRTypes(t.f)
+	}
+	if t.rtErr != nil {
+		return nil
+	}
+	if addr < t.rt.types {
+		// Newer linkers record the offset from the start
+		// of the type descriptors.
+		addr += t.rt.types
+	}
+	return t.rt.imethods(addr)
+}
+
+// pkgOf returns the package prefix of name, including the trailing
+// ".", or "".
+func pkgOf(name string) string {
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		return name[:i+1]
+	}
+	return ""
 }
 
 func isBuiltinName(typeName string) bool {
@@ -158,11 +377,19 @@ func isBuiltinName(typeName string) bool {
 }
 
 type typePrinter struct {
+	w io.Writer
+
 	offset []int64
 	depth  int
 	nameOk int
 	pkg    string
 
+	// cacheLine is the cache line size to annotate, or 0.
+	cacheLine int64
+	// line is the index of the cache line containing the
+	// last printed field.
+	line int64
+
 	// pos is the current character position on this line.
 	pos int
 
@@ -173,7 +400,7 @@ type typePrinter struct {
 func (p *typePrinter) fmt(f string, args ...interface{}) {
 	b := fmt.Sprintf(f, args...)
 	if strings.IndexAny(b, "\n\t") < 0 {
-		fmt.Printf("%s", b)
+		fmt.Fprintf(p.w, "%s", b)
 		p.pos += utf8.RuneCountInString(b)
 		return
 	}
@@ -182,7 +409,7 @@ func (p *typePrinter) fmt(f string, args ...interface{}) {
 		hasNL := i < len(lines)-1
 		if p.lineComment == "" && hasNL {
 			// Fast path for complete lines with no comment.
-			fmt.Printf("%s\n", line)
+			fmt.Fprintf(p.w, "%s\n", line)
 			p.pos = 0
 			continue
 		}
@@ -194,17 +421,17 @@ func (p *typePrinter) fmt(f string, args ...interface{}) {
 				p.pos++
 			}
 		}
-		fmt.Printf("%s", line)
+		fmt.Fprintf(p.w, "%s", line)
 		if hasNL {
 			if p.lineComment != "" {
 				space := 50 - p.pos
 				if space < 1 {
 					space = 1
 				}
-				fmt.Printf("%*s// %s", space, "", p.lineComment)
+				fmt.Fprintf(p.w, "%*s// %s", space, "", p.lineComment)
 				p.lineComment = ""
 			}
-			fmt.Printf("\n")
+			fmt.Fprintf(p.w, "\n")
 			p.pos = 0
 		}
 	}
@@ -282,20 +509,36 @@ func (p *typePrinter) printType(typ dwarf.Type) {
 		}
 		p.depth++
 		indent := "\n" + strings.Repeat("\t", p.depth)
-		p.fmt("%s// %d byte %s", indent, typ.Size(), typ.Kind)
 		startOffset := p.offset[len(p.offset)-1]
+		p.fmt("%s// %d byte %s", indent, typ.Size(), typ.Kind)
+		if p.annotateLines() && typ.Size() > 0 {
+			lines := (startOffset+typ.Size()-1)/p.cacheLine - startOffset/p.cacheLine + 1
+			p.fmt(", %d cache lines", lines)
+		}
 		var prevEnd int64
 		for i, f := range typ.Field {
-			p.fmt(indent)
+			p.fmt("%s", indent)
 			// TODO: Bit offsets?
 			if !isUnion {
 				offset := startOffset + f.ByteOffset
 				if i > 0 && prevEnd < offset {
 					p.fmt("// %d byte gap", offset-prevEnd)
-					p.fmt(indent)
+					p.fmt("%s", indent)
 				}
 				p.offset[len(p.offset)-1] = offset
-				p.setLineComment("offset %s", p.strOffset())
+				straddle := ""
+				if p.annotateLines() {
+					if line := offset / p.cacheLine; line > p.line {
+						p.fmt("// --- cache line %d boundary (%d bytes) ---", line, line*p.cacheLine)
+						p.fmt("%s", indent)
+						p.line = line
+					}
+					size := f.Type.Size()
+					if size > 0 && size <= p.cacheLine && offset/p.cacheLine != (offset+size-1)/p.cacheLine {
+						straddle = ", straddles cache line"
+					}
+				}
+				p.setLineComment("offset %s%s", p.strOffset(), straddle)
 				if f.Type.Size() < 0 {
 					// Who knows. Give up.
 					// TODO: This happens for funcs.
@@ -327,18 +570,18 @@ func (p *typePrinter) printType(typ dwarf.Type) {
 			p.fmt("unsafe.Pointer")
 			break
 		}
-		origOffset := p.offset
-		p.offset = []int64{0}
+		origOffset, origLine := p.offset, p.line
+		p.offset, p.line = []int64{0}, 0
 		p.nameOk++
 		p.fmt("*")
 		p.printType(typ.Type)
 		p.nameOk--
-		p.offset = origOffset
+		p.offset, p.line = origOffset, origLine
 
 	case *dwarf.FuncType:
 		// TODO: Expand ourselves so we can clean up argument
 		// types, etc.
-		p.fmt(typ.String())
+		p.fmt("%s", typ.String())
 
 	case *dwarf.QualType:
 		p.fmt("/* %s */ ", typ.Qual)
@@ -367,7 +610,7 @@ func (p *typePrinter) printType(typ dwarf.Type) {
 			switch str.StructName {
 			case "runtime.iface", "runtime.eface":
 				// Named interface type.
-				p.fmt(p.stripPkg(n))
+				p.fmt("%s", p.stripPkg(n))
 				return
 			}
 		}
@@ -390,6 +633,26 @@ func (p *typePrinter) printType(typ dwarf.Type) {
 	p.offset[len(p.offset)-1] += typ.Size()
 }
 
+// printInterface prints an interface type with the given method
+// signatures.
+func (p *typePrinter) printInterface(methods []string) {
+	p.fmt("interface {")
+	p.depth++
+	indent := "\n" + strings.Repeat("\t", p.depth)
+	for _, m := range methods {
+		p.fmt("%s%s", indent, m)
+	}
+	p.depth--
+	p.fmt("\n%s}", strings.Repeat("\t", p.depth))
+}
+
+// annotateLines returns whether to annotate cache lines at the
+// current offset. Offsets within arrays aren't fixed, so these
+// aren't annotated.
+func (p *typePrinter) annotateLines() bool {
+	return p.cacheLine > 0 && len(p.offset) == 1
+}
+
 func (p *typePrinter) strOffset() string {
 	buf := fmt.Sprintf("%d", p.offset[0])
 	for i, idx := 1, 'i'; i < len(p.offset); i, idx = i+2, idx+1 {
@@ -0,0 +1,868 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"debug/dwarf"
+	"debug/elf"
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// This file reconstructs types from the runtime's type descriptors
+// (internal/abi.Type and friends) for binaries without DWARF. The
+// descriptors record each type's name, size, and kind, plus the
+// element types and field layouts of composite types, which is
+// enough to print most of what ptype prints from DWARF.
+//
+// Only types the binary has descriptors for can be found. That
+// includes every type that is converted to an interface or reachable
+// from such a type, but not necessarily every type in the program.
+// Package-level variables have no descriptors, so they can't start
+// a type expression.
+
+// Type kinds, from internal/abi.
+const (
+	kindBool = 1 + iota
+	kindInt
+	kindInt8
+	kindInt16
+	kindInt32
+	kindInt64
+	kindUint
+	kindUint8
+	kindUint16
+	kindUint32
+	kindUint64
+	kindUintptr
+	kindFloat32
+	kindFloat64
+	kindComplex64
+	kindComplex128
+	kindArray
+	kindChan
+	kindFunc
+	kindInterface
+	kindMap
+	kindPtr
+	kindSlice
+	kindString
+	kindStruct
+	kindUnsafePointer
+
+	kindMask = 1<<5 - 1
+)
+
+// Type flags, from internal/abi.
+const (
+	tflagUncommon  = 1 << 0
+	tflagExtraStar = 1 << 1
+	tflagNamed     = 1 << 2
+)
+
+// rtypes is a typeSource that reads a binary's runtime type
+// descriptors.
+type rtypes struct {
+	f       *elf.File
+	order   binary.ByteOrder
+	ptrSize uint64
+
+	// types and etypes bound the type descriptors. Name and type
+	// offsets in descriptors are relative to types.
+	types, etypes uint64
+
+	// descs are the addresses of the descriptors found by
+	// scanning the binary. More are reachable from these.
+	descs []uint64
+
+	// Encodings that changed between Go releases.
+	oldNames        bool // Before Go 1.17, name lengths were 2 bytes
+	oldFieldOffsets bool // Before Go 1.19, field offsets were shifted left 1
+
+	secData map[*elf.Section][]byte
+	cache   map[uint64]dwarf.Type
+	named   map[string]uint64
+}
+
+// loadRTypes finds the type descriptors in f.
+func loadRTypes(f *elf.File) (*rtypes, error) {
+	if f.Type != elf.ET_EXEC {
+		// The pointers in a position-independent binary are
+		// filled in by dynamic relocations.
+		return nil, fmt.Errorf("only non-PIE executables are supported")
+	}
+	r := &rtypes{
+		f:       f,
+		order:   f.ByteOrder,
+		ptrSize: 8,
+		secData: make(map[*elf.Section][]byte),
+		cache:   make(map[uint64]dwarf.Type),
+	}
+	if f.Class == elf.ELFCLASS32 {
+		r.ptrSize = 4
+	}
+
+	md, err := r.findModuledata()
+	if err != nil {
+		return nil, err
+	}
+	if err := r.findTypes(md); err != nil {
+		return nil, err
+	}
+	r.detectEncodings()
+	r.collectNamed()
+	return r, nil
+}
+
+// findModuledata returns the address of the runtime's moduledata,
+// which records where the type descriptors are. It's the
+// runtime.firstmoduledata symbol, but binaries linked with -s don't
+// have symbols, so failing that, it finds the moduledata by its
+// first field, which points to the pclntab.
+func (r *rtypes) findModuledata() (uint64, error) {
+	if syms, err := r.f.Symbols(); err == nil {
+		for _, sym := range syms {
+			if sym.Name == "runtime.firstmoduledata" {
+				return sym.Value, nil
+			}
+		}
+	}
+
+	pclntab := r.f.Section(".gopclntab")
+	if pclntab == nil {
+		return 0, fmt.Errorf("no .gopclntab section (not a Go binary?)")
+	}
+	for _, name := range []string{".go.module", ".noptrdata", ".data"} {
+		sec := r.f.Section(name)
+		if sec == nil {
+			continue
+		}
+		data, err := r.section(sec)
+		if err != nil {
+			return 0, err
+		}
+		for off := uint64(0); off+r.ptrSize <= uint64(len(data)); off += r.ptrSize {
+			if r.uintN(data[off:]) == pclntab.Addr {
+				return sec.Addr + off, nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("cannot find runtime.moduledata")
+}
+
+// findTypes finds the bounds of the type descriptors and the
+// descriptors to start from, given the address of the moduledata.
+// The layout of moduledata varies between Go releases, so this
+// checks each candidate layout against the binary.
+func (r *rtypes) findTypes(md uint64) error {
+	const nWords = 64
+	words := make([]uint64, nWords)
+	for i := range words {
+		w, err := r.word(md + uint64(i)*r.ptrSize)
+		if err != nil {
+			return fmt.Errorf("reading moduledata: %v", err)
+		}
+		words[i] = w
+	}
+
+	// Before Go 1.27, the typelinks slice lists offsets of
+	// descriptors, and it's the contents of the .typelink
+	// section.
+	if tl := r.f.Section(".typelink"); tl != nil {
+		n := tl.Size / 4
+		for i := 0; i+2 < nWords; i++ {
+			if words[i] != tl.Addr || words[i+1] != n || words[i+2] != n {
+				continue
+			}
+			data, err := r.section(tl)
+			if err != nil {
+				return err
+			}
+			offs := make([]uint64, n)
+			for j := range offs {
+				offs[j] = uint64(r.order.Uint32(data[4*j:]))
+			}
+			// types and etypes come a few words before
+			// typelinks, depending on the Go version.
+			for back := 5; back <= 12 && back <= i; back++ {
+				types, etypes := words[i-back], words[i-back+1]
+				if r.validTypes(types, etypes, offs) {
+					r.types, r.etypes = types, etypes
+					for _, off := range offs {
+						r.descs = append(r.descs, types+off)
+					}
+					return nil
+				}
+			}
+		}
+		return fmt.Errorf("cannot find typelinks in moduledata (unsupported Go version?)")
+	}
+
+	// In Go 1.27 and later, the descriptors are laid out
+	// contiguously after the types symbol, and moduledata
+	// records their total length between types and etypes.
+	// Rather than depend on where these are in moduledata,
+	// look for the words that bound a plausible first
+	// descriptor.
+	for i := 0; i+2 < nWords; i++ {
+		types, typedesclen, etypes := words[i], words[i+1], words[i+2]
+		if types == 0 || typedesclen == 0 || types >= etypes || typedesclen > etypes-types {
+			continue
+		}
+		r.types, r.etypes = types, etypes
+		if !r.plausible(types + r.ptrSize) {
+			continue
+		}
+		r.walkTypes(types+r.ptrSize, types+typedesclen)
+		if len(r.descs) > 0 {
+			return nil
+		}
+	}
+	r.types, r.etypes = 0, 0
+	return fmt.Errorf("cannot find types in moduledata (unsupported Go version?)")
+}
+
+// validTypes reports whether types and etypes bound the type
+// descriptors at offsets offs.
+func (r *rtypes) validTypes(types, etypes uint64, offs []uint64) bool {
+	if types >= etypes {
+		return false
+	}
+	r.types, r.etypes = types, etypes
+	for i, off := range offs {
+		if i == 16 {
+			break
+		}
+		if off >= etypes-types || !r.plausible(types+off) {
+			return false
+		}
+	}
+	return true
+}
+
+// walkTypes finds the descriptors laid out contiguously between start
+// and end. Descriptors are pointer-aligned, and the size of each can
+// be computed from its kind, except for maps, whose descriptor
+// changes often. Where the size isn't known, walkTypes scans for the
+// next plausible descriptor.
+func (r *rtypes) walkTypes(start, end uint64) {
+	for addr := start; addr < end; {
+		addr = (addr + r.ptrSize - 1) &^ (r.ptrSize - 1)
+		if !r.plausible(addr) {
+			addr += r.ptrSize
+			continue
+		}
+		r.descs = append(r.descs, addr)
+		size := r.descSize(addr)
+		if size == 0 {
+			size = r.commonSize()
+		}
+		addr += size
+	}
+}
+
+// commonSize returns the size of the abi.Type common to all
+// descriptors.
+func (r *rtypes) commonSize() uint64 {
+	return 4*r.ptrSize + 16
+}
+
+// descSize returns the size of the descriptor at addr, including any
+// trailing uncommon type and arrays, or 0 if it's unknown.
+func (r *rtypes) descSize(addr uint64) uint64 {
+	p := r.ptrSize
+	size, add, ok := r.kindSize(addr)
+	if !ok {
+		return 0
+	}
+	if tflag, _ := r.u8(addr + 2*p + 4); tflag&tflagUncommon != 0 {
+		mcount, _ := r.u16(addr + size + 4)
+		size += 16 + uint64(mcount)*16
+	}
+	return size + add
+}
+
+// kindSize returns the size of the kind-specific descriptor at addr,
+// which is followed by its uncommon type, if any, and then add bytes
+// of arrays. It returns false if the size is unknown, which is the
+// case for maps.
+func (r *rtypes) kindSize(addr uint64) (size, add uint64, ok bool) {
+	p, base := r.ptrSize, r.commonSize()
+	kind, _ := r.u8(addr + 2*p + 7)
+	switch kind & kindMask {
+	default:
+		size = base
+	case kindArray:
+		size = base + 3*p
+	case kindChan:
+		size = base + 2*p
+	case kindPtr, kindSlice:
+		size = base + p
+	case kindFunc:
+		in, _ := r.u16(addr + base)
+		out, _ := r.u16(addr + base + 2)
+		size = base + p
+		add = uint64(in+out&(1<<15-1)) * p
+	case kindInterface:
+		n, _ := r.word(addr + base + 2*p)
+		size = base + 4*p
+		add = n * 8
+	case kindStruct:
+		n, _ := r.word(addr + base + 2*p)
+		size = base + 4*p
+		add = n * 3 * p
+	case kindMap:
+		return 0, 0, false
+	}
+	return size, add, true
+}
+
+// plausible reports whether addr looks like the start of a type
+// descriptor.
+func (r *rtypes) plausible(addr uint64) bool {
+	p := r.ptrSize
+	size, err1 := r.word(addr)
+	kind, err2 := r.u8(addr + 2*p + 7)
+	align, err3 := r.u8(addr + 2*p + 5)
+	fieldAlign, err4 := r.u8(addr + 2*p + 6)
+	str, err5 := r.u32(addr + 4*p + 8)
+	if err1 != nil || err2 != nil || err3 != nil || err4 != nil || err5 != nil {
+		return false
+	}
+	isPow2 := func(x uint8) bool { return x != 0 && x&(x-1) == 0 }
+	if size >= 1<<48 || kind&kindMask == 0 || kind&kindMask > kindUnsafePointer || !isPow2(align) || !isPow2(fieldAlign) {
+		return false
+	}
+	if uint64(str) >= r.etypes-r.types {
+		return false
+	}
+	name, err := r.name(r.types + uint64(str))
+	return err == nil && name != "" && utf8.ValidString(name) && !strings.ContainsAny(name, "\x00\n")
+}
+
+// detectEncodings determines the name and field offset encodings
+// used by this binary.
+func (r *rtypes) detectEncodings() {
+	// Type strings are never empty, so the first length byte
+	// is zero only with the old 2-byte encoding.
+	for _, addr := range r.descs {
+		str, _ := r.u32(addr + 4*r.ptrSize + 8)
+		if b, err := r.u8(r.types + uint64(str) + 1); err == nil {
+			r.oldNames = b == 0
+			break
+		}
+	}
+
+	// With the old field offset encoding, the raw offset of a
+	// struct's last non-zero-offset field plus its size exceeds
+	// the struct's size.
+	for _, addr := range r.descs {
+		d, err := r.desc(addr)
+		if err != nil || d.kind != kindStruct {
+			continue
+		}
+		fields, _ := r.fields(addr)
+		for _, f := range fields {
+			fsize, _ := r.word(f.typ)
+			if f.rawOffset+fsize > d.size {
+				r.oldFieldOffsets = true
+				return
+			}
+		}
+	}
+}
+
+// collectNamed indexes the named types reachable from the descriptors
+// found by scanning.
+func (r *rtypes) collectNamed() {
+	r.named = make(map[string]uint64)
+	seen := make(map[uint64]bool)
+	var visit func(addr uint64)
+	visit = func(addr uint64) {
+		if addr == 0 || seen[addr] {
+			return
+		}
+		seen[addr] = true
+		d, err := r.desc(addr)
+		if err != nil {
+			return
+		}
+		if d.tflag&tflagNamed != 0 && strings.Contains(d.name, ".") {
+			if _, ok := r.named[d.name]; !ok {
+				r.named[d.name] = addr
+			}
+		}
+		if d.ptrToThis != 0 {
+			visit(r.types + d.ptrToThis)
+		}
+		base := addr + r.commonSize()
+		switch d.kind {
+		case kindArray, kindChan, kindPtr, kindSlice:
+			elem, _ := r.word(base)
+			visit(elem)
+		case kindMap:
+			key, _ := r.word(base)
+			elem, _ := r.word(base + r.ptrSize)
+			visit(key)
+			visit(elem)
+		case kindStruct:
+			fields, _ := r.fields(addr)
+			for _, f := range fields {
+				visit(f.typ)
+			}
+		}
+	}
+	for _, addr := range r.descs {
+		visit(addr)
+	}
+}
+
+func (r *rtypes) roots() (map[string]bool, error) {
+	roots := make(map[string]bool)
+	for name := range r.named {
+		roots[name] = true
+	}
+	return roots, nil
+}
+
+func (r *rtypes) rootType(name string) (dwarf.Type, error) {
+	return r.typ(r.named[name]), nil
+}
+
+func (r *rtypes) namedTypes(want func(name string) bool, fn func(name string, typ dwarf.Type)) error {
+	var names []string
+	for name := range r.named {
+		if want(name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		typ := r.typ(r.named[name])
+		if td, ok := typ.(*dwarf.TypedefType); ok {
+			typ = td.Type
+		} else if d, err := r.desc(r.named[name]); err == nil && d.kind < kindArray {
+			// A named basic type. Print its kind.
+			typ = r.basicType(d.kind, basicNames[d.kind], d.size)
+		}
+		fn(name, typ)
+	}
+	return nil
+}
+
+func (r *rtypes) interfaceMethods(name string) []string {
+	return r.imethods(r.named[name])
+}
+
+// imethods returns the method signatures of the interface type
+// described at addr, or nil if it's not a non-empty interface.
+func (r *rtypes) imethods(addr uint64) []string {
+	d, err := r.desc(addr)
+	if err != nil || d.kind != kindInterface {
+		return nil
+	}
+	p, base := r.ptrSize, addr+r.commonSize()
+	ptr, err1 := r.word(base + p)
+	n, err2 := r.word(base + 2*p)
+	if err1 != nil || err2 != nil || n > 1<<16 {
+		return nil
+	}
+	var methods []string
+	for i := uint64(0); i < n; i++ {
+		// Each abi.Imethod is a name offset and a type
+		// offset of the method's func type.
+		nameOff, err1 := r.u32(ptr + 8*i)
+		typOff, err2 := r.u32(ptr + 8*i + 4)
+		if err1 != nil || err2 != nil {
+			return nil
+		}
+		name, err := r.name(r.types + uint64(nameOff))
+		if err != nil {
+			return nil
+		}
+		// The string of an unnamed func type is its
+		// signature.
+		sig := "(...)"
+		if fd, err := r.desc(r.types + uint64(typOff)); err == nil && strings.HasPrefix(fd.name, "func(") {
+			sig = strings.TrimPrefix(fd.name, "func")
+		}
+		methods = append(methods, name+sig)
+	}
+	return methods
+}
+
+// An rdesc is the decoded common part of a type descriptor.
+type rdesc struct {
+	size      uint64
+	tflag     uint8
+	kind      uint8
+	name      string
+	ptrToThis uint64 // 0 if none
+}
+
+func (r *rtypes) desc(addr uint64) (*rdesc, error) {
+	p := r.ptrSize
+	buf, err := r.bytes(addr, r.commonSize())
+	if err != nil {
+		return nil, err
+	}
+	d := &rdesc{
+		size:      r.uintN(buf),
+		tflag:     buf[2*p+4],
+		kind:      buf[2*p+7] & kindMask,
+		ptrToThis: uint64(r.order.Uint32(buf[4*p+12:])),
+	}
+	d.name, err = r.name(r.types + uint64(r.order.Uint32(buf[4*p+8:])))
+	if err != nil {
+		return nil, err
+	}
+	if d.tflag&tflagExtraStar != 0 {
+		d.name = d.name[1:]
+	}
+	if d.tflag&tflagNamed != 0 && d.tflag&tflagUncommon != 0 {
+		// The type string qualifies names by package name.
+		// Use the import path instead, like DWARF does.
+		if size, _, ok := r.kindSize(addr); ok {
+			off, err := r.u32(addr + size)
+			if err != nil {
+				return nil, err
+			}
+			var pkg string
+			if off != 0 {
+				pkg, err = r.name(r.types + uint64(off))
+			}
+			if i := strings.Index(d.name, "."); err == nil && pkg != "" && i >= 0 {
+				d.name = pkg + d.name[i:]
+			}
+		}
+	}
+	return d, nil
+}
+
+// name decodes the abi.Name at addr.
+func (r *rtypes) name(addr uint64) (string, error) {
+	hdr, err := r.bytes(addr, 3)
+	if err != nil {
+		return "", err
+	}
+	var n, hdrLen uint64
+	if r.oldNames || hdr[1] == 0 {
+		// Names aren't empty, so a zero first length byte
+		// means this is the old encoding, even before
+		// detectEncodings has run.
+		n, hdrLen = uint64(hdr[1])<<8|uint64(hdr[2]), 3
+	} else {
+		// Varint length. Names are short, so this only reads
+		// the first few bytes.
+		buf, err := r.bytes(addr+1, 1)
+		for i := uint(0); err == nil; i += 7 {
+			b := buf[0]
+			n |= uint64(b&0x7f) << i
+			hdrLen++
+			if b&0x80 == 0 || i > 28 {
+				break
+			}
+			buf, err = r.bytes(addr+1+hdrLen, 1)
+		}
+		if err != nil {
+			return "", err
+		}
+		hdrLen++
+	}
+	if n > 1<<16 {
+		return "", fmt.Errorf("bad name at %#x", addr)
+	}
+	data, err := r.bytes(addr+hdrLen, n)
+	return string(data), err
+}
+
+// An rfield is a struct field of a type descriptor.
+type rfield struct {
+	name      string
+	typ       uint64
+	rawOffset uint64
+}
+
+func (r *rtypes) fields(addr uint64) ([]rfield, error) {
+	p := r.ptrSize
+	base := addr + r.commonSize()
+	ptr, err1 := r.word(base + p)
+	n, err2 := r.word(base + 2*p)
+	if err1 != nil || err2 != nil {
+		return nil, fmt.Errorf("bad struct descriptor at %#x", addr)
+	}
+	if n > 1<<16 {
+		return nil, fmt.Errorf("bad struct descriptor at %#x", addr)
+	}
+	fields := make([]rfield, n)
+	for i := range fields {
+		fa := ptr + uint64(i)*3*p
+		nameAddr, _ := r.word(fa)
+		fields[i].typ, _ = r.word(fa + p)
+		fields[i].rawOffset, _ = r.word(fa + 2*p)
+		name, err := r.name(nameAddr)
+		if err != nil {
+			return nil, err
+		}
+		fields[i].name = name
+	}
+	return fields, nil
+}
+
+var basicNames = [...]string{
+	kindBool: "bool", kindInt: "int", kindInt8: "int8", kindInt16: "int16",
+	kindInt32: "int32", kindInt64: "int64", kindUint: "uint", kindUint8: "uint8",
+	kindUint16: "uint16", kindUint32: "uint32", kindUint64: "uint64",
+	kindUintptr: "uintptr", kindFloat32: "float32", kindFloat64: "float64",
+	kindComplex64: "complex64", kindComplex128: "complex128",
+}
+
+func (r *rtypes) basicType(kind uint8, name string, size uint64) dwarf.Type {
+	bt := dwarf.BasicType{CommonType: dwarf.CommonType{ByteSize: int64(size), Name: name}}
+	switch kind {
+	case kindBool:
+		return &dwarf.BoolType{BasicType: bt}
+	case kindInt, kindInt8, kindInt16, kindInt32, kindInt64:
+		return &dwarf.IntType{BasicType: bt}
+	case kindFloat32, kindFloat64:
+		return &dwarf.FloatType{BasicType: bt}
+	case kindComplex64, kindComplex128:
+		return &dwarf.ComplexType{BasicType: bt}
+	}
+	return &dwarf.UintType{BasicType: bt}
+}
+
+// typ returns the type described by the descriptor at addr, shaped
+// like the type the Go linker would have described in DWARF, so the
+// typePrinter treats them the same.
+func (r *rtypes) typ(addr uint64) dwarf.Type {
+	if t, ok := r.cache[addr]; ok {
+		return t
+	}
+	d, err := r.desc(addr)
+	if err != nil {
+		t := &dwarf.UnspecifiedType{BasicType: dwarf.BasicType{CommonType: dwarf.CommonType{Name: "?"}}}
+		r.cache[addr] = t
+		return t
+	}
+	p, base := r.ptrSize, addr+r.commonSize()
+	named := d.tflag&tflagNamed != 0
+	common := dwarf.CommonType{ByteSize: int64(d.size)}
+
+	// Named composite types are typedefs of their underlying
+	// type. Record the typedef before decoding the underlying
+	// type, which may refer back to this one.
+	var td *dwarf.TypedefType
+	if named && d.kind >= kindArray && d.kind != kindUnsafePointer {
+		td = &dwarf.TypedefType{CommonType: dwarf.CommonType{ByteSize: int64(d.size), Name: d.name}}
+		r.cache[addr] = td
+	} else {
+		switch d.kind {
+		case kindArray, kindPtr, kindSlice, kindStruct:
+			// The linker doesn't name these in DWARF,
+			// so the typePrinter spells them out, which
+			// strips the package from the names in them.
+		default:
+			common.Name = d.name
+		}
+	}
+	set := func(t dwarf.Type) dwarf.Type {
+		if td != nil {
+			td.Type = t
+			return td
+		}
+		r.cache[addr] = t
+		return t
+	}
+
+	switch d.kind {
+	case kindArray:
+		elem, _ := r.word(base)
+		n, _ := r.word(base + 2*p)
+		t := &dwarf.ArrayType{CommonType: common, Count: int64(n)}
+		set(t)
+		t.Type = r.typ(elem)
+
+	case kindChan, kindFunc, kindMap:
+		// These are pointers to runtime structures, which
+		// ptype shows by their Go type.
+		under := d.name
+		switch d.kind {
+		case kindChan:
+			elem, _ := r.word(base)
+			dir, _ := r.word(base + p)
+			under = [...]string{1: "<-chan ", 2: "chan<- ", 3: "chan "}[dir&3] + r.typ(elem).Common().Name
+		case kindMap:
+			key, _ := r.word(base)
+			elem, _ := r.word(base + p)
+			under = "map[" + r.typ(key).Common().Name + "]" + r.typ(elem).Common().Name
+		case kindFunc:
+			if td != nil {
+				under = "func(...)"
+			}
+		}
+		ptr := &dwarf.PtrType{
+			CommonType: dwarf.CommonType{ByteSize: int64(p)},
+			Type:       &dwarf.StructType{StructName: under, Kind: "struct"},
+		}
+		if td != nil {
+			return set(ptr)
+		}
+		t := &dwarf.TypedefType{CommonType: common, Type: ptr}
+		r.cache[addr] = t
+		return t
+
+	case kindInterface:
+		n, _ := r.word(base + 2*p)
+		name := "runtime.iface"
+		if n == 0 {
+			name = "runtime.eface"
+		}
+		st := &dwarf.StructType{CommonType: dwarf.CommonType{ByteSize: int64(d.size)}, StructName: name, Kind: "struct"}
+		if td != nil {
+			return set(st)
+		}
+		t := &dwarf.TypedefType{CommonType: common, Type: st}
+		r.cache[addr] = t
+		return t
+
+	case kindPtr:
+		elem, _ := r.word(base)
+		t := &dwarf.PtrType{CommonType: common}
+		set(t)
+		t.Type = r.typ(elem)
+
+	case kindSlice:
+		elem, _ := r.word(base)
+		t := &dwarf.StructType{CommonType: common, Kind: "struct"}
+		set(t)
+		e := r.typ(elem)
+		t.StructName = "[]" + e.Common().Name
+		if td != nil {
+			t.StructName = d.name
+		}
+		t.Field = r.sliceFields("array", &dwarf.PtrType{CommonType: dwarf.CommonType{ByteSize: int64(p), Name: "*" + e.Common().Name}, Type: e}, "cap")
+
+	case kindString:
+		t := &dwarf.StructType{CommonType: common, StructName: "string", Kind: "struct"}
+		u8 := r.basicType(kindUint8, "uint8", 1)
+		t.Field = r.sliceFields("str", &dwarf.PtrType{CommonType: dwarf.CommonType{ByteSize: int64(p), Name: "*uint8"}, Type: u8}, "")
+		return set(t)
+
+	case kindStruct:
+		t := &dwarf.StructType{CommonType: common, StructName: d.name, Kind: "struct"}
+		set(t)
+		fields, err := r.fields(addr)
+		if err != nil {
+			t.Incomplete = true
+			break
+		}
+		for _, f := range fields {
+			off := f.rawOffset
+			if r.oldFieldOffsets {
+				off >>= 1
+			}
+			t.Field = append(t.Field, &dwarf.StructField{Name: f.name, Type: r.typ(f.typ), ByteOffset: int64(off)})
+		}
+
+	case kindUnsafePointer:
+		return set(&dwarf.PtrType{CommonType: common, Type: &dwarf.VoidType{}})
+
+	default:
+		return set(r.basicType(d.kind, d.name, d.size))
+	}
+	return r.cache[addr]
+}
+
+// sliceFields returns the fields of a slice or string header, whose
+// first field is ptr. If capName is "", there's no capacity field.
+func (r *rtypes) sliceFields(ptrName string, ptr dwarf.Type, capName string) []*dwarf.StructField {
+	p := int64(r.ptrSize)
+	intType := r.basicType(kindInt, "int", r.ptrSize)
+	fields := []*dwarf.StructField{
+		{Name: ptrName, Type: ptr, ByteOffset: 0},
+		{Name: "len", Type: intType, ByteOffset: p},
+	}
+	if capName != "" {
+		fields = append(fields, &dwarf.StructField{Name: capName, Type: intType, ByteOffset: 2 * p})
+	}
+	return fields
+}
+
+// bytes returns n bytes of the binary's memory image at addr.
+func (r *rtypes) bytes(addr, n uint64) ([]byte, error) {
+	for _, sec := range r.f.Sections {
+		if sec.Flags&elf.SHF_ALLOC == 0 || sec.Type == elf.SHT_NOBITS {
+			continue
+		}
+		if addr < sec.Addr || addr-sec.Addr >= sec.Size {
+			continue
+		}
+		data, err := r.section(sec)
+		if err != nil {
+			return nil, err
+		}
+		off := addr - sec.Addr
+		if n > uint64(len(data))-off {
+			break
+		}
+		return data[off : off+n], nil
+	}
+	return nil, fmt.Errorf("address %#x not in binary", addr)
+}
+
+func (r *rtypes) section(sec *elf.Section) ([]byte, error) {
+	if data, ok := r.secData[sec]; ok {
+		return data, nil
+	}
+	data, err := sec.Data()
+	if err != nil {
+		return nil, err
+	}
+	r.secData[sec] = data
+	return data, nil
+}
+
+func (r *rtypes) uintN(b []byte) uint64 {
+	if r.ptrSize == 4 {
+		return uint64(r.order.Uint32(b))
+	}
+	return r.order.Uint64(b)
+}
+
+func (r *rtypes) word(addr uint64) (uint64, error) {
+	b, err := r.bytes(addr, r.ptrSize)
+	if err != nil {
+		return 0, err
+	}
+	return r.uintN(b), nil
+}
+
+func (r *rtypes) u32(addr uint64) (uint32, error) {
+	b, err := r.bytes(addr, 4)
+	if err != nil {
+		return 0, err
+	}
+	return r.order.Uint32(b), nil
+}
+
+func (r *rtypes) u16(addr uint64) (uint16, error) {
+	b, err := r.bytes(addr, 2)
+	if err != nil {
+		return 0, err
+	}
+	return r.order.Uint16(b), nil
+}
+
+func (r *rtypes) u8(addr uint64) (uint8, error) {
+	b, err := r.bytes(addr, 1)
+	if err != nil {
+		return 0, err
+	}
+	return b[0], nil
+}
@@ -0,0 +1,79 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"debug/elf"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// TestRTypes checks that ptype prints the same types from the runtime
+// type descriptors of a binary without DWARF as it does from DWARF.
+func TestRTypes(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("ptype only reads ELF binaries")
+	}
+	goTool, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go tool not found")
+	}
+	dir := t.TempDir()
+	build := func(name, ldflags string) string {
+		t.Helper()
+		bin := filepath.Join(dir, name)
+		cmd := exec.Command(goTool, "build", "-buildmode=exe", "-ldflags="+ldflags, "-o", bin, "./testdata/prog")
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("building test program: %v\n%s", err, out)
+		}
+		return bin
+	}
+	print := func(bin string, args ...string) string {
+		t.Helper()
+		var buf bytes.Buffer
+		if err := printTypes(&buf, bin, args, 0, ""); err != nil {
+			t.Fatal(err)
+		}
+		return buf.String()
+	}
+
+	withDWARF := build("dwarf", "")
+	for _, test := range []struct {
+		name, ldflags string
+	}{
+		// Without DWARF, but with the runtime.firstmoduledata
+		// symbol.
+		{"nodwarf", "-w"},
+		// Without symbols, so ptype has to find the moduledata.
+		{"stripped", "-s -w"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			bin := build(test.name, test.ldflags)
+			f, err := elf.Open(bin)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+			if _, err := f.DWARF(); err == nil {
+				t.Fatalf("%s has DWARF", bin)
+			}
+			for _, args := range [][]string{
+				{`main\.Inner`},
+				{`main\.Outer`},
+				{`main\.List`},
+				{"main.Outer.In.B"},
+				{"main.Outer.Next"},
+			} {
+				want := print(withDWARF, args...)
+				if got := print(bin, args...); got != want {
+					t.Errorf("%v: got:\n%s\nwant (from DWARF):\n%s", args, got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,40 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Command prog declares types for the ptype tests.
+package main
+
+import "fmt"
+
+type Inner struct {
+	A int32
+	B [3]uint16
+}
+
+type Outer struct {
+	X    int64
+	In   Inner
+	P    *Inner
+	PP   **Inner
+	AP   [2]*Inner
+	S    []Inner
+	Str  string
+	M    map[Kind]*Inner
+	Ch   chan Inner
+	F    func(Inner) error
+	I    fmt.Stringer
+	E    interface{}
+	K    Kind
+	Next *Outer
+}
+
+type Kind uint8
+
+type List []Outer
+
+func main() {
+	var o interface{} = Outer{}
+	var l interface{} = List{}
+	fmt.Println(o, l)
+}
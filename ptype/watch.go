@@ -0,0 +1,152 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+// watch polls binPath every interval and calls print each time it
+// changes. If diff is set, it prints only the differences from the
+// previous output. It never returns.
+func watch(binPath string, interval time.Duration, diff bool, print func(w io.Writer) error) {
+	var last os.FileInfo
+	var prev []string
+	for ; ; time.Sleep(interval) {
+		fi, err := os.Stat(binPath)
+		if err != nil || (last != nil && fi.ModTime().Equal(last.ModTime()) && fi.Size() == last.Size()) {
+			continue
+		}
+		// The linker may still be writing the binary. Wait
+		// until it stops changing.
+		time.Sleep(interval)
+		fi2, err := os.Stat(binPath)
+		if err != nil || !fi2.ModTime().Equal(fi.ModTime()) || fi2.Size() != fi.Size() {
+			continue
+		}
+		last = fi
+
+		var buf bytes.Buffer
+		if err := print(&buf); err != nil {
+			log.Print(err)
+			continue
+		}
+		out := strings.SplitAfter(buf.String(), "\n")
+		if out[len(out)-1] == "" {
+			out = out[:len(out)-1]
+		}
+		if prev == nil {
+			os.Stdout.Write(buf.Bytes())
+		} else {
+			fmt.Printf("==> %s changed at %s\n", binPath, fi.ModTime().Format("15:04:05"))
+			if diff {
+				printDiff(os.Stdout, prev, out)
+			} else {
+				os.Stdout.Write(buf.Bytes())
+			}
+		}
+		prev = out
+	}
+}
+
+// printDiff prints the lines that differ between old and new to w,
+// with a few lines of context.
+func printDiff(w io.Writer, old, new []string) {
+	const context = 2
+
+	// Trim the common prefix and suffix, which is usually most
+	// of the output, so the LCS below is small.
+	pre := 0
+	for pre < len(old) && pre < len(new) && old[pre] == new[pre] {
+		pre++
+	}
+	suf := 0
+	for suf < len(old)-pre && suf < len(new)-pre && old[len(old)-1-suf] == new[len(new)-1-suf] {
+		suf++
+	}
+	if pre == len(old) && pre == len(new) {
+		fmt.Fprintf(w, "(no changes)\n")
+		return
+	}
+	a, b := old[pre:len(old)-suf], new[pre:len(new)-suf]
+
+	// Compute the edit script from the longest common
+	// subsequence of a and b. If that would be too expensive,
+	// just replace all of a with all of b.
+	var edits []string
+	if len(a)*len(b) > 1<<22 {
+		for _, l := range a {
+			edits = append(edits, "-"+l)
+		}
+		for _, l := range b {
+			edits = append(edits, "+"+l)
+		}
+	} else {
+		lcs := make([][]int, len(a)+1)
+		for i := range lcs {
+			lcs[i] = make([]int, len(b)+1)
+		}
+		for i := len(a) - 1; i >= 0; i-- {
+			for j := len(b) - 1; j >= 0; j-- {
+				if a[i] == b[j] {
+					lcs[i][j] = lcs[i+1][j+1] + 1
+				} else if lcs[i+1][j] >= lcs[i][j+1] {
+					lcs[i][j] = lcs[i+1][j]
+				} else {
+					lcs[i][j] = lcs[i][j+1]
+				}
+			}
+		}
+		i, j := 0, 0
+		for i < len(a) || j < len(b) {
+			switch {
+			case i < len(a) && j < len(b) && a[i] == b[j]:
+				edits = append(edits, " "+a[i])
+				i, j = i+1, j+1
+			case j == len(b) || (i < len(a) && lcs[i+1][j] >= lcs[i][j+1]):
+				edits = append(edits, "-"+a[i])
+				i++
+			default:
+				edits = append(edits, "+"+b[j])
+				j++
+			}
+		}
+	}
+
+	// Print changed lines with context, eliding long runs of
+	// unchanged lines.
+	var lines []string
+	for _, l := range old[:pre] {
+		lines = append(lines, " "+l)
+	}
+	lines = append(lines, edits...)
+	for _, l := range new[len(new)-suf:] {
+		lines = append(lines, " "+l)
+	}
+	keep := make([]bool, len(lines))
+	for i, l := range lines {
+		if l[0] == ' ' {
+			continue
+		}
+		for j := i - context; j <= i+context; j++ {
+			if j >= 0 && j < len(lines) {
+				keep[j] = true
+			}
+		}
+	}
+	for i, l := range lines {
+		if keep[i] {
+			fmt.Fprint(w, l)
+		} else if i > 0 && keep[i-1] {
+			fmt.Fprintf(w, "...\n")
+		}
+	}
+}
@@ -0,0 +1,186 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/tools/go/ssa"
+)
+
+// annotations are user-supplied facts about runtime functions that
+// tune the precision of the analysis. A nil *annotations has no
+// annotations.
+type annotations struct {
+	// roots are additional root functions in package runtime.
+	roots []string
+
+	// noEffect are patterns of functions whose calls are assumed
+	// to have no locking effects. The analysis doesn't walk into
+	// them.
+	noEffect []string
+
+	// exclusive are pairs of indexes into exclusiveFns of
+	// functions that are never called concurrently.
+	exclusive    [][2]int
+	exclusiveFns []string
+
+	noEffectCache map[*ssa.Function]bool
+	throughCache  map[*ssa.Function]uint64
+}
+
+// readAnnotations reads an annotations file. Each line of the file
+// is a directive followed by function patterns:
+//
+//	noeffect FUNC...    Assume calls to FUNC have no locking effects.
+//	root FUNC...        Analyze runtime function FUNC as a root.
+//	exclusive FUNC1 FUNC2
+//	                    FUNC1 and FUNC2 never run concurrently, so
+//	                    don't report cycles that require both.
+//
+// Blank lines and lines starting with # are ignored. See matchFunc
+// for the syntax of function patterns.
+func readAnnotations(path string) (*annotations, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	ann := new(annotations)
+	fnIndex := make(map[string]int)
+	exclusiveFn := func(pattern string) int {
+		if i, ok := fnIndex[pattern]; ok {
+			return i
+		}
+		fnIndex[pattern] = len(ann.exclusiveFns)
+		ann.exclusiveFns = append(ann.exclusiveFns, pattern)
+		return fnIndex[pattern]
+	}
+
+	scanner := bufio.NewScanner(f)
+	for lineno := 1; scanner.Scan(); lineno++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fs := strings.Fields(line)
+		switch fs[0] {
+		case "noeffect":
+			ann.noEffect = append(ann.noEffect, fs[1:]...)
+		case "root":
+			for _, name := range fs[1:] {
+				name = strings.TrimPrefix(name, "runtime.")
+				if strings.ContainsAny(name, ".()*$") {
+					return nil, fmt.Errorf("%s:%d: root %s is not a function in package runtime", path, lineno, name)
+				}
+				ann.roots = append(ann.roots, name)
+			}
+		case "exclusive":
+			if len(fs) != 3 {
+				return nil, fmt.Errorf("%s:%d: exclusive takes two functions", path, lineno)
+			}
+			ann.exclusive = append(ann.exclusive, [2]int{exclusiveFn(fs[1]), exclusiveFn(fs[2])})
+		default:
+			return nil, fmt.Errorf("%s:%d: unknown directive %q", path, lineno, fs[0])
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(ann.exclusiveFns) > 64 {
+		return nil, fmt.Errorf("%s: too many exclusive functions (%d > 64)", path, len(ann.exclusiveFns))
+	}
+	return ann, nil
+}
+
+// matchFunc reports whether fn matches pattern. A pattern is either
+// a function name as printed by ssa, such as "runtime.mallocgc" or
+// "(*runtime.mheap).alloc", a bare name, which is a function in
+// package runtime, or "path.*", which matches everything in package
+// path. A function pattern also matches closures in that function.
+func matchFunc(pattern string, fn *ssa.Function) bool {
+	if pkg := strings.TrimSuffix(pattern, ".*"); pkg != pattern {
+		return fn.Pkg != nil && fn.Pkg.Pkg.Path() == pkg
+	}
+	if !strings.ContainsAny(pattern, ".(") {
+		pattern = "runtime." + pattern
+	}
+	name := fn.String()
+	return name == pattern || strings.HasPrefix(name, pattern+"$")
+}
+
+// isNoEffect reports whether calls to fn should be assumed to have
+// no locking effects.
+func (ann *annotations) isNoEffect(fn *ssa.Function) bool {
+	if ann == nil || len(ann.noEffect) == 0 {
+		return false
+	}
+	if v, ok := ann.noEffectCache[fn]; ok {
+		return v
+	}
+	v := false
+	for _, pattern := range ann.noEffect {
+		if matchFunc(pattern, fn) {
+			v = true
+			break
+		}
+	}
+	if ann.noEffectCache == nil {
+		ann.noEffectCache = make(map[*ssa.Function]bool)
+	}
+	ann.noEffectCache[fn] = v
+	return v
+}
+
+// through returns a bit mask of the exclusive functions that stack
+// passes through. Bit i is set if stack passes through
+// ann.exclusiveFns[i].
+func (ann *annotations) through(stack *StackFrame) uint64 {
+	if ann == nil || len(ann.exclusive) == 0 {
+		return 0
+	}
+	var mask uint64
+	for ; stack != nil; stack = stack.parent {
+		fn := stack.call.Parent()
+		bits, ok := ann.throughCache[fn]
+		if !ok {
+			for i, pattern := range ann.exclusiveFns {
+				if matchFunc(pattern, fn) {
+					bits |= 1 << uint(i)
+				}
+			}
+			if ann.throughCache == nil {
+				ann.throughCache = make(map[*ssa.Function]uint64)
+			}
+			ann.throughCache[fn] = bits
+		}
+		mask |= bits
+	}
+	return mask
+}
+
+// excludes reports whether a lock cycle is impossible because its
+// steps can't all run concurrently. steps[i] is the mask of exclusive
+// functions that every path of step i of the cycle passes through.
+func (ann *annotations) excludes(steps []uint64) bool {
+	if ann == nil {
+		return false
+	}
+	for _, pair := range ann.exclusive {
+		a, b := uint64(1)<<uint(pair[0]), uint64(1)<<uint(pair[1])
+		for i := range steps {
+			for j := range steps {
+				if i != j && steps[i]&a != 0 && steps[j]&b != 0 {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,121 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"golang.org/x/tools/go/ssa"
+	"golang.org/x/tools/go/ssa/ssautil"
+)
+
+func TestReadAnnotations(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ann.txt")
+	data := "# comment\nnoeffect traceEvent runtime/internal/atomic.*\nroot runtime.forEachP\n\nexclusive gcStart gcMarkTermination\nexclusive gcStart sweepone\n"
+	if err := os.WriteFile(path, []byte(data), 0666); err != nil {
+		t.Fatal(err)
+	}
+	ann, err := readAnnotations(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"traceEvent", "runtime/internal/atomic.*"}; !reflect.DeepEqual(ann.noEffect, want) {
+		t.Errorf("noEffect = %v, want %v", ann.noEffect, want)
+	}
+	if want := []string{"forEachP"}; !reflect.DeepEqual(ann.roots, want) {
+		t.Errorf("roots = %v, want %v", ann.roots, want)
+	}
+	if want := []string{"gcStart", "gcMarkTermination", "sweepone"}; !reflect.DeepEqual(ann.exclusiveFns, want) {
+		t.Errorf("exclusiveFns = %v, want %v", ann.exclusiveFns, want)
+	}
+	if want := [][2]int{{0, 1}, {0, 2}}; !reflect.DeepEqual(ann.exclusive, want) {
+		t.Errorf("exclusive = %v, want %v", ann.exclusive, want)
+	}
+
+	// A cycle is excluded only if different steps pass through
+	// an exclusive pair.
+	for _, test := range []struct {
+		steps []uint64
+		want  bool
+	}{
+		{[]uint64{1, 2}, true},
+		{[]uint64{4, 1}, true},
+		{[]uint64{3, 0}, false},
+		{[]uint64{1, 1}, false},
+		{[]uint64{3}, false},
+	} {
+		if got := ann.excludes(test.steps); got != test.want {
+			t.Errorf("excludes(%v) = %v, want %v", test.steps, got, test.want)
+		}
+	}
+
+	for _, bad := range []string{"bogus f\n", "exclusive f\n", "root (*mheap).alloc\n"} {
+		if err := os.WriteFile(path, []byte(bad), 0666); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := readAnnotations(path); err == nil {
+			t.Errorf("readAnnotations(%q) succeeded, want error", bad)
+		}
+	}
+}
+
+func TestMatchFunc(t *testing.T) {
+	const src = `package runtime
+
+type mheap struct{}
+
+func (h *mheap) alloc() {}
+
+func traceEvent() { func() {}() }
+
+func traceEventLocked() {}
+`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "trace.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg, _, err := ssautil.BuildPackage(&types.Config{Importer: importer.Default()}, fset, types.NewPackage("runtime", "runtime"), []*ast.File{f}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	traceEvent := pkg.Func("traceEvent")
+	alloc := pkg.Prog.FuncValue(pkg.Type("mheap").Type().(*types.Named).Method(0))
+	fns := map[string]*ssa.Function{
+		"traceEvent":       traceEvent,
+		"traceEvent$1":     traceEvent.AnonFuncs[0],
+		"traceEventLocked": pkg.Func("traceEventLocked"),
+		"alloc":            alloc,
+	}
+
+	for _, test := range []struct {
+		pattern string
+		want    []string
+	}{
+		{"traceEvent", []string{"traceEvent", "traceEvent$1"}},
+		{"runtime.traceEvent", []string{"traceEvent", "traceEvent$1"}},
+		{"(*runtime.mheap).alloc", []string{"alloc"}},
+		{"runtime.*", []string{"alloc", "traceEvent", "traceEvent$1", "traceEventLocked"}},
+		{"sync.*", nil},
+	} {
+		var got []string
+		for _, name := range []string{"alloc", "traceEvent", "traceEvent$1", "traceEventLocked"} {
+			if matchFunc(test.pattern, fns[name]) {
+				got = append(got, name)
+			}
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("matchFunc(%q) matched %v, want %v", test.pattern, got, test.want)
+		}
+	}
+}
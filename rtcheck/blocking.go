@@ -0,0 +1,87 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import "golang.org/x/tools/go/ssa"
+
+// A blockingOp is how the analysis models a call to a function that
+// blocks on or wakes a semaphore or note. The semaphore or note is
+// the function's first argument, and its lock class is found just
+// like a mutex's.
+type blockingOp int
+
+const (
+	// semAcquire acquires a semaphore. The runtime's semaphores
+	// that are acquired and released by the same thread, such as
+	// worldsema, are mutexes, so this is modeled like lock.
+	semAcquire blockingOp = iota
+
+	// semRelease releases a semaphore, like unlock.
+	semRelease
+
+	// noteSleep sleeps until a note is woken. This adds edges from
+	// the held locks to the note, but doesn't hold the note.
+	noteSleep
+
+	// noteWakeup wakes a note. A thread sleeping on the note may
+	// need to wait for any of the locks held by the waker, so
+	// this adds edges from the note to the held locks. Together
+	// with noteSleep, a cycle means a thread may sleep on a note
+	// while holding a lock that the waker needs to reach the
+	// wakeup.
+	noteWakeup
+)
+
+// blockingFns maps from function names (the result of
+// ssa.Function.String()) to how to model them. Like runtimeFns, this
+// names the functions of the runtime being analyzed, so it needs to
+// be updated if they change.
+var blockingFns = map[string]blockingOp{
+	"runtime.semacquire":  semAcquire,
+	"runtime.semacquire1": semAcquire,
+	"runtime.semrelease":  semRelease,
+	"runtime.semrelease1": semRelease,
+
+	"runtime.notesleep":   noteSleep,
+	"runtime.notetsleep":  noteSleep,
+	"runtime.notetsleepg": noteSleep,
+	"runtime.notewakeup":  noteWakeup,
+}
+
+// handler returns the callHandler for op.
+func (op blockingOp) handler() callHandler {
+	switch op {
+	case semAcquire:
+		return lockHandler(false, false)
+	case semRelease:
+		return unlockHandler(false, false)
+	case noteSleep:
+		return handleNoteSleep
+	case noteWakeup:
+		return handleNoteWakeup
+	}
+	panic("bad blockingOp")
+}
+
+func handleNoteSleep(s *state, ps PathState, instr ssa.Instruction, newps []PathState) []PathState {
+	// Equivalent to acquiring and releasing the note.
+	note, err := s.lca.Get(instr.(*ssa.Call).Call.Args[0])
+	if err != nil {
+		s.warnl(instr.Pos(), "%s", err)
+		return append(newps, ps)
+	}
+	s.lockOrder.Add(ps.lockSet, NewLockSet().Plus(note, s.stack), s.stack)
+	return append(newps, ps)
+}
+
+func handleNoteWakeup(s *state, ps PathState, instr ssa.Instruction, newps []PathState) []PathState {
+	note, err := s.lca.Get(instr.(*ssa.Call).Call.Args[0])
+	if err != nil {
+		s.warnl(instr.Pos(), "%s", err)
+		return append(newps, ps)
+	}
+	s.lockOrder.AddWake(ps.lockSet, note, s.stack)
+	return append(newps, ps)
+}
@@ -12,7 +12,7 @@ import (
 	"golang.org/x/tools/go/ssa"
 )
 
-// TODO: Stack barrier locks, semaphores, etc.
+// TODO: Stack barrier locks, etc.
 
 // A callHandler implements special handling of a function call. It
 // should append the updated PathState to newps and return the
@@ -43,8 +43,21 @@ func init() {
 	// function pointers and function calls, so we have to
 	// initialize callHandlers outside of the initialization order.
 	callHandlers = map[string]callHandler{
-		"runtime.lock":   handleRuntimeLock,
-		"runtime.unlock": handleRuntimeUnlock,
+		"runtime.lock":   lockHandler(false, true),
+		"runtime.unlock": unlockHandler(false, true),
+
+		// Reader/writer locks. Read acquisitions are tracked
+		// as a separate lock class so that they don't conflict
+		// with each other. The runtime's rwmutex holds an M
+		// while locked, like runtime.lock.
+		"(*runtime.rwmutex).rlock":   lockHandler(true, true),
+		"(*runtime.rwmutex).runlock": unlockHandler(true, true),
+		"(*runtime.rwmutex).lock":    lockHandler(false, true),
+		"(*runtime.rwmutex).unlock":  unlockHandler(false, true),
+		"(*sync.RWMutex).RLock":      lockHandler(true, false),
+		"(*sync.RWMutex).RUnlock":    unlockHandler(true, false),
+		"(*sync.RWMutex).Lock":       lockHandler(false, false),
+		"(*sync.RWMutex).Unlock":     unlockHandler(false, false),
 
 		"runtime.casgstatus":          handleRuntimeCasgstatus,
 		"runtime.castogscanstatus":    handleRuntimeCastogscanstatus,
@@ -66,69 +79,92 @@ func init() {
 		// from the runtime.
 		"runtime.restartg": handleRuntimeCasfrom_Gscanstatus,
 	}
+	for name, op := range blockingFns {
+		callHandlers[name] = op.handler()
+	}
 }
 
-func handleRuntimeLock(s *state, ps PathState, instr ssa.Instruction, newps []PathState) []PathState {
-	lock, err := s.lca.Get(instr.(*ssa.Call).Call.Args[0])
-	if err != nil {
-		s.warnl(instr.Pos(), "%s", err)
-	} else {
-		newls := NewLockSet().Plus(lock, s.stack)
-		s.lockOrder.Add(ps.lockSet, newls, s.stack)
-		ls2 := ps.lockSet.Plus(lock, s.stack)
-		// If we self-deadlocked, terminate this path.
-		//
-		// TODO: This is only sound if we know it's the same lock
-		// *instance*.
-		if ps.lockSet == ls2 {
-			s.warnp(instr.Pos(), "possible self-deadlock %s %s; trimming path", ps.lockSet, lock)
-			return newps
+// lockHandler returns a callHandler for a function that acquires
+// the lock pointed to by its first argument. If read is true, the
+// lock is acquired in read (shared) mode. If mlocks is true,
+// acquiring the lock increments m.locks.
+func lockHandler(read, mlocks bool) callHandler {
+	return func(s *state, ps PathState, instr ssa.Instruction, newps []PathState) []PathState {
+		lock, err := s.lca.Get(instr.(*ssa.Call).Call.Args[0])
+		if err != nil {
+			s.warnl(instr.Pos(), "%s", err)
+		} else {
+			if read {
+				lock = lock.Read()
+			}
+			newls := NewLockSet().Plus(lock, s.stack)
+			s.lockOrder.Add(ps.lockSet, newls, s.stack)
+			ls2 := ps.lockSet.Plus(lock, s.stack)
+			// If we self-deadlocked, terminate this path.
+			//
+			// TODO: This is only sound if we know it's the same lock
+			// *instance*.
+			if ps.lockSet == ls2 {
+				s.warnp(instr.Pos(), "possible self-deadlock %s %s; trimming path", ps.lockSet, lock)
+				return newps
+			}
+			ps.lockSet = ls2
+		}
+		if !mlocks {
+			return append(newps, ps)
 		}
-		ps.lockSet = ls2
-	}
 
-	// m.locks++
-	mlocks := ps.vs.GetHeap(s.heap.curM_locks).(DynConst)
-	nlocks, _ := constant.Int64Val(mlocks.c)
-	const maxLocks = 16
-	if nlocks >= maxLocks {
-		s.warnp(instr.Pos(), "%d locks held; trimming path", nlocks)
-		return newps
+		// m.locks++
+		mlocks := ps.vs.GetHeap(s.heap.curM_locks).(DynConst)
+		nlocks, _ := constant.Int64Val(mlocks.c)
+		const maxLocks = 16
+		if nlocks >= maxLocks {
+			s.warnp(instr.Pos(), "%d locks held; trimming path", nlocks)
+			return newps
+		}
+		ps.vs = ps.vs.ExtendHeap(s.heap.curM_locks, mlocks.BinOp(token.ADD, DynConst{constant.MakeInt64(1)}))
+		return append(newps, ps)
 	}
-	ps.vs = ps.vs.ExtendHeap(s.heap.curM_locks, mlocks.BinOp(token.ADD, DynConst{constant.MakeInt64(1)}))
-	return append(newps, ps)
 }
 
-func handleRuntimeUnlock(s *state, ps PathState, instr ssa.Instruction, newps []PathState) []PathState {
-	held := false
-	lock, err := s.lca.Get(instr.(*ssa.Call).Call.Args[0])
-	if err != nil {
-		s.warnl(instr.Pos(), "%s", err)
-	} else {
-		held = ps.lockSet.Contains(lock)
-		ps.lockSet = ps.lockSet.Minus(lock)
-		if !held {
-			// TODO: Perhaps warn more stringently if this is a
-			// single instance lock class, though even then we
-			// could be confused by control flow.
-			s.warnl(instr.Pos(), "possible unlock of unlocked lock")
+// unlockHandler returns a callHandler for a function that releases
+// the lock pointed to by its first argument. read and mlocks must
+// match the corresponding lockHandler.
+func unlockHandler(read, mlocks bool) callHandler {
+	return func(s *state, ps PathState, instr ssa.Instruction, newps []PathState) []PathState {
+		held := false
+		lock, err := s.lca.Get(instr.(*ssa.Call).Call.Args[0])
+		if err != nil {
+			s.warnl(instr.Pos(), "%s", err)
+		} else {
+			if read {
+				lock = lock.Read()
+			}
+			held = ps.lockSet.Contains(lock)
+			ps.lockSet = ps.lockSet.Minus(lock)
+			if !held {
+				// TODO: Perhaps warn more stringently if this is a
+				// single instance lock class, though even then we
+				// could be confused by control flow.
+				s.warnl(instr.Pos(), "possible unlock of unlocked lock")
+			}
 		}
-	}
 
-	// m.locks-- if lock is held. We only do this conditionally
-	// because sometimes our handling of correlated control flow
-	// leads to *three* paths: both lock and unlock, neither lock
-	// or unlock, and just unlock.
-	if held {
-		mlocks := ps.vs.GetHeap(s.heap.curM_locks).(DynConst)
-		if constant.Compare(mlocks.c, token.LEQ, constant.MakeInt64(0)) {
-			// Terminate path.
-			s.warnp(instr.Pos(), "unlock with m.locks <= 0; trimming path")
-			return newps
+		// m.locks-- if lock is held. We only do this conditionally
+		// because sometimes our handling of correlated control flow
+		// leads to *three* paths: both lock and unlock, neither lock
+		// or unlock, and just unlock.
+		if held && mlocks {
+			mlocks := ps.vs.GetHeap(s.heap.curM_locks).(DynConst)
+			if constant.Compare(mlocks.c, token.LEQ, constant.MakeInt64(0)) {
+				// Terminate path.
+				s.warnp(instr.Pos(), "unlock with m.locks <= 0; trimming path")
+				return newps
+			}
+			ps.vs = ps.vs.ExtendHeap(s.heap.curM_locks, mlocks.BinOp(token.SUB, DynConst{constant.MakeInt64(1)}))
 		}
-		ps.vs = ps.vs.ExtendHeap(s.heap.curM_locks, mlocks.BinOp(token.SUB, DynConst{constant.MakeInt64(1)}))
+		return append(newps, ps)
 	}
-	return append(newps, ps)
 }
 
 func handleRuntimeCasgstatus(s *state, ps PathState, instr ssa.Instruction, newps []PathState) []PathState {
@@ -22,6 +22,14 @@ type LockClass struct {
 	isUnique bool
 	id       int
 	lca      *LockClassAnalysis
+
+	// exclusive is the lock class of exclusive acquisitions of
+	// the same locks if this lock class represents read
+	// acquisitions, or nil otherwise.
+	exclusive *LockClass
+	// read is the lock class representing read acquisitions of
+	// this lock class, or nil if it hasn't been created yet.
+	read *LockClass
 }
 
 func (lc *LockClass) Analysis() *LockClassAnalysis {
@@ -29,6 +37,9 @@ func (lc *LockClass) Analysis() *LockClassAnalysis {
 }
 
 func (lc *LockClass) String() string {
+	if lc.exclusive != nil {
+		return lc.exclusive.String() + " (read)"
+	}
 	if !lc.isUnique {
 		return lc.label + "*"
 	}
@@ -40,6 +51,27 @@ func (lc *LockClass) IsUnique() bool {
 	return lc.isUnique
 }
 
+// IsRead returns true if lc represents read (shared) acquisitions of
+// a reader/writer lock.
+func (lc *LockClass) IsRead() bool {
+	return lc.exclusive != nil
+}
+
+// Read returns the lock class representing read (shared)
+// acquisitions of the locks in lc. Read acquisitions of a lock don't
+// conflict with each other, but conflict with exclusive acquisitions
+// of the same lock, so they're tracked as a separate lock class.
+func (lc *LockClass) Read() *LockClass {
+	if lc.exclusive != nil {
+		return lc
+	}
+	if lc.read == nil {
+		lc.read = lc.lca.NewLockClass(lc.label, lc.isUnique)
+		lc.read.exclusive = lc
+	}
+	return lc.read
+}
+
 // Id returns a small integer ID for this lock class that is unique
 // within the LockClassAnalysis that returned this *LockClass.
 func (lc *LockClass) Id() int {
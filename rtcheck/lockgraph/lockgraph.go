@@ -0,0 +1,328 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package lockgraph represents the lock graph computed by rtcheck.
+//
+// A Graph can be written to and read from JSON so other tools can
+// load and query rtcheck results without re-running the analysis.
+package lockgraph
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/token"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Version is the current version of the JSON encoding of Graph.
+const Version = 1
+
+// A Graph is a lock graph. Nodes are lock classes and there is an
+// edge from lock class A to lock class B if some path acquires B
+// while holding A. A graph with a cycle indicates a potential
+// deadlock.
+type Graph struct {
+	// Version is the encoding version. ReadJSON rejects graphs
+	// with an unknown version.
+	Version int
+
+	// Locks is the set of lock classes. A lock's ID is its index
+	// in Locks.
+	Locks []Lock
+
+	// Edges is the set of edges in the lock graph, sorted by
+	// From and then To.
+	Edges []*Edge
+
+	// Cycles lists the cycles rtcheck reports. These are the
+	// cycles returned by FindCycles, minus any that rtcheck's
+	// annotations rule out. It may be nil if the cycles weren't
+	// computed.
+	Cycles [][]int `json:",omitempty"`
+
+	// Platforms lists the GOOS/GOARCH platforms the graph was
+	// computed for, if the analysis was run for specific
+	// platforms. See Merge.
+	Platforms []string `json:",omitempty"`
+}
+
+// A Lock is a lock class.
+type Lock struct {
+	// Name is the name of the lock class, such as
+	// "runtime.mheap_.lock" or "runtime.mspan.speciallock*".
+	Name string
+
+	// Unique is true if this lock class is inhabited by a
+	// single lock instance.
+	Unique bool `json:",omitempty"`
+
+	// Read is true if this lock class represents read (shared)
+	// acquisitions of a reader/writer lock. Read acquisitions of
+	// a lock don't conflict with each other. Exclusive is the ID
+	// of the lock class representing exclusive acquisitions of
+	// the same lock.
+	Read      bool `json:",omitempty"`
+	Exclusive int  `json:",omitempty"`
+}
+
+// An Edge records that lock class To was acquired while holding
+// lock class From.
+type Edge struct {
+	From, To int
+
+	// Paths lists the distinct paths that acquire From and then
+	// To.
+	Paths []Path
+
+	// Platforms lists the platforms of the graph's Platforms on
+	// which some path acquires From and then To.
+	Platforms []string `json:",omitempty"`
+}
+
+// A Path is a pair of call stacks that acquire the two locks of an
+// Edge. Both stacks start in the common function RootFn.
+type Path struct {
+	RootFn   string
+	From, To []Frame
+}
+
+// A Frame is one step of a Path. The last frame of a stack acquires
+// the lock, and all earlier frames are calls.
+type Frame struct {
+	Op  string // "calls F" or "acquires L"
+	Pos token.Position
+}
+
+// Edge returns the edge from lock from to lock to, or nil if there
+// is no such edge.
+func (g *Graph) Edge(from, to int) *Edge {
+	i := sort.Search(len(g.Edges), func(i int) bool {
+		e := g.Edges[i]
+		return e.From > from || (e.From == from && e.To >= to)
+	})
+	if i < len(g.Edges) && g.Edges[i].From == from && g.Edges[i].To == to {
+		return g.Edges[i]
+	}
+	return nil
+}
+
+// Sort sorts g.Edges into the order required by Graph.
+func (g *Graph) Sort() {
+	sort.Slice(g.Edges, func(i, j int) bool {
+		a, b := g.Edges[i], g.Edges[j]
+		if a.From != b.From {
+			return a.From < b.From
+		}
+		return a.To < b.To
+	})
+}
+
+// exclusive returns the ID of the exclusive lock class of lock id.
+func (g *Graph) exclusive(id int) int {
+	if g.Locks[id].Read {
+		return g.Locks[id].Exclusive
+	}
+	return id
+}
+
+// Conflicts returns whether acquiring lock class a can block on a
+// lock held as lock class b. This is true if they are the same lock
+// and at least one of them is an exclusive acquisition.
+func (g *Graph) Conflicts(a, b int) bool {
+	return g.exclusive(a) == g.exclusive(b) && !(g.Locks[a].Read && g.Locks[b].Read)
+}
+
+// FindCycles returns the elementary cycles in g. Each cycle is a
+// list of lock IDs in cycle order (without any repetition), starting
+// with the lowest ID in the cycle.
+//
+// Each lock in a cycle is held while acquiring a lock class that
+// conflicts with the next lock in the cycle. For ordinary locks, this
+// is just the next lock, but a cycle through read acquisitions must
+// have an exclusive acquisition on at least one side of each step.
+// CycleEdges returns the edges that make up each step of a cycle.
+func (g *Graph) FindCycles() [][]int {
+	// Find the read class of each exclusive class, if any.
+	readOf := make(map[int]int)
+	for id, l := range g.Locks {
+		if l.Read {
+			readOf[l.Exclusive] = id
+		}
+	}
+	// Construct a graph where each node is a held lock class
+	// and edges lead to the lock classes that may then be held
+	// and conflict with the acquired lock.
+	type pair struct{ from, to int }
+	seen := make(map[pair]bool)
+	out := make(map[int][]int)
+	add := func(from, to int) {
+		if !seen[pair{from, to}] {
+			seen[pair{from, to}] = true
+			out[from] = append(out[from], to)
+		}
+	}
+	for _, e := range g.Edges {
+		x := g.exclusive(e.To)
+		add(e.From, x)
+		if r, ok := readOf[x]; ok && g.Conflicts(e.To, r) {
+			add(e.From, r)
+		}
+	}
+	return FindCycles(out)
+}
+
+// CycleEdges returns the edges that make up each step of cycle, which
+// must be a cycle returned by FindCycles. Element i of the result
+// lists the edges from cycle[i] to lock classes that conflict with
+// cycle[i+1].
+func (g *Graph) CycleEdges(cycle []int) [][]*Edge {
+	steps := make([][]*Edge, len(cycle))
+	for i, from := range cycle {
+		next := cycle[(i+1)%len(cycle)]
+		j := sort.Search(len(g.Edges), func(j int) bool { return g.Edges[j].From >= from })
+		for ; j < len(g.Edges) && g.Edges[j].From == from; j++ {
+			if g.Conflicts(g.Edges[j].To, next) {
+				steps[i] = append(steps[i], g.Edges[j])
+			}
+		}
+	}
+	return steps
+}
+
+// FindCycles returns the elementary cycles in the graph with
+// adjacency list out. Each cycle is a list of node IDs in cycle
+// order (without any repetition), starting with the lowest ID in the
+// cycle. Cycles are ordered by their first node.
+func FindCycles(out map[int][]int) [][]int {
+	// Use DFS to find cycles.
+	//
+	// TODO: Implement a real cycle-finding algorithm. This one is
+	// terrible.
+	path, pathSet := []int{}, map[int]struct{}{}
+	cycles := [][]int{}
+	var dfs func(root, node int)
+	dfs = func(root, node int) {
+		if _, ok := pathSet[node]; ok {
+			// Only report as a cycle if we got back to
+			// where we started and this is the lowest
+			// numbered node in the cycle. This gets us
+			// each elementary cycle exactly once.
+			if node == root {
+				minNode := node
+				for _, n := range path {
+					if n < minNode {
+						minNode = n
+					}
+				}
+				if node == minNode {
+					pathCopy := append([]int(nil), path...)
+					cycles = append(cycles, pathCopy)
+				}
+			}
+			return
+		}
+		pathSet[node] = struct{}{}
+		path = append(path, node)
+		for _, next := range out[node] {
+			dfs(root, next)
+		}
+		path = path[:len(path)-1]
+		delete(pathSet, node)
+	}
+	roots := make([]int, 0, len(out))
+	for root := range out {
+		roots = append(roots, root)
+	}
+	sort.Ints(roots)
+	for _, root := range roots {
+		dfs(root, root)
+	}
+	return cycles
+}
+
+// WriteCycles writes a text report of the cycles in g to w.
+//
+// This report is thorough, but can be quite repetitive, since a
+// single edge can participate in multiple cycles.
+func (g *Graph) WriteCycles(w io.Writer) {
+	g.WriteCycleList(w, g.FindCycles())
+}
+
+// WriteCycleList is like WriteCycles, but reports only cycles, which
+// must be a subset of the cycles returned by FindCycles.
+func (g *Graph) WriteCycleList(w io.Writer, cycles [][]int) {
+	printStack := func(stack []Frame) {
+		indent := 6
+		for _, fr := range stack {
+			fmt.Fprintf(w, "%*s%s at %s\n", indent, "", fr.Op, fr.Pos)
+			indent += 2
+		}
+	}
+	for _, cycle := range cycles {
+		cycle = append(cycle[:len(cycle):len(cycle)], cycle[0])
+		fmt.Fprintf(w, "lock cycle: ")
+		for i, node := range cycle {
+			if i != 0 {
+				fmt.Fprintf(w, " -> ")
+			}
+			fmt.Fprint(w, g.Locks[node].Name)
+		}
+		fmt.Fprintf(w, "\n")
+
+		for _, step := range g.CycleEdges(cycle[:len(cycle)-1]) {
+			for _, edge := range step {
+				fmt.Fprintf(w, "  %d path(s) acquire %s then %s", len(edge.Paths), g.Locks[edge.From].Name, g.Locks[edge.To].Name)
+				if len(edge.Platforms) < len(g.Platforms) {
+					fmt.Fprintf(w, " on %s", strings.Join(edge.Platforms, ", "))
+				}
+				fmt.Fprintf(w, ":\n")
+				for _, path := range edge.Paths {
+					fmt.Fprintf(w, "    %s\n", path.RootFn)
+					printStack(path.From)
+					printStack(path.To)
+				}
+				fmt.Fprintf(w, "\n")
+			}
+		}
+	}
+}
+
+// WriteJSON writes g to w in JSON.
+func (g *Graph) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "\t")
+	return enc.Encode(g)
+}
+
+// ReadJSON reads a Graph written by WriteJSON from r.
+func ReadJSON(r io.Reader) (*Graph, error) {
+	var g Graph
+	if err := json.NewDecoder(r).Decode(&g); err != nil {
+		return nil, err
+	}
+	if g.Version != Version {
+		return nil, fmt.Errorf("unsupported lock graph version %d (want %d)", g.Version, Version)
+	}
+	for _, e := range g.Edges {
+		if e.From < 0 || e.From >= len(g.Locks) || e.To < 0 || e.To >= len(g.Locks) {
+			return nil, fmt.Errorf("lock graph edge %d -> %d refers to unknown lock", e.From, e.To)
+		}
+	}
+	for _, l := range g.Locks {
+		if l.Read && (l.Exclusive < 0 || l.Exclusive >= len(g.Locks) || g.Locks[l.Exclusive].Read) {
+			return nil, fmt.Errorf("read lock %s has bad exclusive lock %d", l.Name, l.Exclusive)
+		}
+	}
+	for _, cycle := range g.Cycles {
+		for _, id := range cycle {
+			if id < 0 || id >= len(g.Locks) {
+				return nil, fmt.Errorf("lock cycle %v refers to unknown lock", cycle)
+			}
+		}
+	}
+	g.Sort()
+	return &g, nil
+}
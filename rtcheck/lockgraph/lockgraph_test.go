@@ -0,0 +1,223 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package lockgraph
+
+import (
+	"bytes"
+	"encoding/json"
+	"go/token"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func testGraph() *Graph {
+	pos := func(line int) token.Position {
+		return token.Position{Filename: "proc.go", Line: line, Column: 2}
+	}
+	path := func(root string, line int) []Path {
+		return []Path{{
+			RootFn: root,
+			From:   []Frame{{Op: "acquires A", Pos: pos(line)}},
+			To:     []Frame{{Op: "calls f", Pos: pos(line + 1)}, {Op: "acquires B", Pos: pos(line + 10)}},
+		}}
+	}
+	g := &Graph{
+		Version: Version,
+		Locks:   []Lock{{Name: "A", Unique: true}, {Name: "B*"}, {Name: "C"}},
+		Edges: []*Edge{
+			{From: 1, To: 0, Paths: path("runtime.g", 20)},
+			{From: 0, To: 1, Paths: path("runtime.f", 10)},
+			{From: 1, To: 2, Paths: path("runtime.h", 30)},
+		},
+	}
+	g.Sort()
+	return g
+}
+
+func TestRoundTrip(t *testing.T) {
+	g := testGraph()
+	var buf bytes.Buffer
+	if err := g.WriteJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+	g2, err := ReadJSON(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(g, g2) {
+		t.Errorf("round trip changed graph:\n%+v\nwant:\n%+v", g2, g)
+	}
+}
+
+func TestReadJSONErrors(t *testing.T) {
+	for _, test := range []struct {
+		json, err string
+	}{
+		{`{"Version": 2}`, "unsupported lock graph version"},
+		{`{"Version": 1, "Locks": [{"Name": "A"}], "Edges": [{"From": 0, "To": 1}]}`, "unknown lock"},
+		{`{"Version": 1, "Locks": [{"Name": "A", "Read": true}]}`, "bad exclusive lock"},
+	} {
+		_, err := ReadJSON(strings.NewReader(test.json))
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("ReadJSON(%s): got error %v, want %q", test.json, err, test.err)
+		}
+	}
+}
+
+func TestFindCycles(t *testing.T) {
+	g := testGraph()
+	want := [][]int{{0, 1}}
+	if got := g.FindCycles(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got cycles %v, want %v", got, want)
+	}
+	if e := g.Edge(1, 2); e == nil || e.Paths[0].RootFn != "runtime.h" {
+		t.Errorf("Edge(1, 2) = %+v, want edge with root runtime.h", e)
+	}
+	if e := g.Edge(2, 1); e != nil {
+		t.Errorf("Edge(2, 1) = %+v, want nil", e)
+	}
+}
+
+func TestReadCycles(t *testing.T) {
+	// A is a reader/writer lock. B acquires A for reading, which
+	// only conflicts with the path that holds A for writing.
+	g := &Graph{
+		Version: Version,
+		Locks:   []Lock{{Name: "A"}, {Name: "A (read)", Read: true, Exclusive: 0}, {Name: "B"}},
+		Edges: []*Edge{
+			{From: 0, To: 2},
+			{From: 1, To: 2},
+			{From: 2, To: 1},
+		},
+	}
+	g.Sort()
+	want := [][]int{{0, 2}}
+	got := g.FindCycles()
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got cycles %v, want %v", got, want)
+	}
+	steps := g.CycleEdges(got[0])
+	if len(steps) != 2 || len(steps[0]) != 1 || steps[0][0] != g.Edge(0, 2) || len(steps[1]) != 1 || steps[1][0] != g.Edge(2, 1) {
+		t.Errorf("got cycle edges %v, want [[0->2] [2->1]]", steps)
+	}
+}
+
+func TestSARIF(t *testing.T) {
+	g := testGraph()
+	g.Cycles = g.FindCycles()
+	var buf bytes.Buffer
+	if err := g.WriteSARIF(&buf, g.Cycles, "/goroot"); err != nil {
+		t.Fatal(err)
+	}
+	var log struct {
+		Runs []struct {
+			Results []struct {
+				RuleID    string
+				Message   struct{ Text string }
+				Locations []struct {
+					PhysicalLocation struct {
+						ArtifactLocation struct{ URI, URIBaseID string }
+						Region           struct{ StartLine int }
+					}
+				}
+				PartialFingerprints map[string]string
+				CodeFlows           []json.RawMessage
+			}
+		}
+	}
+	if err := json.Unmarshal(buf.Bytes(), &log); err != nil {
+		t.Fatal(err)
+	}
+	if len(log.Runs) != 1 || len(log.Runs[0].Results) != 1 {
+		t.Fatalf("want 1 run with 1 result, got:\n%s", buf.String())
+	}
+	res := log.Runs[0].Results[0]
+	if res.RuleID != SARIFRule || res.Message.Text != "lock cycle: A -> B* -> A" {
+		t.Errorf("got rule %q, message %q", res.RuleID, res.Message.Text)
+	}
+	if len(res.CodeFlows) != 2 {
+		t.Errorf("got %d code flows, want 2", len(res.CodeFlows))
+	}
+	if len(res.Locations) != 1 || res.Locations[0].PhysicalLocation.Region.StartLine != 20 {
+		t.Errorf("got locations %+v, want line 20", res.Locations)
+	}
+
+	// The fingerprint doesn't depend on lock IDs.
+	g2 := testGraph()
+	g2.Locks[0], g2.Locks[1] = g2.Locks[1], g2.Locks[0]
+	swap := func(id int) int {
+		if id < 2 {
+			return 1 - id
+		}
+		return id
+	}
+	for _, e := range g2.Edges {
+		e.From, e.To = swap(e.From), swap(e.To)
+	}
+	g2.Sort()
+	buf.Reset()
+	if err := g2.WriteSARIF(&buf, g2.FindCycles(), ""); err != nil {
+		t.Fatal(err)
+	}
+	fp := res.PartialFingerprints["lockCycle/v1"]
+	if !strings.Contains(buf.String(), fp) {
+		t.Errorf("fingerprint %s changed when lock IDs changed:\n%s", fp, buf.String())
+	}
+}
+
+func TestMerge(t *testing.T) {
+	g1 := testGraph()
+	g1.Cycles = g1.FindCycles()
+
+	// g2 has the A->B edge, a new edge, and B in a different
+	// order. Its B is the same as g1's B but A is not unique.
+	g2 := &Graph{
+		Version: Version,
+		Locks:   []Lock{{Name: "B*"}, {Name: "D"}, {Name: "A"}},
+		Edges: []*Edge{
+			{From: 2, To: 0, Paths: testGraph().Edge(0, 1).Paths},
+			{From: 0, To: 1},
+		},
+	}
+	g2.Sort()
+
+	g := Merge([]*Graph{g1, g2}, []string{"linux/amd64", "windows/arm64"})
+	wantLocks := []Lock{{Name: "A"}, {Name: "B*"}, {Name: "C"}, {Name: "D"}}
+	if !reflect.DeepEqual(g.Locks, wantLocks) {
+		t.Errorf("got locks %+v, want %+v", g.Locks, wantLocks)
+	}
+	for _, test := range []struct {
+		from, to  int
+		paths     int
+		platforms []string
+	}{
+		{0, 1, 1, []string{"linux/amd64", "windows/arm64"}},
+		{1, 0, 1, []string{"linux/amd64"}},
+		{1, 2, 1, []string{"linux/amd64"}},
+		{1, 3, 0, []string{"windows/arm64"}},
+	} {
+		e := g.Edge(test.from, test.to)
+		if e == nil {
+			t.Errorf("missing edge %d->%d", test.from, test.to)
+			continue
+		}
+		if len(e.Paths) != test.paths || !reflect.DeepEqual(e.Platforms, test.platforms) {
+			t.Errorf("edge %d->%d: got %d paths on %v, want %d paths on %v", test.from, test.to, len(e.Paths), e.Platforms, test.paths, test.platforms)
+		}
+	}
+	if len(g.Edges) != 4 {
+		t.Errorf("got %d edges, want 4", len(g.Edges))
+	}
+	if want := [][]int{{0, 1}}; !reflect.DeepEqual(g.Cycles, want) {
+		t.Errorf("got cycles %v, want %v", g.Cycles, want)
+	}
+
+	var buf bytes.Buffer
+	g.WriteCycleList(&buf, g.Cycles)
+	if want := "acquire B* then A on linux/amd64:"; !strings.Contains(buf.String(), want) {
+		t.Errorf("cycle list does not contain %q:\n%s", want, buf.String())
+	}
+}
@@ -0,0 +1,110 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package lockgraph
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Merge combines the lock graphs of the same code analyzed for
+// different platforms into one graph. platforms[i] names the platform
+// of gs[i].
+//
+// Locks are identified by name. A merged lock is unique if it's
+// unique in every graph it appears in. Each merged edge has the
+// paths of the edge in every graph, and its Platforms lists the
+// platforms it appears on. The merged Cycles are the cycles of each
+// graph. It doesn't add cycles made of edges from different
+// platforms, since no single platform can take all of their paths.
+func Merge(gs []*Graph, platforms []string) *Graph {
+	out := &Graph{Version: Version, Locks: []Lock{}, Edges: []*Edge{}, Platforms: platforms}
+	ids := make(map[string]int)
+	lockID := func(l Lock) int {
+		id, ok := ids[l.Name]
+		if !ok {
+			id = len(out.Locks)
+			ids[l.Name] = id
+			l.Exclusive = 0
+			out.Locks = append(out.Locks, l)
+		} else if !l.Unique {
+			out.Locks[id].Unique = false
+		}
+		return id
+	}
+
+	type edgeKey struct{ from, to int }
+	edges := make(map[edgeKey]*Edge)
+	cycles := make(map[string]bool)
+	for i, g := range gs {
+		// Map g's lock IDs to merged lock IDs.
+		idMap := make([]int, len(g.Locks))
+		for id, l := range g.Locks {
+			idMap[id] = lockID(l)
+		}
+		for id, l := range g.Locks {
+			if l.Read {
+				out.Locks[idMap[id]].Exclusive = idMap[l.Exclusive]
+			}
+		}
+
+		for _, e := range g.Edges {
+			k := edgeKey{idMap[e.From], idMap[e.To]}
+			me := edges[k]
+			if me == nil {
+				me = &Edge{From: k.from, To: k.to}
+				edges[k] = me
+				out.Edges = append(out.Edges, me)
+			}
+			me.Paths = append(me.Paths, e.Paths...)
+			me.Platforms = append(me.Platforms, platforms[i])
+		}
+
+		for _, cycle := range g.Cycles {
+			mc := make([]int, len(cycle))
+			for j, id := range cycle {
+				mc[j] = idMap[id]
+			}
+			// Rotate the cycle to start with its lowest ID,
+			// like FindCycles.
+			min := 0
+			for j := range mc {
+				if mc[j] < mc[min] {
+					min = j
+				}
+			}
+			mc = append(mc[min:], mc[:min]...)
+			if key := fmt.Sprint(mc); !cycles[key] {
+				cycles[key] = true
+				out.Cycles = append(out.Cycles, mc)
+			}
+		}
+	}
+
+	// Remove duplicate paths.
+	for _, e := range out.Edges {
+		sort.Slice(e.Paths, func(i, j int) bool {
+			return fmt.Sprint(e.Paths[i]) < fmt.Sprint(e.Paths[j])
+		})
+		paths := e.Paths[:0]
+		for i, p := range e.Paths {
+			if i == 0 || fmt.Sprint(p) != fmt.Sprint(e.Paths[i-1]) {
+				paths = append(paths, p)
+			}
+		}
+		e.Paths = paths
+	}
+	sort.Slice(out.Cycles, func(i, j int) bool {
+		a, b := out.Cycles[i], out.Cycles[j]
+		for k := 0; k < len(a) && k < len(b); k++ {
+			if a[k] != b[k] {
+				return a[k] < b[k]
+			}
+		}
+		return len(a) < len(b)
+	})
+	out.Sort()
+	return out
+}
@@ -0,0 +1,200 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package lockgraph
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"path/filepath"
+	"strings"
+)
+
+// SARIFRule is the rule ID of lock cycle results in SARIF logs.
+const SARIFRule = "lock-cycle"
+
+// The subset of SARIF 2.1.0 used by WriteSARIF. See
+// https://docs.oasis-open.org/sarif/sarif/v2.1.0/sarif-v2.1.0.html.
+type (
+	sarifLog struct {
+		Schema  string     `json:"$schema"`
+		Version string     `json:"version"`
+		Runs    []sarifRun `json:"runs"`
+	}
+	sarifRun struct {
+		Tool               sarifTool                `json:"tool"`
+		OriginalURIBaseIDs map[string]sarifArtifact `json:"originalUriBaseIds,omitempty"`
+		Results            []sarifResult            `json:"results"`
+	}
+	sarifTool struct {
+		Driver sarifDriver `json:"driver"`
+	}
+	sarifDriver struct {
+		Name           string      `json:"name"`
+		InformationURI string      `json:"informationUri"`
+		Rules          []sarifRule `json:"rules"`
+	}
+	sarifRule struct {
+		ID               string       `json:"id"`
+		ShortDescription sarifMessage `json:"shortDescription"`
+	}
+	sarifMessage struct {
+		Text string `json:"text"`
+	}
+	sarifResult struct {
+		RuleID              string            `json:"ruleId"`
+		Level               string            `json:"level"`
+		Message             sarifMessage      `json:"message"`
+		Locations           []sarifLocation   `json:"locations"`
+		PartialFingerprints map[string]string `json:"partialFingerprints"`
+		CodeFlows           []sarifCodeFlow   `json:"codeFlows"`
+	}
+	sarifLocation struct {
+		PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+		Message          *sarifMessage         `json:"message,omitempty"`
+	}
+	sarifPhysicalLocation struct {
+		ArtifactLocation sarifArtifact `json:"artifactLocation"`
+		Region           *sarifRegion  `json:"region,omitempty"`
+	}
+	sarifArtifact struct {
+		URI       string `json:"uri"`
+		URIBaseID string `json:"uriBaseId,omitempty"`
+	}
+	sarifRegion struct {
+		StartLine   int `json:"startLine"`
+		StartColumn int `json:"startColumn,omitempty"`
+	}
+	sarifCodeFlow struct {
+		Message     sarifMessage      `json:"message"`
+		ThreadFlows []sarifThreadFlow `json:"threadFlows"`
+	}
+	sarifThreadFlow struct {
+		Locations []sarifThreadFlowLocation `json:"locations"`
+	}
+	sarifThreadFlowLocation struct {
+		Location sarifLocation `json:"location"`
+	}
+)
+
+// WriteSARIF writes cycles, which must be a subset of the cycles
+// returned by FindCycles, to w as a SARIF 2.1.0 log, so tools such
+// as code scanning services can track them.
+//
+// Each cycle is one result. Its location is where the first path of
+// the cycle's first edge acquires the second lock, and it has a code
+// flow for each path of each edge in the cycle, from the root
+// function to where each lock is acquired. Its "lockCycle/v1"
+// partial fingerprint depends only on the names of the locks in the
+// cycle, so it identifies the same cycle across runs and code
+// changes.
+//
+// Source files under srcRoot are given relative to the SRCROOT URI
+// base ID. For example, if the runtime was loaded from $GOROOT/src,
+// srcRoot should be $GOROOT so locations are relative to the root of
+// the Go repository. If srcRoot is "", all paths are absolute.
+func (g *Graph) WriteSARIF(w io.Writer, cycles [][]int, srcRoot string) error {
+	artifact := func(path string) sarifArtifact {
+		if srcRoot != "" {
+			if rel, err := filepath.Rel(srcRoot, path); err == nil && !strings.HasPrefix(rel, "..") {
+				return sarifArtifact{URI: filepath.ToSlash(rel), URIBaseID: "SRCROOT"}
+			}
+		}
+		return sarifArtifact{URI: fileURI(path)}
+	}
+	location := func(fr Frame) sarifLocation {
+		loc := sarifLocation{PhysicalLocation: sarifPhysicalLocation{ArtifactLocation: artifact(fr.Pos.Filename)}}
+		if fr.Pos.Line > 0 {
+			loc.PhysicalLocation.Region = &sarifRegion{StartLine: fr.Pos.Line, StartColumn: fr.Pos.Column}
+		}
+		return loc
+	}
+
+	run := sarifRun{
+		Tool: sarifTool{Driver: sarifDriver{
+			Name:           "rtcheck",
+			InformationURI: "https://github.com/aclements/go-misc/tree/master/rtcheck",
+			Rules: []sarifRule{{
+				ID:               SARIFRule,
+				ShortDescription: sarifMessage{"Cycle in the lock order, indicating a potential deadlock"},
+			}},
+		}},
+		Results: []sarifResult{},
+	}
+	if srcRoot != "" {
+		run.OriginalURIBaseIDs = map[string]sarifArtifact{
+			"SRCROOT": {URI: fileURI(srcRoot) + "/"},
+		}
+	}
+
+	for _, cycle := range cycles {
+		names := make([]string, len(cycle)+1)
+		for i, id := range cycle {
+			names[i] = g.Locks[id].Name
+		}
+		names[len(cycle)] = names[0]
+		res := sarifResult{
+			RuleID:              SARIFRule,
+			Level:               "warning",
+			Message:             sarifMessage{"lock cycle: " + strings.Join(names, " -> ")},
+			Locations:           []sarifLocation{},
+			PartialFingerprints: map[string]string{"lockCycle/v1": cycleFingerprint(names[:len(cycle)])},
+			CodeFlows:           []sarifCodeFlow{},
+		}
+		for _, step := range g.CycleEdges(cycle) {
+			for _, edge := range step {
+				for _, path := range edge.Paths {
+					if len(res.Locations) == 0 && len(path.To) > 0 {
+						res.Locations = append(res.Locations, location(path.To[len(path.To)-1]))
+					}
+					var tfl []sarifThreadFlowLocation
+					for _, stack := range [][]Frame{path.From, path.To} {
+						for _, fr := range stack {
+							loc := location(fr)
+							loc.Message = &sarifMessage{fr.Op}
+							tfl = append(tfl, sarifThreadFlowLocation{loc})
+						}
+					}
+					res.CodeFlows = append(res.CodeFlows, sarifCodeFlow{
+						Message:     sarifMessage{fmt.Sprintf("%s acquires %s then %s", path.RootFn, g.Locks[edge.From].Name, g.Locks[edge.To].Name)},
+						ThreadFlows: []sarifThreadFlow{{tfl}},
+					})
+				}
+			}
+		}
+		run.Results = append(run.Results, res)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "\t")
+	return enc.Encode(sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs:    []sarifRun{run},
+	})
+}
+
+// cycleFingerprint returns a fingerprint of the cycle of lock names.
+// Lock IDs vary between runs, so this rotates the cycle to start at
+// the least name.
+func cycleFingerprint(names []string) string {
+	min := 0
+	for i, name := range names {
+		if name < names[min] {
+			min = i
+		}
+	}
+	rotated := append(names[min:len(names):len(names)], names[:min]...)
+	sum := sha256.Sum256([]byte(strings.Join(rotated, "\n")))
+	return fmt.Sprintf("%x", sum[:16])
+}
+
+// fileURI returns a file URI for path.
+func fileURI(path string) string {
+	u := url.URL{Scheme: "file", Path: filepath.ToSlash(path)}
+	return u.String()
+}
@@ -4,19 +4,30 @@
 
 // Command rtcheck performs static analysis of the Go runtime.
 //
-// Note: Currently this requires a small modification to
-// golang.org/x/tools/go/pointer:
+// rtcheck loads the runtime with golang.org/x/tools/go/packages,
+// substituting rewritten runtime sources through the loader's
+// overlay, and builds it into SSA form. It then constructs a call
+// graph, which resolves calls through function values and interface
+// methods. By default, it uses Variable Type Analysis (VTA), which
+// refines a Class Hierarchy Analysis (CHA) call graph by tracking
+// which types and functions flow to each dynamic call. With -fast,
+// it instead uses Rapid Type Analysis (RTA) to construct the call
+// graph. This is faster, but less precise: indirect calls are
+// resolved to every address-taken function or method of a matching
+// type, so the report may contain lock cycles on paths that are
+// impossible at runtime. This is useful for quick feedback on small
+// changes, but a clean -fast report should be confirmed with the full
+// analysis.
 //
-//     --- a/go/pointer/intrinsics.go
-//     +++ b/go/pointer/intrinsics.go
-//     @@ -180,7 +180,6 @@ func (a *analysis) findIntrinsic(fn *ssa.Function) intrinsic {
-//      			// Ignore "runtime" (except SetFinalizer):
-//      			// it has few interesting effects on aliasing
-//      			// and is full of unsafe code we can't analyze.
-//     -			impl = ext۰NoEffect
-//      		}
-//
-//      		a.intrinsics[fn] = impl
+// Analyzing the whole runtime is slow. -scope restricts the analysis
+// to the parts of the runtime that matter to a list of functions,
+// given as patterns in the same form as the -annotations file.
+// rtcheck keeps the functions on call paths from the roots to the
+// functions in scope and everything the functions in scope can call,
+// and replaces the bodies of all other functions with empty stubs.
+// This makes the analysis much smaller, but it misses lock cycles
+// through the stubbed functions, as well as calls made through
+// interface methods that got stubbed.
 //
 // rtcheck currently implements one analysis:
 //
@@ -37,6 +48,64 @@
 // other edges, fixing those code paths is likely the easiest way to
 // fix the deadlock.
 //
+// Besides mutexes, the lock graph includes the runtime's semaphores
+// and notes, as listed in blockingFns. A semaphore is treated like a
+// mutex. Sleeping on a note N while holding L adds an edge L -> N,
+// and waking N while holding L adds an edge N -> L, since the sleeper
+// may have to wait for the waker to get L. A cycle through N means a
+// thread may sleep on N while holding a lock that N's waker needs.
+//
+// With -html, rtcheck writes the report as a self-contained web page
+// showing the lock graph. Clicking an edge shows its code paths, the
+// lock filter hides lock classes whose names don't match a regexp,
+// and the cycle list can be sorted by the weakest edge of each cycle,
+// by total path count, or by length.
+//
+// With -json, rtcheck also writes the lock graph, including the code
+// paths for each edge, in the JSON format defined by package
+// github.com/aclements/go-misc/rtcheck/lockgraph. Other tools can
+// load and query this without re-running the analysis. The graph's
+// Cycles field lists the reported lock cycles.
+//
+// With -sarif, rtcheck writes the reported lock cycles as a SARIF
+// log, with a result for each cycle whose code flows are the code
+// paths of its edges. Source locations are relative to $GOROOT. Each
+// result has a fingerprint derived from the names of its locks, so
+// CI systems that understand SARIF can tell new lock cycles from
+// known ones.
+//
+// By default, rtcheck analyzes the runtime as built for the host
+// GOOS/GOARCH. -platforms analyzes it for each of a list of
+// platforms, such as "linux/amd64,windows/arm64", or for every
+// platform with "all", and merges the results into one report. Locks
+// are matched across platforms by name. Edges that don't occur on
+// every platform are labeled with the platforms they occur on, and
+// the -json lock graph records these in the Platforms fields. Each
+// platform is a full analysis, so this takes as much time as running
+// rtcheck once per platform.
+//
+// The analysis starts from a set of root functions in the runtime. By
+// default, these are the functions the compiler can generate calls
+// to, which rtcheck finds in the compiler's declarations of the
+// runtime builtins in $GOROOT. -roots and -rootsfile replace these
+// with a list of function names. Roots that don't exist in the
+// analyzed runtime are reported and ignored.
+//
+// The -annotations file tunes the analysis with facts about specific
+// functions: that calls to a function (or every function in a
+// package) have no locking effects, that a function should be
+// analyzed as an additional root, or that two functions never run
+// concurrently, in which case rtcheck doesn't report cycles that
+// need both of them. For example:
+//
+//	noeffect printlock printunlock
+//	noeffect internal/runtime/atomic.*
+//	root forEachP
+//	exclusive gcStart gcMarkTermination
+//
+// Exclusive annotations only affect the reported cycles; the edges of
+// the -json lock graph are unfiltered.
+//
 // This uses an inter-procedural, path-sensitive, and partially
 // value-sensitive analysis based on Engler and Ashcroft, "RacerX:
 // Effective, static detection of race conditions and deadlocks", SOSP
@@ -104,13 +173,15 @@ import (
 	"os"
 	"path/filepath"
 	"reflect"
-	"runtime"
+	"sort"
 	"strings"
 
-	"golang.org/x/tools/go/buildutil"
+	"github.com/aclements/go-misc/rtcheck/lockgraph"
 	"golang.org/x/tools/go/callgraph"
-	"golang.org/x/tools/go/loader"
-	"golang.org/x/tools/go/pointer"
+	"golang.org/x/tools/go/callgraph/cha"
+	"golang.org/x/tools/go/callgraph/rta"
+	"golang.org/x/tools/go/callgraph/vta"
+	"golang.org/x/tools/go/packages"
 	"golang.org/x/tools/go/ssa"
 	"golang.org/x/tools/go/ssa/ssautil"
 )
@@ -125,12 +196,28 @@ func main() {
 		outLockGraph string
 		outCallGraph string
 		outHTML      string
+		outJSON      string
+		outSARIF     string
 		debugFuncs   string
+		fast         bool
+		rootNames    string
+		rootsFile    string
+		annFile      string
+		scope        string
+		platformList string
 	)
 	flag.StringVar(&outLockGraph, "lockgraph", "", "write lock graph in dot to `file`")
 	flag.StringVar(&outCallGraph, "callgraph", "", "write call graph in dot to `file`")
 	flag.StringVar(&outHTML, "html", "", "write HTML deadlock report to `file`")
+	flag.StringVar(&outJSON, "json", "", "write lock graph in JSON to `file` (see package lockgraph)")
+	flag.StringVar(&outSARIF, "sarif", "", "write lock cycles as a SARIF log to `file`")
 	flag.StringVar(&debugFuncs, "debugfuncs", "", "write debug graphs for `funcs` (comma-separated list)")
+	flag.BoolVar(&fast, "fast", false, "use a fast but less precise call graph (RTA instead of VTA)")
+	flag.StringVar(&rootNames, "roots", "", "analyze runtime `funcs` (comma-separated list) instead of the functions the compiler calls")
+	flag.StringVar(&rootsFile, "rootsfile", "", "analyze the runtime functions listed in `file`, one per line, or declared in file if it is a Go source file")
+	flag.StringVar(&annFile, "annotations", "", "read function annotations from `file` (see readAnnotations)")
+	flag.StringVar(&platformList, "platforms", "", "analyze the runtime for each GOOS/GOARCH in `list` (comma-separated, or \"all\") and merge the results")
+	flag.StringVar(&scope, "scope", "", "analyze only the code relevant to `funcs` (comma-separated list of patterns) and stub out the rest")
 	flag.Parse()
 	if flag.NArg() > 0 {
 		flag.Usage()
@@ -140,22 +227,114 @@ func main() {
 		debugFunctions[name] = true
 	}
 
-	roots := getDefaultRoots()
+	roots, err := getRoots(rootNames, rootsFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var ann *annotations
+	if annFile != "" {
+		ann, err = readAnnotations(annFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		roots = append(roots, ann.roots...)
+	}
 
-	var conf loader.Config
+	var platforms []platform
+	if platformList != "" {
+		platforms, err = parsePlatforms(platformList)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if len(platforms) > 1 && (outLockGraph != "" || outHTML != "" || outCallGraph != "" || debugFuncs != "") {
+			log.Fatal("-lockgraph, -html, -callgraph, and -debugfuncs require a single platform")
+		}
+	}
 
-	// TODO: Check all reasonable arch/OS combos.
+	// Analyze the runtime for each platform and merge the lock
+	// graphs.
+	var s *state
+	var g *lockgraph.Graph
+	var analyzed []string
+	if platforms == nil {
+		s = analyze(&build.Default, roots, ann, scope, fast, outCallGraph)
+		g = s.lockOrder.Graph()
+		for _, fn := range s.roots {
+			analyzed = append(analyzed, fn.String())
+		}
+	} else {
+		var gs []*lockgraph.Graph
+		var names []string
+		haveRoot := make(map[string]bool)
+		for _, p := range platforms {
+			log.Printf("analyzing %s", p)
+			s = analyze(p.context(), roots, ann, scope, fast, outCallGraph)
+			gs = append(gs, s.lockOrder.Graph())
+			names = append(names, p.String())
+			for _, fn := range s.roots {
+				if name := fn.String(); !haveRoot[name] {
+					haveRoot[name] = true
+					analyzed = append(analyzed, name)
+				}
+			}
+		}
+		g = lockgraph.Merge(gs, names)
+	}
 
-	// TODO: This would be so much easier and nicer if I could
-	// just plug (path, AST)s into the loader, or at least slip in
-	// between when the loader has parsed everything and when it
-	// type-checks everything. Currently it's only possible to
-	// provide ASTs for non-importable packages to the
-	// loader.Config.
+	// Output lock graph.
+	if outLockGraph != "" {
+		withWriter(outLockGraph, s.lockOrder.WriteToDot)
+	}
 
+	// Output HTML report.
+	if outHTML != "" {
+		withWriter(outHTML, s.lockOrder.WriteToHTML)
+	}
+
+	// Output JSON lock graph.
+	if outJSON != "" {
+		withWriter(outJSON, func(w io.Writer) {
+			if err := g.WriteJSON(w); err != nil {
+				log.Fatal(err)
+			}
+		})
+	}
+
+	// Output SARIF lock cycle report.
+	if outSARIF != "" {
+		withWriter(outSARIF, func(w io.Writer) {
+			if err := g.WriteSARIF(w, g.Cycles, build.Default.GOROOT); err != nil {
+				log.Fatal(err)
+			}
+		})
+	}
+
+	// Output text lock cycle report.
+	fmt.Println()
+	if g.Platforms != nil {
+		fmt.Printf("platforms: %s\n", strings.Join(g.Platforms, " "))
+	}
+	fmt.Printf("roots: %s\n", strings.Join(analyzed, " "))
+	if fast {
+		fmt.Println("fast mode: call graph is imprecise; confirm results without -fast")
+	}
+	if scope != "" {
+		fmt.Printf("scoped to %s: functions outside scope were not analyzed\n", scope)
+	}
+	fmt.Printf("number of lock cycles: %d\n\n", len(g.Cycles))
+	g.WriteCycleList(os.Stdout, g.Cycles)
+}
+
+// analyze loads the runtime for build context ctxt and runs the
+// deadlock analysis from roots. If outCallGraph is not "", it writes
+// the call graph to that file.
+func analyze(ctxt *build.Context, roots []string, ann *annotations, scope string, fast bool, outCallGraph string) *state {
+	// Rewrite the runtime sources. These replace the original
+	// files through the loader's overlay, so they must be
+	// complete source files.
 	newSources := make(map[string][]byte)
-	for _, pkgName := range []string{"runtime", "runtime/internal/atomic"} {
-		buildPkg, err := build.Import(pkgName, "", 0)
+	for _, pkgName := range []string{"runtime", atomicPkg(ctxt)} {
+		buildPkg, err := ctxt.Import(pkgName, "", 0)
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -163,43 +342,67 @@ func main() {
 		if pkgName == "runtime" {
 			pkgRoots = roots
 		}
-		rewriteSources(buildPkg, pkgRoots, newSources)
+		if missing := rewriteSources(buildPkg, pkgRoots, newSources); len(missing) > 0 {
+			log.Printf("warning: ignoring roots not found in %s: %s", pkgName, strings.Join(missing, " "))
+			roots = removeRoots(roots, missing)
+		}
 	}
 
-	ctxt := &build.Default
-	ctxt = buildutil.OverlayContext(ctxt, newSources)
-
-	conf.Build = ctxt
-	conf.Import("runtime")
-
-	lprog, err := conf.Load()
+	cgo := "0"
+	if ctxt.CgoEnabled {
+		cgo = "1"
+	}
+	cfg := &packages.Config{
+		Mode:    packages.NeedName | packages.NeedFiles | packages.NeedImports | packages.NeedDeps | packages.NeedTypes | packages.NeedSyntax | packages.NeedTypesInfo,
+		Env:     append(os.Environ(), "GOOS="+ctxt.GOOS, "GOARCH="+ctxt.GOARCH, "CGO_ENABLED="+cgo),
+		Overlay: newSources,
+	}
+	pkgs, err := packages.Load(cfg, "runtime")
 	if err != nil {
 		log.Fatal("loading runtime: ", err)
 	}
-	fset := lprog.Fset
+	if packages.PrintErrors(pkgs) > 0 {
+		log.Fatal("loading runtime failed")
+	}
 
-	prog := ssautil.CreateProgram(lprog, 0)
+	prog, ssaPkgs := ssautil.AllPackages(pkgs, 0)
 	prog.Build()
-	runtimePkg := prog.ImportedPackage("runtime")
+	fset := prog.Fset
+	runtimePkg := ssaPkgs[0]
 	lookupMembers(runtimePkg, runtimeFns)
+	if fn, ok := runtimePkg.Members["slicestringcopy"].(*ssa.Function); ok {
+		fns.slicestringcopy = fn
+	} else {
+		// Go 1.16 and later copy strings with slicecopy.
+		fns.slicestringcopy = fns.slicecopy
+	}
+
+	// Slice the program down to the requested scope.
+	var stubs map[*ssa.Function]bool
+	if scope != "" {
+		stubs, err = scopeProgram(prog, runtimePkg.Func("init"), strings.Split(scope, ","))
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("stubbed out %d functions outside scope", len(stubs))
+	}
 
 	// TODO: Teach it that you can jump to sigprof at any point?
 	//
 	// TODO: Teach it about implicit write barriers?
 
-	// Prepare for pointer analysis.
-	ptrConfig := pointer.Config{
-		Mains:          []*ssa.Package{runtimePkg},
-		BuildCallGraph: true,
-		//Log:            os.Stderr,
-	}
-
-	// Run pointer analysis.
-	pta, err := pointer.Analyze(&ptrConfig)
-	if err != nil {
-		log.Fatal(err)
+	var cg *callgraph.Graph
+	if fast {
+		// Construct the call graph using RTA, starting from
+		// the package initializer, which calls the roots.
+		res := rta.Analyze([]*ssa.Function{runtimePkg.Func("init")}, true)
+		cg = res.CallGraph
+	} else {
+		// Construct the call graph using VTA, which refines
+		// the conservative CHA call graph by tracking which
+		// types flow to each dynamic call.
+		cg = vta.CallGraph(ssautil.AllFunctions(prog), cha.CallGraph(prog))
 	}
-	cg := pta.CallGraph
 
 	cg.DeleteSyntheticNodes() // ?
 
@@ -209,7 +412,7 @@ func main() {
 			type edge struct{ a, b *callgraph.Node }
 			have := make(map[edge]struct{})
 			fmt.Fprintln(w, "digraph callgraph {")
-			callgraph.GraphVisitEdges(pta.CallGraph, func(e *callgraph.Edge) error {
+			callgraph.GraphVisitEdges(cg, func(e *callgraph.Edge) error {
 				if _, ok := have[edge{e.Caller, e.Callee}]; ok {
 					return nil
 				}
@@ -221,18 +424,42 @@ func main() {
 		})
 	}
 
-	s := state{
+	// Resolve the roots.
+	var rootFns []*ssa.Function
+	for _, name := range roots {
+		m, ok := runtimePkg.Members[name].(*ssa.Function)
+		if !ok {
+			log.Printf("warning: ignoring unknown root: %s", name)
+			continue
+		}
+		if stubs[m] {
+			// Out of scope.
+			continue
+		}
+		rootFns = append(rootFns, m)
+	}
+	return analyzeRoots(fset, cg, stubs, ann, rootFns)
+}
+
+// analyzeRoots runs the deadlock analysis from the functions in roots
+// using call graph cg. The roots are normally in the runtime, but
+// tests analyze small packages that stand in for it.
+func analyzeRoots(fset *token.FileSet, cg *callgraph.Graph, stubs map[*ssa.Function]bool, ann *annotations, roots []*ssa.Function) *state {
+	s := &state{
 		fset: fset,
 		cg:   cg,
-		pta:  pta,
 		fns:  make(map[*ssa.Function]*funcInfo),
 
+		stubs: stubs,
+
 		lockOrder: NewLockOrder(fset),
+		ann:       ann,
 
 		roots:   nil,
 		rootSet: make(map[*ssa.Function]struct{}),
 	}
 	s.gscanLock = s.lca.NewLockClass("_Gscan", false)
+	s.lockOrder.ann = ann
 
 	// Create heap objects we care about.
 	//
@@ -249,12 +476,8 @@ func main() {
 	curM_printlock := NewHeapObject("curM.printlock")
 
 	// Add roots to state.
-	for _, name := range roots {
-		m, ok := runtimePkg.Members[name].(*ssa.Function)
-		if !ok {
-			log.Fatalf("unknown root: %s", name)
-		}
-		s.addRoot(m)
+	for _, fn := range roots {
+		s.addRoot(fn)
 	}
 
 	// Analyze each root. Analysis may add more roots.
@@ -306,25 +529,7 @@ func main() {
 		withWriter(fmt.Sprintf("debug-%s.dot", fn), fInfo.debugTree.WriteToDot)
 	}
 
-	// Output lock graph.
-	if outLockGraph != "" {
-		withWriter(outLockGraph, s.lockOrder.WriteToDot)
-	}
-
-	// Output HTML report.
-	if outHTML != "" {
-		withWriter(outHTML, s.lockOrder.WriteToHTML)
-	}
-
-	// Output text lock cycle report.
-	fmt.Println()
-	fmt.Print("roots:")
-	for _, fn := range s.roots {
-		fmt.Printf(" %s", fn)
-	}
-	fmt.Print("\n")
-	fmt.Printf("number of lock cycles: %d\n\n", len(s.lockOrder.FindCycles()))
-	s.lockOrder.Check(os.Stdout)
+	return s
 }
 
 // withWriter creates path and calls f with the file.
@@ -341,52 +546,12 @@ func withWriter(path string, f func(w io.Writer)) {
 	f(file)
 }
 
-// getDefaultRoots returns a list of functions in the runtime package
-// to use as roots.
-//
-// It parses $GOROOT/src/cmd/compile/internal/gc/builtin/runtime.go to
-// get this list, since these are the functions the compiler can
-// generate calls to.
-func getDefaultRoots() []string {
-	path := filepath.Join(runtime.GOROOT(), "src/cmd/compile/internal/gc/builtin/runtime.go")
-	fset := token.NewFileSet()
-	f, err := parser.ParseFile(fset, path, nil, 0)
-	if err != nil {
-		log.Fatalf("%s: %s", path, err)
-	}
-
-	var roots []string
-	for _, decl := range f.Decls {
-		decl, ok := decl.(*ast.FuncDecl)
-		if !ok {
-			continue
-		}
-		switch decl.Name.Name {
-		case "cmpstring", "eqstring",
-			"int64div", "uint64div", "int64mod", "uint64mod",
-			"float64toint64", "float64touint64",
-			"int64tofloat64", "uint64tofloat64",
-			// Go 1.8:
-			"float64touint32", "uint32tofloat64":
-			// These are declared only in assembly.
-			continue
-		}
-		if strings.HasPrefix(decl.Name.Name, "race") {
-			// These functions are declared by runtime.go,
-			// but only exist in race mode.
-			continue
-		}
-		roots = append(roots, decl.Name.Name)
-	}
-	return roots
-}
-
 // rewriteSources rewrites all of the Go files in pkg to eliminate
 // runtime-isms, make them easier for go/ssa to process, to add stubs
 // for internal functions, and to generate init-time calls to analysis
 // root functions. It fills rewritten with path -> new source
-// mappings.
-func rewriteSources(pkg *build.Package, roots []string, rewritten map[string][]byte) {
+// mappings. It returns the roots that aren't declared in pkg.
+func rewriteSources(pkg *build.Package, roots []string, rewritten map[string][]byte) []string {
 	rootSet := make(map[string]struct{})
 	for _, root := range roots {
 		rootSet[root] = struct{}{}
@@ -423,21 +588,34 @@ func rewriteSources(pkg *build.Package, roots []string, rewritten map[string][]b
 // specially.
 func rtcheck۰presystemstack() *g { return nil }
 func rtcheck۰postsystemstack(*g) { }
+
+// rtcheck۰use takes arguments that rewriting would otherwise drop.
+func rtcheck۰use(...any) { }
 `))
 		}
 
 		rewritten[path] = buf.Bytes()
 	}
 
-	// Check that we found all of the roots.
-	if len(rootSet) > 0 {
-		fmt.Fprintf(os.Stderr, "unknown roots:")
-		for root := range rootSet {
-			fmt.Fprintf(os.Stderr, " %s", root)
+	// Return the roots we didn't find.
+	var missing []string
+	for root := range rootSet {
+		missing = append(missing, root)
+	}
+	sort.Strings(missing)
+	return missing
+}
+
+// atomicPkg returns the import path of the runtime's atomics package
+// in ctxt's Go release. Go 1.23 moved it from runtime/internal/atomic
+// to internal/runtime/atomic.
+func atomicPkg(ctxt *build.Context) string {
+	for _, tag := range ctxt.ReleaseTags {
+		if tag == "go1.23" {
+			return "internal/runtime/atomic"
 		}
-		fmt.Fprintf(os.Stderr, "\n")
-		os.Exit(1)
 	}
+	return "runtime/internal/atomic"
 }
 
 var newStubs = make(map[string]map[string]*ast.FuncDecl)
@@ -478,7 +656,7 @@ func getcallerpc() uintptr { return 0 }
 func getcallersp() uintptr { return 0 }
 func asmcgocall() int32 { return 0 }
 // morestack is handled specially.
-func time_now() (int64, int32) { return 0, 0 }
+func time_now() (int64, int32, int64) { return 0, 0, 0 }
 
 // os_linux.go
 func futex() int32 { return 0 }
@@ -504,13 +682,13 @@ func usleep() {}
 func munmap() {}
 func write() int32 { return 0 }
 func open() int32 { return 0 }
-func madvise() {}
+func madvise() int32 { return 0 }
 
 // cputicks.go
 func cputicks() { return 0 }
 
 // cgo_mmap.go
-func sysMmap() unsafe.Pointer { return nil }
+func sysMmap() (unsafe.Pointer, int) { return nil, 0 }
 func callCgoMmap() uintptr { return 0 }
 
 // alg.go
@@ -686,7 +864,7 @@ func addRootCalls(f *ast.File, rootSet map[string]struct{}) {
 					body = append(body, adecl)
 					args = append(args, &ast.Ident{Name: name})
 				default:
-					log.Fatalf("unexpected function argument type: %s", aspec)
+					log.Fatalf("unexpected function argument type: %s", aspec.Type)
 				}
 			}
 		}
@@ -751,14 +929,22 @@ func rewriteRuntime(f *ast.File, isNosplit map[ast.Decl]bool) {
 				if cb, ok := node.Args[0].(*ast.Ident); ok && cb.Name == "nil" {
 					break
 				}
-				// gopark(fn, arg, ...) -> fn(nil, arg)
-				return &ast.CallExpr{
+				// gopark(fn, arg, ...) -> rtcheck۰use(fn(nil, arg), ...)
+				//
+				// The remaining arguments, such as the wait
+				// reason, may be variables that are otherwise
+				// unused.
+				call := &ast.CallExpr{
 					Fun: node.Args[0],
 					Args: []ast.Expr{
 						&ast.Ident{Name: "nil"},
 						node.Args[1],
 					},
 				}
+				return &ast.CallExpr{
+					Fun:  &ast.Ident{Name: "rtcheck۰use"},
+					Args: append([]ast.Expr{call}, node.Args[2:]...),
+				}
 			case "goparkunlock":
 				// goparkunlock(x, ...) -> unlock(x)
 				return &ast.CallExpr{
@@ -780,7 +966,7 @@ func rewriteRuntime(f *ast.File, isNosplit map[ast.Decl]bool) {
 				break
 			}
 			var x ast.Stmt
-			if arg, ok := expr.Args[0].(*ast.FuncLit); ok {
+			if arg, ok := expr.Args[0].(*ast.FuncLit); ok && !hasReturn(arg.Body) {
 				x = arg.Body
 			} else {
 				x = &ast.ExprStmt{&ast.CallExpr{Fun: expr.Args[0]}}
@@ -843,6 +1029,23 @@ func rewriteRuntime(f *ast.File, isNosplit map[ast.Decl]bool) {
 	}, f)
 }
 
+// hasReturn reports whether body returns from its function. Inlining
+// a function literal with such a body would return from the enclosing
+// function instead.
+func hasReturn(body *ast.BlockStmt) bool {
+	found := false
+	ast.Inspect(body, func(n ast.Node) bool {
+		switch n.(type) {
+		case *ast.ReturnStmt:
+			found = true
+		case *ast.FuncLit:
+			return false
+		}
+		return !found
+	})
+	return found
+}
+
 var fns struct {
 	// Locking functions.
 	lock, unlock *ssa.Function
@@ -868,8 +1071,7 @@ var runtimeFns = map[string]interface{}{
 	"newobject": &fns.newobject, "newarray": &fns.newarray,
 	"makemap": &fns.makemap, "makechan": &fns.makechan,
 	"growslice": &fns.growslice, "slicecopy": &fns.slicecopy,
-	"slicestringcopy": &fns.slicestringcopy,
-	"mapaccess1":      &fns.mapaccess1, "mapaccess2": &fns.mapaccess2,
+	"mapaccess1": &fns.mapaccess1, "mapaccess2": &fns.mapaccess2,
 	//"mapassign1": &fns.mapassign1, // Pre-1.8
 	"mapassign": &fns.mapassign, // Go 1.8
 	"mapdelete": &fns.mapdelete,
@@ -1189,10 +1391,13 @@ func (sf *StackFrame) TrimCommonPrefix(other *StackFrame, minLen int) (*StackFra
 type state struct {
 	fset  *token.FileSet
 	cg    *callgraph.Graph
-	pta   *pointer.Result
 	fns   map[*ssa.Function]*funcInfo
 	stack *StackFrame
 
+	// stubs is the set of functions outside the -scope whose
+	// bodies were removed.
+	stubs map[*ssa.Function]bool
+
 	// heap contains handles to heap objects that are needed by
 	// specially handled functions.
 	heap struct {
@@ -1207,6 +1412,9 @@ type state struct {
 
 	lockOrder *LockOrder
 
+	// ann is the user's function annotations, or nil.
+	ann *annotations
+
 	// messages is the set of warning strings that have been
 	// emitted.
 	messages map[string]struct{}
@@ -1260,8 +1468,8 @@ func (s *state) addRoot(fn *ssa.Function) {
 }
 
 // callees returns the set of functions that call could possibly
-// invoke. It returns nil for built-in functions or if pointer
-// analysis failed.
+// invoke. It returns nil for built-in functions or if the call graph
+// has no node for the caller.
 func (s *state) callees(call ssa.CallInstruction) []*ssa.Function {
 	if builtin, ok := call.Common().Value.(*ssa.Builtin); ok {
 		// TODO: cap, len for map and channel
@@ -1317,7 +1525,7 @@ func (s *state) callees(call ssa.CallInstruction) []*ssa.Function {
 // correlated control flow.
 //
 // TODO: This totally fails with multi-use higher-order functions,
-// since the flow computed by the pointer analysis is not segregated
+// since the flow computed by the call graph analysis is not segregated
 // by PathState.
 //
 // TODO: A lot of call trees simply don't take locks. We could record
@@ -1371,7 +1579,7 @@ func (s *state) walkFunction(f *ssa.Function, ps PathState) *PathStateSet {
 		}
 		s.fns[f] = fInfo
 
-		if f.Blocks == nil {
+		if f.Blocks == nil && !s.stubs[f] {
 			s.warnl(f.Pos(), "external function %s", f)
 		}
 
@@ -1690,7 +1898,9 @@ func (s *state) walkBlock(blockCache *PathStateSet, enterPathState PathState, ex
 				vs:      ps.vs.LimitToHeap(),
 			}
 			for _, fn := range fns {
-				if handler, ok := callHandlers[fn.String()]; ok {
+				if s.ann.isNoEffect(fn) {
+					newps = append(newps, ps)
+				} else if handler, ok := callHandlers[fn.String()]; ok {
 					// TODO: Instead of using
 					// FlatMap, I could just pass
 					// the PathStateSet to add new
@@ -1815,7 +2025,7 @@ func (s *state) walkBlock(blockCache *PathStateSet, enterPathState PathState, ex
 				if debugTree != nil {
 					var buf bytes.Buffer
 					ps.WriteTo(&buf)
-					debugTree.Leaff("exit:\n%s", buf)
+					debugTree.Leaff("exit:\n%s", buf.String())
 				}
 			})
 		}
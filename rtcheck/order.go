@@ -6,17 +6,17 @@ package main
 
 import (
 	"bytes"
+	"embed"
 	"fmt"
 	"go/token"
 	"html/template"
 	"io"
-	"io/ioutil"
 	"log"
 	"math/big"
-	"os"
 	"os/exec"
-	"path/filepath"
+	"sort"
 
+	"github.com/aclements/go-misc/rtcheck/lockgraph"
 	"golang.org/x/tools/go/ssa"
 )
 
@@ -27,6 +27,10 @@ type LockOrder struct {
 	fset *token.FileSet
 	m    map[lockOrderEdge]map[lockOrderInfo]struct{}
 
+	// ann, if non-nil, supplies exclusive functions used to
+	// eliminate impossible cycles.
+	ann *annotations
+
 	// cycles is the cached result of FindCycles, or nil.
 	cycles [][]int
 }
@@ -37,6 +41,10 @@ type lockOrderEdge struct {
 
 type lockOrderInfo struct {
 	fromStack, toStack *StackFrame // Must be interned and common trimmed
+
+	// through is the mask of ann's exclusive functions on the
+	// full, untrimmed stacks.
+	through uint64
 }
 
 // NewLockOrder returns an empty lock graph. Source locations in
@@ -60,8 +68,10 @@ func (lo *LockOrder) Add(locked *LockSet, locking *LockSet, stack *StackFrame) {
 		panic("locks come from a different LockClassAnalyses")
 	}
 
+	stackThrough := lo.ann.through(stack)
 	for i := 0; i < locked.bits.BitLen(); i++ {
 		if locked.bits.Bit(i) != 0 {
+			through := stackThrough | lo.ann.through(locked.stacks[i])
 			for j := 0; j < locking.bits.BitLen(); j++ {
 				if locking.bits.Bit(j) != 0 {
 					// Trim the common prefix of
@@ -71,80 +81,126 @@ func (lo *LockOrder) Add(locked *LockSet, locking *LockSet, stack *StackFrame) {
 					lockedStack := locked.stacks[i]
 					fromStack, toStack := lockedStack.TrimCommonPrefix(stack, 1)
 
-					// Add info to edge.
-					edge := lockOrderEdge{i, j}
-					info := lockOrderInfo{
-						fromStack.Intern(),
-						toStack.Intern(),
-					}
-					infos := lo.m[edge]
-					if infos == nil {
-						infos = make(map[lockOrderInfo]struct{})
-						lo.m[edge] = infos
-					}
-					infos[info] = struct{}{}
+					lo.addInfo(lockOrderEdge{i, j}, fromStack, toStack, through)
 				}
 			}
 		}
 	}
 }
 
+// AddWake adds lock edges to the lock order, given that the locks in
+// locked are currently held and note is being woken at stack. A
+// thread sleeping on note may need to wait for locked, so this adds
+// an edge from note to each lock in locked. Its paths go from the
+// wakeup to the acquisition of the lock.
+func (lo *LockOrder) AddWake(locked *LockSet, note *LockClass, stack *StackFrame) {
+	lo.cycles = nil
+	if lo.lca == nil {
+		lo.lca = note.Analysis()
+	} else if lo.lca != note.Analysis() {
+		panic("locks come from a different LockClassAnalyses")
+	}
+
+	stackThrough := lo.ann.through(stack)
+	for i := 0; i < locked.bits.BitLen(); i++ {
+		if locked.bits.Bit(i) != 0 {
+			lockedStack := locked.stacks[i]
+			wakeStack, toStack := stack.TrimCommonPrefix(lockedStack, 1)
+			through := stackThrough | lo.ann.through(lockedStack)
+			lo.addInfo(lockOrderEdge{note.Id(), i}, wakeStack, toStack, through)
+		}
+	}
+}
+
+// addInfo adds a path from fromStack to toStack to edge.
+func (lo *LockOrder) addInfo(edge lockOrderEdge, fromStack, toStack *StackFrame, through uint64) {
+	info := lockOrderInfo{
+		fromStack.Intern(),
+		toStack.Intern(),
+		through,
+	}
+	infos := lo.m[edge]
+	if infos == nil {
+		infos = make(map[lockOrderInfo]struct{})
+		lo.m[edge] = infos
+	}
+	infos[info] = struct{}{}
+}
+
 // FindCycles returns a list of cycles in the lock order. Each cycle
 // is a list of lock IDs from the StringSpace in cycle order (without
-// any repetition).
+// any repetition). It omits cycles that lo.ann says can't happen
+// because their steps can't all run concurrently.
 func (lo *LockOrder) FindCycles() [][]int {
 	if lo.cycles != nil {
 		return lo.cycles
 	}
 
-	// Compute out-edge adjacency list.
-	out := map[int][]int{}
-	for edge := range lo.m {
-		out[edge.fromId] = append(out[edge.fromId], edge.toId)
-	}
-
-	// Use DFS to find cycles.
-	//
-	// TODO: Implement a real cycle-finding algorithm. This one is
-	// terrible.
-	path, pathSet := []int{}, map[int]struct{}{}
+	g := lo.graph(false)
 	cycles := [][]int{}
-	var dfs func(root, node int)
-	dfs = func(root, node int) {
-		if _, ok := pathSet[node]; ok {
-			// Only report as a cycle if we got back to
-			// where we started and this is the lowest
-			// numbered node in the cycle. This gets us
-			// each elementary cycle exactly once.
-			if node == root {
-				minNode := node
-				for _, n := range path {
-					if n < minNode {
-						minNode = n
+	for _, cycle := range g.FindCycles() {
+		if lo.ann != nil {
+			// Find the exclusive functions that every
+			// path of each step passes through.
+			var steps []uint64
+			for _, step := range g.CycleEdges(cycle) {
+				mask := ^uint64(0)
+				for _, e := range step {
+					for info := range lo.m[lockOrderEdge{e.From, e.To}] {
+						mask &= info.through
 					}
 				}
-				if node == minNode {
-					pathCopy := append([]int(nil), path...)
-					cycles = append(cycles, pathCopy)
-				}
+				steps = append(steps, mask)
+			}
+			if lo.ann.excludes(steps) {
+				continue
 			}
-			return
-		}
-		pathSet[node] = struct{}{}
-		path = append(path, node)
-		for _, next := range out[node] {
-			dfs(root, next)
 		}
-		path = path[:len(path)-1]
-		delete(pathSet, node)
-	}
-	for root := range out {
-		dfs(root, root)
+		cycles = append(cycles, cycle)
 	}
 
 	// Cache the result.
 	lo.cycles = cycles
-	return cycles
+	return lo.cycles
+}
+
+// Graph returns lo as a self-contained lockgraph.Graph with all
+// stacks resolved to source positions and the cycles found by
+// FindCycles.
+func (lo *LockOrder) Graph() *lockgraph.Graph {
+	g := lo.graph(true)
+	g.Cycles = lo.FindCycles()
+	return g
+}
+
+// graph returns lo as a lockgraph.Graph. If paths is false, it omits
+// the paths of each edge, which are expensive to render.
+func (lo *LockOrder) graph(paths bool) *lockgraph.Graph {
+	g := &lockgraph.Graph{Version: lockgraph.Version, Locks: []lockgraph.Lock{}, Edges: []*lockgraph.Edge{}}
+	if lo.lca != nil {
+		for _, lc := range lo.lca.list {
+			l := lockgraph.Lock{Name: lc.String(), Unique: lc.IsUnique()}
+			if lc.IsRead() {
+				l.Read, l.Exclusive = true, lc.exclusive.Id()
+			}
+			g.Locks = append(g.Locks, l)
+		}
+	}
+	for edge, infos := range lo.m {
+		e := &lockgraph.Edge{From: edge.fromId, To: edge.toId}
+		if paths {
+			for info := range infos {
+				e.Paths = append(e.Paths, lo.renderInfo(edge, info))
+			}
+			// Sort paths so the output is deterministic.
+			sort.Slice(e.Paths, func(i, j int) bool {
+				return fmt.Sprint(e.Paths[i]) < fmt.Sprint(e.Paths[j])
+			})
+		}
+		g.Edges = append(g.Edges, e)
+	}
+	g.Sort()
+	return g
 }
 
 // WriteToDot writes the lock graph in the dot language to w, with
@@ -162,16 +218,17 @@ func (lo *LockOrder) writeToDot(w io.Writer) map[lockOrderEdge]string {
 	// condensation, I guess) to reduce noise.
 
 	// Find cycles to highlight edges.
-	cycles := lo.FindCycles()
+	g := lo.graph(false)
 	cycleEdges := map[lockOrderEdge]struct{}{}
 	var maxStack int
-	for _, cycle := range cycles {
-		for i, fromId := range cycle {
-			toId := cycle[(i+1)%len(cycle)]
-			edge := lockOrderEdge{fromId, toId}
-			cycleEdges[edge] = struct{}{}
-			if len(lo.m[edge]) > maxStack {
-				maxStack = len(lo.m[edge])
+	for _, cycle := range lo.FindCycles() {
+		for _, step := range g.CycleEdges(cycle) {
+			for _, e := range step {
+				edge := lockOrderEdge{e.From, e.To}
+				cycleEdges[edge] = struct{}{}
+				if len(lo.m[edge]) > maxStack {
+					maxStack = len(lo.m[edge])
+				}
 			}
 		}
 	}
@@ -212,33 +269,23 @@ func (lo *LockOrder) writeToDot(w io.Writer) map[lockOrderEdge]string {
 	return edgeIds
 }
 
-type renderedPath struct {
-	RootFn   string
-	From, To []renderedFrame
-}
-
-type renderedFrame struct {
-	Op  string
-	Pos token.Position
-}
-
-func (lo *LockOrder) renderInfo(edge lockOrderEdge, info lockOrderInfo) renderedPath {
+func (lo *LockOrder) renderInfo(edge lockOrderEdge, info lockOrderInfo) lockgraph.Path {
 	fset := lo.fset
 	fromStack := info.fromStack.Flatten(nil)
 	toStack := info.toStack.Flatten(nil)
 	rootFn := fromStack[0].Parent()
-	renderStack := func(stack []ssa.Instruction, tail string) []renderedFrame {
-		var frames []renderedFrame
+	renderStack := func(stack []ssa.Instruction, tail string) []lockgraph.Frame {
+		var frames []lockgraph.Frame
 		for i, call := range stack[1:] {
-			frames = append(frames, renderedFrame{"calls " + call.Parent().String(), fset.Position(stack[i].Pos())})
+			frames = append(frames, lockgraph.Frame{Op: "calls " + call.Parent().String(), Pos: fset.Position(stack[i].Pos())})
 		}
-		frames = append(frames, renderedFrame{tail, fset.Position(stack[len(stack)-1].Pos())})
+		frames = append(frames, lockgraph.Frame{Op: tail, Pos: fset.Position(stack[len(stack)-1].Pos())})
 		return frames
 	}
-	return renderedPath{
-		rootFn.String(),
-		renderStack(fromStack, "acquires "+lo.name(edge.fromId)),
-		renderStack(toStack, "acquires "+lo.name(edge.toId)),
+	return lockgraph.Path{
+		RootFn: rootFn.String(),
+		From:   renderStack(fromStack, "acquires "+lo.name(edge.fromId)),
+		To:     renderStack(toStack, "acquires "+lo.name(edge.toId)),
 	}
 }
 
@@ -247,44 +294,8 @@ func (lo *LockOrder) renderInfo(edge lockOrderEdge, info lockOrderInfo) rendered
 // This report is thorough, but can be quite repetitive, since a
 // single edge can participate in multiple cycles.
 func (lo *LockOrder) Check(w io.Writer) {
-	cycles := lo.FindCycles()
-
-	// Report cycles.
-	printStack := func(stack []renderedFrame) {
-		indent := 6
-		for _, fr := range stack {
-			fmt.Fprintf(w, "%*s%s at %s\n", indent, "", fr.Op, fr.Pos)
-			indent += 2
-		}
-	}
-	printInfo := func(rinfo renderedPath) {
-		fmt.Fprintf(w, "    %s\n", rinfo.RootFn)
-		printStack(rinfo.From)
-		printStack(rinfo.To)
-	}
-	for _, cycle := range cycles {
-		cycle = append(cycle, cycle[0])
-		fmt.Fprintf(w, "lock cycle: ")
-		for i, node := range cycle {
-			if i != 0 {
-				fmt.Fprintf(w, " -> ")
-			}
-			fmt.Fprintf(w, lo.name(node))
-		}
-		fmt.Fprintf(w, "\n")
-
-		for i := 0; i < len(cycle)-1; i++ {
-			edge := lockOrderEdge{cycle[i], cycle[i+1]}
-			infos := lo.m[edge]
-
-			fmt.Fprintf(w, "  %d path(s) acquire %s then %s:\n", len(infos), lo.name(edge.fromId), lo.name(edge.toId))
-			for info, _ := range infos {
-				rinfo := lo.renderInfo(edge, info)
-				printInfo(rinfo)
-			}
-			fmt.Fprintf(w, "\n")
-		}
-	}
+	g := lo.Graph()
+	g.WriteCycleList(w, g.Cycles)
 }
 
 // WriteToHTML writes a self-contained, interactive HTML lock graph
@@ -314,7 +325,7 @@ func (lo *LockOrder) WriteToHTML(w io.Writer) {
 	}
 
 	// Construct JSON for lock graph details. This is about an
-	// order of magnitude smaller than the naive renderedFrames.
+	// order of magnitude smaller than the naive lockgraph.Frames.
 	jsonStrings := NewStringSpace()
 	// To save space, we use a struct of arrays.
 	type jsonStack struct {
@@ -322,7 +333,7 @@ func (lo *LockOrder) WriteToHTML(w io.Writer) {
 		PathID []int `json:"P"`
 		Line   []int `json:"L"`
 	}
-	xFrames := func(rs []renderedFrame) jsonStack {
+	xFrames := func(rs []lockgraph.Frame) jsonStack {
 		out := jsonStack{
 			make([]int, len(rs)),
 			make([]int, len(rs)),
@@ -339,7 +350,7 @@ func (lo *LockOrder) WriteToHTML(w io.Writer) {
 		RootFn   int
 		From, To jsonStack
 	}
-	xPath := func(r renderedPath) jsonPath {
+	xPath := func(r lockgraph.Path) jsonPath {
 		return jsonPath{jsonStrings.Intern(r.RootFn), xFrames(r.From), xFrames(r.To)}
 	}
 	type jsonEdge struct {
@@ -359,39 +370,67 @@ func (lo *LockOrder) WriteToHTML(w io.Writer) {
 			Paths:  paths,
 		})
 	}
+	sort.Slice(jsonEdges, func(i, j int) bool {
+		return jsonEdges[i].EdgeID < jsonEdges[j].EdgeID
+	})
 
-	// Find the static file path.
-	//
-	// TODO: Optionally bake these into the binary.
-	var static string
-	var found bool
-	for _, gopath := range filepath.SplitList(os.Getenv("GOPATH")) {
-		static = filepath.Join(gopath, "src/github.com/aclements/go-misc/rtcheck/static")
-		if _, err := os.Stat(static); err == nil {
-			found = true
-			break
+	// Construct JSON for the cycles. Each step of a cycle may
+	// have several edges if it acquires a read lock, so a step's
+	// path count is the total of its edges.
+	type jsonCycle struct {
+		Locks    []int
+		Edges    []string
+		Paths    int
+		MinPaths int
+	}
+	jsonCycles := []jsonCycle{}
+	g := lo.graph(false)
+	for _, cycle := range lo.FindCycles() {
+		jc := jsonCycle{Locks: cycle, Edges: []string{}, MinPaths: -1}
+		for _, step := range g.CycleEdges(cycle) {
+			n := 0
+			for _, e := range step {
+				edge := lockOrderEdge{e.From, e.To}
+				jc.Edges = append(jc.Edges, edgeIds[edge])
+				n += len(lo.m[edge])
+			}
+			jc.Paths += n
+			if jc.MinPaths < 0 || n < jc.MinPaths {
+				jc.MinPaths = n
+			}
 		}
+		jsonCycles = append(jsonCycles, jc)
 	}
-	if !found {
-		log.Fatal("unable to find HTML template in $GOPATH")
+	locks := []string{}
+	if lo.lca != nil {
+		for _, lc := range lo.lca.list {
+			locks = append(locks, lc.String())
+		}
 	}
 
 	// Generate HTML.
-	tmpl, err := template.ParseFiles(filepath.Join(static, "tmpl-order.html"))
+	tmpl, err := template.ParseFS(staticFiles, "static/tmpl-order.html")
 	if err != nil {
 		log.Fatal("loading HTML templates: ", err)
 	}
-	mainJS, err := ioutil.ReadFile(filepath.Join(static, "main.js"))
+	mainJS, err := staticFiles.ReadFile("static/main.js")
 	if err != nil {
 		log.Fatal("loading main.js: ", err)
 	}
 	err = tmpl.Execute(w, map[string]interface{}{
 		"graph":   template.HTML(svg),
 		"strings": jsonStrings.s,
+		"locks":   locks,
 		"edges":   jsonEdges,
+		"cycles":  jsonCycles,
 		"mainJS":  template.JS(mainJS),
 	})
 	if err != nil {
 		log.Fatal("executing HTML template: ", err)
 	}
 }
+
+// staticFiles contains the template and script of the HTML report.
+//
+//go:embed static
+var staticFiles embed.FS
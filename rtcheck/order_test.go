@@ -0,0 +1,121 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"flag"
+	"go/token"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+// TestLockOrderGolden checks the cycles found in the lock graphs in
+// testdata/lockorder. Each *.txt file lists lock graph edges as
+// "A -> B", meaning B is acquired while holding A. A lock named
+// "A (read)" is a read acquisition of A. A line "wake N holding A"
+// means note N is woken while holding A. The corresponding
+// *.golden file lists the expected cycles, one per line, in sorted
+// order.
+//
+// This tests the lock graph and cycle finding in isolation.
+// TestDeadlockGolden tests them together with the analysis.
+func TestLockOrderGolden(t *testing.T) {
+	paths, err := filepath.Glob("testdata/lockorder/*.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range paths {
+		name := strings.TrimSuffix(filepath.Base(path), ".txt")
+		t.Run(name, func(t *testing.T) {
+			got := lockOrderCycles(t, path)
+			goldenPath := strings.TrimSuffix(path, ".txt") + ".golden"
+			if *update {
+				if err := os.WriteFile(goldenPath, []byte(got), 0666); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			want, err := os.ReadFile(goldenPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != string(want) {
+				t.Errorf("got cycles:\n%swant:\n%s", got, want)
+			}
+		})
+	}
+}
+
+// lockOrderCycles builds a lock graph from the edges in path and
+// returns its cycles, one per line.
+func lockOrderCycles(t *testing.T, path string) string {
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var lca LockClassAnalysis
+	classes := make(map[string]*LockClass)
+	var class func(name string) *LockClass
+	class = func(name string) *LockClass {
+		if base := strings.TrimSuffix(name, " (read)"); base != name {
+			return class(base).Read()
+		}
+		lc := classes[name]
+		if lc == nil {
+			lc = lca.NewLockClass(name, true)
+			classes[name] = lc
+		}
+		return lc
+	}
+	lo := NewLockOrder(token.NewFileSet())
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if wake := strings.TrimPrefix(line, "wake "); wake != line {
+			fs := strings.Split(wake, " holding ")
+			if len(fs) != 2 {
+				t.Fatalf("%s: bad wakeup %q", path, line)
+			}
+			lo.AddWake(NewLockSet().Plus(class(fs[1]), nil), class(fs[0]), nil)
+			continue
+		}
+		fs := strings.Split(line, " -> ")
+		if len(fs) != 2 {
+			t.Fatalf("%s: bad edge %q", path, line)
+		}
+		from, to := class(fs[0]), class(fs[1])
+		lo.Add(NewLockSet().Plus(from, nil), NewLockSet().Plus(to, nil), nil)
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	return cycleList(lo)
+}
+
+// cycleList returns the cycles in lo, one per line, in sorted order.
+func cycleList(lo *LockOrder) string {
+	var cycles []string
+	for _, cycle := range lo.FindCycles() {
+		var names []string
+		for _, id := range append(cycle, cycle[0]) {
+			names = append(names, lo.name(id))
+		}
+		cycles = append(cycles, strings.Join(names, " -> ")+"\n")
+	}
+	sort.Strings(cycles)
+	return strings.Join(cycles, "")
+}
@@ -0,0 +1,66 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"go/build"
+	"os/exec"
+	"strings"
+)
+
+// A platform is a GOOS/GOARCH combination to analyze the runtime for.
+type platform struct {
+	goos, goarch string
+}
+
+func (p platform) String() string {
+	return p.goos + "/" + p.goarch
+}
+
+// parsePlatforms parses a comma-separated list of GOOS/GOARCH pairs.
+// If s is "all", it returns every platform supported by the go
+// command, as listed by "go tool dist list".
+func parsePlatforms(s string) ([]platform, error) {
+	if s == "all" {
+		out, err := exec.Command("go", "tool", "dist", "list").Output()
+		if err != nil {
+			return nil, fmt.Errorf("listing platforms: %v", err)
+		}
+		s = strings.Join(strings.Fields(string(out)), ",")
+	}
+
+	var ps []platform
+	seen := make(map[platform]bool)
+	for _, f := range strings.Split(s, ",") {
+		if f = strings.TrimSpace(f); f == "" {
+			continue
+		}
+		i := strings.Index(f, "/")
+		if i <= 0 || i == len(f)-1 || strings.Count(f, "/") != 1 {
+			return nil, fmt.Errorf("bad platform %q: want GOOS/GOARCH", f)
+		}
+		p := platform{f[:i], f[i+1:]}
+		if !seen[p] {
+			seen[p] = true
+			ps = append(ps, p)
+		}
+	}
+	if len(ps) == 0 {
+		return nil, fmt.Errorf("no platforms in %q", s)
+	}
+	return ps, nil
+}
+
+// context returns a build context for analyzing the runtime on p.
+// Like the go command, it disables cgo when cross-compiling.
+func (p platform) context() *build.Context {
+	ctxt := build.Default
+	if p.goos != ctxt.GOOS || p.goarch != ctxt.GOARCH {
+		ctxt.GOOS, ctxt.GOARCH = p.goos, p.goarch
+		ctxt.CgoEnabled = false
+	}
+	return &ctxt
+}
@@ -0,0 +1,32 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParsePlatforms(t *testing.T) {
+	got, err := parsePlatforms("linux/amd64, windows/arm64,linux/amd64")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []platform{{"linux", "amd64"}, {"windows", "arm64"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	for _, bad := range []string{"", "linux", "linux/", "/amd64", "linux/amd64/v3"} {
+		if _, err := parsePlatforms(bad); err == nil {
+			t.Errorf("parsePlatforms(%q) succeeded, want error", bad)
+		}
+	}
+
+	ctxt := platform{"plan9", "386"}.context()
+	if ctxt.GOOS != "plan9" || ctxt.GOARCH != "386" || ctxt.CgoEnabled {
+		t.Errorf("context() = %s/%s cgo=%v, want plan9/386 cgo=false", ctxt.GOOS, ctxt.GOARCH, ctxt.CgoEnabled)
+	}
+}
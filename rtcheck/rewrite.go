@@ -97,6 +97,10 @@ func Rewrite(v func(ast.Node) ast.Node, node ast.Node) ast.Node {
 		n.X = Rewrite(v, n.X).(ast.Expr)
 		n.Index = Rewrite(v, n.Index).(ast.Expr)
 
+	case *ast.IndexListExpr:
+		n.X = Rewrite(v, n.X).(ast.Expr)
+		rewriteExprList(v, n.Indices)
+
 	case *ast.SliceExpr:
 		n.X = Rewrite(v, n.X).(ast.Expr)
 		if n.Low != nil {
@@ -144,6 +148,9 @@ func Rewrite(v func(ast.Node) ast.Node, node ast.Node) ast.Node {
 		n.Fields = Rewrite(v, n.Fields).(*ast.FieldList)
 
 	case *ast.FuncType:
+		if n.TypeParams != nil {
+			n.TypeParams = Rewrite(v, n.TypeParams).(*ast.FieldList)
+		}
 		if n.Params != nil {
 			n.Params = Rewrite(v, n.Params).(*ast.FieldList)
 		}
@@ -298,6 +305,9 @@ func Rewrite(v func(ast.Node) ast.Node, node ast.Node) ast.Node {
 			n.Doc = Rewrite(v, n.Doc).(*ast.CommentGroup)
 		}
 		n.Name = Rewrite(v, n.Name).(*ast.Ident)
+		if n.TypeParams != nil {
+			n.TypeParams = Rewrite(v, n.TypeParams).(*ast.FieldList)
+		}
 		n.Type = Rewrite(v, n.Type).(ast.Expr)
 		if n.Comment != nil {
 			n.Comment = Rewrite(v, n.Comment).(*ast.CommentGroup)
@@ -0,0 +1,142 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// builtinFiles are the locations, relative to $GOROOT/src, that the
+// compiler has kept the declarations of the runtime functions it can
+// generate calls to, newest first.
+var builtinFiles = []string{
+	"cmd/compile/internal/typecheck/_builtin/runtime.go", // Go 1.20+
+	"cmd/compile/internal/typecheck/builtin/runtime.go",  // Go 1.16-1.19
+	"cmd/compile/internal/gc/builtin/runtime.go",         // Go 1.15 and earlier
+}
+
+// getRoots returns the list of functions in the runtime package to
+// use as roots. names is a comma-separated list of functions, and
+// file is a file listing functions, as accepted by readRootsFile. If
+// both are empty, it returns the default roots.
+func getRoots(names, file string) ([]string, error) {
+	if names == "" && file == "" {
+		return getDefaultRoots()
+	}
+	var roots []string
+	for _, name := range strings.Split(names, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			roots = append(roots, name)
+		}
+	}
+	if file != "" {
+		fileRoots, err := readRootsFile(file)
+		if err != nil {
+			return nil, err
+		}
+		roots = append(roots, fileRoots...)
+	}
+	return roots, nil
+}
+
+// getDefaultRoots returns the functions the compiler can generate
+// calls to. It finds these by parsing the compiler's declarations of
+// the runtime builtins, wherever they are in this GOROOT.
+func getDefaultRoots() ([]string, error) {
+	for _, rel := range builtinFiles {
+		path := filepath.Join(runtime.GOROOT(), "src", filepath.FromSlash(rel))
+		if _, err := os.Stat(path); err == nil {
+			return parseGoRoots(path)
+		}
+	}
+	return nil, fmt.Errorf("cannot find the compiler's runtime builtins in %s; use -roots or -rootsfile", runtime.GOROOT())
+}
+
+// readRootsFile reads root function names from path. If path is a Go
+// source file, the roots are the functions it declares. Otherwise,
+// it lists one function per line. Blank lines and lines starting with
+// # are ignored.
+func readRootsFile(path string) ([]string, error) {
+	if strings.HasSuffix(path, ".go") {
+		return parseGoRoots(path)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var roots []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		roots = append(roots, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return roots, nil
+}
+
+// parseGoRoots returns the functions declared in the Go source file
+// at path, skipping those known to be declared only in assembly or
+// only in race mode.
+func parseGoRoots(path string) ([]string, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, path, nil, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	var roots []string
+	for _, decl := range f.Decls {
+		decl, ok := decl.(*ast.FuncDecl)
+		if !ok || decl.Recv != nil {
+			continue
+		}
+		switch decl.Name.Name {
+		case "cmpstring", "eqstring",
+			"int64div", "uint64div", "int64mod", "uint64mod",
+			"float64toint64", "float64touint64",
+			"int64tofloat64", "uint64tofloat64",
+			// Go 1.8:
+			"float64touint32", "uint32tofloat64":
+			// These are declared only in assembly.
+			continue
+		}
+		if strings.HasPrefix(decl.Name.Name, "race") {
+			// These functions are declared by runtime.go,
+			// but only exist in race mode.
+			continue
+		}
+		roots = append(roots, decl.Name.Name)
+	}
+	return roots, nil
+}
+
+// removeRoots returns roots without the functions in remove.
+func removeRoots(roots, remove []string) []string {
+	drop := make(map[string]bool)
+	for _, name := range remove {
+		drop[name] = true
+	}
+	var out []string
+	for _, name := range roots {
+		if !drop[name] {
+			out = append(out, name)
+		}
+	}
+	return out
+}
@@ -0,0 +1,42 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestGetRoots(t *testing.T) {
+	dir := t.TempDir()
+	list := filepath.Join(dir, "roots.txt")
+	if err := os.WriteFile(list, []byte("# roots\nnewobject\n\n  makemap\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	src := filepath.Join(dir, "builtin.go")
+	if err := os.WriteFile(src, []byte("package runtime\nfunc growslice()\nfunc racefuncenter()\nfunc (T) m()\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	check := func(names, file string, want ...string) {
+		t.Helper()
+		got, err := getRoots(names, file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("getRoots(%q, %q) = %v, want %v", names, file, got, want)
+		}
+	}
+	check("mallocgc, chansend1", "", "mallocgc", "chansend1")
+	check("", list, "newobject", "makemap")
+	check("mallocgc", src, "mallocgc", "growslice")
+
+	if got := removeRoots([]string{"a", "b", "c"}, []string{"b"}); !reflect.DeepEqual(got, []string{"a", "c"}) {
+		t.Errorf("removeRoots = %v, want [a c]", got)
+	}
+}
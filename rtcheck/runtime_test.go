@@ -0,0 +1,36 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"go/build"
+	"testing"
+)
+
+// TestRuntimeSmoke runs the whole pipeline, from rewriting and
+// loading the installed runtime to the deadlock analysis, on a small
+// -scope of it. This catches changes in the runtime or in x/tools
+// that break loading, which the synthetic testdata packages can't.
+func TestRuntimeSmoke(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping runtime analysis in short mode")
+	}
+	// analyze sets the runtime functions the analysis models.
+	saved := fns
+	defer func() { fns = saved }()
+
+	s := analyze(&build.Default, []string{"closechan"}, nil, "unlock2", false, "")
+	if len(s.roots) != 1 || s.roots[0].String() != "runtime.closechan" {
+		t.Fatalf("analyzed roots %v, want runtime.closechan", s.roots)
+	}
+	g := s.lockOrder.Graph()
+	found := false
+	for _, lock := range g.Locks {
+		found = found || lock.Name == "runtime.hchan.lock*"
+	}
+	if !found || len(g.Edges) == 0 {
+		t.Errorf("got %d locks and %d edges, want runtime.hchan.lock* and some edges:\n%v", len(g.Locks), len(g.Edges), g.Locks)
+	}
+}
@@ -0,0 +1,96 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+
+	"golang.org/x/tools/go/ssa"
+	"golang.org/x/tools/go/ssa/ssautil"
+)
+
+// scopeProgram restricts the analysis of prog to the functions that
+// matter to the functions matching patterns (see matchFunc). It
+// keeps the functions on paths from entry to a matching function and
+// every function reachable from a matching function, and stubs out
+// the bodies of all other functions, so neither call graph
+// construction nor the lock analysis visits them. It returns the set of stubbed
+// functions.
+//
+// Paths follow static calls and references to function values, but
+// not interface method calls, so a stubbed method may in fact be
+// reachable.
+func scopeProgram(prog *ssa.Program, entry *ssa.Function, patterns []string) (map[*ssa.Function]bool, error) {
+	all := ssautil.AllFunctions(prog)
+
+	// Construct the reference graph.
+	refs := make(map[*ssa.Function][]*ssa.Function)
+	refBy := make(map[*ssa.Function][]*ssa.Function)
+	var rands []*ssa.Value
+	for fn := range all {
+		for _, b := range fn.Blocks {
+			for _, instr := range b.Instrs {
+				rands = instr.Operands(rands[:0])
+				for _, rand := range rands {
+					if callee, ok := (*rand).(*ssa.Function); ok {
+						refs[fn] = append(refs[fn], callee)
+						refBy[callee] = append(refBy[callee], fn)
+					}
+				}
+			}
+		}
+	}
+
+	var matched []*ssa.Function
+	for fn := range all {
+		for _, pattern := range patterns {
+			if matchFunc(pattern, fn) {
+				matched = append(matched, fn)
+				break
+			}
+		}
+	}
+
+	fromEntry := reachable([]*ssa.Function{entry}, refs)
+	toMatched := reachable(matched, refBy)
+	fromMatched := reachable(matched, refs)
+	found := false
+	for _, fn := range matched {
+		if fromEntry[fn] {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("no functions matching scope %v are reachable from the roots", patterns)
+	}
+
+	stubbed := make(map[*ssa.Function]bool)
+	for fn := range all {
+		if fn.Blocks == nil || (fromEntry[fn] && toMatched[fn]) || fromMatched[fn] {
+			continue
+		}
+		fn.Blocks, fn.Recover, fn.Locals = nil, nil, nil
+		stubbed[fn] = true
+	}
+	return stubbed, nil
+}
+
+// reachable returns the set of functions reachable from roots by
+// following edges.
+func reachable(roots []*ssa.Function, edges map[*ssa.Function][]*ssa.Function) map[*ssa.Function]bool {
+	seen := make(map[*ssa.Function]bool)
+	work := append([]*ssa.Function(nil), roots...)
+	for len(work) > 0 {
+		fn := work[len(work)-1]
+		work = work[:len(work)-1]
+		if seen[fn] {
+			continue
+		}
+		seen[fn] = true
+		work = append(work, edges[fn]...)
+	}
+	return seen
+}
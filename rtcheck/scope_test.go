@@ -0,0 +1,88 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"reflect"
+	"sort"
+	"testing"
+
+	"golang.org/x/tools/go/ssa"
+	"golang.org/x/tools/go/ssa/ssautil"
+)
+
+func TestScopeProgram(t *testing.T) {
+	const src = `package runtime
+
+func init() { a(); b() }
+
+func a() { c() }
+
+func b() { d() }
+
+func c() { f := e; f() }
+
+func d() { c() }
+
+func e() { g() }
+
+func g() {}
+
+func unused() { g() }
+`
+	build := func() *ssa.Package {
+		fset := token.NewFileSet()
+		f, err := parser.ParseFile(fset, "proc.go", src, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pkg, _, err := ssautil.BuildPackage(&types.Config{Importer: importer.Default()}, fset, types.NewPackage("runtime", "runtime"), []*ast.File{f}, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return pkg
+	}
+
+	for _, test := range []struct {
+		scope []string
+		want  []string
+	}{
+		// b and d are on a path to c. e and g are reachable
+		// from c.
+		{[]string{"c"}, []string{"runtime.unused"}},
+		// d calls c, so everything c calls stays, but a
+		// doesn't lead to d.
+		{[]string{"d"}, []string{"runtime.a", "runtime.unused"}},
+		{[]string{"g"}, []string{"runtime.unused"}},
+	} {
+		pkg := build()
+		stubs, err := scopeProgram(pkg.Prog, pkg.Func("init"), test.scope)
+		if err != nil {
+			t.Errorf("scope %v: %s", test.scope, err)
+			continue
+		}
+		var got []string
+		for fn := range stubs {
+			if fn.Blocks != nil {
+				t.Errorf("scope %v: stubbed %s still has a body", test.scope, fn)
+			}
+			got = append(got, fn.String())
+		}
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("scope %v: stubbed %v, want %v", test.scope, got, test.want)
+		}
+	}
+
+	pkg := build()
+	if _, err := scopeProgram(pkg.Prog, pkg.Func("init"), []string{"unused"}); err == nil {
+		t.Errorf("scope [unused]: want error for unreachable scope")
+	}
+}
@@ -1,38 +1,155 @@
 "use strict";
 
-function initOrder(strings, edges) {
+// initOrder sets up the lock order report. strings is the string
+// table referenced by the paths, locks is the name of each lock class
+// by ID, edges lists the edges of the lock graph and their paths, and
+// cycles lists the lock cycles.
+function initOrder(strings, locks, edges, cycles) {
+    var svg = document.getElementById("graph");
+    var edgesByID = {};
+
     // Hook into the graph edges.
-    var labelRe = /^l([0-9]+)-l([0-9]+)$/;
-    $.each(edges, function(_, edge) {
-        var g = $(document.getElementById(edge.EdgeID));
+    edges.forEach(function(edge) {
+        edgesByID[edge.EdgeID] = edge;
+        var g = document.getElementById(edge.EdgeID);
+        if (g === null)
+            return;
         // Increase the size of the click target by making a second,
         // invisible, larger path element.
-        var path = $("path:first", g);
-        path.clone().attr("stroke-width", "10px").attr("stroke", "transparent").appendTo(path.parent());
+        var path = g.querySelector("path");
+        if (path !== null) {
+            var wide = path.cloneNode(false);
+            wide.setAttribute("stroke-width", "10px");
+            wide.setAttribute("stroke", "transparent");
+            path.parentNode.appendChild(wide);
+        }
         // On click, update the info box.
-        g.
-          css({cursor: "pointer"}).
-          on("click", function(ev) {
-              showEdge(strings, edge);
-          });
+        g.style.cursor = "pointer";
+        g.addEventListener("click", function(ev) {
+            showEdge(strings, edge);
+        });
+    });
+    var graph = enableHighlighting(svg);
+    initCycles(strings, locks, edgesByID, cycles, graph);
+    zoomify(svg, document.getElementById("graphWrap"));
+    svg.style.visibility = "visible";
+}
+
+// elt creates an element with the given tag and text and appends it
+// to parent.
+function elt(parent, tag, text) {
+    var e = document.createElement(tag);
+    if (text !== undefined)
+        e.textContent = text;
+    parent.appendChild(e);
+    return e;
+}
+
+// details clears and returns the details part of the info box.
+function details() {
+    var d = document.getElementById("details");
+    d.textContent = "";
+    document.getElementById("info").scrollTop = 0;
+    return d;
+}
+
+// initCycles sets up the cycle list and the lock filter.
+function initCycles(strings, locks, edgesByID, cycles, graph) {
+    var list = document.getElementById("cycles");
+    var sortBy = document.getElementById("cycleSort");
+    var filter = document.getElementById("filter");
+    var re = null;
+    var selected = null;
+    var intro = document.getElementById("details").innerHTML;
+
+    function cycleText(cycle) {
+        var names = cycle.Locks.map(function(id) { return locks[id]; });
+        names.push(names[0]);
+        return names.join(" → ");
+    }
+
+    function matches(cycle) {
+        return re === null || cycle.Locks.some(function(id) { return re.test(locks[id]); });
+    }
+
+    function render() {
+        var sorted = cycles.filter(matches);
+        var cmp = {
+            weakest: function(a, b) { return a.MinPaths - b.MinPaths || b.Paths - a.Paths; },
+            paths: function(a, b) { return b.Paths - a.Paths || a.MinPaths - b.MinPaths; },
+            length: function(a, b) { return a.Locks.length - b.Locks.length || a.MinPaths - b.MinPaths; },
+        }[sortBy.value];
+        sorted.sort(cmp);
+
+        var count = sorted.length + " of " + cycles.length + " cycle(s)";
+        if (re === null)
+            count = cycles.length + " cycle(s)";
+        document.getElementById("cycleCount").textContent = count;
+
+        list.textContent = "";
+        sorted.forEach(function(cycle) {
+            var div = elt(list, "div", cycle.Paths + " paths, min " + cycle.MinPaths + ": " + cycleText(cycle));
+            if (cycle === selected)
+                div.className = "selected";
+            div.addEventListener("click", function() {
+                selected = cycle;
+                render();
+                graph.select(cycle.Edges);
+                showCycle(cycle);
+            });
+        });
+    }
+
+    function showCycle(cycle) {
+        var info = details();
+        elt(info, "p", "Cycle " + cycleText(cycle) + ":").style.fontWeight = "bold";
+        var clear = elt(info, "p", "Clear selection");
+        clear.className = "link";
+        clear.addEventListener("click", function() {
+            selected = null;
+            graph.select(null);
+            render();
+            details().innerHTML = intro;
+        });
+        cycle.Edges.forEach(function(id) {
+            var edge = edgesByID[id];
+            var p = elt(info, "p", edge.Paths.length + " path(s) acquire " + edge.Locks[0] + ", then " + edge.Locks[1]);
+            p.className = "link";
+            p.addEventListener("click", function() {
+                showEdge(strings, edge);
+            });
+        });
+    }
+
+    sortBy.addEventListener("change", render);
+    filter.addEventListener("input", function() {
+        if (filter.value === "") {
+            re = null;
+        } else {
+            try {
+                re = new RegExp(filter.value);
+            } catch (e) {
+                filter.className = "bad";
+                return;
+            }
+        }
+        filter.className = "";
+        graph.filter(function(id) { return re === null || re.test(locks[id]); });
+        render();
     });
-    enableHighlighting($("#graph")[0]);
-    zoomify($("#graph")[0], $("#graphWrap")[0]);
-    $("#graph").css("visibility", "visible");
+    render();
 }
 
 function showEdge(strings, edge) {
-    var info = $("#info");
-    info.empty().scrollTop(0);
+    var info = details();
 
     // Show summary information.
-    $("<p>").appendTo(info).text(
-        edge.Paths.length + " path(s) acquire " + edge.Locks[0] + ", then " + edge.Locks[1] + ":"
-    ).css({fontWeight: "bold"});
+    elt(info, "p", edge.Paths.length + " path(s) acquire " + edge.Locks[0] + ", then " + edge.Locks[1] + ":").style.fontWeight = "bold";
 
-    $.each(edge.Paths, function(_, path) {
-        var p = $("<p>").appendTo(info).css("white-space", "nowrap");
-        $("<div>").appendTo(p).text(strings[path.RootFn]);
+    edge.Paths.forEach(function(path) {
+        var p = elt(info, "p");
+        p.style.whiteSpace = "nowrap";
+        elt(p, "div", strings[path.RootFn]);
         function posText(pathID, line) {
             // Keep only the trailing part of the path.
             return strings[pathID].replace(/.*\//, "") + ":" + line;
@@ -41,33 +158,32 @@ function showEdge(strings, edge) {
             var elided = [];
             var elideDiv;
             // Render each frame.
-            $.each(stack.Op, function(i) {
-                var div = $("<div>").appendTo(p);
+            stack.Op.forEach(function(op, i) {
                 var indent = i == 0 ? "1em" : "2em";
                 var showFirst = 2, showLast = 3;
-                if (i >= showFirst && stack.Op.length - i > showLast) {
+                if (i >= showFirst && stack.Op.length - i > showLast && elided.length === 0) {
                     // Elide middle of the path.
-                    if (elided.length === 0) {
-                        elideDiv = $("<div>").appendTo(p).css("padding-left", indent);
-                    }
-                    elided.push(div[0]);
+                    elideDiv = elt(p, "div");
+                    elideDiv.style.paddingLeft = indent;
                 }
-                div.appendTo(p);
                 // TODO: Link to path somehow.
-                div.text(strings[stack.Op[i]] + " at " + posText(stack.P[i], stack.L[i]));
-                div.css("padding-left", indent);
+                var div = elt(p, "div", strings[op] + " at " + posText(stack.P[i], stack.L[i]));
+                div.style.paddingLeft = indent;
+                if (i >= showFirst && stack.Op.length - i > showLast)
+                    elided.push(div);
             });
             // If we elided frames, update the show link.
             if (elided.length === 1) {
                 // No point in eliding one frame.
-                elideDiv.hide();
+                elideDiv.style.display = "none";
             } else if (elided.length > 0) {
-                elideDiv.text("... show " + elided.length + " elided frames ...").css({color: "#00e", cursor: "pointer"});
-                $(elided).hide();
-                elideDiv.on("click", function(ev) {
-                    elideDiv.hide();
-                    $(elided).show();
-                })
+                elideDiv.textContent = "... show " + elided.length + " elided frames ...";
+                elideDiv.className = "link";
+                elided.forEach(function(div) { div.style.display = "none"; });
+                elideDiv.addEventListener("click", function(ev) {
+                    elideDiv.style.display = "none";
+                    elided.forEach(function(div) { div.style.display = ""; });
+                });
             }
         }
         renderStack(path.From);
@@ -77,59 +193,98 @@ function showEdge(strings, edge) {
 
 // enableHighlighting takes an GraphViz-generated SVG and enables
 // interactive highlighting when the mouse hovers over nodes and
-// edges.
+// edges. It returns an object with two methods: select(edgeIDs)
+// keeps the edges with the given element IDs and their nodes
+// highlighted, or clears the selection if edgeIDs is null, and
+// filter(keep) hides the nodes for which keep(lockID) is false and
+// their edges.
 function enableHighlighting(svg) {
     var nodes = {}, edges = {};
+    var selected = null;
     function all(opacity) {
-        $.each(nodes, function(_, node) {
-            $(node.dom).clearQueue().fadeTo('fast', opacity);
-        })
-        $.each(edges, function(_, edge) {
-            $(edge.dom).clearQueue().fadeTo('fast', opacity);
-        })
+        Object.keys(nodes).forEach(function(id) {
+            nodes[id].dom.style.opacity = opacity;
+        });
+        Object.keys(edges).forEach(function(id) {
+            edges[id].dom.style.opacity = opacity;
+        });
     }
 
     function highlight(dom) {
-        $(dom).clearQueue().fadeTo('fast', 1);
+        dom.style.opacity = 1;
+    }
+
+    // reset restores the highlighting of the selection.
+    function reset() {
+        if (selected === null) {
+            all(1);
+            return;
+        }
+        all(0.25);
+        selected.forEach(function(id) {
+            var edge = edges[id];
+            if (edge === undefined)
+                return;
+            highlight(edge.dom);
+            highlight(nodes[edge.from].dom);
+            highlight(nodes[edge.to].dom);
+        });
     }
 
     // Process nodes.
-    $(".node", svg).each(function(_, node) {
-        var id = $("title", node).text();
+    svg.querySelectorAll(".node").forEach(function(node) {
+        var id = node.querySelector("title").textContent;
         var info = {dom: node, edges: []};
         nodes[id] = info;
-        $(node).on("mouseenter", function() {
+        node.addEventListener("mouseenter", function() {
             all(0.25);
             highlight(node);
-            $.each(info.edges, function(_, edge) {
+            info.edges.forEach(function(edge) {
                 highlight(edge.dom);
                 if (edge.from !== id)
                     highlight(nodes[edge.from].dom);
                 if (edge.to !== id)
                     highlight(nodes[edge.to].dom);
             });
-        }).on("mouseleave", function() {
-            all(1);
         });
+        node.addEventListener("mouseleave", reset);
     });
 
     // Process edges.
-    $(".edge", svg).each(function(_, edge) {
-        var id = $("title", edge).text();
+    svg.querySelectorAll(".edge").forEach(function(edge) {
+        var id = edge.querySelector("title").textContent;
         var m = id.match(/^(.*)->(.*)$/);
         var info = {dom: edge, from: m[1], to: m[2]};
         edges[edge.id] = info;
         nodes[info.from].edges.push(info);
         nodes[info.to].edges.push(info);
-        $(edge).on("mouseenter", function() {
+        edge.addEventListener("mouseenter", function() {
             all(0.25);
             highlight(edge);
             highlight(nodes[info.from].dom);
             highlight(nodes[info.to].dom);
-        }).on("mouseleave", function() {
-            all(1);
         });
+        edge.addEventListener("mouseleave", reset);
     });
+
+    return {
+        select: function(edgeIDs) {
+            selected = edgeIDs;
+            reset();
+        },
+        filter: function(keep) {
+            // Node IDs are "l" followed by the lock ID.
+            var visible = {};
+            Object.keys(nodes).forEach(function(id) {
+                visible[id] = keep(parseInt(id.substring(1), 10));
+                nodes[id].dom.style.display = visible[id] ? "" : "none";
+            });
+            Object.keys(edges).forEach(function(id) {
+                var edge = edges[id];
+                edge.dom.style.display = visible[edge.from] && visible[edge.to] ? "" : "none";
+            });
+        },
+    };
 }
 
 // zoomify makes drags and wheel events on element fill pan and zoom
@@ -137,75 +292,73 @@ function enableHighlighting(svg) {
 // fit in fill. Hence, the caller should center the svg element within
 // fill.
 function zoomify(svg, fill) {
-    var svg = $(svg);
-    var fill = $(fill);
-
     // Wrap svg in a group we can transform.
-    var g = $(document.createElementNS("http://www.w3.org/2000/svg", "g"));
-    g.append(svg.children()).appendTo(svg);
+    var g = document.createElementNS("http://www.w3.org/2000/svg", "g");
+    while (svg.firstChild !== null)
+        g.appendChild(svg.firstChild);
+    svg.appendChild(g);
 
     // Create an initial transform to center and fit the svg.
-    var bbox = g[0].getBBox();
-    var scale = Math.min(fill.width() / bbox.width, fill.height() / bbox.height);
+    var bbox = g.getBBox();
+    var scale = Math.min(fill.clientWidth / bbox.width, fill.clientHeight / bbox.height);
     if (scale > 1) scale = 1;
-    var mat = svg[0].createSVGMatrix().
+    var mat = svg.createSVGMatrix().
         translate(-bbox.x, -bbox.y).
         scale(scale).
         translate(-bbox.width/2, -bbox.height/2);
-    var transform = svg[0].createSVGTransform();
+    var transform = svg.createSVGTransform();
     transform.setMatrix(mat);
-    g[0].transform.baseVal.insertItemBefore(transform, 0);
+    g.transform.baseVal.insertItemBefore(transform, 0);
 
     // Handle drags.
     var lastpos;
     function mousemove(ev) {
         if (ev.buttons == 0) {
-            fill.off("mousemove");
+            fill.removeEventListener("mousemove", mousemove);
             return;
         }
         var deltaX = ev.pageX - lastpos.pageX;
         var deltaY = ev.pageY - lastpos.pageY;
         lastpos = ev;
-        var transform = svg[0].createSVGTransform();
+        var transform = svg.createSVGTransform();
         transform.setTranslate(deltaX, deltaY);
-        g[0].transform.baseVal.insertItemBefore(transform, 0);
-        g[0].transform.baseVal.consolidate();
+        g.transform.baseVal.insertItemBefore(transform, 0);
+        g.transform.baseVal.consolidate();
         ev.preventDefault();
     }
-    fill.on("mousedown", function(ev) {
+    fill.addEventListener("mousedown", function(ev) {
         lastpos = ev;
-        fill.on("mousemove", mousemove);
+        fill.addEventListener("mousemove", mousemove);
         ev.preventDefault();
     });
-    fill.on("mouseup", function(ev) {
-        fill.off("mousemove");
+    fill.addEventListener("mouseup", function(ev) {
+        fill.removeEventListener("mousemove", mousemove);
         ev.preventDefault();
     });
 
     // Handle zooms.
-    var point = svg[0].createSVGPoint();
-    fill.on("wheel", function(ev) {
-        var delta = ev.originalEvent.deltaY;
+    var point = svg.createSVGPoint();
+    fill.addEventListener("wheel", function(ev) {
         // rates is the delta required to scale by a factor of 2.
         var rates = [
             500, // WheelEvent.DOM_DELTA_PIXEL
             30,  // WheelEvent.DOM_DELTA_LINE
             0.5, // WheelEvent.DOM_DELTA_PAGE
         ];
-        var factor = Math.pow(2, -delta / rates[ev.originalEvent.deltaMode]);
-        point.x = ev.clientX === undefined ? ev.originalEvent.clientX : ev.clientX;
-        point.y = ev.clientY === undefined ? ev.originalEvent.clientY : ev.clientY;
-        var center = point.matrixTransform(svg[0].getScreenCTM().inverse());
+        var factor = Math.pow(2, -ev.deltaY / rates[ev.deltaMode]);
+        point.x = ev.clientX;
+        point.y = ev.clientY;
+        var center = point.matrixTransform(svg.getScreenCTM().inverse());
 
         // Scale by factor around center.
-        var mat = svg[0].createSVGMatrix().
+        var mat = svg.createSVGMatrix().
                          translate(center.x, center.y).
                          scale(factor).
                          translate(-center.x, -center.y);
-        var transform = svg[0].createSVGTransform();
+        var transform = svg.createSVGTransform();
         transform.setMatrix(mat);
-        g[0].transform.baseVal.insertItemBefore(transform, 0);
-        g[0].transform.baseVal.consolidate();
+        g.transform.baseVal.insertItemBefore(transform, 0);
+        g.transform.baseVal.consolidate();
         ev.preventDefault();
-    });
+    }, {passive: false});
 }
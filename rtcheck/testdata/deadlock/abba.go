@@ -0,0 +1,25 @@
+// Two roots acquire the same pair of global locks in opposite
+// orders.
+
+package runtime
+
+type mutex struct{ key uintptr }
+
+func lock(l *mutex)   {}
+func unlock(l *mutex) {}
+
+var a, b mutex
+
+func root1() {
+	lock(&a)
+	lock(&b)
+	unlock(&b)
+	unlock(&a)
+}
+
+func root2() {
+	lock(&b)
+	lock(&a)
+	unlock(&a)
+	unlock(&b)
+}
@@ -0,0 +1,37 @@
+// A lock acquired on only one side of a branch is still ordered
+// before the locks acquired after the branches join. The lock
+// released early on the other side isn't.
+
+package runtime
+
+type mutex struct{ key uintptr }
+
+func lock(l *mutex)   {}
+func unlock(l *mutex) {}
+
+var a, b, c mutex
+
+func root1(x bool) {
+	if x {
+		lock(&a)
+	}
+	lock(&b)
+	unlock(&b)
+	if x {
+		unlock(&a)
+	}
+}
+
+func root2() {
+	lock(&b)
+	lock(&c)
+	unlock(&c)
+	unlock(&b)
+}
+
+func root3() {
+	lock(&c)
+	lock(&a)
+	unlock(&a)
+	unlock(&c)
+}
@@ -0,0 +1,37 @@
+// Locks embedded in structs are classified by their struct type and
+// field, so the cycle is between lock classes rather than
+// instances. The inversion happens in a callee.
+
+package runtime
+
+type mutex struct{ key uintptr }
+
+func lock(l *mutex)   {}
+func unlock(l *mutex) {}
+
+type hchan struct {
+	lock mutex
+}
+
+type sudog struct {
+	c    *hchan
+	lock mutex
+}
+
+func root1(c *hchan, s *sudog) {
+	lock(&c.lock)
+	lock(&s.lock)
+	unlock(&s.lock)
+	unlock(&c.lock)
+}
+
+func root2(s *sudog) {
+	lock(&s.lock)
+	dequeue(s.c)
+	unlock(&s.lock)
+}
+
+func dequeue(c *hchan) {
+	lock(&c.lock)
+	unlock(&c.lock)
+}
@@ -0,0 +1,30 @@
+// Every path acquires the locks in the same order, including
+// through a helper, so there are no cycles.
+
+package runtime
+
+type mutex struct{ key uintptr }
+
+func lock(l *mutex)   {}
+func unlock(l *mutex) {}
+
+var a, b, c mutex
+
+func root1() {
+	lock(&a)
+	withB()
+	unlock(&a)
+}
+
+func root2() {
+	withB()
+	lock(&c)
+	unlock(&c)
+}
+
+func withB() {
+	lock(&b)
+	lock(&c)
+	unlock(&c)
+	unlock(&b)
+}
@@ -0,0 +1,49 @@
+// Read acquisitions of a reader/writer lock don't conflict with
+// each other, but do conflict with write acquisitions.
+
+package runtime
+
+type mutex struct{ key uintptr }
+
+func lock(l *mutex)   {}
+func unlock(l *mutex) {}
+
+type rwmutex struct{ w mutex }
+
+func (rw *rwmutex) rlock()   {}
+func (rw *rwmutex) runlock() {}
+func (rw *rwmutex) lock()    {}
+func (rw *rwmutex) unlock()  {}
+
+var (
+	rw   rwmutex
+	a, b mutex
+)
+
+func root1() {
+	rw.rlock()
+	lock(&a)
+	unlock(&a)
+	rw.runlock()
+}
+
+func root2() {
+	lock(&a)
+	rw.rlock()
+	rw.runlock()
+	unlock(&a)
+}
+
+func root3() {
+	rw.lock()
+	lock(&b)
+	unlock(&b)
+	rw.unlock()
+}
+
+func root4() {
+	lock(&b)
+	rw.rlock()
+	rw.runlock()
+	unlock(&b)
+}
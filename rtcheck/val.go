@@ -361,6 +361,11 @@ func (x DynConst) BinOp(op token.Token, y DynValue) DynValue {
 			log.Print("division by zero")
 			return dynUnknown{}
 		}
+		if x.c.Kind() == constant.Int && yc.Kind() == constant.Int {
+			// QUO of two Ints is exact division. QUO_ASSIGN
+			// truncates, like Go integer division.
+			op = token.QUO_ASSIGN
+		}
 		fallthrough
 	default:
 		return DynConst{constant.BinaryOp(x.c, op, yc)}
@@ -518,7 +523,7 @@ func (x DynStruct) BinOp(op token.Token, y DynValue) DynValue {
 }
 
 func (x DynStruct) UnOp(op token.Token, vs ValState) DynValue {
-	log.Fatal("bad struct operation: %v", op)
+	log.Fatalf("bad struct operation: %v", op)
 	panic("unreachable")
 }
 
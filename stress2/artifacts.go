@@ -0,0 +1,108 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// An artifact is a file written by a run that is matched against the
+// pass/fail regexps along with the run's output.
+type artifact struct {
+	name string // Relative to the run directory
+	data []byte
+}
+
+// readArtifacts reads the files in dir matching any of the glob
+// patterns. Directories are skipped.
+func readArtifacts(dir string, globs []string) ([]artifact, error) {
+	var artifacts []artifact
+	seen := make(map[string]bool)
+	for _, glob := range globs {
+		paths, err := filepath.Glob(filepath.Join(dir, glob))
+		if err != nil {
+			return nil, err
+		}
+		for _, path := range paths {
+			if seen[path] {
+				continue
+			}
+			seen[path] = true
+			fi, err := os.Stat(path)
+			if err != nil {
+				return nil, err
+			}
+			if fi.IsDir() {
+				continue
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return nil, err
+			}
+			name, err := filepath.Rel(dir, path)
+			if err != nil {
+				name = path
+			}
+			artifacts = append(artifacts, artifact{name, data})
+		}
+	}
+	return artifacts, nil
+}
+
+// matchWhere reports whether re matches output or any artifact and,
+// if so, returns the "file:line" of the first match. Matches in output
+// are reported as "output:line"; see shiftOutputLine for saved logs
+// with a header.
+func matchWhere(re *regexp.Regexp, output []byte, artifacts []artifact) (string, bool) {
+	if loc := re.FindIndex(output); loc != nil {
+		return fmt.Sprintf("output:%d", lineOf(output, loc[0])), true
+	}
+	for _, a := range artifacts {
+		if loc := re.FindIndex(a.data); loc != nil {
+			return fmt.Sprintf("%s:%d", a.name, lineOf(a.data, loc[0])), true
+		}
+	}
+	return "", false
+}
+
+// shiftOutputLine adjusts a match location returned by matchWhere for
+// header being written before the output in the saved log.
+func shiftOutputLine(where string, header []byte) string {
+	line, err := strconv.Atoi(strings.TrimPrefix(where, "output:"))
+	if err != nil || !strings.HasPrefix(where, "output:") {
+		return where
+	}
+	return fmt.Sprintf("output:%d", line+bytes.Count(header, []byte("\n")))
+}
+
+// lineOf returns the 1-based line number of byte offset off in data.
+func lineOf(data []byte, off int) int {
+	return bytes.Count(data[:off], []byte("\n")) + 1
+}
+
+// artifactDir returns the directory the artifacts of the run saved to
+// logPath are kept in.
+func artifactDir(logPath string) string {
+	return strings.TrimSuffix(logPath, ".gz") + ".d"
+}
+
+// appendLog appends a line to the log file at path.
+func appendLog(path, format string, args ...interface{}) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(f, format, args...); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
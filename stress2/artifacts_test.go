@@ -0,0 +1,48 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestMatchWhere(t *testing.T) {
+	artifacts := []artifact{
+		{"a.txt", []byte("ok\n")},
+		{"b.txt", []byte("ok\nok\nFAIL: x\n")},
+	}
+	check := func(re, output, want string) {
+		t.Helper()
+		got, ok := matchWhere(regexp.MustCompile("(?m)"+re), []byte(output), artifacts)
+		if got != want || ok != (want != "") {
+			t.Errorf("matchWhere(%q) = %q, %v; want %q", re, got, ok, want)
+		}
+	}
+	check("^FAIL", "ok\n", "b.txt:3")
+	check("^FAIL", "x\nFAIL\n", "output:2")
+	check("^panic", "ok\n", "")
+}
+
+func TestShiftOutputLine(t *testing.T) {
+	header := envSnapshot{{"go", "go1.27"}, {"cpus", "8"}}.header()
+	output := []byte("ok\nFAIL: x\n")
+	where, _ := matchWhere(regexp.MustCompile("(?m)^FAIL"), output, nil)
+	where = shiftOutputLine(where, header)
+	// The reference must point at the match in the saved log.
+	saved := strings.Split(string(header)+string(output), "\n")
+	var line int
+	if _, err := fmt.Sscanf(where, "output:%d", &line); err != nil || line < 1 || line > len(saved) || !strings.HasPrefix(saved[line-1], "FAIL") {
+		t.Errorf("shifted to %q, which isn't the FAIL line of\n%s", where, strings.Join(saved, "\n"))
+	}
+
+	for _, where := range []string{"", "b.txt:3", "output.txt:2"} {
+		if got := shiftOutputLine(where, header); got != where {
+			t.Errorf("shiftOutputLine(%q) = %q, want unchanged", where, got)
+		}
+	}
+}
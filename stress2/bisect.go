@@ -0,0 +1,189 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// bisectMain implements "stress bisect", which runs git bisect,
+// stressing the command at each candidate revision until it's
+// confident whether the failure is present.
+func bisectMain(args []string) {
+	fs := flag.NewFlagSet("bisect", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), `Usage: %s bisect -good rev [-bad rev] [flags] [--] command...
+
+stress bisect finds the commit that introduced a flaky failure using
+git bisect in the current directory's repository.
+
+It first stresses command at the bad revision until it fails
+-estimate-fails times to estimate the failure rate. It then bisects
+between the good and bad revisions. At each candidate revision, a
+single failure marks the revision bad. Otherwise, the revision is
+marked good after enough passes that, if the failure were present at
+the estimated rate, there would have been a failure with probability
+-confidence. If a revision produces only flakes and timeouts, it is
+skipped.
+
+Logs for each revision are written to a subdirectory of -o named
+after the revision. When bisection finishes, the repository is left
+in the bisection state; use "git bisect reset" to leave it.
+
+`, os.Args[0])
+		fs.PrintDefaults()
+	}
+	var s Stress
+	s.addFlags(fs)
+	flagGood := fs.String("good", "", "known good `revision`")
+	flagBad := fs.String("bad", "HEAD", "known bad `revision`")
+	flagConfidence := fs.Float64("confidence", 0.99, "mark a revision good once its passes give `probability` that the failure is absent")
+	flagRate := fs.Float64("rate", 0, "assume the failure rate at -bad is `fraction` instead of measuring it")
+	flagEstimateFails := fs.Int("estimate-fails", 3, "measure the failure rate at -bad by running until `N` failures")
+	fs.Parse(args)
+	s.Command = fs.Args()
+	if *flagGood == "" || s.Parallelism <= 0 || s.Timeout <= 0 || len(s.Command) == 0 {
+		fs.Usage()
+		os.Exit(1)
+	}
+	if *flagConfidence <= 0 || *flagConfidence >= 1 {
+		log.Fatal("-confidence must be between 0 and 1")
+	}
+	if *flagRate < 0 || *flagRate > 1 || *flagEstimateFails <= 0 {
+		log.Fatal("-rate must be between 0 and 1 and -estimate-fails must be > 0")
+	}
+
+	good, bad := revParse(*flagGood), revParse(*flagBad)
+	s.setup()
+
+	fmt.Print(bisectGit("bisect", "start", bad, good))
+	if bisectDone() {
+		return
+	}
+
+	rate := *flagRate
+	if rate == 0 {
+		// Measure the failure rate at the bad revision, then
+		// return to the revision git bisect picked.
+		next := revParse("HEAD")
+		bisectGit("checkout", "--quiet", bad)
+		fmt.Printf("measuring failure rate at %s\n", short(bad))
+		counts, ok := s.runAt(bad, func(rs *Stress) {
+			rs.MaxFails = *flagEstimateFails
+		})
+		if !ok {
+			os.Exit(1)
+		}
+		if counts[ResultFail] == 0 {
+			log.Fatalf("%s did not fail", short(bad))
+		}
+		rate = float64(counts[ResultFail]) / float64(counts[ResultPass]+counts[ResultFail])
+		bisectGit("checkout", "--quiet", next)
+	}
+	passes := runsForConfidence(rate, *flagConfidence)
+	fmt.Printf("failure rate %.2f%%; marking revisions good after %d passes\n", 100*rate, passes)
+
+	for {
+		head := revParse("HEAD")
+		counts, ok := s.runAt(head, func(rs *Stress) {
+			rs.MaxFails = 1
+			rs.MaxPasses = passes
+			// Give up on a revision that does nothing but
+			// flake.
+			rs.MaxTotalRuns = 2 * passes
+		})
+		if !ok {
+			os.Exit(1)
+		}
+		verdict := "skip"
+		switch {
+		case counts[ResultFail] > 0:
+			verdict = "bad"
+		case counts[ResultPass] >= passes:
+			verdict = "good"
+		}
+		fmt.Printf("%s is %s (%d passes, %d fails)\n", short(head), verdict, counts[ResultPass], counts[ResultFail])
+		fmt.Print(bisectGit("bisect", verdict))
+		if bisectDone() {
+			return
+		}
+	}
+}
+
+// runAt stresses the command at revision rev, which must already be
+// checked out, with limits set by limit. It returns the counts of
+// each outcome, or false if the run was interrupted or couldn't run
+// the command.
+func (s *Stress) runAt(rev string, limit func(rs *Stress)) (map[ResultKind]int, bool) {
+	rs := *s
+	rs.OutDir = filepath.Join(s.OutDir, short(rev))
+	if err := os.MkdirAll(rs.OutDir, 0777); err != nil {
+		log.Fatal(err)
+	}
+	limit(&rs)
+	rs.Run(NewStdoutReporter())
+	select {
+	case <-s.Interrupt:
+		fmt.Printf("interrupted; use \"git bisect reset\" to end bisection\n")
+		return nil, false
+	default:
+	}
+	return rs.Counts, true
+}
+
+// runsForConfidence returns how many consecutive passes of a command
+// that fails at the given rate it takes to conclude with the given
+// confidence that the failure isn't present.
+func runsForConfidence(rate, confidence float64) int {
+	if rate >= 1 {
+		return 1
+	}
+	// The chance of n passes in a row if the failure is present
+	// is (1-rate)^n, which must be at most 1-confidence.
+	return int(math.Ceil(math.Log(1-confidence) / math.Log(1-rate)))
+}
+
+// bisectDone reports whether git bisect has found the first bad
+// commit or run out of commits to test.
+func bisectDone() bool {
+	out := bisectGit("bisect", "log")
+	for _, line := range strings.Split(out, "\n") {
+		if strings.HasPrefix(line, "# first bad commit:") || strings.HasPrefix(line, "# only skipped commits left to test") {
+			return true
+		}
+	}
+	return false
+}
+
+func revParse(rev string) string {
+	return strings.TrimSpace(bisectGit("rev-parse", "--verify", rev+"^{commit}"))
+}
+
+// short returns the abbreviated form of commit hash rev.
+func short(rev string) string {
+	if len(rev) > 10 {
+		return rev[:10]
+	}
+	return rev
+}
+
+// bisectGit runs git with args and returns its output, exiting on
+// failure.
+func bisectGit(args ...string) string {
+	cmd := exec.Command("git", args...)
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	if err != nil {
+		log.Fatalf("git %s: %s", strings.Join(args, " "), err)
+	}
+	return string(out)
+}
@@ -0,0 +1,23 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import "testing"
+
+func TestRunsForConfidence(t *testing.T) {
+	for _, test := range []struct {
+		rate, confidence float64
+		want             int
+	}{
+		{1, 0.99, 1},
+		{0.5, 0.99, 7},
+		{0.2, 0.99, 21},
+		{0.01, 0.95, 299},
+	} {
+		if got := runsForConfidence(test.rate, test.confidence); got != test.want {
+			t.Errorf("runsForConfidence(%v, %v) = %d, want %d", test.rate, test.confidence, got, test.want)
+		}
+	}
+}
@@ -10,6 +10,8 @@ import (
 	"log"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"strings"
 	"sync"
 	"syscall"
 	"time"
@@ -55,7 +57,10 @@ type Command struct {
 }
 
 // StartCommand starts a managed command with the given command-line
-// arguments, with its stdout and stderr redirected to out.
+// arguments in directory dir, with its stdout and stderr redirected
+// to out. If dir is "", the command runs in the current directory.
+// env lists KEY=VAL settings to add to the command's environment,
+// which otherwise is the same as ours.
 //
 // This has several differences from exec.Command:
 //
@@ -65,8 +70,23 @@ type Command struct {
 // sub-processes continue to write to stdout/stderr.
 //
 // - This provides a channel-based way to wait for command completion.
-func StartCommand(args []string, out io.Writer) (*Command, error) {
-	cmd := exec.Command(args[0], args[1:]...)
+func StartCommand(args []string, dir string, env []string, out io.Writer) (*Command, error) {
+	name := args[0]
+	if dir != "" && strings.ContainsRune(name, filepath.Separator) && !filepath.IsAbs(name) {
+		// exec resolves relative command paths against
+		// cmd.Dir, but they were given relative to our
+		// directory.
+		abs, err := filepath.Abs(name)
+		if err != nil {
+			return nil, err
+		}
+		name = abs
+	}
+	cmd := exec.Command(name, args[1:]...)
+	cmd.Dir = dir
+	if len(env) > 0 {
+		cmd.Env = append(os.Environ(), env...)
+	}
 
 	// Put cmd in a process group so we can signal the whole
 	// process group.
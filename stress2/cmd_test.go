@@ -4,7 +4,10 @@
 
 package main
 
-import "testing"
+import (
+	"bytes"
+	"testing"
+)
 
 func TestStdoutExitRace(t *testing.T) {
 	// The stdout pipe is asynchronous with exiting, so even if a
@@ -13,7 +16,8 @@ func TestStdoutExitRace(t *testing.T) {
 	// handle this correctly.
 
 	for i := 0; i < 1000; i++ {
-		cmd, err := StartCommand([]string{"/bin/echo", "hi"})
+		var out bytes.Buffer
+		cmd, err := StartCommand([]string{"/bin/echo", "hi"}, "", nil, &out)
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -21,7 +25,7 @@ func TestStdoutExitRace(t *testing.T) {
 		if !cmd.Status.Success() {
 			t.Fatal("command failed: ", cmd.Status)
 		}
-		if got, want := string(cmd.Output), "hi\n"; got != want {
+		if got, want := out.String(), "hi\n"; got != want {
 			t.Errorf("got %q, want %q", got, want)
 		}
 	}
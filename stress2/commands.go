@@ -0,0 +1,85 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// FlagCommand is a flag.Value that collects repeated -cmd flags. Each
+// command is split into arguments at white space.
+type FlagCommand struct {
+	x *[][]string
+}
+
+func (f FlagCommand) String() string {
+	if f.x == nil {
+		return ""
+	}
+	var cmds []string
+	for _, cmd := range *f.x {
+		cmds = append(cmds, strings.Join(cmd, " "))
+	}
+	return strings.Join(cmds, "; ")
+}
+
+func (f FlagCommand) Set(x string) error {
+	args := strings.Fields(x)
+	if len(args) == 0 {
+		return fmt.Errorf("empty command")
+	}
+	*f.x = append(*f.x, args)
+	return nil
+}
+
+// readCommands reads a file of commands, one per line, split into
+// arguments at white space. Blank lines and lines starting with # are
+// ignored.
+func readCommands(path string) ([][]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parseCommands(f)
+}
+
+func parseCommands(r io.Reader) ([][]string, error) {
+	var cmds [][]string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		cmds = append(cmds, strings.Fields(line))
+	}
+	return cmds, scanner.Err()
+}
+
+// printCommandCounts reports the outcomes and failure rate of each
+// command.
+func printCommandCounts(w io.Writer, cmds [][]string, cmdCounts []map[ResultKind]int) {
+	for i, cmd := range cmds {
+		counts := cmdCounts[i]
+		pass, fail := counts[ResultPass], counts[ResultFail]
+		rate := "?"
+		if pass+fail > 0 {
+			rate = fmt.Sprintf("%.1f%%", 100*float64(fail)/float64(pass+fail))
+		}
+		fmt.Fprintf(w, "command %d: %d passes, %d fails", i, pass, fail)
+		if n := counts[ResultFlake]; n > 0 {
+			fmt.Fprintf(w, ", %d flakes", n)
+		}
+		if n := counts[ResultTimeout]; n > 0 {
+			fmt.Fprintf(w, ", %d timeouts", n)
+		}
+		fmt.Fprintf(w, " (%s failure rate): %s\n", rate, strings.Join(cmd, " "))
+	}
+}
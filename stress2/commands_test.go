@@ -0,0 +1,41 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseCommands(t *testing.T) {
+	data := "# stress these together\n./a.test -test.run=TestA\n\n  ./b.test   -test.run=TestB -test.count=2  \n"
+	got, err := parseCommands(strings.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{
+		{"./a.test", "-test.run=TestA"},
+		{"./b.test", "-test.run=TestB", "-test.count=2"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestPrintCommandCounts(t *testing.T) {
+	var buf strings.Builder
+	cmds := [][]string{{"a", "-x"}, {"b"}}
+	counts := []map[ResultKind]int{
+		{ResultPass: 3, ResultFail: 1, ResultTimeout: 2},
+		{},
+	}
+	printCommandCounts(&buf, cmds, counts)
+	want := "command 0: 3 passes, 1 fails, 2 timeouts (25.0% failure rate): a -x\n" +
+		"command 1: 0 passes, 0 fails (? failure rate): b\n"
+	if buf.String() != want {
+		t.Errorf("got:\n%swant:\n%s", buf.String(), want)
+	}
+}
@@ -0,0 +1,186 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// An envSnapshot records the parts of the environment that are
+// useful for interpreting a failure, such as the Go version, kernel,
+// and CPU.
+type envSnapshot []envItem
+
+type envItem struct {
+	key, value string
+}
+
+// envVars are the environment variables recorded in a snapshot, if
+// they're set.
+var envVars = []string{"GOFLAGS", "GOOS", "GOARCH", "GOMAXPROCS", "GOGC", "GODEBUG", "GOEXPERIMENT", "GOTOOLCHAIN", "GOTRACEBACK"}
+
+// takeEnvSnapshot captures the current environment for running
+// command with the KEY=VAL settings in setEnv added to its
+// environment. Items that can't be determined are omitted.
+func takeEnvSnapshot(command, setEnv []string) envSnapshot {
+	var snap envSnapshot
+	add := func(key, value string) {
+		if value = strings.TrimSpace(value); value != "" {
+			snap = append(snap, envItem{key, value})
+		}
+	}
+	add("command", strings.Join(command, " "))
+	add("date", time.Now().Format(time.RFC3339))
+	host, _ := os.Hostname()
+	add("host", host)
+	add("go", cmdOutput("go", "version"))
+	for _, name := range envVars {
+		if v, ok := lookupEnv(setEnv, name); ok {
+			add(name, fmt.Sprintf("%q", v))
+		}
+	}
+	var set []string
+	for _, kv := range setEnv {
+		set = append(set, fmt.Sprintf("%q", kv))
+	}
+	add("env", strings.Join(set, " "))
+	add("kernel", cmdOutput("uname", "-srvm"))
+	add("cpu", cpuModel())
+	add("ncpu", fmt.Sprint(runtime.NumCPU()))
+	add("governor", readFile("/sys/devices/system/cpu/cpu0/cpufreq/scaling_governor"))
+	return snap
+}
+
+// lookupEnv returns the value of environment variable key in a
+// child's environment: the last setting of key in setEnv if any,
+// otherwise the value in our own environment.
+func lookupEnv(setEnv []string, key string) (string, bool) {
+	for i := len(setEnv) - 1; i >= 0; i-- {
+		if strings.HasPrefix(setEnv[i], key+"=") {
+			return setEnv[i][len(key)+1:], true
+		}
+	}
+	return os.LookupEnv(key)
+}
+
+// cmdOutput returns the output of running a command, or "" if it
+// fails.
+func cmdOutput(name string, args ...string) string {
+	out, err := exec.Command(name, args...).Output()
+	if err != nil {
+		return ""
+	}
+	return string(out)
+}
+
+func readFile(path string) string {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+func cpuModel() string {
+	switch runtime.GOOS {
+	case "darwin":
+		return cmdOutput("sysctl", "-n", "machdep.cpu.brand_string")
+	case "freebsd", "netbsd", "openbsd":
+		return cmdOutput("sysctl", "-n", "hw.model")
+	}
+	// On Linux, the CPU model is in /proc/cpuinfo, but the key
+	// depends on the architecture.
+	scanner := bufio.NewScanner(strings.NewReader(readFile("/proc/cpuinfo")))
+	for scanner.Scan() {
+		line := scanner.Text()
+		i := strings.IndexByte(line, ':')
+		if i < 0 {
+			continue
+		}
+		switch strings.TrimSpace(line[:i]) {
+		case "model name", "Hardware", "cpu model", "cpu":
+			return line[i+1:]
+		}
+	}
+	return ""
+}
+
+// String formats snap as "key: value" lines.
+func (snap envSnapshot) String() string {
+	var buf strings.Builder
+	for _, item := range snap {
+		fmt.Fprintf(&buf, "%s: %s\n", item.key, item.value)
+	}
+	return buf.String()
+}
+
+// header formats snap as a header for a saved log.
+func (snap envSnapshot) header() []byte {
+	var buf bytes.Buffer
+	for _, item := range snap {
+		fmt.Fprintf(&buf, "# %s: %s\n", item.key, item.value)
+	}
+	buf.WriteString("\n")
+	return buf.Bytes()
+}
+
+// withCommand returns a copy of snap that records command as the
+// command being run.
+func (snap envSnapshot) withCommand(command []string) envSnapshot {
+	out := envSnapshot{{"command", strings.Join(command, " ")}}
+	for _, item := range snap {
+		if item.key != "command" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+// parseEnvSnapshot parses the output of envSnapshot.String.
+func parseEnvSnapshot(data string) envSnapshot {
+	var snap envSnapshot
+	for _, line := range strings.Split(data, "\n") {
+		if i := strings.Index(line, ": "); i >= 0 {
+			snap = append(snap, envItem{line[:i], line[i+2:]})
+		}
+	}
+	return snap
+}
+
+// diff returns a description of each item that differs between old
+// and snap. It ignores the date.
+func (snap envSnapshot) diff(old envSnapshot) []string {
+	oldMap := make(map[string]string)
+	for _, item := range old {
+		oldMap[item.key] = item.value
+	}
+	var diffs []string
+	seen := make(map[string]bool)
+	for _, item := range snap {
+		seen[item.key] = true
+		if item.key == "date" {
+			continue
+		}
+		if ov, ok := oldMap[item.key]; !ok {
+			diffs = append(diffs, fmt.Sprintf("%s: (unset) -> %s", item.key, item.value))
+		} else if ov != item.value {
+			diffs = append(diffs, fmt.Sprintf("%s: %s -> %s", item.key, ov, item.value))
+		}
+	}
+	for _, item := range old {
+		if !seen[item.key] {
+			diffs = append(diffs, fmt.Sprintf("%s: %s -> (unset)", item.key, item.value))
+		}
+	}
+	return diffs
+}
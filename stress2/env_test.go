@@ -0,0 +1,48 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEnvSnapshotDiff(t *testing.T) {
+	old := envSnapshot{{"date", "1"}, {"go", "go1.23"}, {"GOFLAGS", `"-race"`}, {"cpu", "x"}}
+	if got := parseEnvSnapshot(old.String()); !reflect.DeepEqual(got, old) {
+		t.Fatalf("round trip: got %v, want %v", got, old)
+	}
+
+	cur := envSnapshot{{"date", "2"}, {"go", "go1.24"}, {"cpu", "x"}, {"governor", "performance"}}
+	want := []string{
+		"go: go1.23 -> go1.24",
+		"governor: (unset) -> performance",
+		`GOFLAGS: "-race" -> (unset)`,
+	}
+	if got := cur.diff(old); !reflect.DeepEqual(got, want) {
+		t.Errorf("got diff %q, want %q", got, want)
+	}
+}
+
+func TestSetEnv(t *testing.T) {
+	var env []string
+	if err := (FlagEnv{x: &env}).Set("GODEBUG=x=1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := (FlagEnv{x: &env}).Set("=1"); err == nil {
+		t.Errorf("Set(%q) succeeded, want error", "=1")
+	}
+	(FlagEnv{x: &env}).Set("GOTRACEBACK=all")
+	(FlagEnv{x: &env, key: "GOTRACEBACK"}).Set("crash")
+	if want := []string{"GODEBUG=x=1", "GOTRACEBACK=all", "GOTRACEBACK=crash"}; !reflect.DeepEqual(env, want) {
+		t.Fatalf("got %q, want %q", env, want)
+	}
+
+	for key, want := range map[string]string{"GODEBUG": "x=1", "GOTRACEBACK": "crash"} {
+		if got, ok := lookupEnv(env, key); !ok || got != want {
+			t.Errorf("lookupEnv(%s) = %q, %v, want %q", key, got, ok, want)
+		}
+	}
+}
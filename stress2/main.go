@@ -7,6 +7,7 @@ package main
 import (
 	"flag"
 	"fmt"
+	"io/ioutil"
 	"log"
 	"os"
 	"os/signal"
@@ -14,12 +15,15 @@ import (
 	"regexp"
 	"runtime"
 	"strconv"
+	"strings"
 	"time"
 )
 
 func main() {
 	flag.Usage = func() {
-		fmt.Fprintf(flag.CommandLine.Output(), `Usage: %s [flags] command...
+		fmt.Fprintf(flag.CommandLine.Output(), `Usage: %[1]s [flags] command...
+       %[1]s [flags] -cmd command [-cmd command]... [command...]
+       %[1]s bisect -good rev [flags] command...
 
 stress runs command repeatedly and in parallel and collects failures.
 
@@ -33,40 +37,128 @@ considered a flake.
 If -pass or -fail regular expressions are provided, they override
 pass/fail exit status checking.
 
-The -max-* flags cause the stress tool to exit after some number of
-passes, failures, or total runs. This is useful for bisecting a known
-flaky failure.
+With -artifacts, each run executes in its own directory under the
+output directory, and -pass and -fail are also matched against files
+the command writes there that match the given glob pattern. The file
+and line of the match are reported and appended to the log, and the
+directories of failed runs are kept next to their logs with a ".d"
+suffix.
 
-Command output is written to the directory specified by -o. Failures
-are logged to numbered files in this directory. Actively running
-commands log to ".run-NNNNNN" files and passes are logged to
-".pass-NNNNNN" files.
+To stress several commands at once, such as related tests, give each
+with -cmd or list them one per line in a file given with -cmds. The
+commands are split into arguments at white space. Runs are started
+round-robin across the commands and share the parallelism of -p.
+Failures, saved logs, and the manifest identify the command that
+ran, and stress reports statistics for each command when it exits.
+
+The -max-passes, -max-fails, -max-runs, and -max-total-runs flags
+cause the stress tool to exit after some number of passes, failures,
+or total runs. This is useful for bisecting a known flaky failure.
+"%[1]s bisect" automates this; see "%[1]s bisect -h".
+
+Command output is written to the directory specified by -o. Actively
+running commands log to ".run-NNNNNN" files in this directory. When a
+run finishes, its log is moved to a numbered file in the "pass" or
+"flake" subdirectory, or, for failures, in "fail/CLASS", where CLASS
+is a fingerprint of the first panic, test failure, or file:line
+message in the output, or "timeout". With -gzip, saved logs are
+compressed and have a ".gz" suffix. "MANIFEST.jsonl" in the output
+directory indexes every run with its outcome, failure class, timing,
+and log path, as one JSON object per line. A run whose log is later
+pruned appears again with "pruned" set; the last line for each run
+number is current.
+
+At startup, stress records the Go version, Go environment variables,
+kernel, CPU model, and CPU frequency governor to "env" in the output
+directory and writes them at the top of every saved failure, flake,
+and timeout log, so shared logs carry the context needed to
+interpret them. If the output directory has an "env" file from a
+previous run, stress reports any differences.
+
+The -env flag adds a KEY=VAL setting to the environment of each run,
+and -gotraceback level is shorthand for -env GOTRACEBACK=level. If a
+key is set more than once, the last setting wins. The settings are
+recorded in "env" and in saved log headers along with the values of
+the Go environment variables each run sees.
+
+The -max-logs and -max-output-bytes flags limit the saved logs by
+deleting the oldest logs first. The first log of each failure class
+and the first flake are never deleted. Pruned runs stay in the
+manifest.
 
 `, os.Args[0])
 		flag.PrintDefaults()
 	}
 
+	if len(os.Args) > 1 && os.Args[1] == "bisect" {
+		bisectMain(os.Args[2:])
+		return
+	}
+
 	var s Stress
-	flag.IntVar(&s.Parallelism, "p", runtime.NumCPU(), "run `N` processes in parallel")
-	flag.DurationVar(&s.Timeout, "timeout", 10*time.Minute, "timeout each process after `duration`")
-	defaultDir := filepath.Join(os.TempDir(), time.Now().Format("stress-20060102T150405"))
-	flag.StringVar(&s.OutDir, "o", defaultDir, "write command logs to `directory`")
+	s.addFlags(flag.CommandLine)
 	flag.Var(FlagLimit{&s.MaxRuns}, "max-runs", "exit after `N` passes+fails (but not flakes/timeouts)")
 	flag.Var(FlagLimit{&s.MaxTotalRuns}, "max-total-runs", "exit after `N` runs with any outcome")
 	flag.Var(FlagLimit{&s.MaxPasses}, "max-passes", "exit after `N` successful runs")
 	flag.Var(FlagLimit{&s.MaxFails}, "max-fails", "exit after `N` failed runs")
-	flag.BoolVar(&s.TimeoutsFail, "timeouts-fail", false, "consider timeouts to be failures")
-	// TODO: Flag to keep timed-out subprocesses around for
-	// inspection.
-	flag.Var(FlagRegexp{&s.FailRe}, "fail", "fail only if output matches `regexp`")
-	flag.Var(FlagRegexp{&s.PassRe}, "pass", "pass only if output matches `regexp`")
+	flag.Var(FlagCommand{&s.Commands}, "cmd", "also run `command` (may be repeated)")
+	flagCmds := flag.String("cmds", "", "also run the commands listed one per line in `file`")
 	flag.Parse()
-	s.Command = flag.Args()
-	if s.Parallelism <= 0 || s.Timeout <= 0 || len(s.Command) == 0 {
+	if *flagCmds != "" {
+		cmds, err := readCommands(*flagCmds)
+		if err != nil {
+			log.Fatal(err)
+		}
+		s.Commands = append(s.Commands, cmds...)
+	}
+	if len(s.Commands) == 0 {
+		s.Command = flag.Args()
+	} else if flag.NArg() > 0 {
+		s.Commands = append(s.Commands, flag.Args())
+	}
+	if s.Parallelism <= 0 || s.Timeout <= 0 || len(s.commands()[0]) == 0 {
 		flag.Usage()
 		os.Exit(1)
 	}
+	s.setup()
 
+	// Run the stress test.
+	result := s.Run(NewStdoutReporter())
+
+	switch result {
+	case ResultPass:
+		os.Exit(0)
+	case ResultFail:
+		os.Exit(1)
+	case ResultFlake:
+		os.Exit(125)
+	}
+}
+
+// addFlags registers the flags that configure how s runs its command
+// on fs. These are shared by stress and stress bisect.
+func (s *Stress) addFlags(fs *flag.FlagSet) {
+	fs.IntVar(&s.Parallelism, "p", runtime.NumCPU(), "run `N` processes in parallel")
+	fs.DurationVar(&s.Ramp, "ramp", 0, "ramp up from 1 to -p processes over `duration` and report failure rates at each level")
+	fs.DurationVar(&s.Timeout, "timeout", 10*time.Minute, "timeout each process after `duration`")
+	defaultDir := filepath.Join(os.TempDir(), time.Now().Format("stress-20060102T150405"))
+	fs.StringVar(&s.OutDir, "o", defaultDir, "write command logs to `directory`")
+	fs.Var(FlagLimit{&s.MaxLogs}, "max-logs", "keep at most `N` saved logs, deleting the oldest first")
+	fs.Int64Var(&s.MaxOutputBytes, "max-output-bytes", 0, "keep at most `bytes` of saved logs, deleting the oldest first (0 means no limit)")
+	fs.BoolVar(&s.Gzip, "gzip", false, "compress saved logs")
+	fs.BoolVar(&s.TimeoutsFail, "timeouts-fail", false, "consider timeouts to be failures")
+	// TODO: Flag to keep timed-out subprocesses around for
+	// inspection.
+	fs.Var(FlagRegexp{&s.FailRe}, "fail", "fail only if output matches `regexp`")
+	fs.Var(FlagRegexp{&s.PassRe}, "pass", "pass only if output matches `regexp`")
+	fs.Var(FlagEnv{x: &s.SetEnv}, "env", "set `KEY=VAL` in the environment of each run (may be repeated)")
+	fs.Var(FlagEnv{x: &s.SetEnv, key: "GOTRACEBACK"}, "gotraceback", "set GOTRACEBACK to `level` in each run (for example, all or crash)")
+	fs.Var(FlagList{&s.Artifacts}, "artifacts", "also match -pass and -fail against files matching `glob` in each run's directory (may be repeated)")
+}
+
+// setup creates s's output directory, snapshots the environment, and
+// traps signals to set s.Interrupt.
+func (s *Stress) setup() {
 	// Ensure the output directory exists.
 	err := os.MkdirAll(s.OutDir, 0777)
 	if err != nil {
@@ -74,6 +166,21 @@ commands log to ".run-NNNNNN" files and passes are logged to
 	}
 	fmt.Printf("output to: %s\n", s.OutDir)
 
+	// Snapshot the environment.
+	s.Env = takeEnvSnapshot(s.Command, s.SetEnv)
+	envPath := filepath.Join(s.OutDir, "env")
+	if old, err := ioutil.ReadFile(envPath); err == nil {
+		if diffs := s.Env.diff(parseEnvSnapshot(string(old))); len(diffs) > 0 {
+			fmt.Printf("environment differs from previous run in %s:\n", s.OutDir)
+			for _, d := range diffs {
+				fmt.Printf("\t%s\n", d)
+			}
+		}
+	}
+	if err := ioutil.WriteFile(envPath, []byte(s.Env.String()), 0666); err != nil {
+		log.Fatal(err)
+	}
+
 	// Trap signals and shut down cleanly.
 	//
 	// It's important we at least trap the signals that would
@@ -81,7 +188,7 @@ commands log to ".run-NNNNNN" files and passes are logged to
 	// processes in their own process group.
 	interrupt := make(chan struct{})
 	s.Interrupt = interrupt
-	sig := make(chan os.Signal)
+	sig := make(chan os.Signal, 1)
 	signal.Notify(sig, exitSignals...)
 	go func() {
 		<-sig
@@ -89,18 +196,6 @@ commands log to ".run-NNNNNN" files and passes are logged to
 		signal.Stop(sig)
 		close(interrupt)
 	}()
-
-	// Run the stress test.
-	result := s.Run(NewStdoutReporter())
-
-	switch result {
-	case ResultPass:
-		os.Exit(0)
-	case ResultFail:
-		os.Exit(1)
-	case ResultFlake:
-		os.Exit(125)
-	}
 }
 
 type FlagLimit struct {
@@ -161,3 +256,46 @@ func (f FlagRegexp) Set(x string) error {
 	*f.x = re
 	return nil
 }
+
+type FlagList struct {
+	x *[]string
+}
+
+func (f FlagList) String() string {
+	if f.x == nil {
+		return ""
+	}
+	return strings.Join(*f.x, ",")
+}
+
+func (f FlagList) Set(x string) error {
+	if _, err := filepath.Match(x, ""); err != nil {
+		return err
+	}
+	*f.x = append(*f.x, x)
+	return nil
+}
+
+// FlagEnv is a flag.Value that collects KEY=VAL environment settings.
+// If key is set, the flag's value is the value of that key.
+type FlagEnv struct {
+	x   *[]string
+	key string
+}
+
+func (f FlagEnv) String() string {
+	if f.x == nil {
+		return ""
+	}
+	return strings.Join(*f.x, " ")
+}
+
+func (f FlagEnv) Set(x string) error {
+	if f.key != "" {
+		x = f.key + "=" + x
+	} else if i := strings.Index(x, "="); i <= 0 {
+		return fmt.Errorf("want KEY=VAL")
+	}
+	*f.x = append(*f.x, x)
+	return nil
+}
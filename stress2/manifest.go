@@ -0,0 +1,201 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// The output directory is organized into subdirectories by outcome.
+// Failures are further divided by failure class, so each class of
+// failure can be examined on its own.
+const (
+	passDir  = "pass"
+	failDir  = "fail"
+	flakeDir = "flake"
+
+	// timeoutClass is the failure class of runs that timed out.
+	timeoutClass = "timeout"
+
+	manifestName = "MANIFEST.jsonl"
+)
+
+var (
+	// signatureRes match lines that identify a failure, in
+	// priority order.
+	signatureRes = []*regexp.Regexp{
+		regexp.MustCompile(`(?m)^(?:panic|fatal error|runtime: .*): .*$`),
+		regexp.MustCompile(`(?m)^--- FAIL: \S+`),
+		regexp.MustCompile(`(?m)^\s*\S+\.go:\d+: .*$`),
+	}
+
+	// canonRe matches hexadecimal and decimal numbers, which
+	// vary between runs of the same failure.
+	canonRe = regexp.MustCompile(`0x[0-9a-fA-F]+|[0-9]+`)
+)
+
+// failureSignature returns a line of output that summarizes why a run
+// failed, with numbers replaced by "N" so it's the same across runs
+// that failed the same way. If there's no recognizable failure line,
+// it uses the last line of output.
+func failureSignature(output []byte) string {
+	var line string
+	for _, re := range signatureRes {
+		if m := re.Find(output); m != nil {
+			line = string(m)
+			break
+		}
+	}
+	if line == "" {
+		lines := strings.Split(strings.TrimRight(string(output), "\n"), "\n")
+		line = lines[len(lines)-1]
+	}
+	return canonRe.ReplaceAllString(strings.TrimSpace(line), "N")
+}
+
+// classFingerprint returns a short, directory-safe name for the
+// failure class with the given signature.
+func classFingerprint(sig string) string {
+	h := sha256.Sum256([]byte(sig))
+	return fmt.Sprintf("%x", h[:4])
+}
+
+// A manifest indexes every run saved to an output directory. It is
+// stored as MANIFEST.jsonl in the output directory, with one JSON
+// manifestEntry per line. The file is only ever appended to, so
+// updating it costs the same however many runs it records. When a
+// run's entry changes, such as when its log is pruned, the whole
+// entry is appended again, and later entries for a run supersede
+// earlier ones.
+type manifest struct {
+	path    string
+	runs    int
+	byLog   map[string]*manifestEntry
+	pending bytes.Buffer
+}
+
+type manifestEntry struct {
+	Run       int       `json:"run"`
+	Outcome   string    `json:"outcome"`           // pass, fail, flake, or timeout
+	Command   string    `json:"command,omitempty"` // Set if there are multiple commands
+	Class     string    `json:"class,omitempty"`
+	Signature string    `json:"signature,omitempty"`
+	Start     time.Time `json:"start"`
+	Seconds   float64   `json:"seconds"`
+	Log       string    `json:"log"` // Relative to the output directory
+	Artifacts string    `json:"artifacts,omitempty"`
+	Match     string    `json:"match,omitempty"` // file:line of -pass or -fail match
+	Pruned    bool      `json:"pruned,omitempty"`
+}
+
+// openManifest reads the manifest in outDir, if there is one, so runs
+// added to an existing output directory are numbered after it.
+func openManifest(outDir string) (*manifest, error) {
+	m := &manifest{path: filepath.Join(outDir, manifestName), byLog: make(map[string]*manifestEntry)}
+	data, err := ioutil.ReadFile(m.path)
+	if os.IsNotExist(err) {
+		return m, nil
+	} else if err != nil {
+		return nil, err
+	}
+	// A write that was interrupted leaves a partial last line.
+	// Drop it so new entries start on a line of their own.
+	if n := bytes.LastIndexByte(data, '\n') + 1; n < len(data) {
+		data = data[:n]
+		if err := os.Truncate(m.path, int64(n)); err != nil {
+			return nil, err
+		}
+	}
+	entries, err := parseManifest(data)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", m.path, err)
+	}
+	for _, e := range entries {
+		if e.Run >= m.runs {
+			m.runs = e.Run + 1
+		}
+		m.byLog[e.Log] = e
+	}
+	return m, nil
+}
+
+// parseManifest returns the runs recorded in manifest data, in run
+// order, with each run's latest entry.
+func parseManifest(data []byte) ([]*manifestEntry, error) {
+	byRun := make(map[int]*manifestEntry)
+	for i, line := range bytes.Split(data, []byte("\n")) {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		e := new(manifestEntry)
+		if err := json.Unmarshal(line, e); err != nil {
+			return nil, fmt.Errorf("line %d: %w", i+1, err)
+		}
+		byRun[e.Run] = e
+	}
+	entries := make([]*manifestEntry, 0, len(byRun))
+	for _, e := range byRun {
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Run < entries[j].Run })
+	return entries, nil
+}
+
+// add records a run. It assigns e.Run.
+func (m *manifest) add(e *manifestEntry) {
+	e.Run = m.runs
+	m.runs++
+	m.byLog[e.Log] = e
+	m.append(e)
+}
+
+// pruned marks the run with the given log path, relative to the
+// output directory, as pruned.
+func (m *manifest) pruned(log string) {
+	if e := m.byLog[log]; e != nil && !e.Pruned {
+		e.Pruned = true
+		m.append(e)
+	}
+}
+
+func (m *manifest) append(e *manifestEntry) {
+	data, err := json.Marshal(e)
+	if err != nil {
+		panic(err) // manifestEntry always marshals
+	}
+	m.pending.Write(data)
+	m.pending.WriteByte('\n')
+}
+
+// flush appends the entries recorded since the last flush to the
+// manifest file.
+func (m *manifest) flush() error {
+	if m.pending.Len() == 0 {
+		return nil
+	}
+	f, err := os.OpenFile(m.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0666)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(m.pending.Bytes())
+	if err1 := f.Close(); err == nil {
+		err = err1
+	}
+	if err != nil {
+		return err
+	}
+	m.pending.Reset()
+	return nil
+}
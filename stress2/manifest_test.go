@@ -0,0 +1,104 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFailureSignature(t *testing.T) {
+	check := func(output, want string) {
+		t.Helper()
+		if got := failureSignature([]byte(output)); got != want {
+			t.Errorf("failureSignature(%q) = %q, want %q", output, got, want)
+		}
+	}
+	check("ok\npanic: bad thing at 0xc000123456\n\ngoroutine 7 [running]:\n", "panic: bad thing at N")
+	check("=== RUN TestX\n--- FAIL: TestX (0.12s)\n    x_test.go:12: got 3\nFAIL\n", "--- FAIL: TestX")
+	check("x_test.go:12: got 3\n", "x_test.go:N: got N")
+	check("some output\nexited: status 2\n", "exited: status N")
+
+	// Runs that fail the same way have the same class.
+	a := failureSignature([]byte("fatal error: concurrent map writes\n\ngoroutine 12 [running]:\n"))
+	b := failureSignature([]byte("fatal error: concurrent map writes\n\ngoroutine 40 [running]:\n"))
+	if classFingerprint(a) != classFingerprint(b) {
+		t.Errorf("different classes for %q and %q", a, b)
+	}
+}
+
+func TestManifest(t *testing.T) {
+	dir := t.TempDir()
+	m, err := openManifest(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.add(&manifestEntry{Outcome: "pass", Log: "pass/log.000000"})
+	m.add(&manifestEntry{Outcome: "fail", Class: "abcd1234", Log: "fail/abcd1234/log.000000"})
+	if err := m.flush(); err != nil {
+		t.Fatal(err)
+	}
+	m.pruned("pass/log.000000")
+	m.pruned("pass/log.000000")
+	if err := m.flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Each update appends one line.
+	path := filepath.Join(dir, manifestName)
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(string(data), "\n"); n != 3 {
+		t.Errorf("manifest has %d lines, want 3:\n%s", n, data)
+	}
+
+	// Simulate an interrupted write. Reopening drops the partial
+	// line and numbers new runs after the existing ones.
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"run":2,"outc`)
+	f.Close()
+	m, err = openManifest(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.add(&manifestEntry{Outcome: "flake", Log: "flake/log.000000"})
+	if err := m.flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err = ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries, err := parseManifest(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, e := range entries {
+		s := fmt.Sprintf("%d %s %s", e.Run, e.Outcome, e.Log)
+		if e.Pruned {
+			s += " pruned"
+		}
+		got = append(got, s)
+	}
+	want := []string{
+		"0 pass pass/log.000000 pruned",
+		"1 fail fail/abcd1234/log.000000",
+		"2 flake flake/log.000000",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got runs\n\t%s\nwant\n\t%s", strings.Join(got, "\n\t"), strings.Join(want, "\n\t"))
+	}
+}
@@ -0,0 +1,108 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+)
+
+// A logPruner tracks saved logs and deletes the oldest ones to keep
+// the output directory within size and count limits.
+type logPruner struct {
+	maxBytes int64 // If 0, no limit
+	maxLogs  int   // If 0, no limit
+
+	// logs is the queue of prunable logs, oldest first.
+	logs []savedLog
+
+	// nBytes and nLogs are the totals of all saved logs,
+	// including protected logs.
+	nBytes int64
+	nLogs  int
+
+	// remove deletes a log file. It is os.Remove except in tests.
+	remove func(path string) error
+}
+
+type savedLog struct {
+	path string
+	size int64
+}
+
+func newLogPruner(maxBytes int64, maxLogs int) *logPruner {
+	return &logPruner{maxBytes: maxBytes, maxLogs: maxLogs, remove: os.Remove}
+}
+
+// add records a saved log of the given size. If protect is true, the
+// log counts toward the limits but is never pruned.
+func (p *logPruner) add(path string, size int64, protect bool) {
+	p.nBytes += size
+	p.nLogs++
+	if !protect {
+		p.logs = append(p.logs, savedLog{path, size})
+	}
+}
+
+// prune deletes the oldest unprotected logs until the saved logs are
+// within the limits or there's nothing left to delete. It returns the
+// first error encountered.
+func (p *logPruner) prune() error {
+	var firstErr error
+	for len(p.logs) > 0 && p.over() {
+		l := p.logs[0]
+		p.logs = p.logs[1:]
+		if err := p.remove(l.path); err != nil && !os.IsNotExist(err) && firstErr == nil {
+			firstErr = err
+		}
+		p.nBytes -= l.size
+		p.nLogs--
+	}
+	return firstErr
+}
+
+func (p *logPruner) over() bool {
+	return (p.maxBytes > 0 && p.nBytes > p.maxBytes) || (p.maxLogs > 0 && p.nLogs > p.maxLogs)
+}
+
+// copyLog writes header followed by the contents of src to dst,
+// which must not already exist. If compress is true, dst is
+// gzip-compressed.
+func copyLog(src, dst string, header []byte, compress bool) (err error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0666)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			out.Close()
+			os.Remove(dst)
+		}
+	}()
+	var w io.Writer = out
+	var zw *gzip.Writer
+	if compress {
+		zw = gzip.NewWriter(out)
+		w = zw
+	}
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	if _, err := io.Copy(w, in); err != nil {
+		return err
+	}
+	if zw != nil {
+		if err := zw.Close(); err != nil {
+			return err
+		}
+	}
+	return out.Close()
+}
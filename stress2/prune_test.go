@@ -0,0 +1,42 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLogPruner(t *testing.T) {
+	var removed []string
+	p := newLogPruner(25, 3)
+	p.remove = func(path string) error {
+		removed = append(removed, path)
+		return nil
+	}
+	check := func(want ...string) {
+		t.Helper()
+		if err := p.prune(); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(removed, want) {
+			t.Errorf("removed %v, want %v", removed, want)
+		}
+	}
+
+	p.add("fail", 10, true)
+	p.add("pass1", 5, false)
+	p.add("pass2", 5, false)
+	check()
+	// Exceed the count limit.
+	p.add("pass3", 5, false)
+	check("pass1")
+	// Exceed the byte limit. The protected log stays.
+	p.add("pass4", 12, false)
+	check("pass1", "pass2", "pass3")
+	if p.nLogs != 2 || p.nBytes != 22 {
+		t.Errorf("got %d logs, %d bytes; want 2 logs, 22 bytes", p.nLogs, p.nBytes)
+	}
+}
@@ -12,7 +12,10 @@ import (
 	"log"
 	"os"
 	"path"
+	"path/filepath"
 	"regexp"
+	"sort"
+	"strings"
 	"sync"
 	"syscall"
 	"time"
@@ -21,11 +24,21 @@ import (
 
 // A Stress stress tests a command.
 type Stress struct {
-	Command     []string
+	Command []string
+
+	// Commands, if set, are run instead of Command. Runs are
+	// started round-robin across them.
+	Commands [][]string
+
 	Parallelism int
+	Ramp        time.Duration // If non-zero, ramp up to Parallelism over this duration
 	Timeout     time.Duration
 	OutDir      string
 
+	MaxOutputBytes int64 // If 0, no limit on saved log bytes
+	MaxLogs        int   // If 0, no limit on saved logs
+	Gzip           bool  // Compress saved logs
+
 	MaxPasses    int // If 0, no limit
 	MaxFails     int
 	MaxRuns      int // Limit on passes+fails (but not flakes)
@@ -36,16 +49,37 @@ type Stress struct {
 	FailRe *regexp.Regexp
 	PassRe *regexp.Regexp
 
+	// Artifacts are glob patterns of files to match against
+	// FailRe and PassRe in addition to the command output. If
+	// set, each run executes in its own directory under OutDir
+	// and the patterns are relative to that directory.
+	Artifacts []string
+
+	// SetEnv lists KEY=VAL settings to add to the environment of
+	// each run. Later settings of the same key take precedence.
+	SetEnv []string
+
+	// Env is a snapshot of the environment to write at the top
+	// of every saved failure, flake, and timeout log.
+	Env envSnapshot
+
 	Interrupt <-chan struct{}
+
+	// Counts is set by Run to the number of runs with each
+	// outcome.
+	Counts map[ResultKind]int
 }
 
 type startRun struct {
-	id int64
+	id  int64
+	cmd int // Index into Stress.commands
 }
 
 type result struct {
 	id     int64
+	cmd    int
 	output *os.File
+	dir    string           // Run directory, or "" if none
 	status *os.ProcessState // nil on timeout
 	err    error            // If non-nil, error starting command
 }
@@ -59,19 +93,36 @@ const (
 	ResultTimeout
 )
 
-func (s *Stress) resultKind(res result, output []byte) ResultKind {
-	switch {
-	case res.status == nil && !s.TimeoutsFail:
-		return ResultTimeout
-	case s.PassRe == nil && res.status != nil && res.status.Success(),
-		s.PassRe != nil && s.PassRe.Match(output):
-		return ResultPass
-	case s.FailRe == nil && (res.status == nil || res.status.ExitCode() != 125),
-		s.FailRe != nil && s.FailRe.Match(output):
-		return ResultFail
-	default:
-		return ResultFlake
+// resultKind classifies a run. If the classification came from
+// matching PassRe or FailRe, it also returns the "file:line" of the
+// match.
+func (s *Stress) resultKind(res result, output []byte, artifacts []artifact) (ResultKind, string) {
+	if res.status == nil && !s.TimeoutsFail {
+		return ResultTimeout, ""
+	}
+	if s.PassRe == nil {
+		if res.status != nil && res.status.Success() {
+			return ResultPass, ""
+		}
+	} else if where, ok := matchWhere(s.PassRe, output, artifacts); ok {
+		return ResultPass, where
+	}
+	if s.FailRe == nil {
+		if res.status == nil || res.status.ExitCode() != 125 {
+			return ResultFail, ""
+		}
+	} else if where, ok := matchWhere(s.FailRe, output, artifacts); ok {
+		return ResultFail, where
 	}
+	return ResultFlake, ""
+}
+
+// commands returns the commands s runs.
+func (s *Stress) commands() [][]string {
+	if len(s.Commands) > 0 {
+		return s.Commands
+	}
+	return [][]string{s.Command}
 }
 
 func (s *Stress) Run(reporter StressReporter) ResultKind {
@@ -90,11 +141,45 @@ func (s *Stress) Run(reporter StressReporter) ResultKind {
 	var id int64
 	activeStartTimes := make(map[int64]time.Time)
 
+	// When ramping, parallelism increases linearly from 1 to
+	// s.Parallelism over s.Ramp. We record the parallelism each
+	// run started at so we can report failure rates by level.
+	rampStart := time.Now()
+	parallelism := func() int {
+		elapsed := time.Since(rampStart)
+		if s.Ramp <= 0 || elapsed >= s.Ramp {
+			return s.Parallelism
+		}
+		return 1 + int(int64(s.Parallelism-1)*int64(elapsed)/int64(s.Ramp))
+	}
+	var rampTick <-chan time.Time
+	if s.Ramp > 0 && s.Parallelism > 1 {
+		period := s.Ramp / time.Duration(s.Parallelism-1)
+		if period < time.Second/10 {
+			period = time.Second / 10
+		}
+		ticker := time.NewTicker(period)
+		defer ticker.Stop()
+		rampTick = ticker.C
+	}
+	runLevels := make(map[int64]int)
+	levelCounts := make(map[int]map[ResultKind]int)
+
 	reporter.StartStatus()
 
 	// TODO: Do a smoke test. Start just one task and if it fails
 	// within a second, go into rate-limited starting mode.
 
+	cmds := s.commands()
+	startRuns := func() {
+		level := parallelism()
+		for len(activeStartTimes) < level {
+			start <- startRun{id, int(id % int64(len(cmds)))}
+			activeStartTimes[id] = time.Now()
+			runLevels[id] = level
+			id++
+		}
+	}
 	var wg sync.WaitGroup
 	for i := 0; i < s.Parallelism; i++ {
 		wg.Add(1)
@@ -102,17 +187,42 @@ func (s *Stress) Run(reporter StressReporter) ResultKind {
 			defer wg.Done()
 			s.runner(start, stop, results)
 		}()
-		start <- startRun{id}
-		activeStartTimes[id] = time.Now()
-		id++
 	}
+	startRuns()
 
 	// TODO: Rate limit restarts after failures.
 
 	fatal := false
 	totalRuns := 0
 	counts := make(map[ResultKind]int)
-	logIdxPass, logIdxFail, logIdxFlake := 0, 0, 0
+	cmdCounts := make([]map[ResultKind]int, len(cmds))
+	for i := range cmdCounts {
+		cmdCounts[i] = make(map[ResultKind]int)
+	}
+	logIdxPass, logIdxFlake := 0, 0
+	logIdxFail := make(map[string]*int) // By failure class
+	man, err := openManifest(s.OutDir)
+	if err != nil {
+		log.Printf("error opening manifest: %s", err)
+		fatal = true
+	}
+	pruner := newLogPruner(s.MaxOutputBytes, s.MaxLogs)
+	if len(s.Artifacts) > 0 {
+		pruner.remove = func(path string) error {
+			os.RemoveAll(artifactDir(path))
+			return os.Remove(path)
+		}
+	}
+	if man != nil {
+		remove := pruner.remove
+		pruner.remove = func(path string) error {
+			if rel, err := filepath.Rel(s.OutDir, path); err == nil {
+				man.pruned(rel)
+			}
+			return remove(path)
+		}
+	}
+	savedIn := make(map[string]int) // Number of logs saved in each directory
 	var passFailTime time.Duration
 	updateStatus := func() {
 		// TODO: ETA if we have s.Max*?
@@ -134,15 +244,24 @@ func (s *Stress) Run(reporter StressReporter) ResultKind {
 				oldest = t
 			}
 		}
+		if rampTick != nil {
+			fmt.Fprintf(buf, ", parallelism %d", len(activeStartTimes))
+		}
 		reporter.Status("%s, avg %s, max active %s", buf.String(), avg, TimeSince(oldest))
 	}
 loop:
-	for {
+	for !fatal {
 		updateStatus()
 
 		var res result
 		select {
 		case res = <-results:
+		case <-rampTick:
+			startRuns()
+			if len(activeStartTimes) >= s.Parallelism {
+				rampTick = nil
+			}
+			continue
 		case <-s.Interrupt:
 			break loop
 		}
@@ -173,41 +292,138 @@ loop:
 		}
 
 		// Classify the result.
-		kind := s.resultKind(res, output)
+		var artifacts []artifact
+		if res.dir != "" {
+			artifacts, err = readArtifacts(res.dir, s.Artifacts)
+			if err != nil {
+				log.Printf("error reading artifacts: %s", err)
+			}
+		}
+		kind, where := s.resultKind(res, output, artifacts)
+		// Non-pass logs are saved with an environment header,
+		// which shifts the lines of the output.
+		var header []byte
+		if kind != ResultPass && s.Env != nil {
+			if len(cmds) > 1 {
+				header = s.Env.withCommand(cmds[res.cmd]).header()
+			} else {
+				header = s.Env.header()
+			}
+			where = shiftOutputLine(where, header)
+		}
+		if where != "" {
+			flag := "-fail"
+			if kind == ResultPass {
+				flag = "-pass"
+			}
+			if err := appendLog(logPath, "%s regexp matched at %s\n", flag, where); err != nil {
+				log.Printf("error writing log file: %s", err)
+			}
+		}
 		totalRuns++
 		counts[kind]++
+		cmdCounts[res.cmd][kind]++
 
 		// Update time stats.
-		duration := time.Since(activeStartTimes[res.id])
+		startTime := activeStartTimes[res.id]
+		duration := time.Since(startTime)
 		delete(activeStartTimes, res.id)
+		level := runLevels[res.id]
+		delete(runLevels, res.id)
+		if levelCounts[level] == nil {
+			levelCounts[level] = make(map[ResultKind]int)
+		}
+		levelCounts[level][kind]++
 		if kind == ResultPass || kind == ResultFail {
 			passFailTime += duration
 		}
 
-		// Save log.
-		var prefix string
+		// Save log. Failures and timeouts are saved by
+		// failure class.
+		entry := &manifestEntry{Start: startTime, Seconds: duration.Seconds(), Match: where}
+		if len(cmds) > 1 {
+			entry.Command = strings.Join(cmds[res.cmd], " ")
+		}
+		var dir string
 		var logIdx *int
 		switch kind {
 		default:
 			panic("bad kind")
 		case ResultPass:
-			prefix, logIdx = ".pass-", &logIdxPass
+			dir, logIdx = passDir, &logIdxPass
+			entry.Outcome = "pass"
 		case ResultFail, ResultTimeout:
-			prefix, logIdx = "", &logIdxFail
+			entry.Outcome = "fail"
+			entry.Class = timeoutClass
+			if kind == ResultTimeout {
+				entry.Outcome = "timeout"
+			} else if res.status != nil {
+				entry.Signature = failureSignature(output)
+				entry.Class = classFingerprint(entry.Signature)
+			}
+			dir = filepath.Join(failDir, entry.Class)
+			if logIdxFail[entry.Class] == nil {
+				logIdxFail[entry.Class] = new(int)
+			}
+			logIdx = logIdxFail[entry.Class]
 		case ResultFlake:
-			prefix, logIdx = "flake-", &logIdxFlake
+			dir, logIdx = flakeDir, &logIdxFlake
+			entry.Outcome = "flake"
 		}
-		path, err := saveLog(s.OutDir, prefix, logIdx, logPath)
+		path, err := saveLog(filepath.Join(s.OutDir, dir), logIdx, logPath, header, s.Gzip)
 		if err != nil {
 			log.Printf("error saving log: %s", err)
 			fatal = true
 			break
 		}
 
+		// Keep the artifacts of non-pass runs alongside the
+		// log.
+		if res.dir != "" {
+			if kind == ResultPass {
+				os.RemoveAll(res.dir)
+			} else if err := os.Rename(res.dir, artifactDir(path)); err != nil {
+				log.Printf("error saving artifacts: %s", err)
+			} else {
+				entry.Artifacts = filepath.Join(dir, filepath.Base(artifactDir(path)))
+			}
+		}
+		entry.Log = filepath.Join(dir, filepath.Base(path))
+		if man != nil {
+			man.add(entry)
+		}
+
+		// Prune old logs, but always keep the first log of
+		// each non-pass outcome and failure class.
+		savedIn[dir]++
+		if fi, err := os.Stat(path); err == nil {
+			pruner.add(path, fi.Size(), kind != ResultPass && savedIn[dir] == 1)
+		}
+		if err := pruner.prune(); err != nil {
+			log.Printf("error pruning logs: %s", err)
+		}
+		if man != nil {
+			if err := man.flush(); err != nil {
+				log.Printf("error writing manifest: %s", err)
+			}
+		}
+
 		// Show failures.
 		if kind != ResultPass {
 			printTail(reporter, output)
+			if len(cmds) > 1 {
+				fmt.Fprintf(reporter, "command %d: %s\n", res.cmd, entry.Command)
+			}
+			if entry.Signature != "" {
+				fmt.Fprintf(reporter, "failure class %s: %s\n", entry.Class, entry.Signature)
+			}
+			if where != "" {
+				fmt.Fprintf(reporter, "matched at %s\n", where)
+			}
 			fmt.Fprintf(reporter, "full output written to %s\n", path)
+			if res.dir != "" {
+				fmt.Fprintf(reporter, "artifacts written to %s\n", artifactDir(path))
+			}
 		}
 
 		// Check if we're done.
@@ -218,13 +434,24 @@ loop:
 			break
 		}
 
-		// Start another process.
-		start <- startRun{id}
-		activeStartTimes[id] = time.Now()
-		id++
+		// Start more processes.
+		startRuns()
 	}
 	updateStatus()
 	reporter.StopStatus()
+	s.Counts = counts
+	if man != nil {
+		if err := man.flush(); err != nil {
+			log.Printf("error writing manifest: %s", err)
+		}
+	}
+
+	if s.Ramp > 0 {
+		printLevelCounts(reporter, levelCounts)
+	}
+	if len(cmds) > 1 {
+		printCommandCounts(reporter, cmds, cmdCounts)
+	}
 
 	// Shut down runners. This will kill the subprocesses.
 	fmt.Fprintf(reporter, "stopping processes...\n")
@@ -250,6 +477,25 @@ loop:
 	}
 }
 
+// printLevelCounts reports the pass/fail counts and failure rate of
+// runs at each parallelism level.
+func printLevelCounts(w io.Writer, levelCounts map[int]map[ResultKind]int) {
+	levels := make([]int, 0, len(levelCounts))
+	for level := range levelCounts {
+		levels = append(levels, level)
+	}
+	sort.Ints(levels)
+	for _, level := range levels {
+		counts := levelCounts[level]
+		pass, fail := counts[ResultPass], counts[ResultFail]
+		rate := "?"
+		if pass+fail > 0 {
+			rate = fmt.Sprintf("%.1f%%", 100*float64(fail)/float64(pass+fail))
+		}
+		fmt.Fprintf(w, "parallelism %d: %d passes, %d fails (%s failure rate)\n", level, pass, fail, rate)
+	}
+}
+
 func (s *Stress) runner(start <-chan startRun, stop <-chan struct{}, results chan<- result) {
 	for tok := range start {
 		if !s.run1(tok, stop, results) {
@@ -264,22 +510,36 @@ func (s *Stress) run1(tok startRun, stop <-chan struct{}, results chan<- result)
 	name := path.Join(s.OutDir, fmt.Sprintf(".run-%06d", tok.id))
 	f, err := os.Create(name)
 	if err != nil {
-		results <- result{tok.id, nil, nil, err}
+		results <- result{id: tok.id, cmd: tok.cmd, err: err}
 		return true
 	}
+	// Create a directory for the run to write artifacts to.
+	var dir string
+	if len(s.Artifacts) > 0 {
+		dir = name + ".d"
+		if err := os.Mkdir(dir, 0777); err != nil {
+			f.Close()
+			os.Remove(name)
+			results <- result{id: tok.id, cmd: tok.cmd, err: err}
+			return true
+		}
+	}
 	deleteFile := true
 	defer func() {
 		if deleteFile {
 			f.Close()
 			os.Remove(name)
+			if dir != "" {
+				os.RemoveAll(dir)
+			}
 		}
 	}()
 
 	// Start command.
-	cmd, err := StartCommand(s.Command, f)
+	cmd, err := StartCommand(s.commands()[tok.cmd], dir, s.SetEnv, f)
 	if err != nil {
 		// TODO(test): Run command that doesn't exist.
-		results <- result{id: tok.id, err: err}
+		results <- result{id: tok.id, cmd: tok.cmd, err: err}
 		return true
 	}
 
@@ -296,25 +556,39 @@ func (s *Stress) run1(tok startRun, stop <-chan struct{}, results chan<- result)
 		<-cmd.Done()
 		fmt.Fprintf(f, "timeout after %s\n", s.Timeout)
 		deleteFile = false
-		results <- result{id: tok.id, output: f}
+		results <- result{id: tok.id, cmd: tok.cmd, output: f, dir: dir}
 
 	case <-cmd.Done():
 		if !cmd.Status.Success() {
 			fmt.Fprintf(f, "exited: %s\n", formatProcessState(cmd.Status))
 		}
 		deleteFile = false
-		results <- result{id: tok.id, output: f, status: cmd.Status}
+		results <- result{id: tok.id, cmd: tok.cmd, output: f, dir: dir, status: cmd.Status}
 	}
 	timeout.Stop()
 	return true
 }
 
-func saveLog(outDir, prefix string, idx *int, oldName string) (string, error) {
+// saveLog moves the log in oldName to the next free numbered log
+// name in dir, creating dir if necessary. If header is non-nil, it is
+// written at the beginning of the saved log.
+func saveLog(dir string, idx *int, oldName string, header []byte, compress bool) (string, error) {
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return "", err
+	}
 	var name string
 	for {
-		name = path.Join(outDir, fmt.Sprintf("%s%06d", prefix, *idx))
+		name = path.Join(dir, fmt.Sprintf("%06d", *idx))
 		*idx++
-		err := os.Link(oldName, name)
+		var err error
+		if compress {
+			name += ".gz"
+		}
+		if compress || header != nil {
+			err = copyLog(oldName, name, header, compress)
+		} else {
+			err = os.Link(oldName, name)
+		}
 		if err == nil {
 			// Found a name.
 			break